@@ -0,0 +1,344 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"uptime-monitor/logger"
+	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
+	"uptime-monitor/storage"
+
+	beeconfig "github.com/astaxie/beego/config"
+)
+
+// EnvPrefix starts the name of the environment variable that overrides a
+// setting, e.g. UPTIME_DATA_DIR for data_dir
+const EnvPrefix = "UPTIME_"
+
+// envNames holds the environment variables of settings whose app.conf key,
+// named by beego, isn't snake case
+var envNames = map[string]string{
+	"httpaddr": EnvPrefix + "HTTP_ADDR",
+	"httpport": EnvPrefix + "HTTP_PORT",
+}
+
+// namespacePattern matches a valid namespace name, which doubles as the name
+// of the namespace's directory under data_dir, or agent ID
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Config holds the application settings. The defaults match a stock app.conf.
+type Config struct {
+	HTTPAddr string
+	HTTPPort int
+
+	DataDir             string
+	StorageBackend      string
+	HistoryMaxEntries   int
+	HistoryRetention    time.Duration
+	CountUnknownAsDown  bool
+	CountDegradedAsDown bool
+	StatsCacheTTL       time.Duration
+	CompressHistory     bool
+	Namespaces          []string
+	BackupInterval      time.Duration
+	BackupDir           string
+	BackupKeep          int
+
+	InternalDNSServer     string
+	MaxChecksPerHost      int
+	MaxConcurrentChecks   int
+	MaxStartupJitter      time.Duration
+	KeepLastStatus        bool
+	CertExpiryWarningDays int
+	FlapThreshold         int
+	FlapWindow            time.Duration
+	RecentResults         int
+	AgentID               string
+	DownQuorum            int
+	AgentKeys             map[string]string // Key each remote agent submits results with, by agent ID
+
+	SeedSamples bool
+	SamplesFile string
+
+	APIKey string
+
+	LogLevel  logger.Level
+	LogChecks string
+
+	Notification notification.NotificationConfig
+	Digest       notification.DigestConfig
+}
+
+// EnvName returns the environment variable that overrides key
+func EnvName(key string) string {
+	if name, ok := envNames[key]; ok {
+		return name
+	}
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// loader reads settings from the environment, then app.conf, and remembers
+// the first malformed value
+type loader struct {
+	conf beeconfig.Configer
+	err  error
+}
+
+// lookup returns the raw value of key. A set environment variable wins even
+// when empty, so e.g. UPTIME_API_KEY= can switch off a configured key.
+func (l *loader) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(EnvName(key)); ok {
+		return value, true
+	}
+	value := l.conf.String(key)
+	return value, value != ""
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	if value, ok := l.lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	value, ok := l.lookup(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(key, value, "a whole number")
+		return defaultValue
+	}
+	return n
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, ok := l.lookup(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(key, value, "true or false")
+		return defaultValue
+	}
+	return b
+}
+
+// digest reads the schedule and recipients of the uptime digest email
+func (l *loader) digest() notification.DigestConfig {
+	digest := notification.DigestConfig{Weekday: time.Monday, Hour: 8, Location: time.Local}
+
+	schedule := strings.ToLower(strings.TrimSpace(l.string("digest_schedule", "off")))
+	switch schedule {
+	case "off", "":
+		digest.Schedule = notification.DigestOff
+	case notification.DigestDaily, notification.DigestWeekly:
+		digest.Schedule = schedule
+	default:
+		l.fail("digest_schedule", schedule, "one of off, daily, weekly")
+	}
+
+	sendTime := l.string("digest_time", "08:00")
+	if parsed, err := time.Parse("15:04", strings.TrimSpace(sendTime)); err != nil {
+		l.fail("digest_time", sendTime, "a time of day such as 08:00")
+	} else {
+		digest.Hour, digest.Minute = parsed.Hour(), parsed.Minute()
+	}
+
+	weekday := strings.ToLower(strings.TrimSpace(l.string("digest_weekday", "monday")))
+	found := false
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if weekday == strings.ToLower(day.String()) {
+			digest.Weekday, found = day, true
+		}
+	}
+	if !found {
+		l.fail("digest_weekday", weekday, "a day of the week such as monday")
+	}
+
+	timezone := l.string("digest_timezone", "Local")
+	if location, err := time.LoadLocation(strings.TrimSpace(timezone)); err != nil {
+		l.fail("digest_timezone", timezone, "a time zone such as UTC or Europe/Berlin")
+	} else {
+		digest.Location = location
+	}
+
+	for _, recipient := range strings.Split(l.string("digest_recipients", ""), ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			digest.Recipients = append(digest.Recipients, recipient)
+		}
+	}
+	if digest.Schedule != notification.DigestOff && len(digest.Recipients) == 0 {
+		l.fail("digest_recipients", "", "set when digest_schedule is daily or weekly")
+	}
+	return digest
+}
+
+// namespaces reads the names of the namespaces kept besides the default one
+func (l *loader) namespaces() []string {
+	value := l.string("namespaces", "")
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !namespacePattern.MatchString(name) {
+			l.fail("namespaces", value, "a comma-separated list of names made of letters, digits, - and _")
+			return nil
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// agentKeys reads the keys remote agents submit results with, given as
+// agent=key pairs separated by commas
+func (l *loader) agentKeys() map[string]string {
+	value := l.string("agent_keys", "")
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		agent, key, found := strings.Cut(pair, "=")
+		agent, key = strings.TrimSpace(agent), strings.TrimSpace(key)
+		if !found || !namespacePattern.MatchString(agent) || key == "" {
+			l.fail("agent_keys", value, "a comma-separated list of agent=key pairs, agents made of letters, digits, - and _")
+			return nil
+		}
+		keys[agent] = key
+	}
+	return keys
+}
+
+func (l *loader) fail(key, value, expected string) {
+	if l.err == nil {
+		l.err = fmt.Errorf("%s must be %s, got %q (set in app.conf or %s)", key, expected, value, EnvName(key))
+	}
+}
+
+// Load reads the settings from conf, usually beego.AppConfig, with every key
+// overridable by its environment variable
+func Load(conf beeconfig.Configer) (*Config, error) {
+	l := &loader{conf: conf}
+
+	logLevel := l.string("log_level", "info")
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		l.fail("log_level", logLevel, "one of debug, info, warn, error")
+	}
+
+	logChecks := strings.ToLower(strings.TrimSpace(l.string("log_checks", monitor.CheckLogAll)))
+	if logChecks != monitor.CheckLogAll && logChecks != monitor.CheckLogChanges {
+		l.fail("log_checks", logChecks, "one of all, changes")
+	}
+
+	cfg := &Config{
+		HTTPAddr: l.string("httpaddr", "0.0.0.0"),
+		HTTPPort: l.int("httpport", 8081),
+
+		DataDir:             l.string("data_dir", "./data"),
+		StorageBackend:      l.string("storage_backend", storage.BackendJSON),
+		HistoryMaxEntries:   l.int("history_max_entries", storage.DefaultMaxHistoryEntries),
+		HistoryRetention:    time.Duration(l.int("history_retention_days", 0)) * 24 * time.Hour,
+		CountUnknownAsDown:  l.bool("uptime_unknown_as_down", false),
+		CountDegradedAsDown: l.bool("uptime_degraded_as_down", false),
+		StatsCacheTTL:       time.Duration(l.int("stats_cache_seconds", 30)) * time.Second,
+		CompressHistory:     l.bool("compress_history", false),
+		Namespaces:          l.namespaces(),
+		BackupInterval:      time.Duration(l.int("backup_interval_hours", 0)) * time.Hour,
+		BackupDir:           l.string("backup_dir", "./backups"),
+		BackupKeep:          l.int("backup_keep", 7),
+
+		InternalDNSServer:     l.string("internal_dns_server", ""),
+		MaxChecksPerHost:      l.int("max_checks_per_host", monitor.DefaultMaxChecksPerHost),
+		MaxConcurrentChecks:   l.int("max_concurrent_checks", monitor.DefaultMaxConcurrentChecks),
+		MaxStartupJitter:      time.Duration(l.int("startup_jitter_seconds", int(monitor.DefaultMaxStartupJitter/time.Second))) * time.Second,
+		KeepLastStatus:        l.bool("keep_last_status", true),
+		CertExpiryWarningDays: l.int("cert_expiry_warning_days", 14),
+		FlapThreshold:         l.int("flap_threshold", monitor.DefaultFlapThreshold),
+		FlapWindow:            time.Duration(l.int("flap_window_seconds", int(monitor.DefaultFlapWindow/time.Second))) * time.Second,
+		RecentResults:         l.int("recent_results", monitor.DefaultRecentResults),
+		AgentID:               strings.TrimSpace(l.string("agent_id", "")),
+		DownQuorum:            l.int("down_quorum", monitor.DefaultDownQuorum),
+		AgentKeys:             l.agentKeys(),
+
+		SeedSamples: l.bool("seed_samples", false),
+		SamplesFile: l.string("samples_file", "conf/samples.json"),
+
+		APIKey: l.string("api_key", ""),
+
+		LogLevel:  level,
+		LogChecks: logChecks,
+
+		Notification: notification.NotificationConfig{
+			SMTPHost:     l.string("smtp_host", ""),
+			SMTPPort:     l.string("smtp_port", ""),
+			SMTPUsername: l.string("smtp_username", ""),
+			SMTPPassword: l.string("smtp_password", ""),
+			FromEmail:    l.string("from_email", ""),
+
+			SMTPReuseConnection:    l.bool("smtp_reuse_connection", true),
+			SMTPIdleTimeoutSeconds: l.int("smtp_idle_timeout_seconds", 60),
+			SMTPEncryption:         strings.ToLower(l.string("smtp_encryption", "")),
+			SMTPSkipVerify:         l.bool("smtp_skip_verify", false),
+			HTMLEmail:              l.bool("html_email", false),
+			DashboardURL:           l.string("dashboard_url", ""),
+
+			TelegramBotToken: l.string("telegram_bot_token", ""),
+
+			PagerDutyRoutingKey: l.string("pagerduty_routing_key", ""),
+			PagerDutyEventsURL:  l.string("pagerduty_events_url", notification.DefaultPagerDutyEventsURL),
+
+			Throttle: time.Duration(l.int("notification_throttle_seconds", int(notification.DefaultThrottle/time.Second))) * time.Second,
+
+			QueueSize:    l.int("notification_queue_size", notification.DefaultQueueSize),
+			QueueTimeout: time.Duration(l.int("notification_queue_timeout_ms", 0)) * time.Millisecond,
+		},
+
+		Digest: l.digest(),
+	}
+
+	if l.err != nil {
+		return nil, l.err
+	}
+	if cfg.HTTPPort <= 0 || cfg.HTTPPort > 65535 {
+		return nil, fmt.Errorf("httpport must be between 1 and 65535, got %d", cfg.HTTPPort)
+	}
+	if cfg.DataDir == "" {
+		return nil, fmt.Errorf("data_dir must not be empty")
+	}
+	if cfg.BackupInterval < 0 {
+		return nil, fmt.Errorf("backup_interval_hours must not be negative, got %d", int(cfg.BackupInterval/time.Hour))
+	}
+	if cfg.BackupDir == "" {
+		return nil, fmt.Errorf("backup_dir must not be empty")
+	}
+	if cfg.FlapThreshold < 0 {
+		return nil, fmt.Errorf("flap_threshold must not be negative, got %d", cfg.FlapThreshold)
+	}
+	if cfg.FlapWindow <= 0 {
+		return nil, fmt.Errorf("flap_window_seconds must be positive, got %d", int(cfg.FlapWindow/time.Second))
+	}
+	if cfg.AgentID != "" && !namespacePattern.MatchString(cfg.AgentID) {
+		return nil, fmt.Errorf("agent_id must be made of letters, digits, - and _, got %q", cfg.AgentID)
+	}
+	if cfg.DownQuorum <= 0 {
+		return nil, fmt.Errorf("down_quorum must be positive, got %d", cfg.DownQuorum)
+	}
+	if cfg.RecentResults <= 0 {
+		return nil, fmt.Errorf("recent_results must be positive, got %d", cfg.RecentResults)
+	}
+	return cfg, nil
+}
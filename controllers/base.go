@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"github.com/astaxie/beego"
+)
+
+// BaseController holds what every API controller shares. CORS headers are
+// set for all API routes by a filter in the routers package.
+type BaseController struct {
+	beego.Controller
+}
+
+// jsonError answers the request with status and an {"error": message} body.
+// The caller still has to return from the handler.
+func (c *BaseController) jsonError(status int, message string) {
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = map[string]string{"error": message}
+	c.ServeJSON()
+}
+
+// Options handles CORS preflight requests
+func (c *BaseController) Options() {
+	c.Ctx.Output.SetStatus(200)
+}
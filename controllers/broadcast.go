@@ -0,0 +1,58 @@
+package controllers
+
+import "sync"
+
+// subscriberBuffer is how many messages may queue for a subscriber before it
+// is considered too slow and dropped
+const subscriberBuffer = 256
+
+// broadcaster fans messages out to subscribers, such as the clients of a
+// streaming endpoint
+type broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan interface{}]struct{}
+}
+
+// newBroadcaster creates a broadcaster without subscribers
+func newBroadcaster() broadcaster {
+	return broadcaster{subscribers: make(map[chan interface{}]struct{})}
+}
+
+// publish queues a message for every subscriber. It never blocks: subscribers
+// whose queue is full are dropped so one slow reader can't stall the others.
+// A dropped subscriber's channel is closed.
+func (b *broadcaster) publish(message interface{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for send := range b.subscribers {
+		select {
+		case send <- message:
+		default:
+			delete(b.subscribers, send)
+			close(send)
+		}
+	}
+}
+
+// subscribe adds a subscriber and returns its message queue
+func (b *broadcaster) subscribe() chan interface{} {
+	send := make(chan interface{}, subscriberBuffer)
+
+	b.mutex.Lock()
+	b.subscribers[send] = struct{}{}
+	b.mutex.Unlock()
+
+	return send
+}
+
+// unsubscribe removes a subscriber unless publish already dropped it
+func (b *broadcaster) unsubscribe(send chan interface{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subscribers[send]; ok {
+		delete(b.subscribers, send)
+		close(send)
+	}
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"uptime-monitor/monitor"
+	"uptime-monitor/storage"
+)
+
+// dashboardCacheTTL is how long a computed dashboard payload is reused
+const dashboardCacheTTL = 5 * time.Second
+
+// DashboardController serves the precomputed dashboard payload
+type DashboardController struct {
+	BaseController
+	MonitorEngine *monitor.MonitorEngine
+	Storage       storage.StorageProvider
+	Namespaces    Namespaces
+
+	namespace string // Name of the namespace the request selects
+}
+
+// DashboardWebsite represents a single website in the dashboard payload
+type DashboardWebsite struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	URL              string    `json:"url"`
+	Status           string    `json:"status"`
+	StatusStale      bool      `json:"status_stale"`
+	Enabled          bool      `json:"enabled"`
+	Internal         bool      `json:"internal"`
+	LastCheckTime    time.Time `json:"last_check_time"`
+	LastResponseTime int       `json:"last_response_time_ms"`
+	Uptime24h        float64   `json:"uptime_24h"`
+	ActiveIncident   bool      `json:"active_incident"`
+}
+
+// DashboardSummary holds global counts across all websites
+type DashboardSummary struct {
+	Total            int     `json:"total"`
+	Up               int     `json:"up"`
+	Down             int     `json:"down"`
+	Degraded         int     `json:"degraded"`
+	Flapping         int     `json:"flapping"`
+	Unknown          int     `json:"unknown"`
+	Paused           int     `json:"paused"`
+	ActiveIncidents  int     `json:"active_incidents"`
+	AverageUptime24h float64 `json:"average_uptime_24h"`
+}
+
+// DashboardResponse represents the API response for the dashboard
+type DashboardResponse struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Summary     DashboardSummary   `json:"summary"`
+	Websites    []DashboardWebsite `json:"websites"`
+}
+
+// StatsWebsite identifies a website in the stats summary
+type StatsWebsite struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	Uptime24h float64 `json:"uptime_24h"`
+}
+
+// StatsResponse represents the API response for the aggregate stats summary
+type StatsResponse struct {
+	GeneratedAt        time.Time     `json:"generated_at"`
+	Total              int           `json:"total"`
+	Up                 int           `json:"up"`
+	Down               int           `json:"down"`
+	Degraded           int           `json:"degraded"`
+	Flapping           int           `json:"flapping"`
+	Unknown            int           `json:"unknown"`
+	Paused             int           `json:"paused"`
+	AvgResponseTime24h float64       `json:"avg_response_time_24h_ms"`
+	WorstWebsite       *StatsWebsite `json:"worst_website"`
+}
+
+// cachedDashboard is a computed dashboard payload and when it expires
+type cachedDashboard struct {
+	response *DashboardResponse
+	expiry   time.Time
+}
+
+// dashboardCaches holds the cached dashboard of each namespace
+var (
+	dashboardMutex  sync.Mutex
+	dashboardCaches = make(map[string]*cachedDashboard)
+)
+
+// Get returns the dashboard payload, recomputing it at most once per TTL
+func (c *DashboardController) Get() {
+	// Hold the lock while computing so concurrent requests wait for one result
+	dashboardMutex.Lock()
+	cached := dashboardCaches[c.namespace]
+	if cached == nil || time.Now().After(cached.expiry) {
+		cached = &cachedDashboard{response: c.buildDashboard(), expiry: time.Now().Add(dashboardCacheTTL)}
+		dashboardCaches[c.namespace] = cached
+	}
+	response := cached.response
+	dashboardMutex.Unlock()
+
+	c.Data["json"] = response
+	c.ServeJSON()
+}
+
+// buildDashboard computes the dashboard payload in a single pass over all
+// websites, then adds their uptime from storage
+func (c *DashboardController) buildDashboard() *DashboardResponse {
+	response := &DashboardResponse{
+		GeneratedAt: time.Now(),
+		Websites:    []DashboardWebsite{},
+	}
+
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		activeIncident := website.Enabled && website.Status == monitor.StatusDown
+
+		response.Summary.Total++
+		switch {
+		case !website.Enabled:
+			response.Summary.Paused++
+		case website.Flapping:
+			response.Summary.Flapping++
+		case website.Status == monitor.StatusUp:
+			response.Summary.Up++
+		case website.Status == monitor.StatusDown:
+			response.Summary.Down++
+		case website.Status == monitor.StatusDegraded:
+			response.Summary.Degraded++
+		default:
+			response.Summary.Unknown++
+		}
+		if activeIncident {
+			response.Summary.ActiveIncidents++
+		}
+
+		response.Websites = append(response.Websites, DashboardWebsite{
+			ID:               website.ID,
+			Name:             website.Name,
+			URL:              website.URL,
+			Status:           website.CurrentStatus(),
+			StatusStale:      website.StatusStale,
+			Enabled:          website.Enabled,
+			Internal:         website.Internal,
+			LastCheckTime:    website.LastCheckTime,
+			LastResponseTime: website.LastResponseTime,
+			ActiveIncident:   activeIncident,
+		})
+	})
+
+	// Storage is read outside the engine's lock
+	totalUptime := 0.0
+	for i := range response.Websites {
+		stats, _ := c.Storage.GetUptimeStats(response.Websites[i].ID)
+		response.Websites[i].Uptime24h = stats.Uptime24h
+		totalUptime += stats.Uptime24h
+	}
+	if len(response.Websites) > 0 {
+		response.Summary.AverageUptime24h = totalUptime / float64(len(response.Websites))
+	}
+
+	// Keep the output stable between refreshes
+	sort.Slice(response.Websites, func(i, j int) bool {
+		return response.Websites[i].Name < response.Websites[j].Name
+	})
+
+	return response
+}
+
+// TagSummary counts the websites carrying one tag by status
+type TagSummary struct {
+	Tag      string `json:"tag"`
+	Total    int    `json:"total"`
+	Up       int    `json:"up"`
+	Down     int    `json:"down"`
+	Degraded int    `json:"degraded"`
+	Flapping int    `json:"flapping"`
+	Unknown  int    `json:"unknown"`
+	Paused   int    `json:"paused"`
+}
+
+// GetTags lists the distinct website tags with per-tag status counts
+func (c *DashboardController) GetTags() {
+	summaries := make(map[string]*TagSummary)
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		for _, tag := range website.Tags {
+			summary, ok := summaries[tag]
+			if !ok {
+				summary = &TagSummary{Tag: tag}
+				summaries[tag] = summary
+			}
+
+			summary.Total++
+			switch {
+			case !website.Enabled:
+				summary.Paused++
+			case website.Flapping:
+				summary.Flapping++
+			case website.Status == monitor.StatusUp:
+				summary.Up++
+			case website.Status == monitor.StatusDown:
+				summary.Down++
+			case website.Status == monitor.StatusDegraded:
+				summary.Degraded++
+			default:
+				summary.Unknown++
+			}
+		}
+	})
+
+	response := make([]TagSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		response = append(response, *summary)
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].Tag < response[j].Tag })
+
+	c.Data["json"] = response
+	c.ServeJSON()
+}
+
+// GetStats returns aggregate numbers across all websites: status counts, the
+// average 24h response time and the enabled website with the lowest 24h uptime
+func (c *DashboardController) GetStats() {
+	response := StatsResponse{GeneratedAt: time.Now()}
+
+	// Count under the engine's lock and read storage for the enabled
+	// websites after
+	var enabled []StatsWebsite
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		response.Total++
+		switch {
+		case !website.Enabled:
+			response.Paused++
+			return
+		case website.Flapping:
+			response.Flapping++
+		case website.Status == monitor.StatusUp:
+			response.Up++
+		case website.Status == monitor.StatusDown:
+			response.Down++
+		case website.Status == monitor.StatusDegraded:
+			response.Degraded++
+		default:
+			response.Unknown++
+		}
+		enabled = append(enabled, StatsWebsite{ID: website.ID, Name: website.Name, URL: website.URL})
+	})
+
+	totalResponseTime := 0.0
+	withResponseTime := 0
+	for _, website := range enabled {
+		stats, err := c.Storage.GetUptimeStats(website.ID)
+		if err != nil {
+			continue
+		}
+		if stats.AvgResponseTime24h > 0 {
+			totalResponseTime += stats.AvgResponseTime24h
+			withResponseTime++
+		}
+
+		worst := response.WorstWebsite
+		if worst == nil || stats.Uptime24h < worst.Uptime24h ||
+			(stats.Uptime24h == worst.Uptime24h && website.Name < worst.Name) {
+			response.WorstWebsite = &StatsWebsite{
+				ID:        website.ID,
+				Name:      website.Name,
+				URL:       website.URL,
+				Uptime24h: stats.Uptime24h,
+			}
+		}
+	}
+
+	// Average of the per-website averages, so busy sites don't dominate
+	if withResponseTime > 0 {
+		response.AvgResponseTime24h = totalResponseTime / float64(withResponseTime)
+	}
+
+	c.Data["json"] = response
+	c.ServeJSON()
+}
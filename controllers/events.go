@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// eventKeepAliveInterval is how often an idle event stream sends a comment
+// so proxies don't close the connection
+const eventKeepAliveInterval = 15 * time.Second
+
+// StatusChange is the payload of a status_change event
+type StatusChange struct {
+	WebsiteID    string    `json:"website_id"`
+	WebsiteName  string    `json:"website_name"`
+	OldStatus    string    `json:"old_status"`
+	NewStatus    string    `json:"new_status"`
+	ResponseTime int       `json:"response_time_ms"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EventStream fans status changes out to the connected Server-Sent Events clients
+type EventStream struct {
+	clients broadcaster
+}
+
+// NewEventStream creates an event stream without clients
+func NewEventStream() *EventStream {
+	return &EventStream{clients: newBroadcaster()}
+}
+
+// PublishStatusChange queues a website's change from oldStatus to the status
+// of result for every client without blocking
+func (s *EventStream) PublishStatusChange(website *monitor.Website, oldStatus string, result monitor.CheckResult) {
+	s.clients.publish(StatusChange{
+		WebsiteID:    website.ID,
+		WebsiteName:  website.Name,
+		OldStatus:    oldStatus,
+		NewStatus:    result.Status,
+		ResponseTime: result.ResponseTime,
+		Timestamp:    result.Timestamp,
+	})
+}
+
+// EventsController serves status changes as Server-Sent Events
+type EventsController struct {
+	BaseController
+	Stream     *EventStream
+	Namespaces Namespaces
+}
+
+// Get streams status_change events until the client disconnects
+func (c *EventsController) Get() {
+	c.Ctx.Output.Header("Content-Type", "text/event-stream")
+	c.Ctx.Output.Header("Cache-Control", "no-cache")
+	c.Ctx.Output.Header("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Ctx.Output.Header("X-Accel-Buffering", "no")
+
+	send := c.Stream.clients.subscribe()
+	defer c.Stream.clients.unsubscribe(send)
+
+	w := c.Ctx.ResponseWriter
+	w.WriteHeader(200)
+	w.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case change, ok := <-send:
+			if !ok {
+				// Dropped for falling behind; the client reconnects
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: status_change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-c.Ctx.Request.Context().Done():
+			return
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+	"uptime-monitor/monitor"
+
+	"golang.org/x/net/websocket"
+)
+
+// liveWriteTimeout bounds how long a write to a client may block
+const liveWriteTimeout = 10 * time.Second
+
+// StatusUpdate is the message pushed to live clients for every check result
+type StatusUpdate struct {
+	WebsiteID    string    `json:"website_id"`
+	Status       string    `json:"status"`
+	ResponseTime int       `json:"response_time_ms"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// LiveHub fans check results out to the connected WebSocket clients
+type LiveHub struct {
+	clients broadcaster
+}
+
+// NewLiveHub creates a hub without clients
+func NewLiveHub() *LiveHub {
+	return &LiveHub{clients: newBroadcaster()}
+}
+
+// Publish queues a check result for every client without blocking
+func (h *LiveHub) Publish(result monitor.CheckResult) {
+	h.clients.publish(StatusUpdate{
+		WebsiteID:    result.WebsiteID,
+		Status:       result.Status,
+		ResponseTime: result.ResponseTime,
+		Timestamp:    result.Timestamp,
+	})
+}
+
+// serve streams updates to one client until it disconnects or falls behind
+func (h *LiveHub) serve(ws *websocket.Conn) {
+	send := h.clients.subscribe()
+	defer h.clients.unsubscribe(send)
+
+	// Clients aren't expected to send anything; reading only notices the
+	// connection going away. The read fails once serve returns and the
+	// connection is closed, so this goroutine never outlives it.
+	disconnected := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(disconnected)
+	}()
+
+	for {
+		select {
+		case update, ok := <-send:
+			if !ok {
+				return
+			}
+			ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := websocket.JSON.Send(ws, update); err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// LiveController serves the WebSocket endpoint for live status updates
+type LiveController struct {
+	BaseController
+	Hub        *LiveHub
+	Namespaces Namespaces
+}
+
+// Get upgrades the request to a WebSocket and streams status updates
+func (c *LiveController) Get() {
+	// The API is open to any origin, so the WebSocket is too
+	server := websocket.Server{Handler: c.Hub.serve}
+	server.ServeHTTP(c.Ctx.ResponseWriter, c.Ctx.Request)
+
+	// The connection was hijacked, so Beego must not write a response
+	c.Ctx.ResponseWriter.Started = true
+}
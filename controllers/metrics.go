@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
+)
+
+// MetricsController exposes website metrics in the Prometheus text format
+type MetricsController struct {
+	BaseController
+	MonitorEngine       *monitor.MonitorEngine
+	NotificationManager *notification.NotificationManager
+	Namespaces          Namespaces
+}
+
+// websiteMetrics is a snapshot of one website's metric values
+type websiteMetrics struct {
+	id, name     string
+	status       string
+	responseTime int
+	checks       uint64
+	failures     uint64
+}
+
+// labelEscaper escapes label values as required by the exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Get returns the metrics of every website
+func (c *MetricsController) Get() {
+	var snapshot []websiteMetrics
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		snapshot = append(snapshot, websiteMetrics{
+			id:           website.ID,
+			name:         website.Name,
+			status:       website.CurrentStatus(),
+			responseTime: website.LastResponseTime,
+			checks:       website.ChecksTotal,
+			failures:     website.FailuresTotal,
+		})
+	})
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].id < snapshot[j].id })
+
+	var out strings.Builder
+	writeMetric := func(name, help, metricType string, value func(m websiteMetrics) (string, bool)) {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+		for _, m := range snapshot {
+			if v, ok := value(m); ok {
+				fmt.Fprintf(&out, "%s{id=\"%s\",name=\"%s\"} %s\n", name, labelEscaper.Replace(m.id), labelEscaper.Replace(m.name), v)
+			}
+		}
+	}
+
+	// Websites that haven't been checked yet or are flapping have no up value
+	writeMetric("uptime_website_up", "Whether the last check of the website succeeded (1) or failed (0).", "gauge",
+		func(m websiteMetrics) (string, bool) {
+			switch m.status {
+			case monitor.StatusUp, monitor.StatusDegraded:
+				return "1", true
+			case monitor.StatusDown, monitor.StatusError:
+				return "0", true
+			}
+			return "", false
+		})
+	writeMetric("uptime_website_response_ms", "Response time of the last check in milliseconds.", "gauge",
+		func(m websiteMetrics) (string, bool) { return fmt.Sprint(m.responseTime), true })
+	writeMetric("uptime_website_checks_total", "Checks run since the monitor started.", "counter",
+		func(m websiteMetrics) (string, bool) { return fmt.Sprint(m.checks), true })
+	writeMetric("uptime_website_check_failures_total", "Checks that found the website down or could not run since the monitor started.", "counter",
+		func(m websiteMetrics) (string, bool) { return fmt.Sprint(m.failures), true })
+
+	queue := c.NotificationManager.QueueStats()
+	fmt.Fprintf(&out, "# HELP uptime_notification_queue_length Notifications waiting to be sent.\n# TYPE uptime_notification_queue_length gauge\nuptime_notification_queue_length %d\n", queue.Length)
+	fmt.Fprintf(&out, "# HELP uptime_notification_queue_capacity Notifications that can wait before new ones are dropped.\n# TYPE uptime_notification_queue_capacity gauge\nuptime_notification_queue_capacity %d\n", queue.Capacity)
+	fmt.Fprintf(&out, "# HELP uptime_notifications_dropped_total Notifications dropped because the queue was full.\n# TYPE uptime_notifications_dropped_total counter\nuptime_notifications_dropped_total %d\n", queue.Dropped)
+
+	c.Ctx.Output.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Ctx.Output.Body([]byte(out.String()))
+}
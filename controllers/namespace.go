@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"fmt"
+	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
+	"uptime-monitor/storage"
+)
+
+// NamespaceHeader selects the namespace an API request works on. Requests
+// without it use the default namespace.
+const NamespaceHeader = "X-Namespace"
+
+// Namespace is a separately stored and monitored set of websites
+type Namespace struct {
+	Name                string // Empty for the default namespace
+	MonitorEngine       *monitor.MonitorEngine
+	Storage             storage.StorageProvider
+	NotificationManager *notification.NotificationManager
+	Hub                 *LiveHub
+	Stream              *EventStream
+	BackupDir           string // Where backups of the namespace's data go
+	BackupKeep          int    // How many backups are kept, all of them if 0
+}
+
+// Backup archives the namespace's stored data into its backup directory and
+// deletes the oldest backups past BackupKeep
+func (n *Namespace) Backup() (storage.BackupInfo, error) {
+	info, err := n.Storage.Backup(n.BackupDir)
+	if err != nil {
+		return info, err
+	}
+	storage.PruneBackups(n.BackupDir, n.BackupKeep)
+	return info, nil
+}
+
+// Namespaces holds the namespaces by name
+type Namespaces map[string]*Namespace
+
+// Names returns the names of the namespaces besides the default one
+func (n Namespaces) Names() []string {
+	names := []string{}
+	for name := range n {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectNamespace returns the namespace named by the X-Namespace header or,
+// since browsers can't set headers on WebSocket and EventSource connections,
+// the namespace query parameter. An unknown namespace is answered with 404
+// and ends the request.
+func selectNamespace(c *BaseController, namespaces Namespaces) *Namespace {
+	name := c.Ctx.Input.Header(NamespaceHeader)
+	if name == "" {
+		name = c.Ctx.Input.Query("namespace")
+	}
+	if namespace, exists := namespaces[name]; exists {
+		return namespace
+	}
+
+	c.jsonError(404, fmt.Sprintf("Unknown namespace %q", name))
+	c.StopRun()
+	return nil
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *WebsiteController) Prepare() {
+	namespace := selectNamespace(&c.BaseController, c.Namespaces)
+	c.MonitorEngine = namespace.MonitorEngine
+	c.Storage = namespace.Storage
+	c.NotificationManager = namespace.NotificationManager
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *DashboardController) Prepare() {
+	namespace := selectNamespace(&c.BaseController, c.Namespaces)
+	c.MonitorEngine = namespace.MonitorEngine
+	c.Storage = namespace.Storage
+	c.namespace = namespace.Name
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *MetricsController) Prepare() {
+	namespace := selectNamespace(&c.BaseController, c.Namespaces)
+	c.MonitorEngine = namespace.MonitorEngine
+	c.NotificationManager = namespace.NotificationManager
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *SystemController) Prepare() {
+	namespace := selectNamespace(&c.BaseController, c.Namespaces)
+	c.MonitorEngine = namespace.MonitorEngine
+	c.Storage = namespace.Storage
+	c.NotificationManager = namespace.NotificationManager
+	c.namespace = namespace
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *LiveController) Prepare() {
+	c.Hub = selectNamespace(&c.BaseController, c.Namespaces).Hub
+}
+
+// Prepare points the controller at the namespace the request selects
+func (c *EventsController) Prepare() {
+	c.Stream = selectNamespace(&c.BaseController, c.Namespaces).Stream
+}
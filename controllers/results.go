@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// AgentKeyHeader carries the key a remote agent submits results with
+const AgentKeyHeader = "X-Agent-Key"
+
+// maxResultClockSkew is how far in the future a submitted result may be
+// timestamped, to allow for agents whose clock runs slightly ahead
+const maxResultClockSkew = time.Minute
+
+// SubmitResultRequest is a check result reported by a remote agent
+type SubmitResultRequest struct {
+	WebsiteID     string           `json:"website_id"`
+	Agent         string           `json:"agent"`
+	Status        string           `json:"status"`
+	ResponseTime  int              `json:"response_time_ms"`
+	Timestamp     *time.Time       `json:"timestamp"` // Defaults to when the result arrives
+	StatusCode    int              `json:"status_code"`
+	Protocol      string           `json:"protocol"`
+	Error         string           `json:"error"`
+	ErrorCode     string           `json:"error_code"`
+	ResponseBytes *int64           `json:"response_bytes"`
+	RemoteIP      string           `json:"remote_ip"`
+	Timings       *monitor.Timings `json:"timings"`
+}
+
+// validateSubmitResult checks a submitted result from a remote agent against
+// the agent ID of this instance
+func validateSubmitResult(request *SubmitResultRequest, localAgent string) error {
+	if request.WebsiteID == "" {
+		return fmt.Errorf("website_id is required")
+	}
+	if request.Agent == "" {
+		return fmt.Errorf("agent is required")
+	}
+	if !websiteIDPattern.MatchString(request.Agent) {
+		return fmt.Errorf("agent must be made of letters, digits, - and _")
+	}
+	if request.Agent == localAgent {
+		return fmt.Errorf("agent %q is this instance's own agent_id", request.Agent)
+	}
+	switch request.Status {
+	case monitor.StatusUp, monitor.StatusDown, monitor.StatusDegraded, monitor.StatusError:
+	default:
+		return fmt.Errorf("status must be one of up, down, degraded, error")
+	}
+	if request.ResponseTime < 0 {
+		return fmt.Errorf("response_time_ms must not be negative")
+	}
+	if request.ResponseBytes != nil && *request.ResponseBytes < 0 {
+		return fmt.Errorf("response_bytes must not be negative")
+	}
+	if request.RemoteIP != "" && net.ParseIP(request.RemoteIP) == nil {
+		return fmt.Errorf("remote_ip must be an IP address")
+	}
+	if t := request.Timings; t != nil && (t.DNS < 0 || t.Connect < 0 || t.TLS < 0 || t.TTFB < 0 || t.Total < 0) {
+		return fmt.Errorf("timings must not be negative")
+	}
+	if request.Timestamp != nil && request.Timestamp.After(time.Now().Add(maxResultClockSkew)) {
+		return fmt.Errorf("timestamp must not be in the future")
+	}
+	if request.Timestamp != nil && request.Timestamp.Before(time.Now().Add(-monitor.MaxResultAge)) {
+		return fmt.Errorf("timestamp must not be more than %s in the past", monitor.MaxResultAge)
+	}
+	return nil
+}
+
+// agentKeyValid reports whether provided is the key configured for agent
+func agentKeyValid(keys map[string]string, agent, provided string) bool {
+	key, exists := keys[agent]
+	return exists && provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1
+}
+
+// authorizeAgent checks the request's X-Agent-Key against the key configured
+// for agent. Without any agent keys, submitted results are refused outright,
+// so an open API can't be used to report websites down. It answers rejected
+// requests itself.
+func (c *WebsiteController) authorizeAgent(agent string) bool {
+	if len(c.AgentKeys) == 0 {
+		c.jsonError(403, "Submitting results is disabled, set agent_keys to allow remote agents")
+		return false
+	}
+	if !agentKeyValid(c.AgentKeys, agent, c.Ctx.Input.Header(AgentKeyHeader)) {
+		c.jsonError(401, "Missing or invalid agent key")
+		return false
+	}
+	return true
+}
+
+// maxAgentBatch is how many results an agent may submit in one request
+const maxAgentBatch = 500
+
+// AgentResultStatus reports the outcome of one result of an agent's batch
+type AgentResultStatus struct {
+	Index     int    `json:"index"`
+	WebsiteID string `json:"website_id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// newAgentResult validates a submitted result and returns it as a check
+// result, or the HTTP status and error to reject it with
+func (c *WebsiteController) newAgentResult(request SubmitResultRequest) (monitor.CheckResult, int, error) {
+	if err := validateSubmitResult(&request, c.MonitorEngine.Settings().AgentID); err != nil {
+		return monitor.CheckResult{}, 400, err
+	}
+
+	website, exists := c.MonitorEngine.GetWebsite(request.WebsiteID)
+	if !exists {
+		return monitor.CheckResult{}, 404, fmt.Errorf("Website not found")
+	}
+	if !website.Enabled {
+		return monitor.CheckResult{}, 409, fmt.Errorf("Website is paused")
+	}
+
+	result := monitor.CheckResult{
+		WebsiteID:      website.ID,
+		Status:         request.Status,
+		ResponseTime:   request.ResponseTime,
+		Timestamp:      time.Now(),
+		ContentMatched: true,
+		StatusCode:     request.StatusCode,
+		Protocol:       request.Protocol,
+		ErrorCode:      request.ErrorCode,
+		Agent:          request.Agent,
+		ResponseBytes:  request.ResponseBytes,
+		RemoteIP:       request.RemoteIP,
+		Timings:        request.Timings,
+	}
+	if request.Timestamp != nil {
+		result.Timestamp = *request.Timestamp
+	}
+	if request.Error != "" {
+		result.Error = errors.New(request.Error)
+	}
+	return result, 0, nil
+}
+
+// SubmitResult accepts a check result from a remote agent, authenticated by
+// the agent's key. It is saved to history and counts towards the website's
+// status like a local check.
+func (c *WebsiteController) SubmitResult() {
+	var request SubmitResultRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &request); err != nil {
+		c.jsonError(400, "Invalid JSON")
+		return
+	}
+	if !c.authorizeAgent(request.Agent) {
+		return
+	}
+
+	result, status, err := c.newAgentResult(request)
+	if err != nil {
+		c.jsonError(status, err.Error())
+		return
+	}
+
+	if err := c.MonitorEngine.SubmitResult(result); err != nil {
+		status := 503
+		switch err {
+		case monitor.ErrStaleResult:
+			status = 400
+		case monitor.ErrTooManyAgents:
+			status = 409
+		}
+		c.jsonError(status, err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(202)
+	c.Data["json"] = map[string]string{"message": "Result accepted"}
+	c.ServeJSON()
+}
+
+// SubmitAgentResults accepts a batch of check results from the remote agent
+// named in the path, authenticated by its key. Invalid results are reported and skipped; the valid
+// ones are processed like local checks regardless.
+func (c *WebsiteController) SubmitAgentResults() {
+	agent := c.Ctx.Input.Param(":agent")
+	if !c.authorizeAgent(agent) {
+		return
+	}
+
+	var requests []SubmitResultRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &requests); err != nil {
+		c.jsonError(400, "Invalid JSON, expected an array of results")
+		return
+	}
+	if len(requests) == 0 || len(requests) > maxAgentBatch {
+		c.jsonError(400, fmt.Sprintf("Submit between 1 and %d results", maxAgentBatch))
+		return
+	}
+
+	statuses := make([]AgentResultStatus, 0, len(requests))
+	accepted := 0
+	for i, request := range requests {
+		status := AgentResultStatus{Index: i, WebsiteID: request.WebsiteID}
+		if request.Agent != "" && request.Agent != agent {
+			status.Error = fmt.Sprintf("agent %q doesn't match the agent %q in the path", request.Agent, agent)
+			statuses = append(statuses, status)
+			continue
+		}
+		request.Agent = agent
+
+		result, _, err := c.newAgentResult(request)
+		if err == nil {
+			err = c.MonitorEngine.SubmitResult(result)
+		}
+		if err == monitor.ErrNotRunning {
+			c.jsonError(503, err.Error())
+			return
+		}
+		if err != nil {
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Success = true
+		statuses = append(statuses, status)
+		accepted++
+	}
+
+	if accepted > 0 {
+		c.Ctx.Output.SetStatus(202)
+	} else {
+		c.Ctx.Output.SetStatus(400)
+	}
+	c.Data["json"] = map[string]interface{}{
+		"accepted": accepted,
+		"failed":   len(requests) - accepted,
+		"results":  statuses,
+	}
+	c.ServeJSON()
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"runtime"
+	"time"
+	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
+	"uptime-monitor/storage"
+
+	"github.com/astaxie/beego"
+)
+
+// redacted replaces secret values in responses
+const redacted = "[redacted]"
+
+// SystemController exposes read-only configuration and runtime information
+type SystemController struct {
+	BaseController
+	MonitorEngine       *monitor.MonitorEngine
+	Storage             storage.StorageProvider
+	NotificationManager *notification.NotificationManager
+	Namespaces          Namespaces
+	StartTime           time.Time
+
+	namespace *Namespace
+}
+
+// SystemInfoResponse represents the API response for system info
+type SystemInfoResponse struct {
+	Config  SystemConfig  `json:"config"`
+	Runtime SystemRuntime `json:"runtime"`
+}
+
+// SystemConfig holds the effective configuration, with secrets redacted
+type SystemConfig struct {
+	Engine          monitor.EngineSettings `json:"engine"`
+	Storage         StorageInfo            `json:"storage"`
+	Notifications   NotificationInfo       `json:"notifications"`
+	HTTPAddr        string                 `json:"http_addr"`
+	HTTPPort        int                    `json:"http_port"`
+	RunMode         string                 `json:"run_mode"`
+	MinInterval     int                    `json:"min_interval_seconds"`
+	MaxInterval     int                    `json:"max_interval_seconds"`
+	DefaultInterval int                    `json:"default_interval_seconds"`
+	Namespaces      []string               `json:"namespaces"`
+}
+
+// StorageInfo describes the storage backend
+type StorageInfo struct {
+	Backend string `json:"backend"`
+	DataDir string `json:"data_dir"`
+}
+
+// NotificationInfo describes which notification channels are available
+type NotificationInfo struct {
+	EmailEnabled     bool   `json:"email_enabled"`
+	SMTPHost         string `json:"smtp_host"`
+	SMTPPort         string `json:"smtp_port"`
+	SMTPUsername     string `json:"smtp_username"`
+	SMTPPassword     string `json:"smtp_password"`
+	FromEmail        string `json:"from_email"`
+	SlackWebhooks    int    `json:"slack_webhooks"`
+	TeamsWebhooks    int    `json:"teams_webhooks"`
+	TelegramEnabled  bool   `json:"telegram_enabled"`
+	TelegramBotToken string `json:"telegram_bot_token"`
+	TelegramChats    int    `json:"telegram_chats"`
+
+	PagerDutyEnabled    bool   `json:"pagerduty_enabled"`
+	PagerDutyRoutingKey string `json:"pagerduty_routing_key"`
+	PagerDutyEventsURL  string `json:"pagerduty_events_url"`
+	PagerDutyWebsites   int    `json:"pagerduty_websites"`
+}
+
+// SystemRuntime holds process runtime information
+type SystemRuntime struct {
+	StartTime         time.Time `json:"start_time"`
+	UptimeSeconds     int64     `json:"uptime_seconds"`
+	Goroutines        int       `json:"goroutines"`
+	GoVersion         string    `json:"go_version"`
+	MonitoredWebsites int       `json:"monitored_websites"`
+	EnabledWebsites   int       `json:"enabled_websites"`
+
+	NotificationQueueLength   int    `json:"notification_queue_length"`
+	NotificationQueueCapacity int    `json:"notification_queue_capacity"`
+	NotificationsDropped      uint64 `json:"notifications_dropped"`
+}
+
+// GetInfo returns the effective configuration and runtime information
+func (c *SystemController) GetInfo() {
+	total := 0
+	enabled := 0
+	slackWebhooks := 0
+	teamsWebhooks := 0
+	telegramChats := 0
+	pagerDutyWebsites := 0
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		total++
+		if website.Enabled {
+			enabled++
+		}
+		if website.SlackWebhook != "" {
+			slackWebhooks++
+		}
+		if website.TeamsWebhook != "" {
+			teamsWebhooks++
+		}
+		if website.TelegramChatID != "" {
+			telegramChats++
+		}
+		if website.PagerDuty {
+			pagerDutyWebsites++
+		}
+	})
+
+	notificationConfig := c.NotificationManager.Config()
+	queue := c.NotificationManager.QueueStats()
+	smtpPassword := ""
+	if notificationConfig.SMTPPassword != "" {
+		smtpPassword = redacted
+	}
+	telegramBotToken := ""
+	if notificationConfig.TelegramBotToken != "" {
+		telegramBotToken = redacted
+	}
+	pagerDutyRoutingKey := ""
+	if notificationConfig.PagerDutyRoutingKey != "" {
+		pagerDutyRoutingKey = redacted
+	}
+
+	c.Data["json"] = SystemInfoResponse{
+		Config: SystemConfig{
+			Engine: c.MonitorEngine.Settings(),
+			Storage: StorageInfo{
+				Backend: c.Storage.Backend(),
+				DataDir: c.Storage.DataDir(),
+			},
+			Notifications: NotificationInfo{
+				EmailEnabled:     notificationConfig.SMTPHost != "",
+				SMTPHost:         notificationConfig.SMTPHost,
+				SMTPPort:         notificationConfig.SMTPPort,
+				SMTPUsername:     notificationConfig.SMTPUsername,
+				SMTPPassword:     smtpPassword,
+				FromEmail:        notificationConfig.FromEmail,
+				SlackWebhooks:    slackWebhooks,
+				TeamsWebhooks:    teamsWebhooks,
+				TelegramEnabled:  notificationConfig.TelegramBotToken != "",
+				TelegramBotToken: telegramBotToken,
+				TelegramChats:    telegramChats,
+
+				PagerDutyEnabled:    notificationConfig.PagerDutyRoutingKey != "",
+				PagerDutyRoutingKey: pagerDutyRoutingKey,
+				PagerDutyEventsURL:  notificationConfig.PagerDutyEventsURL,
+				PagerDutyWebsites:   pagerDutyWebsites,
+			},
+			HTTPAddr:        beego.BConfig.Listen.HTTPAddr,
+			HTTPPort:        beego.BConfig.Listen.HTTPPort,
+			RunMode:         beego.BConfig.RunMode,
+			MinInterval:     minIntervalSeconds,
+			MaxInterval:     maxIntervalSeconds,
+			DefaultInterval: defaultIntervalSeconds,
+			Namespaces:      c.Namespaces.Names(),
+		},
+		Runtime: SystemRuntime{
+			StartTime:         c.StartTime,
+			UptimeSeconds:     int64(time.Since(c.StartTime).Seconds()),
+			Goroutines:        runtime.NumGoroutine(),
+			GoVersion:         runtime.Version(),
+			MonitoredWebsites: total,
+			EnabledWebsites:   enabled,
+
+			NotificationQueueLength:   queue.Length,
+			NotificationQueueCapacity: queue.Capacity,
+			NotificationsDropped:      queue.Dropped,
+		},
+	}
+	c.ServeJSON()
+}
+
+// Backup archives the stored data of the namespace into its backup
+// directory and reports where the backup went
+func (c *SystemController) Backup() {
+	info, err := c.namespace.Backup()
+	if err != nil {
+		c.jsonError(500, err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	c.Data["json"] = info
+	c.ServeJSON()
+}
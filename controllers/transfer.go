@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// exportVersion is the format version written to export files
+const exportVersion = 1
+
+// Import modes
+const (
+	importModeMerge   = "merge"   // Add the imported websites next to the existing ones
+	importModeReplace = "replace" // Remove every existing website first
+)
+
+// websiteIDPattern matches IDs safe to keep on import; they end up in file names
+var websiteIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ExportFile is the document served by Export and accepted by Import
+type ExportFile struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Websites   []ExportedWebsite `json:"websites"`
+}
+
+// ExportedWebsite is the configuration of one website in an export file
+type ExportedWebsite struct {
+	ID string `json:"id"`
+	// Enabled is nil in hand-written files, which imports the website enabled
+	Enabled *bool `json:"enabled,omitempty"`
+	CreateWebsiteRequest
+}
+
+// ImportResult reports the outcome of one website of an import
+type ImportResult struct {
+	Index      int    `json:"index"`
+	ID         string `json:"id,omitempty"`
+	OriginalID string `json:"original_id,omitempty"`
+	Name       string `json:"name"`
+	Success    bool   `json:"success"`
+	Conflict   string `json:"conflict,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ExistingID string `json:"existing_id,omitempty"` // The website with the same URL, for a skipped duplicate
+}
+
+// Export returns the configuration of every website, without history or
+// check results, as a downloadable file. Secrets are included so the file
+// can restore a working setup.
+func (c *WebsiteController) Export() {
+	file := ExportFile{
+		Version:    exportVersion,
+		ExportedAt: time.Now(),
+		Websites:   []ExportedWebsite{},
+	}
+	for _, website := range c.MonitorEngine.GetAllWebsites() {
+		enabled := website.Enabled
+		file.Websites = append(file.Websites, ExportedWebsite{
+			ID:                   website.ID,
+			Enabled:              &enabled,
+			CreateWebsiteRequest: websiteConfig(website),
+		})
+	}
+	sort.Slice(file.Websites, func(i, j int) bool { return file.Websites[i].ID < file.Websites[j].ID })
+
+	c.Ctx.Output.Header("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"uptime-monitor-export-%s.json\"", file.ExportedAt.Format("20060102-150405")))
+	c.Data["json"] = file
+	c.ServeJSON()
+}
+
+// Import recreates the websites of an export file. With mode=merge (the
+// default) they are added next to the existing websites, and invalid ones and,
+// unless allow_duplicate is set, ones whose URL is already monitored are
+// skipped. With mode=replace every existing website is removed first, and
+// nothing changes unless the whole file is valid. Original IDs are kept
+// unless already taken, in which case the website gets a new ID and the
+// conflict is reported.
+func (c *WebsiteController) Import() {
+	mode := c.GetString("mode", importModeMerge)
+	if mode != importModeMerge && mode != importModeReplace {
+		c.jsonError(400, "mode must be merge or replace")
+		return
+	}
+	allowDuplicate, err := c.GetBool("allow_duplicate", false)
+	if err != nil {
+		c.jsonError(400, "allow_duplicate must be true or false")
+		return
+	}
+
+	var file ExportFile
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &file); err != nil {
+		c.jsonError(400, jsonErrorMessage(err, "Invalid JSON, expected an export file"))
+		return
+	}
+	if file.Version != exportVersion {
+		c.jsonError(400, fmt.Sprintf("Unsupported export version %d", file.Version))
+		return
+	}
+	if len(file.Websites) == 0 {
+		c.jsonError(400, "The export file contains no websites")
+		return
+	}
+
+	// Validate everything before touching the existing websites
+	results := make([]ImportResult, len(file.Websites))
+	failed := 0
+	for i := range file.Websites {
+		item := &file.Websites[i]
+		results[i] = ImportResult{Index: i, OriginalID: item.ID, Name: item.Name}
+		if err := validateCreateRequest(&item.CreateWebsiteRequest); err != nil {
+			results[i].Error = err.Error()
+			failed++
+		}
+	}
+
+	if mode == importModeReplace {
+		if failed > 0 {
+			c.Ctx.Output.SetStatus(400)
+			c.Data["json"] = map[string]interface{}{
+				"error":   "Nothing was imported: replace mode needs every website to be valid",
+				"failed":  failed,
+				"results": results,
+			}
+			c.ServeJSON()
+			return
+		}
+
+		// Websites coming back under the same ID keep their history
+		imported := make(map[string]bool)
+		for _, item := range file.Websites {
+			imported[item.ID] = true
+		}
+		for id := range c.MonitorEngine.GetAllWebsites() {
+			c.MonitorEngine.RemoveWebsite(id)
+			c.NotificationManager.StopEscalation(id)
+			if !imported[id] {
+				c.Storage.DeleteWebsiteHistory(id)
+				c.Storage.DeleteNotificationLog(id)
+			}
+		}
+	}
+
+	created, conflicts := 0, 0
+	for i, item := range file.Websites {
+		if results[i].Error != "" {
+			continue
+		}
+		// A replace restores the file as it is
+		if mode == importModeMerge && !allowDuplicate {
+			if existing, exists := c.MonitorEngine.FindWebsiteByURL(item.URL); exists {
+				results[i].Error = duplicateURLError
+				results[i].ExistingID = existing.ID
+				failed++
+				continue
+			}
+		}
+
+		id := item.ID
+		switch {
+		case id == "":
+			id = c.newWebsiteID()
+		case !websiteIDPattern.MatchString(id):
+			results[i].Conflict = fmt.Sprintf("id %q is not a valid website ID", item.ID)
+			id = c.newWebsiteID()
+		default:
+			if _, taken := c.MonitorEngine.GetWebsite(id); taken {
+				results[i].Conflict = fmt.Sprintf("id %q is already in use", item.ID)
+				id = c.newWebsiteID()
+			}
+		}
+		if results[i].Conflict != "" {
+			conflicts++
+		}
+
+		website := newWebsite(id, item.CreateWebsiteRequest)
+		if item.Enabled != nil {
+			website.Enabled = *item.Enabled
+		}
+		c.MonitorEngine.AddWebsite(website)
+
+		results[i].ID = id
+		results[i].Success = true
+		created++
+	}
+
+	// Save to storage once for the whole import
+	if created > 0 || mode == importModeReplace {
+		if err := c.Storage.SaveWebsites(c.MonitorEngine.GetAllWebsites()); err != nil {
+			c.jsonError(500, "Failed to save websites")
+			return
+		}
+	}
+	if created == 0 {
+		c.Ctx.Output.SetStatus(400)
+	}
+
+	c.Data["json"] = map[string]interface{}{
+		"mode":      mode,
+		"imported":  created,
+		"failed":    failed,
+		"conflicts": conflicts,
+		"results":   results,
+	}
+	c.ServeJSON()
+}
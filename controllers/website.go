@@ -1,190 +1,549 @@
 package controllers
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"uptime-monitor/logger"
 	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
 	"uptime-monitor/storage"
-
-	"github.com/astaxie/beego"
 )
 
 // WebsiteController handles website-related API endpoints
 type WebsiteController struct {
-	beego.Controller
-	MonitorEngine *monitor.MonitorEngine
-	Storage       *storage.Storage
+	BaseController
+	MonitorEngine       *monitor.MonitorEngine
+	Storage             storage.StorageProvider
+	NotificationManager *notification.NotificationManager
+	Namespaces          Namespaces
+	AgentKeys           map[string]string // Keys remote agents submit results with, by agent ID
+}
+
+// TestNotificationResult reports the outcome of a test notification on one channel
+type TestNotificationResult struct {
+	Channel string `json:"channel"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
 }
 
 // WebsiteResponse represents the API response for a website
 type WebsiteResponse struct {
-	ID                string    `json:"id"`
-	Name              string    `json:"name"`
-	URL               string    `json:"url"`
-	IntervalSeconds   int       `json:"interval_seconds"`
-	Status            string    `json:"status"`
-	LastCheckTime     time.Time `json:"last_check_time"`
-	LastResponseTime  int       `json:"last_response_time_ms"`
-	NotificationEmails []string `json:"notification_emails"`
-	SlackWebhook      string    `json:"slack_webhook"`
-	Enabled           bool      `json:"enabled"`
-	Uptime24h         float64   `json:"uptime_24h"`
-	Uptime30d         float64   `json:"uptime_30d"`
-	AvgResponseTime24h float64  `json:"avg_response_time_24h"`
-	History           []storage.HistoryEntry `json:"history,omitempty"`
+	ID                          string                      `json:"id"`
+	Name                        string                      `json:"name"`
+	URL                         string                      `json:"url"`
+	IntervalSeconds             int                         `json:"interval_seconds"`
+	Status                      string                      `json:"status"`
+	StatusStale                 bool                        `json:"status_stale"`
+	LastCheckTime               time.Time                   `json:"last_check_time"`
+	LastResponseTime            int                         `json:"last_response_time_ms"`
+	LastError                   string                      `json:"last_error,omitempty"`
+	LastErrorCode               string                      `json:"last_error_code,omitempty"`
+	NotificationEmails          []string                    `json:"notification_emails"`
+	SlackWebhook                string                      `json:"slack_webhook"`
+	Enabled                     bool                        `json:"enabled"`
+	Internal                    bool                        `json:"internal"`
+	Uptime24h                   float64                     `json:"uptime_24h"`
+	Uptime30d                   float64                     `json:"uptime_30d"`
+	AvgResponseTime24h          float64                     `json:"avg_response_time_24h"`
+	ResponseTimeStats24h        storage.ResponseTimeStats   `json:"response_time_stats_24h"`
+	ActiveSchedule              []monitor.TimeWindow        `json:"active_schedule,omitempty"`
+	UptimeAlert                 *monitor.UptimeAlert        `json:"uptime_alert,omitempty"`
+	ClientCert                  *monitor.ClientCertificate  `json:"client_cert,omitempty"`
+	RecoveryConfirmChecks       int                         `json:"recovery_confirm_checks"`
+	RecoveryConfirmSeconds      int                         `json:"recovery_confirm_seconds"`
+	ExpectedKeyword             string                      `json:"expected_keyword,omitempty"`
+	ExpectedStatusCodes         []int                       `json:"expected_status_codes,omitempty"`
+	RetryCount                  int                         `json:"retry_count,omitempty"`
+	RetryDelaySeconds           int                         `json:"retry_delay_seconds,omitempty"`
+	TimeoutSeconds              int                         `json:"timeout_seconds,omitempty"`
+	HTTPMethod                  string                      `json:"http_method,omitempty"`
+	RequestBody                 string                      `json:"request_body,omitempty"`
+	ContentType                 string                      `json:"content_type,omitempty"`
+	Headers                     map[string]string           `json:"headers,omitempty"`
+	CertExpiryWarningDays       int                         `json:"cert_expiry_warning_days,omitempty"`
+	CertExpiresAt               *time.Time                  `json:"cert_expires_at,omitempty"`
+	CertDaysRemaining           *int                        `json:"cert_days_remaining,omitempty"`
+	Auth                        *monitor.Auth               `json:"auth,omitempty"`
+	GenericWebhook              string                      `json:"generic_webhook,omitempty"`
+	WebhookTemplate             string                      `json:"webhook_template,omitempty"`
+	TelegramChatID              string                      `json:"telegram_chat_id,omitempty"`
+	NotificationThrottleSeconds *int                        `json:"notification_throttle_seconds,omitempty"`
+	NotifyOn                    string                      `json:"notify_on,omitempty"`
+	Tags                        []string                    `json:"tags,omitempty"`
+	MaintenanceWindows          []monitor.MaintenanceWindow `json:"maintenance_windows,omitempty"`
+	CheckType                   string                      `json:"check_type,omitempty"`
+	DNSRecordType               string                      `json:"dns_record_type,omitempty"`
+	ExpectedDNSValue            string                      `json:"expected_dns_value,omitempty"`
+	FollowRedirects             *bool                       `json:"follow_redirects,omitempty"`
+	MaxRedirects                int                         `json:"max_redirects,omitempty"`
+	FinalURL                    string                      `json:"final_url,omitempty"`
+	Protocol                    string                      `json:"protocol,omitempty"`
+	RemoteIP                    string                      `json:"remote_ip,omitempty"`
+	MaxResponseTimeMs           int                         `json:"max_response_time_ms,omitempty"`
+	ProxyURL                    string                      `json:"proxy_url,omitempty"`
+	MaxBodyBytes                int64                       `json:"max_body_bytes,omitempty"`
+	MinResponseBytes            int64                       `json:"min_response_bytes,omitempty"`
+	MaxResponseBytes            int64                       `json:"max_response_bytes,omitempty"`
+	PagerDuty                   bool                        `json:"pagerduty,omitempty"`
+	TeamsWebhook                string                      `json:"teams_webhook,omitempty"`
+	EscalationAfterSeconds      int                         `json:"escalation_after_seconds,omitempty"`
+	InsecureSkipTLSVerify       bool                        `json:"insecure_skip_tls_verify,omitempty"`
+	TLSUnverified               bool                        `json:"tls_unverified,omitempty"`
+	ExpectedBodyRegex           string                      `json:"expected_body_regex,omitempty"`
+	JSONAssertions              []monitor.JSONAssertion     `json:"json_assertions,omitempty"`
+	RequireHTTP2                bool                        `json:"require_http2,omitempty"`
+	IPVersion                   string                      `json:"ip_version,omitempty"`
+	BackoffAfterFailures        int                         `json:"backoff_after_failures,omitempty"`
+	BackoffFactor               float64                     `json:"backoff_factor,omitempty"`
+	BackoffMaxIntervalSeconds   int                         `json:"backoff_max_interval_seconds,omitempty"`
+	ConsecutiveFailures         int                         `json:"consecutive_failures,omitempty"`
+	EffectiveIntervalSeconds    int                         `json:"effective_interval_seconds,omitempty"`
+	CaptureOnFailure            bool                        `json:"capture_on_failure,omitempty"`
+	FailureThreshold            int                         `json:"failure_threshold,omitempty"`
+	SubChecks                   []monitor.SubCheck          `json:"sub_checks,omitempty"`
+	RequireSubChecks            bool                        `json:"require_sub_checks,omitempty"`
+	Verbose                     bool                        `json:"verbose,omitempty"`
+	History                     []storage.HistoryEntry      `json:"history,omitempty"`
 }
 
 // CreateWebsiteRequest represents the request to create a website
 type CreateWebsiteRequest struct {
-	Name              string   `json:"name"`
-	URL               string   `json:"url"`
-	IntervalSeconds   int      `json:"interval_seconds"`
-	NotificationEmails []string `json:"notification_emails"`
-	SlackWebhook      string   `json:"slack_webhook"`
+	Name                        string                      `json:"name"`
+	URL                         string                      `json:"url"`
+	IntervalSeconds             int                         `json:"interval_seconds"`
+	NotificationEmails          []string                    `json:"notification_emails"`
+	SlackWebhook                string                      `json:"slack_webhook"`
+	Internal                    bool                        `json:"internal"`
+	ActiveSchedule              []monitor.TimeWindow        `json:"active_schedule"`
+	UptimeAlert                 *monitor.UptimeAlert        `json:"uptime_alert"`
+	ClientCert                  *monitor.ClientCertificate  `json:"client_cert"`
+	RecoveryConfirmChecks       int                         `json:"recovery_confirm_checks"`
+	RecoveryConfirmSeconds      int                         `json:"recovery_confirm_seconds"`
+	ExpectedKeyword             string                      `json:"expected_keyword"`
+	ExpectedStatusCodes         []int                       `json:"expected_status_codes"`
+	RetryCount                  int                         `json:"retry_count"`
+	RetryDelaySeconds           int                         `json:"retry_delay_seconds"`
+	TimeoutSeconds              int                         `json:"timeout_seconds"`
+	HTTPMethod                  string                      `json:"http_method"`
+	RequestBody                 string                      `json:"request_body"`
+	ContentType                 string                      `json:"content_type"`
+	Headers                     map[string]string           `json:"headers"`
+	CertExpiryWarningDays       int                         `json:"cert_expiry_warning_days"`
+	Auth                        *monitor.Auth               `json:"auth"`
+	GenericWebhook              string                      `json:"generic_webhook"`
+	WebhookTemplate             string                      `json:"webhook_template"`
+	TelegramChatID              string                      `json:"telegram_chat_id"`
+	NotificationThrottleSeconds *int                        `json:"notification_throttle_seconds"`
+	NotifyOn                    string                      `json:"notify_on"`
+	Tags                        []string                    `json:"tags"`
+	MaintenanceWindows          []monitor.MaintenanceWindow `json:"maintenance_windows"`
+	CheckType                   string                      `json:"check_type"`
+	DNSRecordType               string                      `json:"dns_record_type"`
+	ExpectedDNSValue            string                      `json:"expected_dns_value"`
+	FollowRedirects             *bool                       `json:"follow_redirects"`
+	MaxRedirects                int                         `json:"max_redirects"`
+	MaxResponseTimeMs           int                         `json:"max_response_time_ms"`
+	ProxyURL                    string                      `json:"proxy_url"`
+	MaxBodyBytes                int64                       `json:"max_body_bytes"`
+	MinResponseBytes            int64                       `json:"min_response_bytes"`
+	MaxResponseBytes            int64                       `json:"max_response_bytes"`
+	PagerDuty                   bool                        `json:"pagerduty"`
+	TeamsWebhook                string                      `json:"teams_webhook"`
+	EscalationAfterSeconds      int                         `json:"escalation_after_seconds"`
+	InsecureSkipTLSVerify       bool                        `json:"insecure_skip_tls_verify"`
+	ExpectedBodyRegex           string                      `json:"expected_body_regex"`
+	JSONAssertions              []monitor.JSONAssertion     `json:"json_assertions"`
+	RequireHTTP2                bool                        `json:"require_http2"`
+	IPVersion                   string                      `json:"ip_version"`
+	BackoffAfterFailures        int                         `json:"backoff_after_failures"`
+	BackoffFactor               float64                     `json:"backoff_factor"`
+	BackoffMaxIntervalSeconds   int                         `json:"backoff_max_interval_seconds"`
+	CaptureOnFailure            bool                        `json:"capture_on_failure"`
+	FailureThreshold            int                         `json:"failure_threshold"`
+	SubChecks                   []monitor.SubCheck          `json:"sub_checks"`
+	RequireSubChecks            bool                        `json:"require_sub_checks"`
+	Verbose                     bool                        `json:"verbose"`
 }
 
-// UpdateWebsiteRequest represents the request to update a website
+// UpdateWebsiteRequest represents the request to update a website: the
+// fields of a new website and whether it is enabled
 type UpdateWebsiteRequest struct {
-	Name              string   `json:"name"`
-	URL               string   `json:"url"`
-	IntervalSeconds   int      `json:"interval_seconds"`
-	NotificationEmails []string `json:"notification_emails"`
-	SlackWebhook      string   `json:"slack_webhook"`
-	Enabled           bool     `json:"enabled"`
+	CreateWebsiteRequest
+	Enabled bool `json:"enabled"`
 }
 
-// GetAll returns all websites
+// GetAll returns the websites matching the optional status, tag and search
+// filters, sorted and optionally paginated, with the total number of matches
+// in the X-Total-Count header
 func (c *WebsiteController) GetAll() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+	page, err := strconv.Atoi(c.GetString("page", "1"))
+	if err != nil || page < 1 {
+		c.jsonError(400, "page must be a positive number")
+		return
+	}
 
-	websites := c.MonitorEngine.GetAllWebsites()
-	var response []WebsiteResponse
+	// A page size of 0 returns every matching website
+	pageSize, err := strconv.Atoi(c.GetString("page_size", "0"))
+	if err != nil || pageSize < 0 {
+		c.jsonError(400, "page_size must be a positive number")
+		return
+	}
+
+	status := c.GetString("status")
+	switch status {
+	case "", monitor.StatusUp, monitor.StatusDown, monitor.StatusDegraded, monitor.StatusFlapping, monitor.StatusError, monitor.StatusUnknown:
+	default:
+		c.jsonError(400, "status must be one of up, down, degraded, flapping, error, unknown")
+		return
+	}
+
+	sortBy := c.GetString("sort", "name")
+	switch sortBy {
+	case "name", "uptime", "response_time":
+	default:
+		c.jsonError(400, "sort must be one of name, uptime, response_time")
+		return
+	}
+
+	descending := false
+	switch c.GetString("order", "asc") {
+	case "asc":
+	case "desc":
+		descending = true
+	default:
+		c.jsonError(400, "order must be asc or desc")
+		return
+	}
+
+	includeHistory, err := c.GetBool("include_history", true)
+	if err != nil {
+		c.jsonError(400, "include_history must be true or false")
+		return
+	}
+
+	uptimeMethod := c.GetString("uptime_method", uptimeMethodSamples)
+	if !validUptimeMethod(uptimeMethod) {
+		c.jsonError(400, "uptime_method must be samples or time")
+		return
+	}
 
+	search := strings.ToLower(strings.TrimSpace(c.GetString("search")))
+	tag := strings.ToLower(strings.TrimSpace(c.GetString("tag")))
+
+	// Filter on status, tag and a case-insensitive name or URL match
+	var websites []*monitor.Website
+	c.MonitorEngine.ForEachWebsite(func(website *monitor.Website) {
+		if status != "" && website.CurrentStatus() != status {
+			return
+		}
+		if tag != "" && !hasTag(website, tag) {
+			return
+		}
+		if search != "" && !strings.Contains(strings.ToLower(website.Name), search) &&
+			!strings.Contains(strings.ToLower(website.URL), search) {
+			return
+		}
+		websites = append(websites, website)
+	})
+
+	// Stats are only needed up front to sort on them
+	stats := make(map[string]storage.UptimeStats)
+	if sortBy != "name" {
+		for _, website := range websites {
+			stats[website.ID] = c.uptimeStats(website.ID, uptimeMethod)
+		}
+	}
+
+	sort.Slice(websites, func(i, j int) bool {
+		a, b := websites[i], websites[j]
+		if descending {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "uptime":
+			if stats[a.ID].Uptime24h != stats[b.ID].Uptime24h {
+				return stats[a.ID].Uptime24h < stats[b.ID].Uptime24h
+			}
+		case "response_time":
+			if stats[a.ID].AvgResponseTime24h != stats[b.ID].AvgResponseTime24h {
+				return stats[a.ID].AvgResponseTime24h < stats[b.ID].AvgResponseTime24h
+			}
+		}
+		if !strings.EqualFold(a.Name, b.Name) {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(websites)
+	if pageSize > 0 {
+		start := (page - 1) * pageSize
+		if start > total {
+			start = total
+		}
+		end := start + pageSize
+		if end > total {
+			end = total
+		}
+		websites = websites[start:end]
+	}
+
+	response := make([]WebsiteResponse, 0, len(websites))
 	for _, website := range websites {
-		// Calculate uptime and average response time
-		uptime24h, _ := c.Storage.CalculateUptime(website.ID, 24)
-		uptime30d, _ := c.Storage.CalculateUptime(website.ID, 24*30)
-		avgResponseTime24h, _ := c.Storage.GetAverageResponseTime(website.ID, 24)
-
-		// Load recent history (last 50 checks)
-		history, _ := c.Storage.GetRecentHistory(website.ID, 24) // last 24h
-
-		response = append(response, WebsiteResponse{
-			ID:                website.ID,
-			Name:              website.Name,
-			URL:               website.URL,
-			IntervalSeconds:   website.IntervalSeconds,
-			Status:            website.Status,
-			LastCheckTime:     website.LastCheckTime,
-			LastResponseTime:  website.LastResponseTime,
-			NotificationEmails: website.NotificationEmails,
-			SlackWebhook:      website.SlackWebhook,
-			Enabled:           website.Enabled,
-			Uptime24h:         uptime24h,
-			Uptime30d:         uptime30d,
-			AvgResponseTime24h: avgResponseTime24h,
-			// Add history to response
-			History:           history,
-		})
+		response = append(response, c.buildWebsiteResponse(website, includeHistory, uptimeMethod))
 	}
 
+	// The body stays a plain array for existing clients; the count of all
+	// matches is passed alongside it
+	c.Ctx.Output.Header("X-Total-Count", strconv.Itoa(total))
 	c.Data["json"] = response
 	c.ServeJSON()
 }
 
 // Get returns a specific website by ID
 func (c *WebsiteController) Get() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+	id := c.Ctx.Input.Param(":id")
+	website, exists := c.MonitorEngine.GetWebsite(id)
+
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	uptimeMethod := c.GetString("uptime_method", uptimeMethodSamples)
+	if !validUptimeMethod(uptimeMethod) {
+		c.jsonError(400, "uptime_method must be samples or time")
+		return
+	}
+
+	c.Data["json"] = c.buildWebsiteResponse(website, true, uptimeMethod)
+	c.ServeJSON()
+}
 
+// GetLastFailure returns what the server sent back to the website's last
+// failed check, for websites that set capture_on_failure
+func (c *WebsiteController) GetLastFailure() {
 	id := c.Ctx.Input.Param(":id")
 	website, exists := c.MonitorEngine.GetWebsite(id)
-	
 	if !exists {
-		c.Ctx.Output.SetStatus(404)
-		c.Data["json"] = map[string]string{"error": "Website not found"}
-		c.ServeJSON()
+		c.jsonError(404, "Website not found")
+		return
+	}
+	if !website.CaptureOnFailure {
+		c.jsonError(404, "capture_on_failure is not enabled for this website")
 		return
 	}
 
-	// Calculate uptime and average response time
-	uptime24h, _ := c.Storage.CalculateUptime(website.ID, 24)
-	uptime30d, _ := c.Storage.CalculateUptime(website.ID, 24*30)
-	avgResponseTime24h, _ := c.Storage.GetAverageResponseTime(website.ID, 24)
+	capture, exists := c.MonitorEngine.LastFailure(id)
+	if !exists {
+		c.jsonError(404, "No failed check captured yet")
+		return
+	}
+	c.Data["json"] = capture
+	c.ServeJSON()
+}
 
-	// Load recent history (last 50 checks)
-	history, _ := c.Storage.GetRecentHistory(website.ID, 24)
+// WebsiteStatusResponse is the current state of a website as served by
+// GetStatus, without uptime or history
+type WebsiteStatusResponse struct {
+	ID               string    `json:"id"`
+	Status           string    `json:"status"`
+	StatusStale      bool      `json:"status_stale"`
+	LastCheckTime    time.Time `json:"last_check_time"`
+	LastResponseTime int       `json:"last_response_time_ms"`
+}
 
-	response := WebsiteResponse{
-		ID:                website.ID,
-		Name:              website.Name,
-		URL:               website.URL,
-		IntervalSeconds:   website.IntervalSeconds,
-		Status:            website.Status,
-		LastCheckTime:     website.LastCheckTime,
-		LastResponseTime:  website.LastResponseTime,
-		NotificationEmails: website.NotificationEmails,
-		SlackWebhook:      website.SlackWebhook,
-		Enabled:           website.Enabled,
-		Uptime24h:         uptime24h,
-		Uptime30d:         uptime30d,
-		AvgResponseTime24h: avgResponseTime24h,
-		History:           history,
+// GetStatus returns a website's current status from the engine, without
+// touching storage, for clients that poll often such as status badges
+func (c *WebsiteController) GetStatus() {
+	id := c.Ctx.Input.Param(":id")
+	website, exists := c.MonitorEngine.GetWebsite(id)
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
 	}
 
-	c.Data["json"] = response
+	c.Data["json"] = WebsiteStatusResponse{
+		ID:               website.ID,
+		Status:           website.CurrentStatus(),
+		StatusStale:      website.StatusStale,
+		LastCheckTime:    website.LastCheckTime,
+		LastResponseTime: website.LastResponseTime,
+	}
 	c.ServeJSON()
 }
 
+// Uptime calculation methods selectable with the uptime_method query parameter
+const (
+	uptimeMethodSamples = "samples" // Share of checks that found the website up, the default
+	uptimeMethodTime    = "time"    // Share of time the website was up, see CalculateUptimeWeighted
+)
+
+// validUptimeMethod reports whether method is a known uptime calculation method
+func validUptimeMethod(method string) bool {
+	return method == uptimeMethodSamples || method == uptimeMethodTime
+}
+
+// uptimeStats returns the summary stats of a website with uptime computed by
+// the given method. Time-weighted uptime isn't cached.
+func (c *WebsiteController) uptimeStats(websiteID, method string) storage.UptimeStats {
+	stats, _ := c.Storage.GetUptimeStats(websiteID)
+	if method == uptimeMethodTime {
+		if uptime, err := c.Storage.CalculateUptimeWeighted(websiteID, 24); err == nil {
+			stats.Uptime24h = uptime
+		}
+		if uptime, err := c.Storage.CalculateUptimeWeighted(websiteID, 24*30); err == nil {
+			stats.Uptime30d = uptime
+		}
+	}
+	return stats
+}
+
+// buildWebsiteResponse builds the API response for a website, including
+// uptime computed by uptimeMethod, average response time and, if
+// includeHistory is set, its most recent check results
+func (c *WebsiteController) buildWebsiteResponse(website *monitor.Website, includeHistory bool, uptimeMethod string) WebsiteResponse {
+	// Uptime and average response time, cached briefly by storage
+	stats := c.uptimeStats(website.ID, uptimeMethod)
+
+	// Recent results are kept in memory; longer ranges come from GetHistory
+	var history []storage.HistoryEntry
+	if includeHistory {
+		for _, result := range c.MonitorEngine.RecentResults(website.ID) {
+			history = append(history, storage.NewHistoryEntry(result))
+		}
+	}
+
+	response := WebsiteResponse{
+		ID:                          website.ID,
+		Name:                        website.Name,
+		URL:                         website.URL,
+		IntervalSeconds:             website.IntervalSeconds,
+		Status:                      website.CurrentStatus(),
+		StatusStale:                 website.StatusStale,
+		LastCheckTime:               website.LastCheckTime,
+		LastResponseTime:            website.LastResponseTime,
+		LastError:                   website.LastError,
+		LastErrorCode:               website.LastErrorCode,
+		NotificationEmails:          website.NotificationEmails,
+		SlackWebhook:                redactValue(website.SlackWebhook),
+		Enabled:                     website.Enabled,
+		Internal:                    website.Internal,
+		Uptime24h:                   stats.Uptime24h,
+		Uptime30d:                   stats.Uptime30d,
+		AvgResponseTime24h:          stats.AvgResponseTime24h,
+		ResponseTimeStats24h:        stats.ResponseTime24h,
+		ActiveSchedule:              website.ActiveSchedule,
+		UptimeAlert:                 website.UptimeAlert,
+		ClientCert:                  redactClientCert(website.ClientCert),
+		RecoveryConfirmChecks:       website.RecoveryConfirmChecks,
+		RecoveryConfirmSeconds:      website.RecoveryConfirmSeconds,
+		ExpectedKeyword:             website.ExpectedKeyword,
+		ExpectedStatusCodes:         website.ExpectedStatusCodes,
+		RetryCount:                  website.RetryCount,
+		RetryDelaySeconds:           website.RetryDelaySeconds,
+		TimeoutSeconds:              website.TimeoutSeconds,
+		HTTPMethod:                  website.HTTPMethod,
+		RequestBody:                 website.RequestBody,
+		ContentType:                 website.ContentType,
+		Headers:                     redactHeaders(website.Headers),
+		CertExpiryWarningDays:       website.CertExpiryWarningDays,
+		Auth:                        redactAuth(website.Auth),
+		GenericWebhook:              redactValue(website.GenericWebhook),
+		WebhookTemplate:             website.WebhookTemplate,
+		TelegramChatID:              website.TelegramChatID,
+		NotificationThrottleSeconds: website.NotificationThrottleSeconds,
+		NotifyOn:                    website.NotifyOn,
+		Tags:                        website.Tags,
+		MaintenanceWindows:          website.MaintenanceWindows,
+		CheckType:                   website.CheckType,
+		DNSRecordType:               website.DNSRecordType,
+		ExpectedDNSValue:            website.ExpectedDNSValue,
+		FollowRedirects:             website.FollowRedirects,
+		MaxRedirects:                website.MaxRedirects,
+		FinalURL:                    website.FinalURL,
+		Protocol:                    website.Protocol,
+		RemoteIP:                    website.RemoteIP,
+		MaxResponseTimeMs:           website.MaxResponseTimeMs,
+		ProxyURL:                    redactProxyURL(website.ProxyURL),
+		MaxBodyBytes:                website.MaxBodyBytes,
+		MinResponseBytes:            website.MinResponseBytes,
+		MaxResponseBytes:            website.MaxResponseBytes,
+		PagerDuty:                   website.PagerDuty,
+		TeamsWebhook:                redactValue(website.TeamsWebhook),
+		EscalationAfterSeconds:      website.EscalationAfterSeconds,
+		InsecureSkipTLSVerify:       website.InsecureSkipTLSVerify,
+		TLSUnverified:               website.TLSUnverified,
+		ExpectedBodyRegex:           website.ExpectedBodyRegex,
+		JSONAssertions:              website.JSONAssertions,
+		RequireHTTP2:                website.RequireHTTP2,
+		IPVersion:                   website.IPVersion,
+		BackoffAfterFailures:        website.BackoffAfterFailures,
+		BackoffFactor:               website.BackoffFactor,
+		BackoffMaxIntervalSeconds:   website.BackoffMaxIntervalSeconds,
+		ConsecutiveFailures:         website.ConsecutiveFailures,
+		EffectiveIntervalSeconds:    int(website.EffectiveInterval() / time.Second),
+		CaptureOnFailure:            website.CaptureOnFailure,
+		FailureThreshold:            website.FailureThreshold,
+		SubChecks:                   website.SubChecks,
+		RequireSubChecks:            website.RequireSubChecks,
+		Verbose:                     website.Verbose,
+		History:                     history,
+	}
+
+	// Only HTTPS websites have certificate details
+	if !website.CertExpiresAt.IsZero() {
+		expiresAt := website.CertExpiresAt
+		daysRemaining := website.CertDaysRemaining
+		response.CertExpiresAt = &expiresAt
+		response.CertDaysRemaining = &daysRemaining
+	}
+
+	return response
+}
+
 // Post creates a new website
 func (c *WebsiteController) Post() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+	allowDuplicate, err := c.GetBool("allow_duplicate", false)
+	if err != nil {
+		c.jsonError(400, "allow_duplicate must be true or false")
+		return
+	}
 
 	var request CreateWebsiteRequest
 	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &request); err != nil {
-		c.Ctx.Output.SetStatus(400)
-		c.Data["json"] = map[string]string{"error": "Invalid JSON"}
-		c.ServeJSON()
+		c.jsonError(400, jsonErrorMessage(err, "Invalid JSON"))
 		return
 	}
 
 	// Validate request
-	if request.Name == "" || request.URL == "" {
-		c.Ctx.Output.SetStatus(400)
-		c.Data["json"] = map[string]string{"error": "Name and URL are required"}
-		c.ServeJSON()
+	if err := validateCreateRequest(&request); err != nil {
+		c.jsonError(400, err.Error())
 		return
 	}
 
-	if request.IntervalSeconds < 30 {
-		request.IntervalSeconds = 60 // Default to 60 seconds
+	// Refuse to add the same site twice unless asked to
+	if !allowDuplicate {
+		if existing, exists := c.MonitorEngine.FindWebsiteByURL(request.URL); exists {
+			c.Ctx.Output.SetStatus(409)
+			c.Data["json"] = map[string]string{"error": duplicateURLError, "existing_id": existing.ID}
+			c.ServeJSON()
+			return
+		}
 	}
 
 	// Generate unique ID
-	id := fmt.Sprintf("website_%d", time.Now().UnixNano())
-
-	website := &monitor.Website{
-		ID:                id,
-		Name:              request.Name,
-		URL:               request.URL,
-		IntervalSeconds:   request.IntervalSeconds,
-		Status:            "unknown",
-		LastCheckTime:     time.Time{},
-		LastResponseTime:  0,
-		NotificationEmails: request.NotificationEmails,
-		SlackWebhook:      request.SlackWebhook,
-		Enabled:           true,
-	}
+	id := c.newWebsiteID()
+	website := newWebsite(id, request)
 
 	// Add to monitor engine
 	c.MonitorEngine.AddWebsite(website)
@@ -192,9 +551,7 @@ func (c *WebsiteController) Post() {
 	// Save to storage
 	websites := c.MonitorEngine.GetAllWebsites()
 	if err := c.Storage.SaveWebsites(websites); err != nil {
-		c.Ctx.Output.SetStatus(500)
-		c.Data["json"] = map[string]string{"error": "Failed to save website"}
-		c.ServeJSON()
+		c.jsonError(500, "Failed to save website")
 		return
 	}
 
@@ -202,126 +559,1443 @@ func (c *WebsiteController) Post() {
 	c.ServeJSON()
 }
 
-// Put updates an existing website
-func (c *WebsiteController) Put() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+// BulkCreateResult reports the outcome of one item of a bulk create request
+type BulkCreateResult struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// ExistingID is the website with the same URL when the item was a duplicate
+	ExistingID string `json:"existing_id,omitempty"`
+}
 
-	id := c.Ctx.Input.Param(":id")
-	website, exists := c.MonitorEngine.GetWebsite(id)
-	
-	if !exists {
-		c.Ctx.Output.SetStatus(404)
-		c.Data["json"] = map[string]string{"error": "Website not found"}
-		c.ServeJSON()
+// duplicateURLError reports a website whose URL is already monitored
+const duplicateURLError = "A website with this URL already exists"
+
+// BulkCreate creates several websites from an array of create requests and
+// saves them once. Invalid items and, unless allow_duplicate is set, URLs
+// already monitored are reported and skipped; the valid ones are created
+// regardless.
+func (c *WebsiteController) BulkCreate() {
+	allowDuplicate, err := c.GetBool("allow_duplicate", false)
+	if err != nil {
+		c.jsonError(400, "allow_duplicate must be true or false")
 		return
 	}
 
-	var request UpdateWebsiteRequest
-	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &request); err != nil {
-		c.Ctx.Output.SetStatus(400)
-		c.Data["json"] = map[string]string{"error": "Invalid JSON"}
-		c.ServeJSON()
+	var requests []CreateWebsiteRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &requests); err != nil {
+		c.jsonError(400, jsonErrorMessage(err, "Invalid JSON, expected an array of websites"))
 		return
 	}
 
-	// Update website
-	if request.Name != "" {
-		website.Name = request.Name
-	}
-	if request.URL != "" {
-		website.URL = request.URL
+	if len(requests) == 0 {
+		c.jsonError(400, "At least one website is required")
+		return
 	}
-	if request.IntervalSeconds >= 30 {
-		website.IntervalSeconds = request.IntervalSeconds
+
+	results := make([]BulkCreateResult, 0, len(requests))
+	created := 0
+	for i, request := range requests {
+		result := BulkCreateResult{Index: i, Name: request.Name}
+		if err := validateCreateRequest(&request); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		// Earlier items of the batch are already added, so they count too
+		if !allowDuplicate {
+			if existing, exists := c.MonitorEngine.FindWebsiteByURL(request.URL); exists {
+				result.Error = duplicateURLError
+				result.ExistingID = existing.ID
+				results = append(results, result)
+				continue
+			}
+		}
+
+		website := newWebsite(c.newWebsiteID(), request)
+		c.MonitorEngine.AddWebsite(website)
+
+		result.ID = website.ID
+		result.Success = true
+		results = append(results, result)
+		created++
 	}
-	website.NotificationEmails = request.NotificationEmails
-	website.SlackWebhook = request.SlackWebhook
-	website.Enabled = request.Enabled
 
-	// Save to storage
-	websites := c.MonitorEngine.GetAllWebsites()
-	if err := c.Storage.SaveWebsites(websites); err != nil {
-		c.Ctx.Output.SetStatus(500)
-		c.Data["json"] = map[string]string{"error": "Failed to update website"}
-		c.ServeJSON()
-		return
+	// Save to storage once for the whole batch
+	if created > 0 {
+		if err := c.Storage.SaveWebsites(c.MonitorEngine.GetAllWebsites()); err != nil {
+			c.jsonError(500, "Failed to save websites")
+			return
+		}
+	} else {
+		c.Ctx.Output.SetStatus(400)
 	}
 
-	c.Data["json"] = map[string]string{"message": "Website updated successfully"}
+	c.Data["json"] = map[string]interface{}{
+		"created": created,
+		"failed":  len(requests) - created,
+		"results": results,
+	}
 	c.ServeJSON()
 }
 
-// Delete removes a website
-func (c *WebsiteController) Delete() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+// validateCreateRequest checks a create request, filling in the default check interval
+func validateCreateRequest(request *CreateWebsiteRequest) error {
+	if request.Name == "" || request.URL == "" {
+		return fmt.Errorf("Name and URL are required")
+	}
+
+	if err := validateInterval(request.IntervalSeconds); err != nil {
+		return err
+	}
+	if request.IntervalSeconds == 0 {
+		request.IntervalSeconds = defaultIntervalSeconds
+	}
+
+	if err := validateSchedule(request.ActiveSchedule); err != nil {
+		return err
+	}
+
+	if err := validateMaintenanceWindows(request.MaintenanceWindows); err != nil {
+		return err
+	}
+
+	if err := monitor.ValidateCheckType(strings.ToLower(request.CheckType), request.URL); err != nil {
+		return err
+	}
+
+	if err := monitor.ValidateDNSRecordType(request.DNSRecordType); err != nil {
+		return err
+	}
+
+	if err := validateProxy(request.ProxyURL, request.CheckType); err != nil {
+		return err
+	}
+
+	if err := validateRequireHTTP2(request.RequireHTTP2, request.URL, request.CheckType); err != nil {
+		return err
+	}
+
+	if request.CaptureOnFailure && request.CheckType != "" && !strings.EqualFold(request.CheckType, monitor.CheckTypeHTTP) {
+		return fmt.Errorf("capture_on_failure is only supported for http checks")
+	}
+
+	if err := validateFailureThreshold(request.FailureThreshold); err != nil {
+		return err
+	}
+
+	if err := validateSubChecks(request.SubChecks, request.CheckType); err != nil {
+		return err
+	}
+
+	if err := monitor.ValidateIPVersion(request.IPVersion, request.CheckType, request.ProxyURL); err != nil {
+		return err
+	}
+
+	if err := validateBackoff(request.BackoffAfterFailures, request.BackoffFactor, request.BackoffMaxIntervalSeconds); err != nil {
+		return err
+	}
+
+	if err := validateUptimeAlert(request.UptimeAlert); err != nil {
+		return err
+	}
+
+	if err := validateStatusCodes(request.ExpectedStatusCodes); err != nil {
+		return err
+	}
+
+	if err := validateHTTPMethod(request.HTTPMethod); err != nil {
+		return err
+	}
+
+	if err := validateBodyCheck(request.HTTPMethod, request.ExpectedKeyword, request.ExpectedBodyRegex, request.MaxBodyBytes); err != nil {
+		return err
+	}
+
+	if err := monitor.ValidateResponseSize(request.HTTPMethod, request.MinResponseBytes, request.MaxResponseBytes, request.MaxBodyBytes); err != nil {
+		return err
+	}
+
+	if err := validateJSONAssertions(request.HTTPMethod, request.JSONAssertions); err != nil {
+		return err
+	}
+
+	if err := validateHeaders(request.Headers); err != nil {
+		return err
+	}
+
+	if err := validateClientCert(request.ClientCert); err != nil {
+		return err
+	}
+
+	if err := validateAuth(request.Auth); err != nil {
+		return err
+	}
+
+	if err := validateWebhook(request.GenericWebhook, request.WebhookTemplate); err != nil {
+		return err
+	}
+
+	if err := validateTeamsWebhook(request.TeamsWebhook); err != nil {
+		return err
+	}
+
+	if request.NotificationThrottleSeconds != nil && *request.NotificationThrottleSeconds < 0 {
+		return fmt.Errorf("notification_throttle_seconds must not be negative")
+	}
+
+	if err := validateEscalation(request.EscalationAfterSeconds); err != nil {
+		return err
+	}
+
+	if err := validateNotifyOn(request.NotifyOn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newWebsiteID generates an ID not used by any website
+func (c *WebsiteController) newWebsiteID() string {
+	for {
+		id := fmt.Sprintf("website_%d", time.Now().UnixNano())
+		if _, exists := c.MonitorEngine.GetWebsite(id); !exists {
+			return id
+		}
+	}
+}
+
+// newWebsite creates an enabled website from a validated create request
+func newWebsite(id string, request CreateWebsiteRequest) *monitor.Website {
+	website := &monitor.Website{
+		ID:                          id,
+		Name:                        request.Name,
+		URL:                         request.URL,
+		IntervalSeconds:             request.IntervalSeconds,
+		Status:                      monitor.StatusUnknown,
+		LastCheckTime:               time.Time{},
+		LastResponseTime:            0,
+		NotificationEmails:          request.NotificationEmails,
+		SlackWebhook:                request.SlackWebhook,
+		Enabled:                     true,
+		Internal:                    request.Internal,
+		ActiveSchedule:              request.ActiveSchedule,
+		UptimeAlert:                 request.UptimeAlert,
+		ClientCert:                  request.ClientCert,
+		RecoveryConfirmChecks:       clampNonNegative(request.RecoveryConfirmChecks),
+		RecoveryConfirmSeconds:      clampNonNegative(request.RecoveryConfirmSeconds),
+		ExpectedKeyword:             request.ExpectedKeyword,
+		ExpectedStatusCodes:         request.ExpectedStatusCodes,
+		RetryCount:                  clampNonNegative(request.RetryCount),
+		RetryDelaySeconds:           clampNonNegative(request.RetryDelaySeconds),
+		TimeoutSeconds:              clampNonNegative(request.TimeoutSeconds),
+		HTTPMethod:                  strings.ToUpper(request.HTTPMethod),
+		RequestBody:                 request.RequestBody,
+		ContentType:                 request.ContentType,
+		Headers:                     request.Headers,
+		CertExpiryWarningDays:       clampNonNegative(request.CertExpiryWarningDays),
+		Auth:                        request.Auth,
+		GenericWebhook:              request.GenericWebhook,
+		WebhookTemplate:             request.WebhookTemplate,
+		TelegramChatID:              request.TelegramChatID,
+		NotificationThrottleSeconds: request.NotificationThrottleSeconds,
+		NotifyOn:                    strings.ToLower(request.NotifyOn),
+		Tags:                        normalizeTags(request.Tags),
+		MaintenanceWindows:          request.MaintenanceWindows,
+		CheckType:                   strings.ToLower(request.CheckType),
+		DNSRecordType:               strings.ToUpper(request.DNSRecordType),
+		ExpectedDNSValue:            request.ExpectedDNSValue,
+		FollowRedirects:             request.FollowRedirects,
+		MaxRedirects:                clampNonNegative(request.MaxRedirects),
+		MaxResponseTimeMs:           clampNonNegative(request.MaxResponseTimeMs),
+		ProxyURL:                    request.ProxyURL,
+		MaxBodyBytes:                request.MaxBodyBytes,
+		MinResponseBytes:            request.MinResponseBytes,
+		MaxResponseBytes:            request.MaxResponseBytes,
+		PagerDuty:                   request.PagerDuty,
+		TeamsWebhook:                request.TeamsWebhook,
+		EscalationAfterSeconds:      request.EscalationAfterSeconds,
+		InsecureSkipTLSVerify:       request.InsecureSkipTLSVerify,
+		ExpectedBodyRegex:           request.ExpectedBodyRegex,
+		JSONAssertions:              request.JSONAssertions,
+		RequireHTTP2:                request.RequireHTTP2,
+		IPVersion:                   strings.ToLower(request.IPVersion),
+		BackoffAfterFailures:        request.BackoffAfterFailures,
+		BackoffFactor:               request.BackoffFactor,
+		BackoffMaxIntervalSeconds:   request.BackoffMaxIntervalSeconds,
+		CaptureOnFailure:            request.CaptureOnFailure,
+		FailureThreshold:            request.FailureThreshold,
+		SubChecks:                   request.SubChecks,
+		RequireSubChecks:            request.RequireSubChecks,
+		Verbose:                     request.Verbose,
+	}
+
+	return website
+}
 
+// Put updates an existing website
+func (c *WebsiteController) Put() {
 	id := c.Ctx.Input.Param(":id")
-	_, exists := c.MonitorEngine.GetWebsite(id)
-	
+	website, exists := c.MonitorEngine.GetWebsite(id)
+
 	if !exists {
-		c.Ctx.Output.SetStatus(404)
-		c.Data["json"] = map[string]string{"error": "Website not found"}
-		c.ServeJSON()
+		c.jsonError(404, "Website not found")
 		return
 	}
 
-	// Remove from monitor engine
-	c.MonitorEngine.RemoveWebsite(id)
+	var request UpdateWebsiteRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &request); err != nil {
+		c.jsonError(400, jsonErrorMessage(err, "Invalid JSON"))
+		return
+	}
+	// Fields left out of the body keep their current values
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &present); err != nil {
+		c.jsonError(400, "Invalid JSON")
+		return
+	}
+	keepAbsentFields(&request, website, present)
 
-	// Delete history
-	c.Storage.DeleteWebsiteHistory(id)
+	// An empty name, URL or interval keeps the current one
+	if request.Name == "" {
+		request.Name = website.Name
+	}
+	if request.URL == "" {
+		request.URL = website.URL
+	}
+	if request.IntervalSeconds == 0 {
+		request.IntervalSeconds = website.IntervalSeconds
+	}
 
-	// Save to storage
-	websites := c.MonitorEngine.GetAllWebsites()
-	if err := c.Storage.SaveWebsites(websites); err != nil {
-		c.Ctx.Output.SetStatus(500)
-		c.Data["json"] = map[string]string{"error": "Failed to delete website"}
-		c.ServeJSON()
-		return
+	// Redacted secrets, as read back from the API, mean "keep the current ones"
+	if request.ProxyURL != "" && request.ProxyURL == redactProxyURL(website.ProxyURL) {
+		request.ProxyURL = website.ProxyURL
+	}
+	request.Headers = keepRedactedHeaders(request.Headers, website.Headers)
+	if request.ClientCert != nil && request.ClientCert.KeyPEM == redacted && website.ClientCert != nil {
+		request.ClientCert.KeyPEM = website.ClientCert.KeyPEM
 	}
+	keepRedactedAuth(request.Auth, website.Auth)
+	request.SlackWebhook = keepRedactedValue(request.SlackWebhook, website.SlackWebhook)
+	request.GenericWebhook = keepRedactedValue(request.GenericWebhook, website.GenericWebhook)
+	request.TeamsWebhook = keepRedactedValue(request.TeamsWebhook, website.TeamsWebhook)
 
-	c.Data["json"] = map[string]string{"message": "Website deleted successfully"}
-	c.ServeJSON()
-}
+	// Updates follow the same rules as new websites
+	if err := validateCreateRequest(&request.CreateWebsiteRequest); err != nil {
+		c.jsonError(400, err.Error())
+		return
+	}
 
-// GetHistory returns history for a website
-func (c *WebsiteController) GetHistory() {
-	// Enable CORS
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
+	// Update a copy of the website that replaces it, so running checks don't
+	// see a half-updated configuration
+	updated := c.MonitorEngine.UpdateWebsite(id, func(website *monitor.Website) {
+		website.Name = request.Name
+		website.URL = request.URL
+		website.IntervalSeconds = request.IntervalSeconds
+		website.NotificationEmails = request.NotificationEmails
+		website.SlackWebhook = request.SlackWebhook
+		website.Internal = request.Internal
+		website.ActiveSchedule = request.ActiveSchedule
+		website.UptimeAlert = request.UptimeAlert
+		website.ClientCert = request.ClientCert
+		website.RecoveryConfirmChecks = clampNonNegative(request.RecoveryConfirmChecks)
+		website.RecoveryConfirmSeconds = clampNonNegative(request.RecoveryConfirmSeconds)
+		website.ExpectedKeyword = request.ExpectedKeyword
+		website.ExpectedStatusCodes = request.ExpectedStatusCodes
+		website.RetryCount = clampNonNegative(request.RetryCount)
+		website.RetryDelaySeconds = clampNonNegative(request.RetryDelaySeconds)
+		website.TimeoutSeconds = clampNonNegative(request.TimeoutSeconds)
+		website.HTTPMethod = strings.ToUpper(request.HTTPMethod)
+		website.RequestBody = request.RequestBody
+		website.ContentType = request.ContentType
+		website.Headers = request.Headers
+		website.CertExpiryWarningDays = clampNonNegative(request.CertExpiryWarningDays)
+		website.Auth = request.Auth
+		website.GenericWebhook = request.GenericWebhook
+		website.WebhookTemplate = request.WebhookTemplate
+		website.TelegramChatID = request.TelegramChatID
+		website.NotificationThrottleSeconds = request.NotificationThrottleSeconds
+		website.NotifyOn = strings.ToLower(request.NotifyOn)
+		website.Tags = normalizeTags(request.Tags)
+		website.MaintenanceWindows = request.MaintenanceWindows
+		website.CheckType = strings.ToLower(request.CheckType)
+		website.DNSRecordType = strings.ToUpper(request.DNSRecordType)
+		website.ExpectedDNSValue = request.ExpectedDNSValue
+		website.FollowRedirects = request.FollowRedirects
+		website.MaxRedirects = clampNonNegative(request.MaxRedirects)
+		website.MaxResponseTimeMs = clampNonNegative(request.MaxResponseTimeMs)
+		website.ProxyURL = request.ProxyURL
+		website.MaxBodyBytes = request.MaxBodyBytes
+		website.MinResponseBytes = request.MinResponseBytes
+		website.MaxResponseBytes = request.MaxResponseBytes
+		website.PagerDuty = request.PagerDuty
+		website.TeamsWebhook = request.TeamsWebhook
+		website.EscalationAfterSeconds = request.EscalationAfterSeconds
+		website.InsecureSkipTLSVerify = request.InsecureSkipTLSVerify
+		website.ExpectedBodyRegex = request.ExpectedBodyRegex
+		website.JSONAssertions = request.JSONAssertions
+		website.RequireHTTP2 = request.RequireHTTP2
+		website.IPVersion = strings.ToLower(request.IPVersion)
+		website.BackoffAfterFailures = request.BackoffAfterFailures
+		website.BackoffFactor = request.BackoffFactor
+		website.BackoffMaxIntervalSeconds = request.BackoffMaxIntervalSeconds
+		website.CaptureOnFailure = request.CaptureOnFailure
+		website.FailureThreshold = request.FailureThreshold
+		website.SubChecks = request.SubChecks
+		website.RequireSubChecks = request.RequireSubChecks
+		website.Verbose = request.Verbose
+	})
+	if !updated {
+		c.jsonError(404, "Website not found")
+		return
+	}
 
-	id := c.Ctx.Input.Param(":id")
-	hoursStr := c.GetString("hours", "24")
-	
-	hours, err := strconv.Atoi(hoursStr)
-	if err != nil || hours < 1 {
-		hours = 24
+	// Add the website to or remove it from the check schedule
+	if request.Enabled {
+		c.MonitorEngine.ResumeWebsite(id)
+	} else {
+		c.MonitorEngine.PauseWebsite(id)
+		c.NotificationManager.StopEscalation(id)
 	}
+	c.MonitorEngine.WebsiteChanged(id)
 
-	history, err := c.Storage.GetRecentHistory(id, hours)
-	if err != nil {
-		c.Ctx.Output.SetStatus(500)
-		c.Data["json"] = map[string]string{"error": "Failed to get history"}
-		c.ServeJSON()
+	// Save to storage
+	websites := c.MonitorEngine.GetAllWebsites()
+	if err := c.Storage.SaveWebsites(websites); err != nil {
+		c.jsonError(500, "Failed to update website")
 		return
 	}
 
-	c.Data["json"] = history
+	c.Data["json"] = map[string]string{"message": "Website updated successfully"}
 	c.ServeJSON()
 }
 
-// Options handles CORS preflight requests
-func (c *WebsiteController) Options() {
-	c.Ctx.Output.Header("Access-Control-Allow-Origin", "*")
-	c.Ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	c.Ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type")
-	c.Ctx.Output.SetStatus(200)
-}
+// keepAbsentFields fills the fields of an update request that are missing from
+// its body with the website's current values, so a partial update, such as the
+// dashboard's edit form, leaves the rest of the configuration alone
+func keepAbsentFields(request *UpdateWebsiteRequest, website *monitor.Website, present map[string]json.RawMessage) {
+	keep := func(field string) bool {
+		_, ok := present[field]
+		return !ok
+	}
 
+	if keep("name") {
+		request.Name = website.Name
+	}
+	if keep("url") {
+		request.URL = website.URL
+	}
+	if keep("interval_seconds") {
+		request.IntervalSeconds = website.IntervalSeconds
+	}
+	if keep("notification_emails") {
+		request.NotificationEmails = website.NotificationEmails
+	}
+	if keep("slack_webhook") {
+		request.SlackWebhook = website.SlackWebhook
+	}
+	if keep("enabled") {
+		request.Enabled = website.Enabled
+	}
+	if keep("internal") {
+		request.Internal = website.Internal
+	}
+	if keep("active_schedule") {
+		request.ActiveSchedule = website.ActiveSchedule
+	}
+	if keep("uptime_alert") {
+		request.UptimeAlert = website.UptimeAlert
+	}
+	if keep("client_cert") {
+		request.ClientCert = website.ClientCert
+	}
+	if keep("recovery_confirm_checks") {
+		request.RecoveryConfirmChecks = website.RecoveryConfirmChecks
+	}
+	if keep("recovery_confirm_seconds") {
+		request.RecoveryConfirmSeconds = website.RecoveryConfirmSeconds
+	}
+	if keep("expected_keyword") {
+		request.ExpectedKeyword = website.ExpectedKeyword
+	}
+	if keep("expected_status_codes") {
+		request.ExpectedStatusCodes = website.ExpectedStatusCodes
+	}
+	if keep("retry_count") {
+		request.RetryCount = website.RetryCount
+	}
+	if keep("retry_delay_seconds") {
+		request.RetryDelaySeconds = website.RetryDelaySeconds
+	}
+	if keep("timeout_seconds") {
+		request.TimeoutSeconds = website.TimeoutSeconds
+	}
+	if keep("http_method") {
+		request.HTTPMethod = website.HTTPMethod
+	}
+	if keep("request_body") {
+		request.RequestBody = website.RequestBody
+	}
+	if keep("content_type") {
+		request.ContentType = website.ContentType
+	}
+	if keep("headers") {
+		request.Headers = website.Headers
+	}
+	if keep("cert_expiry_warning_days") {
+		request.CertExpiryWarningDays = website.CertExpiryWarningDays
+	}
+	if keep("auth") && website.Auth != nil {
+		// Copied, since validation normalizes it
+		auth := *website.Auth
+		request.Auth = &auth
+	}
+	if keep("generic_webhook") {
+		request.GenericWebhook = website.GenericWebhook
+	}
+	if keep("webhook_template") {
+		request.WebhookTemplate = website.WebhookTemplate
+	}
+	if keep("telegram_chat_id") {
+		request.TelegramChatID = website.TelegramChatID
+	}
+	if keep("notification_throttle_seconds") {
+		request.NotificationThrottleSeconds = website.NotificationThrottleSeconds
+	}
+	if keep("notify_on") {
+		request.NotifyOn = website.NotifyOn
+	}
+	if keep("tags") {
+		request.Tags = website.Tags
+	}
+	if keep("maintenance_windows") {
+		request.MaintenanceWindows = website.MaintenanceWindows
+	}
+	if keep("check_type") {
+		request.CheckType = website.CheckType
+	}
+	if keep("dns_record_type") {
+		request.DNSRecordType = website.DNSRecordType
+	}
+	if keep("expected_dns_value") {
+		request.ExpectedDNSValue = website.ExpectedDNSValue
+	}
+	if keep("follow_redirects") {
+		request.FollowRedirects = website.FollowRedirects
+	}
+	if keep("max_redirects") {
+		request.MaxRedirects = website.MaxRedirects
+	}
+	if keep("max_response_time_ms") {
+		request.MaxResponseTimeMs = website.MaxResponseTimeMs
+	}
+	if keep("proxy_url") {
+		request.ProxyURL = website.ProxyURL
+	}
+	if keep("max_body_bytes") {
+		request.MaxBodyBytes = website.MaxBodyBytes
+	}
+	if keep("min_response_bytes") {
+		request.MinResponseBytes = website.MinResponseBytes
+	}
+	if keep("max_response_bytes") {
+		request.MaxResponseBytes = website.MaxResponseBytes
+	}
+	if keep("pagerduty") {
+		request.PagerDuty = website.PagerDuty
+	}
+	if keep("teams_webhook") {
+		request.TeamsWebhook = website.TeamsWebhook
+	}
+	if keep("escalation_after_seconds") {
+		request.EscalationAfterSeconds = website.EscalationAfterSeconds
+	}
+	if keep("insecure_skip_tls_verify") {
+		request.InsecureSkipTLSVerify = website.InsecureSkipTLSVerify
+	}
+	if keep("expected_body_regex") {
+		request.ExpectedBodyRegex = website.ExpectedBodyRegex
+	}
+	if keep("json_assertions") {
+		request.JSONAssertions = website.JSONAssertions
+	}
+	if keep("require_http2") {
+		request.RequireHTTP2 = website.RequireHTTP2
+	}
+	if keep("ip_version") {
+		request.IPVersion = website.IPVersion
+	}
+	if keep("backoff_after_failures") {
+		request.BackoffAfterFailures = website.BackoffAfterFailures
+	}
+	if keep("backoff_factor") {
+		request.BackoffFactor = website.BackoffFactor
+	}
+	if keep("backoff_max_interval_seconds") {
+		request.BackoffMaxIntervalSeconds = website.BackoffMaxIntervalSeconds
+	}
+	if keep("capture_on_failure") {
+		request.CaptureOnFailure = website.CaptureOnFailure
+	}
+	if keep("failure_threshold") {
+		request.FailureThreshold = website.FailureThreshold
+	}
+	if keep("sub_checks") {
+		request.SubChecks = website.SubChecks
+	}
+	if keep("require_sub_checks") {
+		request.RequireSubChecks = website.RequireSubChecks
+	}
+	if keep("verbose") {
+		request.Verbose = website.Verbose
+	}
+}
+
+// Delete removes a website
+func (c *WebsiteController) Delete() {
+	id := c.Ctx.Input.Param(":id")
+	_, exists := c.MonitorEngine.GetWebsite(id)
+
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	// Remove from monitor engine
+	c.MonitorEngine.RemoveWebsite(id)
+	c.NotificationManager.StopEscalation(id)
+
+	// Delete history
+	c.Storage.DeleteWebsiteHistory(id)
+	c.Storage.DeleteNotificationLog(id)
+
+	// Save to storage
+	websites := c.MonitorEngine.GetAllWebsites()
+	if err := c.Storage.SaveWebsites(websites); err != nil {
+		c.jsonError(500, "Failed to delete website")
+		return
+	}
+
+	c.Data["json"] = map[string]string{"message": "Website deleted successfully"}
+	c.ServeJSON()
+}
+
+// Pause stops monitoring a website without deleting it or its history
+func (c *WebsiteController) Pause() {
+	c.setEnabled(false, "Website paused successfully")
+}
+
+// Resume restarts monitoring of a paused website
+func (c *WebsiteController) Resume() {
+	c.setEnabled(true, "Website resumed successfully")
+}
+
+// setEnabled pauses or resumes a website and persists the change
+func (c *WebsiteController) setEnabled(enabled bool, message string) {
+	id := c.Ctx.Input.Param(":id")
+
+	var exists bool
+	if enabled {
+		exists = c.MonitorEngine.ResumeWebsite(id)
+	} else {
+		exists = c.MonitorEngine.PauseWebsite(id)
+	}
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+	if !enabled {
+		c.NotificationManager.StopEscalation(id)
+	}
+
+	// Save to storage
+	websites := c.MonitorEngine.GetAllWebsites()
+	if err := c.Storage.SaveWebsites(websites); err != nil {
+		c.jsonError(500, "Failed to update website")
+		return
+	}
+
+	c.Data["json"] = map[string]string{"message": message}
+	c.ServeJSON()
+}
+
+// TestNotification sends a test notification for a website through every
+// configured channel and reports the result of each
+func (c *WebsiteController) TestNotification() {
+	id := c.Ctx.Input.Param(":id")
+	website, exists := c.MonitorEngine.GetWebsite(id)
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	event := notification.StatusChangeEvent{
+		WebsiteID:       website.ID,
+		WebsiteName:     website.Name,
+		WebsiteURL:      website.URL,
+		OldStatus:       website.Status,
+		NewStatus:       website.Status,
+		ResponseTime:    website.LastResponseTime,
+		Timestamp:       time.Now(),
+		Emails:          website.NotificationEmails,
+		SlackWebhook:    website.SlackWebhook,
+		Webhook:         website.GenericWebhook,
+		WebhookTemplate: website.WebhookTemplate,
+		TelegramChatID:  website.TelegramChatID,
+		TeamsWebhook:    website.TeamsWebhook,
+		PagerDuty:       website.PagerDuty,
+		Reason:          "Test notification sent from the uptime monitor",
+		EventType:       notification.EventTest,
+	}
+
+	results := []TestNotificationResult{}
+	for _, attempt := range c.NotificationManager.SendTest(event) {
+		result := TestNotificationResult{Channel: attempt.Channel, Success: attempt.Err == nil}
+		if attempt.Err != nil {
+			result.Error = attempt.Err.Error()
+		}
+		results = append(results, result)
+	}
+
+	c.Data["json"] = map[string]interface{}{"results": results}
+	c.ServeJSON()
+}
+
+// GetNotifications returns the notification delivery log of a website
+func (c *WebsiteController) GetNotifications() {
+	id := c.Ctx.Input.Param(":id")
+	if _, exists := c.MonitorEngine.GetWebsite(id); !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	entries, err := c.Storage.LoadNotificationLog(id)
+	if err != nil {
+		c.jsonError(500, "Failed to get notification log")
+		return
+	}
+
+	c.Data["json"] = entries
+	c.ServeJSON()
+}
+
+// GetOutages returns the outages of a website over the last days (30 by
+// default) with their count and total downtime
+func (c *WebsiteController) GetOutages() {
+	id := c.Ctx.Input.Param(":id")
+	if _, exists := c.MonitorEngine.GetWebsite(id); !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	days, err := strconv.Atoi(c.GetString("days", "30"))
+	if err != nil || days < 1 || days > 3650 {
+		c.jsonError(400, "days must be between 1 and 3650")
+		return
+	}
+
+	outages, err := c.Storage.GetOutages(id, days)
+	if err != nil {
+		c.jsonError(500, "Failed to get outages")
+		return
+	}
+
+	var totalDowntime int64
+	for _, outage := range outages {
+		totalDowntime += outage.DurationSeconds
+	}
+
+	c.Data["json"] = map[string]interface{}{
+		"website_id":             id,
+		"days":                   days,
+		"count":                  len(outages),
+		"total_downtime_seconds": totalDowntime,
+		"outages":                outages,
+	}
+	c.ServeJSON()
+}
+
+// GetHistory returns history for a website
+func (c *WebsiteController) GetHistory() {
+	id := c.Ctx.Input.Param(":id")
+
+	if c.GetString("format") == "csv" {
+		c.serveHistoryCSV(id)
+		return
+	}
+
+	hoursStr := c.GetString("hours", "24")
+
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil || hours < 1 {
+		hours = 24
+	}
+
+	// Summarize the history in buckets of this many minutes
+	if bucketStr := c.GetString("bucket"); bucketStr != "" {
+		bucketMinutes, err := strconv.Atoi(bucketStr)
+		if err != nil || bucketMinutes < 1 {
+			c.jsonError(400, "bucket must be a positive number of minutes")
+			return
+		}
+
+		buckets, err := c.Storage.GetAggregatedHistory(id, hours, bucketMinutes)
+		if err != nil {
+			c.jsonError(500, "Failed to get history")
+			return
+		}
+
+		c.Data["json"] = buckets
+		c.ServeJSON()
+		return
+	}
+
+	history, err := c.Storage.GetRecentHistory(id, hours)
+	if err != nil {
+		c.jsonError(500, "Failed to get history")
+		return
+	}
+
+	c.Data["json"] = history
+	c.ServeJSON()
+}
+
+// serveHistoryCSV streams a website's history as a CSV download. Without an
+// hours parameter the whole history is exported.
+func (c *WebsiteController) serveHistoryCSV(id string) {
+	if _, exists := c.MonitorEngine.GetWebsite(id); !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	var cutoff time.Time
+	if hours, err := strconv.Atoi(c.GetString("hours")); err == nil && hours > 0 {
+		cutoff = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+
+	c.Ctx.Output.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"history_%s.csv\"", id))
+
+	// The CSV writer buffers its output, so rows reach the client in chunks as they are read
+	writer := csv.NewWriter(c.Ctx.ResponseWriter)
+	writer.Write([]string{"timestamp", "status", "response_time_ms", "maintenance", "status_code", "error", "error_code", "tls_unverified", "protocol", "agent", "response_bytes", "remote_ip", "dns_ms", "connect_ms", "tls_ms", "ttfb_ms"})
+
+	err := c.Storage.StreamHistory(id, cutoff, func(entry storage.HistoryEntry) error {
+		statusCode := ""
+		if entry.StatusCode != 0 {
+			statusCode = strconv.Itoa(entry.StatusCode)
+		}
+		responseBytes := ""
+		if entry.ResponseBytes != nil {
+			responseBytes = strconv.FormatInt(*entry.ResponseBytes, 10)
+		}
+		// Entries without timings leave the phase columns empty
+		timings := make([]string, 4)
+		if t := entry.Timings; t != nil {
+			for i, ms := range []int{t.DNS, t.Connect, t.TLS, t.TTFB} {
+				timings[i] = strconv.Itoa(ms)
+			}
+		}
+		return writer.Write([]string{
+			entry.Timestamp.Format(time.RFC3339),
+			entry.Status,
+			strconv.Itoa(entry.ResponseTime),
+			strconv.FormatBool(entry.Maintenance),
+			statusCode,
+			entry.Error,
+			entry.ErrorCode,
+			strconv.FormatBool(entry.TLSUnverified),
+			entry.Protocol,
+			entry.Agent,
+			responseBytes,
+			entry.RemoteIP,
+			timings[0],
+			timings[1],
+			timings[2],
+			timings[3],
+		})
+	})
+	if err != nil {
+		// Report the error as JSON if nothing has been sent yet
+		if !c.Ctx.ResponseWriter.Started {
+			c.Ctx.ResponseWriter.Header().Del("Content-Disposition")
+			c.jsonError(500, "Failed to get history")
+			return
+		}
+		logger.Errorf("Error streaming history for %s: %v", id, err)
+	}
+
+	writer.Flush()
+}
+
+// GetConfig returns the website's configuration as a create request body,
+// with secrets redacted, so it can be recreated elsewhere
+func (c *WebsiteController) GetConfig() {
+	id := c.Ctx.Input.Param(":id")
+	website, exists := c.MonitorEngine.GetWebsite(id)
+
+	if !exists {
+		c.jsonError(404, "Website not found")
+		return
+	}
+
+	c.Data["json"] = buildCreateRequest(website)
+	c.ServeJSON()
+}
+
+// buildCreateRequest converts a website back into the request body that
+// would create it, redacting secrets
+func buildCreateRequest(website *monitor.Website) CreateWebsiteRequest {
+	request := websiteConfig(website)
+	request.SlackWebhook = redactValue(request.SlackWebhook)
+	request.ClientCert = redactClientCert(request.ClientCert)
+	request.Headers = redactHeaders(request.Headers)
+	request.Auth = redactAuth(request.Auth)
+	request.GenericWebhook = redactValue(request.GenericWebhook)
+	request.TeamsWebhook = redactValue(request.TeamsWebhook)
+	request.ProxyURL = redactProxyURL(request.ProxyURL)
+	return request
+}
+
+// websiteConfig converts a website back into the request body that would
+// create it, secrets included
+func websiteConfig(website *monitor.Website) CreateWebsiteRequest {
+	return CreateWebsiteRequest{
+		Name:                        website.Name,
+		URL:                         website.URL,
+		IntervalSeconds:             website.IntervalSeconds,
+		NotificationEmails:          website.NotificationEmails,
+		SlackWebhook:                website.SlackWebhook,
+		Internal:                    website.Internal,
+		ActiveSchedule:              website.ActiveSchedule,
+		UptimeAlert:                 website.UptimeAlert,
+		ClientCert:                  website.ClientCert,
+		RecoveryConfirmChecks:       website.RecoveryConfirmChecks,
+		RecoveryConfirmSeconds:      website.RecoveryConfirmSeconds,
+		ExpectedKeyword:             website.ExpectedKeyword,
+		ExpectedStatusCodes:         website.ExpectedStatusCodes,
+		RetryCount:                  website.RetryCount,
+		RetryDelaySeconds:           website.RetryDelaySeconds,
+		TimeoutSeconds:              website.TimeoutSeconds,
+		HTTPMethod:                  website.HTTPMethod,
+		RequestBody:                 website.RequestBody,
+		ContentType:                 website.ContentType,
+		Headers:                     website.Headers,
+		CertExpiryWarningDays:       website.CertExpiryWarningDays,
+		Auth:                        website.Auth,
+		GenericWebhook:              website.GenericWebhook,
+		WebhookTemplate:             website.WebhookTemplate,
+		TelegramChatID:              website.TelegramChatID,
+		NotificationThrottleSeconds: website.NotificationThrottleSeconds,
+		NotifyOn:                    website.NotifyOn,
+		Tags:                        website.Tags,
+		MaintenanceWindows:          website.MaintenanceWindows,
+		CheckType:                   website.CheckType,
+		DNSRecordType:               website.DNSRecordType,
+		ExpectedDNSValue:            website.ExpectedDNSValue,
+		FollowRedirects:             website.FollowRedirects,
+		MaxRedirects:                website.MaxRedirects,
+		MaxResponseTimeMs:           website.MaxResponseTimeMs,
+		ProxyURL:                    website.ProxyURL,
+		MaxBodyBytes:                website.MaxBodyBytes,
+		MinResponseBytes:            website.MinResponseBytes,
+		MaxResponseBytes:            website.MaxResponseBytes,
+		PagerDuty:                   website.PagerDuty,
+		TeamsWebhook:                website.TeamsWebhook,
+		EscalationAfterSeconds:      website.EscalationAfterSeconds,
+		InsecureSkipTLSVerify:       website.InsecureSkipTLSVerify,
+		ExpectedBodyRegex:           website.ExpectedBodyRegex,
+		JSONAssertions:              website.JSONAssertions,
+		RequireHTTP2:                website.RequireHTTP2,
+		IPVersion:                   website.IPVersion,
+		BackoffAfterFailures:        website.BackoffAfterFailures,
+		BackoffFactor:               website.BackoffFactor,
+		BackoffMaxIntervalSeconds:   website.BackoffMaxIntervalSeconds,
+		CaptureOnFailure:            website.CaptureOnFailure,
+		FailureThreshold:            website.FailureThreshold,
+		SubChecks:                   website.SubChecks,
+		RequireSubChecks:            website.RequireSubChecks,
+		Verbose:                     website.Verbose,
+	}
+}
+
+// CheckAll triggers an immediate check of every enabled website
+func (c *WebsiteController) CheckAll() {
+	job := c.MonitorEngine.CheckAll()
+
+	c.Ctx.Output.SetStatus(202)
+	c.Data["json"] = job
+	c.ServeJSON()
+}
+
+// GetCheckJob returns the progress of a check-all job
+func (c *WebsiteController) GetCheckJob() {
+	job, exists := c.MonitorEngine.GetCheckJob(c.Ctx.Input.Param(":job"))
+	if !exists {
+		c.jsonError(404, "Job not found")
+		return
+	}
+
+	c.Data["json"] = job
+	c.ServeJSON()
+}
+
+// validateSchedule checks every window of an active schedule
+func validateSchedule(schedule []monitor.TimeWindow) error {
+	for i, window := range schedule {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("active_schedule[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// validateMaintenanceWindows checks every maintenance window of a website
+func validateMaintenanceWindows(windows []monitor.MaintenanceWindow) error {
+	for i, window := range windows {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("maintenance_windows[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// validateUptimeAlert checks the window and threshold of an uptime alert rule
+func validateUptimeAlert(rule *monitor.UptimeAlert) error {
+	if rule == nil {
+		return nil
+	}
+	if rule.WindowMinutes < 1 || rule.WindowMinutes > 24*60*30 {
+		return fmt.Errorf("uptime_alert.window_minutes must be between 1 and 43200")
+	}
+	if rule.ThresholdPercent <= 0 || rule.ThresholdPercent > 100 {
+		return fmt.Errorf("uptime_alert.threshold_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// validateStatusCodes checks that every expected status code is a valid HTTP status
+func validateStatusCodes(codes []int) error {
+	for _, code := range codes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("expected_status_codes: %d is not between 100 and 599", code)
+		}
+	}
+	return nil
+}
+
+// allowedHTTPMethods lists the methods a check may use
+var allowedHTTPMethods = map[string]bool{
+	"GET":   true,
+	"POST":  true,
+	"PUT":   true,
+	"HEAD":  true,
+	"PATCH": true,
+}
+
+// validateHTTPMethod checks the check method against the whitelist; empty means GET
+func validateHTTPMethod(method string) error {
+	if method != "" && !allowedHTTPMethods[strings.ToUpper(method)] {
+		return fmt.Errorf("http_method must be one of GET, POST, PUT, HEAD, PATCH")
+	}
+	return nil
+}
+
+// validateBodyCheck checks the response body settings against the check method
+func validateBodyCheck(method, keyword, bodyRegex string, maxBodyBytes int64) error {
+	if maxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must not be negative")
+	}
+	if keyword != "" && strings.EqualFold(method, http.MethodHead) {
+		return fmt.Errorf("expected_keyword can't be checked with http_method HEAD, which returns no body")
+	}
+	if bodyRegex != "" {
+		if strings.EqualFold(method, http.MethodHead) {
+			return fmt.Errorf("expected_body_regex can't be checked with http_method HEAD, which returns no body")
+		}
+		if _, err := regexp.Compile(bodyRegex); err != nil {
+			return fmt.Errorf("expected_body_regex is invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateJSONAssertions checks the paths of JSON body assertions
+func validateJSONAssertions(method string, assertions []monitor.JSONAssertion) error {
+	if len(assertions) > 0 && strings.EqualFold(method, http.MethodHead) {
+		return fmt.Errorf("json_assertions can't be checked with http_method HEAD, which returns no body")
+	}
+	for i, assertion := range assertions {
+		if err := monitor.ValidateJSONPath(assertion.Path); err != nil {
+			return fmt.Errorf("json_assertions[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// forbiddenHeaders are managed by the HTTP client and would break the request if overridden
+var forbiddenHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+}
+
+// validateHeaders checks custom header names and values
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("headers: invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("headers: value of %s must not contain line breaks", name)
+		}
+		if forbiddenHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("headers: %s is set automatically and cannot be overridden", name)
+		}
+		if strings.EqualFold(name, "Host") && value == "" {
+			return fmt.Errorf("headers: Host must not be empty")
+		}
+	}
+	return nil
+}
+
+// isSensitiveHeader reports whether a header usually carries credentials
+func isSensitiveHeader(name string) bool {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	return strings.Contains(lower, "key") || strings.Contains(lower, "token") || strings.Contains(lower, "secret")
+}
+
+// redactHeaders returns a copy of custom headers with credential values redacted
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if isSensitiveHeader(name) {
+			value = redacted
+		}
+		copied[name] = value
+	}
+	return copied
+}
+
+// keepRedactedHeaders returns updated custom headers, as read back from the
+// API, with redacted values replaced by the current ones. A redacted header
+// without a current value is dropped rather than sent as the placeholder.
+func keepRedactedHeaders(headers, current map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	merged := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if value == redacted {
+			var found bool
+			value, found = headerValue(current, name)
+			if !found {
+				continue
+			}
+		}
+		merged[name] = value
+	}
+	return merged
+}
+
+// headerValue looks a header up by its case-insensitive name
+func headerValue(headers map[string]string, name string) (string, bool) {
+	if value, found := headers[name]; found {
+		return value, true
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// validateClientCert checks that a client certificate and key can be loaded
+func validateClientCert(cert *monitor.ClientCertificate) error {
+	if cert == nil {
+		return nil
+	}
+	if _, err := cert.Load(); err != nil {
+		return fmt.Errorf("client_cert: %v", err)
+	}
+	return nil
+}
+
+// redactClientCert returns a copy of a client certificate config without the private key
+func redactClientCert(cert *monitor.ClientCertificate) *monitor.ClientCertificate {
+	if cert == nil {
+		return nil
+	}
+	copied := *cert
+	if copied.KeyPEM != "" {
+		copied.KeyPEM = redacted
+	}
+	return &copied
+}
+
+// validateAuth checks the auth type and its required credentials
+func validateAuth(auth *monitor.Auth) error {
+	if auth == nil {
+		return nil
+	}
+	auth.Type = strings.ToLower(auth.Type)
+	if err := auth.Validate(); err != nil {
+		return fmt.Errorf("auth: %v", err)
+	}
+	return nil
+}
+
+// keepRedactedAuth replaces redacted credentials of an updated auth config,
+// as read back from the API, with the current ones. Without current ones they
+// are cleared rather than saved as the placeholder.
+func keepRedactedAuth(auth, current *monitor.Auth) {
+	if auth == nil {
+		return
+	}
+	if current == nil {
+		current = &monitor.Auth{}
+	}
+	if auth.Password == redacted {
+		auth.Password = current.Password
+	}
+	if auth.Token == redacted {
+		auth.Token = current.Token
+	}
+}
+
+// redactAuth returns a copy of an auth config with the password and token redacted
+func redactAuth(auth *monitor.Auth) *monitor.Auth {
+	if auth == nil {
+		return nil
+	}
+	copied := *auth
+	if copied.Password != "" {
+		copied.Password = redacted
+	}
+	if copied.Token != "" {
+		copied.Token = redacted
+	}
+	return &copied
+}
+
+// normalizeTags lowercases and trims tags, dropping empty and duplicate ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// hasTag reports whether a website carries the given normalized tag
+func hasTag(website *monitor.Website, tag string) bool {
+	for _, t := range website.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNotifyOn checks the status change direction filter; empty means both
+func validateNotifyOn(notifyOn string) error {
+	switch strings.ToLower(notifyOn) {
+	case "", notification.NotifyOnBoth, notification.NotifyOnDown, notification.NotifyOnUp:
+		return nil
+	}
+	return fmt.Errorf("notify_on must be one of both, down, up")
+}
+
+// validateWebhook checks the generic webhook URL and its body template
+func validateWebhook(webhook, bodyTemplate string) error {
+	if webhook == "" {
+		if bodyTemplate != "" {
+			return fmt.Errorf("webhook_template requires generic_webhook")
+		}
+		return nil
+	}
+	if parsed, err := url.Parse(webhook); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("generic_webhook must be an http or https URL")
+	}
+	if bodyTemplate != "" {
+		if _, err := notification.ParseWebhookTemplate(bodyTemplate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// minEscalationAfterSeconds is the shortest interval between reminders for a
+// website that stays down
+const minEscalationAfterSeconds = 60
+
+// validateEscalation checks the reminder interval; 0 disables reminders
+func validateEscalation(afterSeconds int) error {
+	if afterSeconds != 0 && afterSeconds < minEscalationAfterSeconds {
+		return fmt.Errorf("escalation_after_seconds must be 0 or at least %d", minEscalationAfterSeconds)
+	}
+	return nil
+}
+
+// Bounds of a website's check interval, and the interval of a website created
+// without one
+const (
+	minIntervalSeconds     = 30
+	maxIntervalSeconds     = 24 * 60 * 60
+	defaultIntervalSeconds = 60
+)
+
+// validateInterval checks a website's check interval; 0 means the default on
+// create and the current interval on update
+func validateInterval(seconds int) error {
+	if seconds == 0 {
+		return nil
+	}
+	if seconds < 0 {
+		return fmt.Errorf("interval_seconds must not be negative")
+	}
+	if seconds < minIntervalSeconds || seconds > maxIntervalSeconds {
+		return fmt.Errorf("interval_seconds must be between %d and %d", minIntervalSeconds, maxIntervalSeconds)
+	}
+	return nil
+}
+
+// jsonErrorMessage describes a request body that couldn't be decoded. A
+// non-numeric interval is named, since it is the field most often sent as a
+// string; any other error gets the fallback message.
+func jsonErrorMessage(err error, fallback string) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && (typeErr.Field == "interval_seconds" || strings.HasSuffix(typeErr.Field, ".interval_seconds")) {
+		return "interval_seconds must be a whole number of seconds"
+	}
+	return fallback
+}
+
+// maxFailureThreshold bounds how many failed checks in a row may be waited
+// for before a website is declared down
+const maxFailureThreshold = 100
+
+// validateFailureThreshold checks a website's failure threshold; 0 declares
+// it down on the first failed check
+func validateFailureThreshold(threshold int) error {
+	if threshold < 0 || threshold > maxFailureThreshold {
+		return fmt.Errorf("failure_threshold must be between 0 and %d", maxFailureThreshold)
+	}
+	return nil
+}
+
+// validateSubChecks checks a website's sub-checks, which only HTTP checks run
+func validateSubChecks(subChecks []monitor.SubCheck, checkType string) error {
+	if len(subChecks) > 0 && checkType != "" && !strings.EqualFold(checkType, monitor.CheckTypeHTTP) {
+		return fmt.Errorf("sub_checks are only supported for http checks")
+	}
+	return monitor.ValidateSubChecks(subChecks)
+}
+
+// maxBackoffFactor bounds how fast the check interval of a failing website grows
+const maxBackoffFactor = 10
+
+// validateBackoff checks a website's backoff settings; 0 leaves each at its
+// default, and backoff is off unless afterFailures is set
+func validateBackoff(afterFailures int, factor float64, maxIntervalSeconds int) error {
+	if afterFailures < 0 {
+		return fmt.Errorf("backoff_after_failures must not be negative")
+	}
+	if factor != 0 && (factor <= 1 || factor > maxBackoffFactor) {
+		return fmt.Errorf("backoff_factor must be above 1 and at most %d", maxBackoffFactor)
+	}
+	if maxIntervalSeconds < 0 {
+		return fmt.Errorf("backoff_max_interval_seconds must not be negative")
+	}
+	return nil
+}
+
+// validateTeamsWebhook checks the Microsoft Teams webhook URL
+func validateTeamsWebhook(webhook string) error {
+	if webhook == "" {
+		return nil
+	}
+	if parsed, err := url.Parse(webhook); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("teams_webhook must be an http or https URL")
+	}
+	return nil
+}
+
+// validateProxy checks a website's proxy URL, which only applies to HTTP checks
+func validateProxy(proxyURL, checkType string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	if checkType != "" && !strings.EqualFold(checkType, monitor.CheckTypeHTTP) {
+		return fmt.Errorf("proxy_url is only supported for http checks")
+	}
+	return monitor.ValidateProxyURL(proxyURL)
+}
+
+// validateRequireHTTP2 checks that require_http2 is only set where HTTP/2 can
+// be negotiated, i.e. on HTTPS URLs checked over HTTP
+func validateRequireHTTP2(require bool, websiteURL, checkType string) error {
+	if !require {
+		return nil
+	}
+	if checkType != "" && !strings.EqualFold(checkType, monitor.CheckTypeHTTP) {
+		return fmt.Errorf("require_http2 is only supported for http checks")
+	}
+	if !strings.HasPrefix(strings.ToLower(websiteURL), "https://") {
+		return fmt.Errorf("require_http2 needs an https:// URL, HTTP/2 is only negotiated over TLS")
+	}
+	return nil
+}
+
+// redactProxyURL redacts the password of a proxy URL, keeping the rest visible
+func redactProxyURL(proxyURL string) string {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.User == nil {
+		return proxyURL
+	}
+	if _, hasPassword := parsed.User.Password(); !hasPassword {
+		return proxyURL
+	}
+	parsed.User = url.User(parsed.User.Username())
+	return strings.Replace(parsed.String(), "@", ":"+redacted+"@", 1)
+}
+
+// redactValue redacts a secret, leaving empty values empty
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// keepRedactedValue returns the current value of a secret that was sent back
+// redacted, as read from the API, and the sent value otherwise
+func keepRedactedValue(value, current string) string {
+	if value == redacted {
+		return current
+	}
+	return value
+}
+
+// clampNonNegative treats negative settings as unset
+func clampNonNegative(value int) int {
+	if value < 0 {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,491 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+
+	"uptime-monitor/monitor"
+	"uptime-monitor/notification"
+	"uptime-monitor/storage"
+)
+
+// decodeUpdate decodes an update request body the way Put does, keeping the
+// website's values for the fields the body leaves out
+func decodeUpdate(t *testing.T, website *monitor.Website, body string) UpdateWebsiteRequest {
+	t.Helper()
+	var request UpdateWebsiteRequest
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatal(err)
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &present); err != nil {
+		t.Fatal(err)
+	}
+	keepAbsentFields(&request, website, present)
+	return request
+}
+
+// newTestController returns a website controller with an empty engine and
+// storage in a temporary directory
+func newTestController(t *testing.T) *WebsiteController {
+	t.Helper()
+	stor, err := storage.NewJSONStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &WebsiteController{
+		MonitorEngine:       monitor.NewMonitorEngine(),
+		Storage:             stor,
+		NotificationManager: notification.NewNotificationManager(notification.NotificationConfig{}),
+	}
+}
+
+// serve runs handler, an action of c, on a request with the given website ID
+// and body, and returns the response
+func serve(c *WebsiteController, handler func(), method, id, body string) *httptest.ResponseRecorder {
+	return serveURL(c, handler, method, "/api/websites/"+id, id, body)
+}
+
+// serveURL is serve for a request to target, which may carry a query
+func serveURL(c *WebsiteController, handler func(), method, target, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(rec, req)
+	ctx.Input.SetParam(":id", id)
+	ctx.Input.RequestBody = []byte(body)
+	c.Init(ctx, "WebsiteController", method, c)
+	handler()
+	return rec
+}
+
+func TestKeepAbsentFields(t *testing.T) {
+	website := &monitor.Website{
+		Name:            "Example",
+		URL:             "https://example.com",
+		IntervalSeconds: 60,
+		Enabled:         false,
+		ExpectedKeyword: "Welcome",
+		RetryCount:      2,
+		Tags:            []string{"prod"},
+		SubChecks:       []monitor.SubCheck{{Path: "/robots.txt"}},
+	}
+
+	// The dashboard's edit form sends only its own fields
+	request := decodeUpdate(t, website, `{"name": "Renamed", "url": "https://example.com", "interval_seconds": 120, "notification_emails": [], "slack_webhook": "", "enabled": true}`)
+	if request.Name != "Renamed" || request.IntervalSeconds != 120 || !request.Enabled {
+		t.Errorf("sent fields not applied: %+v", request)
+	}
+	if request.ExpectedKeyword != "Welcome" || request.RetryCount != 2 {
+		t.Errorf("expected keyword %q and retry count %d, want the current ones", request.ExpectedKeyword, request.RetryCount)
+	}
+	if !reflect.DeepEqual(request.Tags, website.Tags) || !reflect.DeepEqual(request.SubChecks, website.SubChecks) {
+		t.Errorf("tags %v and sub-checks %v, want the current ones", request.Tags, request.SubChecks)
+	}
+
+	// Fields that are sent, even empty, replace the current values
+	request = decodeUpdate(t, website, `{"expected_keyword": "", "tags": []}`)
+	if request.ExpectedKeyword != "" || len(request.Tags) != 0 {
+		t.Errorf("expected keyword %q and tags %v, want them cleared", request.ExpectedKeyword, request.Tags)
+	}
+	if request.Name != "Example" || request.Enabled {
+		t.Errorf("name %q and enabled %v, want the current ones", request.Name, request.Enabled)
+	}
+}
+
+func TestKeepAbsentCheckType(t *testing.T) {
+	website := &monitor.Website{URL: "db.example.com:5432", CheckType: monitor.CheckTypeTCP}
+
+	request := decodeUpdate(t, website, `{"name": "Database", "interval_seconds": 30}`)
+	if request.CheckType != monitor.CheckTypeTCP {
+		t.Errorf("check type = %q, want %q", request.CheckType, monitor.CheckTypeTCP)
+	}
+	if err := monitor.ValidateCheckType(request.CheckType, request.URL); err != nil {
+		t.Errorf("kept check type doesn't validate: %v", err)
+	}
+
+	request = decodeUpdate(t, website, `{"check_type": "http", "url": "https://example.com"}`)
+	if request.CheckType != monitor.CheckTypeHTTP {
+		t.Errorf("check type = %q, want %q", request.CheckType, monitor.CheckTypeHTTP)
+	}
+}
+
+func TestKeepAuth(t *testing.T) {
+	website := &monitor.Website{Auth: &monitor.Auth{Type: monitor.AuthBasic, Username: "admin", Password: "s3cret"}}
+
+	request := decodeUpdate(t, website, `{"name": "Example"}`)
+	if !reflect.DeepEqual(request.Auth, website.Auth) {
+		t.Errorf("auth = %+v, want the current %+v", request.Auth, website.Auth)
+	}
+
+	// Read back from GET, the password is redacted
+	request = decodeUpdate(t, website, `{"auth": {"type": "basic", "username": "root", "password": "[redacted]"}}`)
+	keepRedactedAuth(request.Auth, website.Auth)
+	if request.Auth.Username != "root" || request.Auth.Password != "s3cret" {
+		t.Errorf("auth = %+v, want the new username with the current password", request.Auth)
+	}
+
+	// Without a current password there is nothing to keep
+	request = decodeUpdate(t, &monitor.Website{}, `{"auth": {"type": "bearer", "token": "[redacted]"}}`)
+	keepRedactedAuth(request.Auth, nil)
+	if request.Auth.Token != "" {
+		t.Errorf("token = %q, want the placeholder cleared", request.Auth.Token)
+	}
+
+	request = decodeUpdate(t, website, `{"auth": null}`)
+	if request.Auth != nil {
+		t.Errorf("auth = %+v, want it removed", request.Auth)
+	}
+}
+
+func TestKeepHeaders(t *testing.T) {
+	website := &monitor.Website{Headers: map[string]string{"X-Api-Key": "k3y", "Accept": "text/html"}}
+
+	request := decodeUpdate(t, website, `{"name": "Example"}`)
+	if !reflect.DeepEqual(request.Headers, website.Headers) {
+		t.Errorf("headers = %v, want the current %v", request.Headers, website.Headers)
+	}
+
+	// Read back from GET, the key is redacted; a header without a current
+	// value can't be kept
+	request = decodeUpdate(t, website, `{"headers": {"x-api-key": "[redacted]", "Accept": "application/json", "Authorization": "[redacted]"}}`)
+	headers := keepRedactedHeaders(request.Headers, website.Headers)
+	want := map[string]string{"x-api-key": "k3y", "Accept": "application/json"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %v, want %v", headers, want)
+	}
+	if website.Headers["Accept"] != "text/html" {
+		t.Error("merging changed the current headers")
+	}
+
+	request = decodeUpdate(t, website, `{"headers": {}}`)
+	if headers := keepRedactedHeaders(request.Headers, website.Headers); len(headers) != 0 {
+		t.Errorf("headers = %v, want them removed", headers)
+	}
+}
+
+func TestKeepRedactedWebhook(t *testing.T) {
+	website := &monitor.Website{
+		ID:             "site",
+		SlackWebhook:   "https://hooks.slack.com/services/T000/B000/s3cret",
+		GenericWebhook: "https://hooks.example.com/notify?token=s3cret",
+		TeamsWebhook:   "https://example.webhook.office.com/webhookb2/s3cret",
+	}
+
+	response := newTestController(t).buildWebsiteResponse(website, false, "")
+	if response.SlackWebhook != redacted {
+		t.Errorf("Slack webhook = %q in responses, want it redacted", response.SlackWebhook)
+	}
+	if response.GenericWebhook != redacted {
+		t.Errorf("generic webhook = %q in responses, want it redacted", response.GenericWebhook)
+	}
+	if response.TeamsWebhook != redacted {
+		t.Errorf("Teams webhook = %q in responses, want it redacted", response.TeamsWebhook)
+	}
+	if kept := keepRedactedValue(response.TeamsWebhook, website.TeamsWebhook); kept != website.TeamsWebhook {
+		t.Errorf("redacted Teams webhook kept as %q, want the current URL", kept)
+	}
+
+	if kept := keepRedactedValue(response.GenericWebhook, website.GenericWebhook); kept != website.GenericWebhook {
+		t.Errorf("redacted webhook kept as %q, want the current URL", kept)
+	}
+	if kept := keepRedactedValue("https://hooks.example.com/new", website.GenericWebhook); kept != "https://hooks.example.com/new" {
+		t.Errorf("new webhook kept as %q, want it replaced", kept)
+	}
+}
+
+func TestPutValidatesLikeCreate(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", IntervalSeconds: 60, Enabled: true})
+
+	// Every body that can't create a website can't update one either
+	for _, body := range []string{
+		`{"http_method": "TRACE"}`,
+		`{"expected_status_codes": [42]}`,
+		`{"failure_threshold": -1}`,
+		`{"failure_threshold": 101}`,
+		`{"notify_on": "sometimes"}`,
+		`{"check_type": "tcp"}`,
+		`{"auth": {"type": "basic"}}`,
+		`{"teams_webhook": "ftp://example.com"}`,
+		`{"interval_seconds": -60}`,
+		`{"interval_seconds": 10}`,
+		`{"interval_seconds": 86401}`,
+	} {
+		var create CreateWebsiteRequest
+		json.Unmarshal([]byte(`{"name": "Site", "url": "https://site.example.com"}`), &create)
+		json.Unmarshal([]byte(body), &create)
+		createErr := validateCreateRequest(&create)
+		if createErr == nil {
+			t.Fatalf("%s: creating succeeded, want it rejected", body)
+		}
+
+		rec := serve(c, c.Put, "PUT", "site", body)
+		if rec.Code != 400 {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+			continue
+		}
+		var response map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if response["error"] != createErr.Error() {
+			t.Errorf("%s: error = %q, want %q", body, response["error"], createErr.Error())
+		}
+	}
+
+	// A valid partial update keeps the rest of the website
+	if rec := serve(c, c.Put, "PUT", "site", `{"name": "Renamed", "url": ""}`); rec.Code != 200 {
+		t.Fatalf("status = %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	website, _ := c.MonitorEngine.GetWebsite("site")
+	if website.Name != "Renamed" || website.URL != "https://site.example.com" || website.IntervalSeconds != 60 || !website.Enabled {
+		t.Errorf("website = %+v, want only the name changed", website)
+	}
+}
+
+func TestGetAllReturnsArrayWithTotalCount(t *testing.T) {
+	c := newTestController(t)
+	for _, name := range []string{"Alpha", "Bravo", "Charlie"} {
+		c.MonitorEngine.AddWebsite(&monitor.Website{ID: strings.ToLower(name), Name: name, URL: "https://" + strings.ToLower(name) + ".example.com", Enabled: true})
+	}
+
+	tests := []struct {
+		target    string
+		wantNames []string
+	}{
+		{target: "/api/websites", wantNames: []string{"Alpha", "Bravo", "Charlie"}},
+		{target: "/api/websites?page=2&page_size=2", wantNames: []string{"Charlie"}},
+		{target: "/api/websites?order=desc&page_size=1", wantNames: []string{"Charlie"}},
+	}
+	for _, tt := range tests {
+		rec := serveURL(c, c.GetAll, "GET", tt.target, "", "")
+		if rec.Code != 200 {
+			t.Fatalf("%s: status = %d (%s)", tt.target, rec.Code, rec.Body.String())
+		}
+		var websites []WebsiteResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &websites); err != nil {
+			t.Fatalf("%s: body isn't an array of websites: %v", tt.target, err)
+		}
+		var names []string
+		for _, website := range websites {
+			names = append(names, website.Name)
+		}
+		if !reflect.DeepEqual(names, tt.wantNames) {
+			t.Errorf("%s: websites = %v, want %v", tt.target, names, tt.wantNames)
+		}
+		if total := rec.Header().Get("X-Total-Count"); total != "3" {
+			t.Errorf("%s: X-Total-Count = %q, want 3", tt.target, total)
+		}
+	}
+}
+
+// serveAgent runs handler on a result submission to target, sent with the
+// agent key key, by the agent in the path if agent is set
+func serveAgent(c *WebsiteController, handler func(), target, agent, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(AgentKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(rec, req)
+	ctx.Input.SetParam(":agent", agent)
+	ctx.Input.RequestBody = []byte(body)
+	c.Init(ctx, "WebsiteController", "POST", c)
+	handler()
+	return rec
+}
+
+func TestSubmitResultsRequireAgentKey(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", Enabled: true})
+	single := `{"website_id": "site", "agent": "eu", "status": "down"}`
+	batch := `[{"website_id": "site", "status": "down"}]`
+
+	// Without agent keys nobody may submit results
+	if rec := serveAgent(c, c.SubmitResult, "/api/results", "", "secret", single); rec.Code != 403 {
+		t.Errorf("without agent keys: status = %d, want 403", rec.Code)
+	}
+
+	c.AgentKeys = map[string]string{"eu": "secret", "us": "other"}
+	tests := []struct {
+		name    string
+		handler func()
+		target  string
+		agent   string
+		key     string
+		body    string
+		want    int
+	}{
+		{name: "missing key", handler: c.SubmitResult, target: "/api/results", key: "", body: single, want: 401},
+		{name: "wrong key", handler: c.SubmitResult, target: "/api/results", key: "wrong", body: single, want: 401},
+		{name: "another agent's key", handler: c.SubmitResult, target: "/api/results", key: "other", body: single, want: 401},
+		{name: "unknown agent", handler: c.SubmitResult, target: "/api/results", key: "secret", body: `{"website_id": "site", "agent": "asia", "status": "down"}`, want: 401},
+		// Authorized; the engine isn't running, so the result can't be processed
+		{name: "agent's key", handler: c.SubmitResult, target: "/api/results", key: "secret", body: single, want: 503},
+		{name: "old result", handler: c.SubmitResult, target: "/api/results", key: "secret", body: `{"website_id": "site", "agent": "eu", "status": "down", "timestamp": "2020-01-01T00:00:00Z"}`, want: 400},
+		{name: "batch without key", handler: c.SubmitAgentResults, target: "/api/agents/eu/results", agent: "eu", key: "", body: batch, want: 401},
+		{name: "batch with another agent's key", handler: c.SubmitAgentResults, target: "/api/agents/us/results", agent: "us", key: "secret", body: batch, want: 401},
+		{name: "batch with agent's key", handler: c.SubmitAgentResults, target: "/api/agents/eu/results", agent: "eu", key: "secret", body: batch, want: 503},
+	}
+	for _, tt := range tests {
+		if rec := serveAgent(c, tt.handler, tt.target, tt.agent, tt.key, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status = %d (%s), want %d", tt.name, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestPrepareSelectsNamespace(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", Enabled: true})
+	staging := newTestController(t)
+	c.Namespaces = Namespaces{
+		"":        {MonitorEngine: c.MonitorEngine, Storage: c.Storage, NotificationManager: c.NotificationManager},
+		"staging": {Name: "staging", MonitorEngine: staging.MonitorEngine, Storage: staging.Storage, NotificationManager: staging.NotificationManager},
+	}
+
+	tests := []struct {
+		name      string
+		target    string
+		header    string
+		wantCode  int
+		wantTotal string
+	}{
+		{name: "default", target: "/api/websites", wantCode: 200, wantTotal: "1"},
+		{name: "header", target: "/api/websites", header: "staging", wantCode: 200, wantTotal: "0"},
+		{name: "query parameter", target: "/api/websites?namespace=staging", wantCode: 200, wantTotal: "0"},
+		{name: "header over query parameter", target: "/api/websites?namespace=prod", header: "staging", wantCode: 200, wantTotal: "0"},
+		{name: "unknown", target: "/api/websites", header: "prod", wantCode: 404},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set(NamespaceHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(rec, req)
+		c.Init(ctx, "WebsiteController", "GET", c)
+		func() {
+			// StopRun ends the request by panicking
+			defer func() {
+				if r := recover(); r != nil && r != beego.ErrAbort {
+					panic(r)
+				}
+			}()
+			c.Prepare()
+			c.GetAll()
+		}()
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: status = %d (%s), want %d", tt.name, rec.Code, rec.Body.String(), tt.wantCode)
+		}
+		if total := rec.Header().Get("X-Total-Count"); total != tt.wantTotal {
+			t.Errorf("%s: X-Total-Count = %q, want %q", tt.name, total, tt.wantTotal)
+		}
+	}
+}
+
+func TestCreateRejectsDuplicateURL(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", Enabled: true})
+	duplicate := `{"name": "Again", "url": "HTTPS://Site.Example.com:443/#status"}`
+
+	rec := serveURL(c, c.Post, "POST", "/api/websites", "", duplicate)
+	var conflict map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &conflict)
+	if rec.Code != 409 || conflict["existing_id"] != "site" {
+		t.Errorf("duplicate: status = %d (%s), want 409 naming the existing website", rec.Code, rec.Body.String())
+	}
+
+	// Bulk create skips the duplicate, including one earlier in the batch
+	batch := `[{"name": "Other", "url": "https://other.example.com"}, ` + duplicate + `, {"name": "Other again", "url": "https://other.example.com/"}]`
+	rec = serveURL(c, c.BulkCreate, "POST", "/api/websites/bulk", "", batch)
+	var bulk struct {
+		Created int                `json:"created"`
+		Results []BulkCreateResult `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &bulk); err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Created != 1 || len(bulk.Results) != 3 {
+		t.Fatalf("bulk create = %s, want only the first item created", rec.Body.String())
+	}
+	if bulk.Results[1].ExistingID != "site" || bulk.Results[2].ExistingID != bulk.Results[0].ID {
+		t.Errorf("results = %+v, want the duplicates to name the existing websites", bulk.Results)
+	}
+
+	rec = serveURL(c, c.Post, "POST", "/api/websites?allow_duplicate=true", "", duplicate)
+	if rec.Code != 200 {
+		t.Errorf("allowed duplicate: status = %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	if total := len(c.MonitorEngine.GetAllWebsites()); total != 3 {
+		t.Errorf("%d websites, want 3", total)
+	}
+}
+
+func TestNonNumericIntervalIsNamed(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", IntervalSeconds: 60, Enabled: true})
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		serveURL(c, c.Post, "POST", "/api/websites", "", `{"name": "Other", "url": "https://other.example.com", "interval_seconds": "60"}`),
+		serve(c, c.Put, "PUT", "site", `{"interval_seconds": "5m"}`),
+	} {
+		var response map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		if rec.Code != 400 || response["error"] != "interval_seconds must be a whole number of seconds" {
+			t.Errorf("status = %d (%s), want 400 naming the interval", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", Enabled: true})
+	c.MonitorEngine.UpdateWebsiteStatus("site", monitor.StatusUp, 120)
+	c.MonitorEngine.MarkStatusesStale()
+
+	rec := serve(c, c.GetStatus, "GET", "site", "")
+	if rec.Code != 200 {
+		t.Fatalf("status = %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	var response WebsiteStatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID != "site" || response.Status != monitor.StatusUp || !response.StatusStale || response.LastResponseTime != 120 || response.LastCheckTime.IsZero() {
+		t.Errorf("response = %+v, want the engine's stale up status", response)
+	}
+
+	if rec := serve(c, c.GetStatus, "GET", "missing", ""); rec.Code != 404 {
+		t.Errorf("unknown website: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestPutKeepsRedactedWebhooks(t *testing.T) {
+	c := newTestController(t)
+	c.MonitorEngine.AddWebsite(&monitor.Website{
+		ID:           "site",
+		Name:         "Site",
+		URL:          "https://site.example.com",
+		Enabled:      true,
+		SlackWebhook: "https://hooks.slack.com/services/T000/B000/s3cret",
+		TeamsWebhook: "https://example.webhook.office.com/webhookb2/s3cret",
+	})
+
+	// The dashboard sends back what it read
+	body := `{"name": "Renamed", "slack_webhook": "[redacted]", "teams_webhook": "[redacted]"}`
+	if rec := serve(c, c.Put, "PUT", "site", body); rec.Code != 200 {
+		t.Fatalf("status = %d (%s), want 200", rec.Code, rec.Body.String())
+	}
+	website, _ := c.MonitorEngine.GetWebsite("site")
+	if website.SlackWebhook != "https://hooks.slack.com/services/T000/B000/s3cret" || website.TeamsWebhook != "https://example.webhook.office.com/webhookb2/s3cret" {
+		t.Errorf("webhooks = %q and %q, want the current ones kept", website.SlackWebhook, website.TeamsWebhook)
+	}
+}
@@ -2,7 +2,11 @@ module uptime-monitor
 
 go 1.18
 
-require github.com/astaxie/beego v1.12.3
+require (
+	github.com/astaxie/beego v1.12.3
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
+)
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
@@ -16,9 +20,11 @@ require (
 	github.com/prometheus/procfs v0.1.3 // indirect
 	github.com/shiena/ansicolor v0.0.0-20151119151921-a422bbe96644 // indirect
 	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550 // indirect
-	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sys v0.0.0-20200615200032-f1bc736245b1 // indirect
 	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/protobuf v1.23.0 // indirect
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
+
+// beego v1.12.3 requires the mistakenly published v2.0.3 tag of go-sqlite3
+replace github.com/mattn/go-sqlite3 => github.com/mattn/go-sqlite3 v1.14.18
@@ -0,0 +1,103 @@
+// Package logger writes leveled log messages through the standard log
+// package, dropping those below the configured level.
+package logger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message
+type Level int32
+
+// Log levels, from most to least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames are the names of the levels, as accepted by ParseLevel
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the name of the level, e.g. "warn"
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel returns the level with the given name. "warning" is accepted
+// for "warn".
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		return LevelWarn, nil
+	}
+	for level, levelName := range levelNames {
+		if name == levelName {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// minLevel is the least severe level that is logged
+var minLevel = int32(LevelInfo)
+
+// SetLevel sets the least severe level that is logged
+func SetLevel(level Level) {
+	atomic.StoreInt32(&minLevel, int32(level))
+}
+
+// Enabled reports whether messages of the given level are logged, to skip
+// building expensive messages that would be dropped
+func Enabled(level Level) bool {
+	return int32(level) >= atomic.LoadInt32(&minLevel)
+}
+
+// output logs a message of the given level, prefixed with its name
+func output(level Level, format string, args ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	log.Output(3, strings.ToUpper(level.String())+" "+fmt.Sprintf(format, args...))
+}
+
+// Debugf logs a message about routine events, such as successful checks
+func Debugf(format string, args ...interface{}) {
+	output(LevelDebug, format, args...)
+}
+
+// Infof logs a message about notable events, such as startup and shutdown
+func Infof(format string, args ...interface{}) {
+	output(LevelInfo, format, args...)
+}
+
+// Warnf logs a message about problems the application recovers from, such
+// as a website going down
+func Warnf(format string, args ...interface{}) {
+	output(LevelWarn, format, args...)
+}
+
+// Errorf logs a message about failed operations
+func Errorf(format string, args ...interface{}) {
+	output(LevelError, format, args...)
+}
+
+// Fatalf logs a message at the error level, whatever the configured level,
+// and exits
+func Fatalf(format string, args ...interface{}) {
+	log.Output(2, "ERROR "+fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
@@ -2,319 +2,584 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
+	"uptime-monitor/config"
 	"uptime-monitor/controllers"
+	"uptime-monitor/logger"
 	"uptime-monitor/monitor"
 	"uptime-monitor/notification"
-	_ "uptime-monitor/routers" // This import ensures the init() function in routers/router.go runs
+	"uptime-monitor/routers"
 	"uptime-monitor/storage"
 
 	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/logs"
 )
 
 func main() {
-	// Initialize storage
-	dataDir := "./data"
-	stor := storage.NewStorage(dataDir)
-
-	// Initialize notification manager
-	notificationConfig := notification.NotificationConfig{
-		SMTPHost:     beego.AppConfig.String("smtp_host"),
-		SMTPPort:     beego.AppConfig.String("smtp_port"),
-		SMTPUsername: beego.AppConfig.String("smtp_username"),
-		SMTPPassword: beego.AppConfig.String("smtp_password"),
-		FromEmail:    beego.AppConfig.String("from_email"),
-	}
-	notificationManager := notification.NewNotificationManager(notificationConfig)
+	startTime := time.Now()
 
-	// Initialize monitor engine
-	monitorEngine := monitor.NewMonitorEngine()
-
-	// Load existing websites from storage
-	websites, err := stor.LoadWebsites()
+	// Load settings from app.conf and UPTIME_* environment variables
+	cfg, err := config.Load(beego.AppConfig)
 	if err != nil {
-		log.Printf("Warning: Failed to load websites from storage: %v", err)
-	} else {
-		for _, website := range websites {
-			monitorEngine.AddWebsite(website)
-		}
-		log.Printf("Loaded %d websites from storage", len(websites))
+		logger.Fatalf("Invalid configuration: %v", err)
 	}
+	logger.SetLevel(cfg.LogLevel)
+	beego.SetLevel(beegoLogLevel(cfg.LogLevel))
 
-	// --- START: Add this section to add sample websites programmatically ---
-	// Only add samples if no websites are loaded yet (e.g., on first run)
-	if len(websites) == 0 {
-		log.Println("Adding sample websites...")
-		sampleURLs := []struct { Name string; URL string } {
-			{Name: "Google", URL: "https://www.google.com"},
-			{Name: "YouTube", URL: "https://www.youtube.com"},
-			{Name: "Facebook", URL: "https://www.facebook.com"},
-			{Name: "Baidu", URL: "https://www.baidu.com"},
-			{Name: "Wikipedia", URL: "https://www.wikipedia.org"},
-			{Name: "Reddit", URL: "https://www.reddit.com"},
-			{Name: "Yahoo", URL: "https://www.yahoo.com"},
-			{Name: "Amazon", URL: "https://www.amazon.com"},
-			{Name: "Twitter", URL: "https://www.twitter.com"},
-			{Name: "Instagram", URL: "https://www.instagram.com"},
-			{Name: "LinkedIn", URL: "https://www.linkedin.com"},
-			{Name: "Netflix", URL: "https://www.netflix.com"},
-			{Name: "Microsoft", URL: "https://www.microsoft.com"},
-			{Name: "Apple", URL: "https://www.apple.com"},
-			{Name: "Twitch", URL: "https://www.twitch.tv"},
-			{Name: "eBay", URL: "https://www.ebay.com"},
-			{Name: "Pinterest", URL: "https://www.pinterest.com"},
-			{Name: "Bing", URL: "https://www.bing.com"},
-			{Name: "Stack Overflow", URL: "https://www.stackoverflow.com"},
-			{Name: "GitHub", URL: "https://www.github.com"},
-			{Name: "Medium", URL: "https://www.medium.com"},
-			{Name: "WordPress", URL: "https://www.wordpress.com"},
-			{Name: "Blogger", URL: "https://www.blogger.com"},
-			{Name: "Tumblr", URL: "https://www.tumblr.com"},
-			{Name: "Quora", URL: "https://www.quora.com"},
-			{Name: "GitLab", URL: "https://www.gitlab.com"},
-			{Name: "Bitbucket", URL: "https://www.bitbucket.org"},
-			{Name: "Atlassian", URL: "https://www.atlassian.com"},
-			{Name: "Docker", URL: "https://www.docker.com"},
-			{Name: "Kubernetes", URL: "https://www.kubernetes.io"},
-			{Name: "AWS", URL: "https://www.aws.amazon.com"},
-			{Name: "Azure", URL: "https://azure.microsoft.com"},
-			{Name: "Google Cloud", URL: "https://cloud.google.com"},
-			{Name: "DigitalOcean", URL: "https://www.digitalocean.com"},
-			{Name: "Heroku", URL: "https://www.heroku.com"},
-			{Name: "Netlify", URL: "https://www.netlify.com"},
-			{Name: "Vercel", URL: "https://www.vercel.com"},
-			{Name: "Cloudflare", URL: "https://www.cloudflare.com"},
-			{Name: "Stripe", URL: "https://www.stripe.com"},
-			{Name: "PayPal", URL: "https://www.paypal.com"},
-			{Name: "Shopify", URL: "https://www.shopify.com"},
-			{Name: "Etsy", URL: "https://www.etsy.com"},
-			{Name: "Walmart", URL: "https://www.walmart.com"},
-			{Name: "Target", URL: "https://www.target.com"},
-			{Name: "Best Buy", URL: "https://www.bestbuy.com"},
-			{Name: "CNN", URL: "https://www.cnn.com"},
-			{Name: "BBC", URL: "https://www.bbc.com"},
-			{Name: "NY Times", URL: "https://www.nytimes.com"},
-			{Name: "The Guardian", URL: "https://www.theguardian.com"},
-			{Name: "Reuters", URL: "https://www.reuters.com"},
-			{Name: "Bloomberg", URL: "https://www.bloomberg.com"},
-			{Name: "Forbes", URL: "https://www.forbes.com"},
-			{Name: "TechCrunch", URL: "https://www.techcrunch.com"},
-			{Name: "Wired", URL: "https://www.wired.com"},
-			{Name: "The Verge", URL: "https://www.theverge.com"},
-			{Name: "Engadget", URL: "https://www.engadget.com"},
-			{Name: "Ars Technica", URL: "https://www.arstechnica.com"},
-			{Name: "Mozilla", URL: "https://www.mozilla.org"},
-			{Name: "Opera", URL: "https://www.opera.com"},
-			{Name: "Brave", URL: "https://www.brave.com"},
-			{Name: "DuckDuckGo", URL: "https://www.duckduckgo.com"},
-			{Name: "ProtonMail", URL: "https://www.protonmail.com"},
-			{Name: "Signal", URL: "https://www.signal.org"},
-			{Name: "Telegram", URL: "https://www.telegram.org"},
-			{Name: "WhatsApp", URL: "https://www.whatsapp.com"},
-			{Name: "Zoom", URL: "https://www.zoom.us"},
-			{Name: "Slack", URL: "https://www.slack.com"},
-			{Name: "Trello", URL: "https://www.trello.com"},
-			{Name: "Asana", URL: "https://www.asana.com"},
-			{Name: "Notion", URL: "https://www.notion.so"},
-			{Name: "Figma", URL: "https://www.figma.com"},
-			{Name: "Adobe", URL: "https://www.adobe.com"},
-			{Name: "Autodesk", URL: "https://www.autodesk.com"},
-			{Name: "Blender", URL: "https://www.blender.org"},
-			{Name: "GIMP", URL: "https://www.gimp.org"},
-			{Name: "Inkscape", URL: "https://www.inkscape.org"},
-			{Name: "LibreOffice", URL: "https://www.libreoffice.org"},
-			{Name: "OpenOffice", URL: "https://www.openoffice.org"},
-			{Name: "Ubuntu", URL: "https://www.ubuntu.com"},
-			{Name: "Debian", URL: "https://www.debian.org"},
-			{Name: "Fedora", URL: "https://www.fedora.org"},
-			{Name: "CentOS", URL: "https://www.centos.org"},
-			{Name: "Red Hat", URL: "https://www.redhat.com"},
-			{Name: "SUSE", URL: "https://www.suse.com"},
-			{Name: "Kernel.org", URL: "https://www.kernel.org"},
-			{Name: "GNU", URL: "https://www.gnu.org"},
-			{Name: "FSF", URL: "https://www.fsf.org"},
-			{Name: "Apache", URL: "https://www.apache.org"},
-			{Name: "Nginx", URL: "https://www.nginx.com"},
-			{Name: "MySQL", URL: "https://www.mysql.com"},
-			{Name: "PostgreSQL", URL: "https://www.postgresql.org"},
-			{Name: "MongoDB", URL: "https://www.mongodb.com"},
-			{Name: "Redis", URL: "https://www.redis.io"},
-			{Name: "Elastic", URL: "https://www.elastic.co"},
-			{Name: "Grafana", URL: "https://www.grafana.com"},
-			{Name: "Prometheus", URL: "https://www.prometheus.io"},
-			{Name: "Jenkins", URL: "https://www.jenkins.io"},
-			{Name: "Travis CI", URL: "https://www.travis-ci.com"},
-			{Name: "CircleCI", URL: "https://www.circleci.com"},
-			{Name: "GitHub Pages", URL: "https://www.github.io"},
-			{Name: "Google.org", URL: "https://www.google.org"},
-			{Name: "UN", URL: "https://www.un.org"},
-			{Name: "WHO", URL: "https://www.who.int"},
-			{Name: "NASA", URL: "https://www.nasa.gov"},
-			{Name: "SpaceX", URL: "https://www.spacex.com"},
-			{Name: "Tesla", URL: "https://www.tesla.com"},
-			{Name: "OpenAI", URL: "https://www.openai.com"},
-			{Name: "DeepMind", URL: "https://www.deepmind.com"},
-			{Name: "Hugging Face", URL: "https://www.huggingface.co"},
-			{Name: "Kaggle", URL: "https://www.kaggle.com"},
-			{Name: "Coursera", URL: "https://www.coursera.org"},
-			{Name: "edX", URL: "https://www.edx.org"},
-			{Name: "Udemy", URL: "https://www.udemy.com"},
-			{Name: "Khan Academy", URL: "https://www.khanacademy.org"},
-			{Name: "W3Schools", URL: "https://www.w3schools.com"},
-			{Name: "MDN Web Docs", URL: "https://www.developer.mozilla.org"},
-			{Name: "PHP", URL: "https://www.php.net"},
-			{Name: "Python", URL: "https://www.python.org"},
-			{Name: "Ruby", URL: "https://www.ruby-lang.org"},
-			{Name: "Java", URL: "https://www.java.com"},
-			{Name: "Go", URL: "https://www.golang.org"},
-			{Name: "Rust", URL: "https://www.rust-lang.org"},
-			{Name: "TypeScript", URL: "https://www.typescriptlang.org"},
-			{Name: "Node.js", URL: "https://www.nodejs.org"},
-			{Name: "React", URL: "https://www.react.dev"},
-			{Name: "Angular", URL: "https://www.angular.io"},
-			{Name: "Vue.js", URL: "https://www.vuejs.org"},
-			{Name: "jQuery", URL: "https://www.jquery.com"},
-			{Name: "Bootstrap", URL: "https://www.bootstrapcdn.com"},
-			{Name: "Tailwind CSS", URL: "https://www.tailwindcss.com"},
-			{Name: "Material UI", URL: "https://www.material-ui.com"},
-			{Name: "Ant Design", URL: "https://www.ant.design"},
-			{Name: "D3.js", URL: "https://www.d3js.org"},
-			{Name: "Chart.js", URL: "https://www.chartjs.org"},
-			{Name: "Highcharts", URL: "https://www.highcharts.com"},
-			{Name: "Mapbox", URL: "https://www.mapbox.com"},
-			{Name: "OpenStreetMap", URL: "https://www.openstreetmap.org"},
-			{Name: "Google Maps", URL: "https://www.google.maps"},
-			{Name: "Weather.com", URL: "https://www.weather.com"},
-			{Name: "AccuWeather", URL: "https://www.accuweather.com"},
-			{Name: "Time and Date", URL: "https://www.timeanddate.com"},
-			{Name: "World Bank", URL: "https://www.worldbank.org"},
-			{Name: "IMF", URL: "https://www.imf.org"},
-			{Name: "WTO", URL: "https://www.wto.org"},
-			{Name: "Wikipedia Main", URL: "https://www.wikipedia.org/wiki/Main_Page"},
-			{Name: "Example.com", URL: "https://www.example.com"},
-			{Name: "Example.org", URL: "https://www.example.org"},
-			{Name: "Example.net", URL: "https://www.example.net"},
+	// Set up the default namespace, which keeps its data in the data directory,
+	// and every extra namespace, each in a subdirectory of it
+	namespaces := controllers.Namespaces{}
+	for _, name := range append([]string{""}, cfg.Namespaces...) {
+		namespace, err := newNamespace(cfg, name)
+		if err != nil {
+			logger.Fatalf("Failed to initialize namespace %q: %v", name, err)
 		}
+		namespaces[name] = namespace
+	}
+	defaultNamespace := namespaces[""]
+	monitorEngine := defaultNamespace.MonitorEngine
+	stor := defaultNamespace.Storage
 
-		for i, site := range sampleURLs {
-			id := fmt.Sprintf("website_%d", time.Now( ).UnixNano() + int64(i)) // Unique ID
-			website := &monitor.Website{
-				ID:                id,
-				Name:              site.Name,
-				URL:               site.URL,
-				IntervalSeconds:   60, // Default check interval
-				Status:            "unknown",
-				LastCheckTime:     time.Time{},
-				LastResponseTime:  0,
-				NotificationEmails: []string{}, // No email notifications by default
-				SlackWebhook:      "",         // No Slack notifications by default
-				Enabled:           true,
+	// Seed the bundled sample websites into the default namespace on first run when enabled
+	if len(monitorEngine.GetAllWebsites()) == 0 && cfg.SeedSamples {
+		samples, err := loadSampleWebsites(cfg.SamplesFile)
+		if err != nil {
+			logger.Errorf("Error loading sample websites: %v", err)
+		} else {
+			for _, website := range samples {
+				monitorEngine.AddWebsite(website)
 			}
-			monitorEngine.AddWebsite(website)
-		}
-		// Save the newly added sample websites to storage
-		if err := stor.SaveWebsites(monitorEngine.GetAllWebsites()); err != nil {
-			log.Printf("Error saving sample websites: %v", err)
+			if err := stor.SaveWebsites(monitorEngine.GetAllWebsites()); err != nil {
+				logger.Errorf("Error saving sample websites: %v", err)
+			}
+			logger.Infof("Added %d sample websites from %s", len(samples), cfg.SamplesFile)
 		}
-		log.Printf("Added %d sample websites.", len(sampleURLs))
 	}
-	// --- END: Add this section ---
 
-	// Set up controllers with dependencies
-	// IMPORTANT: Create the controller instance *after* monitorEngine and stor are initialized
+	// Set up controllers with dependencies. Each request is pointed at the
+	// namespace it selects before it is handled.
+	// IMPORTANT: Create the controller instance *after* the namespaces are initialized
 	websiteController := &controllers.WebsiteController{
-		MonitorEngine: monitorEngine,
-		Storage:       stor,
+		Namespaces: namespaces,
+		AgentKeys:  cfg.AgentKeys,
+	}
+	dashboardController := &controllers.DashboardController{
+		Namespaces: namespaces,
+	}
+	metricsController := &controllers.MetricsController{
+		Namespaces: namespaces,
+	}
+	liveController := &controllers.LiveController{
+		Namespaces: namespaces,
+	}
+	eventsController := &controllers.EventsController{
+		Namespaces: namespaces,
+	}
+	systemController := &controllers.SystemController{
+		Namespaces: namespaces,
+		StartTime:  startTime,
 	}
 
 	// Register controller instance with Beego after initialization
 	// These routes MUST be registered here in main.go, not in init() of router.go
 	beego.Router("/api/websites", websiteController, "get:GetAll;post:Post;options:Options")
+	beego.Router("/api/websites/bulk", websiteController, "post:BulkCreate;options:Options")
+	beego.Router("/api/export", websiteController, "get:Export;options:Options")
+	beego.Router("/api/import", websiteController, "post:Import;options:Options")
 	beego.Router("/api/websites/:id", websiteController, "get:Get;put:Put;delete:Delete;options:Options")
+	beego.Router("/api/websites/:id/status", websiteController, "get:GetStatus;options:Options")
+	beego.Router("/api/websites/:id/last-failure", websiteController, "get:GetLastFailure;options:Options")
 	beego.Router("/api/websites/:id/history", websiteController, "get:GetHistory;options:Options")
+	beego.Router("/api/websites/:id/outages", websiteController, "get:GetOutages;options:Options")
+	beego.Router("/api/websites/:id/notifications", websiteController, "get:GetNotifications;options:Options")
+	beego.Router("/api/websites/:id/test-notification", websiteController, "post:TestNotification;options:Options")
+	beego.Router("/api/websites/:id/config", websiteController, "get:GetConfig;options:Options")
+	beego.Router("/api/websites/:id/pause", websiteController, "post:Pause;options:Options")
+	beego.Router("/api/websites/:id/resume", websiteController, "post:Resume;options:Options")
+	beego.Router("/api/dashboard", dashboardController, "get:Get;options:Options")
+	beego.Router("/api/stats", dashboardController, "get:GetStats;options:Options")
+	beego.Router("/api/tags", dashboardController, "get:GetTags;options:Options")
+	beego.Router("/api/system/info", systemController, "get:GetInfo;options:Options")
+	beego.Router("/api/backup", systemController, "post:Backup;options:Options")
+	beego.Router("/metrics", metricsController, "get:Get")
+	beego.Router("/api/ws", liveController, "get:Get")
+	beego.Router("/api/events", eventsController, "get:Get")
+	beego.Router("/api/results", websiteController, "post:SubmitResult;options:Options")
+	beego.Router("/api/agents/:agent/results", websiteController, "post:SubmitAgentResults;options:Options")
+	beego.Router("/api/check-all", websiteController, "post:CheckAll;options:Options")
+	beego.Router("/api/check-all/:job", websiteController, "get:GetCheckJob;options:Options")
 
-	// Start notification manager
-	notificationManager.Start()
+	// Back up every namespace periodically when enabled
+	stopBackups := scheduleBackups(cfg.BackupInterval, namespaces)
 
-	// Start monitor engine
-	monitorEngine.Start()
+	// Start each namespace's notification manager, monitor engine and
+	// scheduled uptime digest emails, and handle their results. resultsSaved
+	// holds a channel per namespace that is closed once its monitor engine has
+	// stopped and its last result has been handled.
+	resultsSaved := make(map[string]<-chan struct{})
+	digestSchedulers := make([]*notification.DigestScheduler, 0, len(namespaces))
+	for name, namespace := range namespaces {
+		namespace.NotificationManager.Start()
+		namespace.MonitorEngine.Start()
+		resultsSaved[name] = handleResults(cfg, namespace)
 
-	// Handle monitoring results and notifications
-	go func() {
-		previousStatus := make(map[string]string)
-		
-		for result := range monitorEngine.GetResultChannel() {
-			// Save history
-			historyEntry := storage.HistoryEntry{
-				Timestamp:    result.Timestamp,
-				Status:       result.Status,
-				ResponseTime: result.ResponseTime,
-			}
-			
-			if err := stor.SaveHistory(result.WebsiteID, historyEntry); err != nil {
-				log.Printf("Error saving history for %s: %v", result.WebsiteID, err)
-			}
-
-			// Check for status changes and send notifications
-			if prevStatus, exists := previousStatus[result.WebsiteID]; exists && prevStatus != result.Status {
-				website, websiteExists := monitorEngine.GetWebsite(result.WebsiteID)
-				if websiteExists {
-					event := notification.StatusChangeEvent{
-						WebsiteID:    result.WebsiteID,
-						WebsiteName:  website.Name,
-						WebsiteURL:   website.URL,
-						OldStatus:    prevStatus,
-						NewStatus:    result.Status,
-						ResponseTime: result.ResponseTime,
-						Timestamp:    result.Timestamp,
-						Emails:       website.NotificationEmails,
-						SlackWebhook: website.SlackWebhook,
-					}
-					notificationManager.SendStatusChange(event)
-				}
-			}
-			
-			previousStatus[result.WebsiteID] = result.Status
-		}
-	}()
+		digestConfig := cfg.Digest
+		digestConfig.Namespace = name
+		digestScheduler := notification.NewDigestScheduler(digestConfig, newDigestSource(namespace.MonitorEngine, namespace.Storage), namespace.NotificationManager)
+		digestScheduler.Start()
+		digestSchedulers = append(digestSchedulers, digestScheduler)
+	}
 
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		fmt.Println("\nShutting down gracefully...")
-		
-		// Stop monitor engine
-		monitorEngine.Stop()
-		
-		// Stop notification manager
-		notificationManager.Stop()
-		
-		// Save current state
-		websites := monitorEngine.GetAllWebsites()
-		if err := stor.SaveWebsites(websites); err != nil {
-			log.Printf("Error saving websites during shutdown: %v", err)
+		logger.Infof("Shutting down gracefully...")
+
+		// Stop monitor engines; running checks are cancelled and completed results handed on
+		for _, namespace := range namespaces {
+			namespace.MonitorEngine.Stop()
+		}
+
+		// Wait until the last results are saved and their notifications queued
+		for _, saved := range resultsSaved {
+			<-saved
+		}
+
+		// Stop notification managers once queued notifications are delivered
+		for _, digestScheduler := range digestSchedulers {
+			digestScheduler.Stop()
+		}
+		stopBackups()
+		for name, namespace := range namespaces {
+			namespace.NotificationManager.Stop()
+
+			// Save current state
+			if err := namespace.Storage.SaveWebsites(namespace.MonitorEngine.GetAllWebsites()); err != nil {
+				logger.Errorf("Error saving websites of namespace %q during shutdown: %v", name, err)
+			}
+
+			namespace.Storage.Close()
 		}
-		
-		// Give some time for cleanup
-		time.Sleep(2 * time.Second)
 		os.Exit(0)
 	}()
 
 	// Configure Beego
 	beego.BConfig.WebConfig.DirectoryIndex = true
 	// The static path is set in routers/router.go init() function
-	beego.BConfig.Listen.HTTPAddr = "0.0.0.0"
-	beego.BConfig.Listen.HTTPPort = 8081 // Ensure this matches your desired port
+	beego.BConfig.Listen.HTTPAddr = cfg.HTTPAddr
+	beego.BConfig.Listen.HTTPPort = cfg.HTTPPort
+
+	// Require an API key on the API when one is configured
+	if cfg.APIKey != "" {
+		routers.RequireAPIKey(cfg.APIKey)
+	}
+
+	logger.Infof("Starting Uptime Monitor on http://%s", net.JoinHostPort(cfg.HTTPAddr, strconv.Itoa(cfg.HTTPPort)))
+	for name, namespace := range namespaces {
+		if name == "" {
+			logger.Infof("Monitoring %d websites", len(namespace.MonitorEngine.GetAllWebsites()))
+		} else {
+			logger.Infof("Monitoring %d websites in namespace %q", len(namespace.MonitorEngine.GetAllWebsites()), name)
+		}
+	}
 
-	fmt.Println("Starting Uptime Monitor on http://0.0.0.0:8081" )
-	fmt.Printf("Monitoring %d websites\n", len(websites))
-	
 	// Start Beego
 	beego.Run()
 }
+
+// beegoLogLevel returns the beego log level matching level, so the framework's
+// own messages are filtered like the application's
+func beegoLogLevel(level logger.Level) int {
+	switch level {
+	case logger.LevelDebug:
+		return logs.LevelDebug
+	case logger.LevelWarn:
+		return logs.LevelWarning
+	case logger.LevelError:
+		return logs.LevelError
+	default:
+		return logs.LevelInformational
+	}
+}
+
+// scheduleBackups backs up every namespace each interval until the returned
+// function is called, which waits for a backup being written. An interval of
+// 0 disables scheduled backups.
+func scheduleBackups(interval time.Duration, namespaces controllers.Namespaces) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for name, namespace := range namespaces {
+					info, err := namespace.Backup()
+					if err != nil {
+						logger.Errorf("Scheduled backup of namespace %q failed: %v", name, err)
+						continue
+					}
+					logger.Infof("Backed up namespace %q to %s (%d bytes)", name, info.Path, info.Size)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// newNamespace sets up the storage, notification manager and monitor engine of
+// a namespace and loads its websites. The default namespace, named "", keeps
+// its data in the data directory and every other one in a subdirectory of it.
+func newNamespace(cfg *config.Config, name string) (*controllers.Namespace, error) {
+	// Initialize storage
+	dataDir := cfg.DataDir
+	backupDir := cfg.BackupDir
+	if name != "" {
+		dataDir = filepath.Join(cfg.DataDir, name)
+		backupDir = filepath.Join(cfg.BackupDir, name)
+	}
+	stor, err := storage.NewStorage(cfg.StorageBackend, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %v", err)
+	}
+	stor.SetCountUnknownAsDown(cfg.CountUnknownAsDown)
+	stor.SetCountDegradedAsDown(cfg.CountDegradedAsDown)
+	stor.SetStatsCacheTTL(cfg.StatsCacheTTL)
+	stor.SetHistoryRetention(cfg.HistoryMaxEntries, cfg.HistoryRetention)
+	stor.SetCompressHistory(cfg.CompressHistory)
+
+	// Initialize notification manager
+	notificationManager := notification.NewNotificationManager(cfg.Notification)
+
+	// Keep a per-website log of every notification attempt
+	notificationManager.SetAttemptHandler(func(attempt notification.Attempt) {
+		entry := storage.NotificationLogEntry{
+			Timestamp: attempt.Timestamp,
+			Channel:   attempt.Channel,
+			EventType: attempt.Event.EventType,
+			OldStatus: attempt.Event.OldStatus,
+			NewStatus: attempt.Event.NewStatus,
+			Success:   attempt.Err == nil,
+		}
+		if entry.EventType == notification.EventStatusChange {
+			entry.EventType = "status_change"
+		}
+		if attempt.Err != nil {
+			entry.Error = attempt.Err.Error()
+		}
+		if err := stor.SaveNotificationLog(attempt.Event.WebsiteID, entry); err != nil {
+			logger.Errorf("Error saving notification log for %s: %v", attempt.Event.WebsiteID, err)
+		}
+	})
+
+	// Initialize monitor engine
+	monitorEngine := monitor.NewMonitorEngine()
+	monitorEngine.SetInternalResolver(cfg.InternalDNSServer)
+	monitorEngine.SetMaxChecksPerHost(cfg.MaxChecksPerHost)
+	monitorEngine.SetMaxConcurrentChecks(cfg.MaxConcurrentChecks)
+	monitorEngine.SetMaxStartupJitter(cfg.MaxStartupJitter)
+	monitorEngine.SetFlapDetection(cfg.FlapThreshold, cfg.FlapWindow)
+	monitorEngine.SetRecentResults(cfg.RecentResults)
+	monitorEngine.SetAgent(cfg.AgentID, cfg.DownQuorum)
+	monitorEngine.SetCheckLogging(cfg.LogChecks)
+
+	// Exclude time outside each website's active schedule from uptime
+	stor.SetActivityFilter(func(websiteID string) func(t time.Time) bool {
+		website, exists := monitorEngine.GetWebsite(websiteID)
+		if !exists || len(website.ActiveSchedule) == 0 {
+			return nil
+		}
+		return website.IsActiveAt
+	})
+
+	// Load existing websites from storage
+	websites, err := stor.LoadWebsites()
+	if err != nil {
+		logger.Warnf("Failed to load websites from storage: %v", err)
+	} else {
+		for _, website := range websites {
+			if !cfg.KeepLastStatus {
+				website.Status = monitor.StatusUnknown
+			}
+			monitorEngine.AddWebsite(website)
+
+			// Serve the recent results from memory from the start
+			history, err := stor.GetRecentHistory(website.ID, 24)
+			if err != nil {
+				logger.Warnf("Failed to load recent history of %s: %v", website.ID, err)
+				continue
+			}
+			results := make([]monitor.CheckResult, len(history))
+			for i, entry := range history {
+				results[i] = entry.CheckResult(website.ID)
+			}
+			monitorEngine.SeedRecentResults(website.ID, results)
+		}
+		// Show the last known status until each site is checked again
+		monitorEngine.MarkStatusesStale()
+		logger.Infof("Loaded %d websites from %s", len(websites), dataDir)
+	}
+
+	return &controllers.Namespace{
+		Name:                name,
+		MonitorEngine:       monitorEngine,
+		Storage:             stor,
+		NotificationManager: notificationManager,
+		Hub:                 controllers.NewLiveHub(),
+		Stream:              controllers.NewEventStream(),
+		BackupDir:           backupDir,
+		BackupKeep:          cfg.BackupKeep,
+	}, nil
+}
+
+// handleResults saves the monitoring results of a namespace and sends its
+// notifications. The returned channel is closed once the monitor engine has
+// stopped and its last result has been handled.
+func handleResults(cfg *config.Config, namespace *controllers.Namespace) <-chan struct{} {
+	monitorEngine := namespace.MonitorEngine
+	stor := namespace.Storage
+	notificationManager := namespace.NotificationManager
+
+	resultsSaved := make(chan struct{})
+	go func() {
+		defer close(resultsSaved)
+
+		previousStatus := make(map[string]string)
+		// Unlike previousStatus, which tracks what was last notified, this
+		// follows every status change for the event stream
+		lastStatus := make(map[string]string)
+		belowThreshold := make(map[string]bool)
+		certWarned := make(map[string]bool)
+		certWarningDays := cfg.CertExpiryWarningDays
+
+		// Seed with the statuses persisted before the last shutdown so the first
+		// check after a restart only notifies on a real change. Flapping isn't
+		// remembered across restarts, so neither is a flapping status.
+		for id, website := range monitorEngine.GetAllWebsites() {
+			if website.Status != monitor.StatusUnknown && website.Status != "" && website.Status != monitor.StatusFlapping {
+				previousStatus[id] = website.Status
+				lastStatus[id] = website.Status
+			}
+		}
+
+		for result := range monitorEngine.GetResultChannel() {
+			// Push the result to live dashboard clients
+			namespace.Hub.Publish(result)
+
+			website, websiteExists := monitorEngine.GetWebsite(result.WebsiteID)
+			inMaintenance := websiteExists && result.Maintenance
+
+			saveHistory(stor, result)
+
+			if result.Unconfirmed {
+				// Not down until the website's failure threshold is met, so no
+				// status change to report yet
+				continue
+			}
+
+			if oldStatus, exists := lastStatus[result.WebsiteID]; exists && oldStatus != result.Status && websiteExists {
+				namespace.Stream.PublishStatusChange(website, oldStatus, result)
+			}
+			lastStatus[result.WebsiteID] = result.Status
+
+			if inMaintenance {
+				// No alerts during maintenance. The last notified status is kept,
+				// so a website still down when the window ends is reported then.
+				notificationManager.StopEscalation(result.WebsiteID)
+				continue
+			}
+
+			if websiteExists && !result.CertExpiresAt.IsZero() {
+				if event, expiring := evaluateCertExpiry(website, result, certWarningDays, certWarned); expiring {
+					notificationManager.SendStatusChange(event)
+				}
+			}
+
+			// A flapping website is notified once as "flapping" and again once
+			// it has settled, instead of on every change in between
+			status := result.Status
+			if result.Flapping {
+				status = monitor.StatusFlapping
+			}
+
+			if websiteExists && website.UptimeAlert != nil {
+				// Rule-based alerting replaces per-transition notifications
+				if event, crossed := evaluateUptimeAlert(stor, website, result, belowThreshold); crossed {
+					notificationManager.SendStatusChange(event)
+				}
+			} else if prevStatus, exists := previousStatus[result.WebsiteID]; exists && prevStatus != status && websiteExists {
+				if status == monitor.StatusUp && !website.RecoveryConfirmed(result.Timestamp) {
+					// Keep the last notified status until the recovery is stable
+					continue
+				}
+
+				// Check for status changes and send notifications
+				event := newStatusChangeEvent(website, result, prevStatus)
+				if result.Flapping {
+					event.NewStatus = monitor.StatusFlapping
+					event.Reason = fmt.Sprintf("Status changed more than %d times within %s; changes are not notified until it holds for that long",
+						cfg.FlapThreshold, cfg.FlapWindow)
+				} else if prevStatus == monitor.StatusFlapping {
+					event.Reason = fmt.Sprintf("Stopped flapping, no status change within %s", cfg.FlapWindow)
+				}
+				notificationManager.SendStatusChange(event)
+			} else if exists && prevStatus == monitor.StatusDown && status == monitor.StatusDown && websiteExists {
+				// Still down: keep the reminders going with the current settings
+				notificationManager.UpdateEscalation(newStatusChangeEvent(website, result, prevStatus))
+			}
+
+			previousStatus[result.WebsiteID] = status
+		}
+	}()
+	return resultsSaved
+}
+
+// saveHistory records a check result in the website's history. Failures held
+// back by the website's failure threshold are left out, so uptime and outages
+// only count the downtime that was declared.
+func saveHistory(stor storage.StorageProvider, result monitor.CheckResult) {
+	if result.Unconfirmed {
+		return
+	}
+	if err := stor.SaveHistory(result.WebsiteID, storage.NewHistoryEntry(result)); err != nil {
+		logger.Errorf("Error saving history for %s: %v", result.WebsiteID, err)
+	}
+}
+
+// newDigestSource returns the source of the uptime digest, summarizing every
+// website over the digest period from its history
+func newDigestSource(monitorEngine *monitor.MonitorEngine, stor storage.StorageProvider) notification.DigestSource {
+	return func(period time.Duration) ([]notification.DigestEntry, error) {
+		hours := int(period / time.Hour)
+		entries := []notification.DigestEntry{}
+		for id, website := range monitorEngine.GetAllWebsites() {
+			uptime, err := stor.CalculateUptime(id, hours)
+			if err != nil {
+				return nil, err
+			}
+			outages, err := stor.GetOutages(id, hours/24)
+			if err != nil {
+				return nil, err
+			}
+			avgResponseTime, err := stor.GetAverageResponseTime(id, hours)
+			if err != nil {
+				return nil, err
+			}
+
+			entry := notification.DigestEntry{
+				Name:              website.Name,
+				URL:               website.URL,
+				Status:            website.CurrentStatus(),
+				UptimePercent:     uptime,
+				Outages:           len(outages),
+				AvgResponseTimeMs: avgResponseTime,
+			}
+			for _, outage := range outages {
+				entry.DowntimeSeconds += outage.DurationSeconds
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+}
+
+// newStatusChangeEvent builds a notification event for a website check result
+func newStatusChangeEvent(website *monitor.Website, result monitor.CheckResult, oldStatus string) notification.StatusChangeEvent {
+	return notification.StatusChangeEvent{
+		WebsiteID:       result.WebsiteID,
+		WebsiteName:     website.Name,
+		WebsiteURL:      website.URL,
+		OldStatus:       oldStatus,
+		NewStatus:       result.Status,
+		ResponseTime:    result.ResponseTime,
+		Timestamp:       result.Timestamp,
+		Emails:          website.NotificationEmails,
+		SlackWebhook:    website.SlackWebhook,
+		Webhook:         website.GenericWebhook,
+		WebhookTemplate: website.WebhookTemplate,
+		TelegramChatID:  website.TelegramChatID,
+		TeamsWebhook:    website.TeamsWebhook,
+		PagerDuty:       website.PagerDuty,
+		ThrottleSeconds: website.NotificationThrottleSeconds,
+		NotifyOn:        website.NotifyOn,
+
+		EscalationAfterSeconds: website.EscalationAfterSeconds,
+	}
+}
+
+// evaluateUptimeAlert checks a website's rolling uptime against its alert rule and
+// returns an event when the threshold is crossed in either direction
+func evaluateUptimeAlert(stor storage.StorageProvider, website *monitor.Website, result monitor.CheckResult, belowThreshold map[string]bool) (notification.StatusChangeEvent, bool) {
+	rule := website.UptimeAlert
+	uptime, err := stor.CalculateUptimeWindow(website.ID, time.Duration(rule.WindowMinutes)*time.Minute)
+	if err != nil {
+		logger.Errorf("Error calculating uptime for %s: %v", website.ID, err)
+		return notification.StatusChangeEvent{}, false
+	}
+
+	below := uptime < rule.ThresholdPercent
+	if below == belowThreshold[website.ID] {
+		return notification.StatusChangeEvent{}, false
+	}
+	belowThreshold[website.ID] = below
+
+	event := newStatusChangeEvent(website, result, monitor.StatusUp)
+	event.NewStatus = monitor.StatusDown
+	event.Reason = fmt.Sprintf("Uptime over the last %d minutes is %.2f%%, below the %.2f%% threshold",
+		rule.WindowMinutes, uptime, rule.ThresholdPercent)
+	if !below {
+		event.OldStatus = monitor.StatusDown
+		event.NewStatus = monitor.StatusUp
+		event.Reason = fmt.Sprintf("Uptime over the last %d minutes recovered to %.2f%% (threshold %.2f%%)",
+			rule.WindowMinutes, uptime, rule.ThresholdPercent)
+	}
+
+	return event, true
+}
+
+// evaluateCertExpiry returns a warning event the first time a website's TLS
+// certificate drops below its expiry threshold. The warning is re-armed once
+// the certificate is renewed.
+func evaluateCertExpiry(website *monitor.Website, result monitor.CheckResult, defaultDays int, certWarned map[string]bool) (notification.StatusChangeEvent, bool) {
+	threshold := defaultDays
+	if website.CertExpiryWarningDays > 0 {
+		threshold = website.CertExpiryWarningDays
+	}
+
+	expiring := result.CertDaysRemaining < threshold
+	if !expiring {
+		delete(certWarned, website.ID)
+		return notification.StatusChangeEvent{}, false
+	}
+	if certWarned[website.ID] {
+		return notification.StatusChangeEvent{}, false
+	}
+	certWarned[website.ID] = true
+
+	event := newStatusChangeEvent(website, result, website.Status)
+	event.EventType = notification.EventCertExpiring
+	event.Reason = fmt.Sprintf("Certificate expires on %s (%d days remaining, threshold %d days)",
+		result.CertExpiresAt.Format("2006-01-02"), result.CertDaysRemaining, threshold)
+
+	return event, true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uptime-monitor/monitor"
+	"uptime-monitor/storage"
+)
+
+func TestUptimeIgnoresUnconfirmedFailures(t *testing.T) {
+	stor, err := storage.NewJSONStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	results := []monitor.CheckResult{
+		{WebsiteID: "site", Status: monitor.StatusUp, Timestamp: start},
+		{WebsiteID: "site", Status: monitor.StatusDown, Timestamp: start.Add(time.Minute), Unconfirmed: true},
+		{WebsiteID: "site", Status: monitor.StatusUp, Timestamp: start.Add(2 * time.Minute)},
+	}
+	for _, result := range results {
+		saveHistory(stor, result)
+	}
+
+	uptime, err := stor.CalculateUptime("site", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime != 100 {
+		t.Errorf("uptime = %v, want 100 with the unconfirmed failure left out", uptime)
+	}
+	outages, err := stor.GetOutages("site", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outages) != 0 {
+		t.Errorf("outages = %+v, want none", outages)
+	}
+}
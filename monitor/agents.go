@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDownQuorum is how many agents must find a website down by default
+const DefaultDownQuorum = 1
+
+// agentStaleIntervals is after how many check intervals without a result an
+// agent no longer counts towards a website's status
+const agentStaleIntervals = 3
+
+// maxAgentsPerWebsite bounds how many agents' views of a website are kept.
+// Results of further agents don't count towards its status until one of the
+// tracked agents goes stale.
+const maxAgentsPerWebsite = 32
+
+// ErrNotRunning is returned for results submitted while the engine is stopped
+var ErrNotRunning = errors.New("monitor engine is not running")
+
+// MaxResultAge is how old a result submitted by a remote agent may be
+const MaxResultAge = 5 * time.Minute
+
+// ErrStaleResult is returned for submitted results older than MaxResultAge
+// or than the agent's previous result for the website, such as a delayed or
+// replayed one, which would put history out of order
+var ErrStaleResult = errors.New("result is older than the agent's previous result or too old to apply")
+
+// ErrTooManyAgents is returned for results of an agent that would be one too
+// many for the website, see maxAgentsPerWebsite
+var ErrTooManyAgents = fmt.Errorf("results of more than %d agents per website are not accepted", maxAgentsPerWebsite)
+
+// agentView is the latest status an agent reported for a website
+type agentView struct {
+	status    string
+	timestamp time.Time
+}
+
+// SetAgent sets the region label carried by the results of this engine's
+// own checks, and how many agents must find a website down before it is
+// declared down. With fewer, the website is degraded. Only agents that
+// reported recently count, and the quorum is capped at their number, so a
+// single agent decides alone.
+func (me *MonitorEngine) SetAgent(id string, downQuorum int) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if downQuorum <= 0 {
+		downQuorum = DefaultDownQuorum
+	}
+	me.agentID = id
+	me.downQuorum = downQuorum
+}
+
+// SubmitResult hands in a check result reported by a remote agent. It is
+// processed like the results of the engine's own checks. Each agent's results
+// for a website must arrive in order and within MaxResultAge.
+func (me *MonitorEngine) SubmitResult(result CheckResult) error {
+	me.mutex.Lock()
+	if !me.running {
+		me.mutex.Unlock()
+		return ErrNotRunning
+	}
+	if views := me.agents[result.WebsiteID]; len(views) >= maxAgentsPerWebsite {
+		if _, tracked := views[result.Agent]; !tracked {
+			me.mutex.Unlock()
+			return ErrTooManyAgents
+		}
+	}
+	if !me.acceptSubmittedLocked(result) {
+		me.mutex.Unlock()
+		return ErrStaleResult
+	}
+	me.submitted.Add(1)
+	me.mutex.Unlock()
+	defer me.submitted.Done()
+
+	me.resultChan <- result
+	return nil
+}
+
+// acceptSubmittedLocked records a submitted result's timestamp as the latest
+// of its agent for the website, unless it is too old or not newer than the
+// one before. The caller must hold mutex.
+func (me *MonitorEngine) acceptSubmittedLocked(result CheckResult) bool {
+	cutoff := me.now().Add(-MaxResultAge)
+	if result.Timestamp.Before(cutoff) {
+		return false
+	}
+
+	latest, exists := me.lastSubmitted[result.WebsiteID]
+	if !exists {
+		latest = make(map[string]time.Time)
+		me.lastSubmitted[result.WebsiteID] = latest
+	}
+	if previous, exists := latest[result.Agent]; exists && !result.Timestamp.After(previous) {
+		return false
+	}
+	latest[result.Agent] = result.Timestamp
+
+	// Agents that stopped reporting are past the age limit anyway
+	for agent, timestamp := range latest {
+		if timestamp.Before(cutoff) {
+			delete(latest, agent)
+		}
+	}
+	return true
+}
+
+// localAgent returns the region label of the engine's own checks
+func (me *MonitorEngine) localAgent() string {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	return me.agentID
+}
+
+// applyQuorum records a result as its agent's latest view of the website and
+// replaces its status with the website's status across all agents
+func (me *MonitorEngine) applyQuorum(result *CheckResult) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	website, exists := me.websites[result.WebsiteID]
+	if !exists {
+		return
+	}
+	views, exists := me.agents[website.ID]
+	if !exists {
+		views = make(map[string]agentView)
+		me.agents[website.ID] = views
+	}
+
+	// Agents check a website in backoff less often too
+	cutoff := result.Timestamp.Add(-agentStaleIntervals * website.EffectiveInterval())
+	for agent, view := range views {
+		if view.timestamp.Before(cutoff) {
+			delete(views, agent)
+		}
+	}
+	if _, tracked := views[result.Agent]; tracked || len(views) < maxAgentsPerWebsite {
+		views[result.Agent] = agentView{status: result.Status, timestamp: result.Timestamp}
+	} else if result.Status == StatusDown {
+		// Not counted, so it can't declare the website down on its own
+		result.Status = StatusDegraded
+		result.Error = fmt.Errorf("down from %s, which isn't counted past %d agents", agentName(result.Agent), maxAgentsPerWebsite)
+		result.ErrorCode = ErrorCodeAgents
+	}
+
+	var down []string
+	for agent, view := range views {
+		if view.status == StatusDown {
+			down = append(down, agentName(agent))
+		}
+	}
+	sort.Strings(down)
+
+	needed := me.downQuorum
+	if needed > len(views) {
+		needed = len(views)
+	}
+	switch {
+	case len(down) >= needed:
+		if result.Status != StatusDown {
+			result.Status = StatusDown
+			result.Error = fmt.Errorf("down from %s", strings.Join(down, ", "))
+			result.ErrorCode = ErrorCodeAgents
+		}
+	case len(down) > 0:
+		result.Status = StatusDegraded
+		result.Error = fmt.Errorf("down from %s only, %d of %d agents needed", strings.Join(down, ", "), len(down), needed)
+		result.ErrorCode = ErrorCodeAgents
+	}
+}
+
+// agentName returns how an agent is referred to in messages
+func agentName(agent string) string {
+	if agent == "" {
+		return "local"
+	}
+	return agent
+}
@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestApplyQuorumCountsAgentsByEffectiveInterval(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		reportedAt time.Time // When the remote agent found the website down
+		wantStatus string
+	}{
+		// In backoff the website is checked every 8 minutes, so a report
+		// from 5 minutes ago is current even though it is over three 60s
+		// intervals old
+		{name: "recent in backoff", reportedAt: now.Add(-5 * time.Minute), wantStatus: StatusDegraded},
+		{name: "stale in backoff", reportedAt: now.Add(-30 * time.Minute), wantStatus: StatusUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			me := NewMonitorEngine()
+			me.SetAgent("us", 2)
+			website := testWebsite("site")
+			website.BackoffAfterFailures = 1
+			website.ConsecutiveFailures = 3
+			me.AddWebsite(website)
+			if interval := website.EffectiveInterval(); interval != 8*time.Minute {
+				t.Fatalf("effective interval = %s, want 8m", interval)
+			}
+
+			remote := CheckResult{WebsiteID: website.ID, Agent: "eu", Status: StatusDown, Timestamp: tt.reportedAt}
+			me.applyQuorum(&remote)
+
+			local := CheckResult{WebsiteID: website.ID, Agent: "us", Status: StatusUp, Timestamp: now}
+			me.applyQuorum(&local)
+			if local.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q (error: %v)", local.Status, tt.wantStatus, local.Error)
+			}
+		})
+	}
+}
+
+func TestApplyQuorumCapsAgents(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Paused, so only the results below count
+	website := testWebsite("site")
+	website.Enabled = false
+	me := startTestEngine(t, signalingDoer(make(chan string, 10)), nil, website)
+	me.SetAgent("", 2)
+
+	for i := 0; i < maxAgentsPerWebsite; i++ {
+		result := CheckResult{WebsiteID: website.ID, Agent: fmt.Sprintf("agent%d", i), Status: StatusUp, Timestamp: now}
+		me.applyQuorum(&result)
+	}
+
+	// One agent too many neither counts nor declares the website down alone
+	extra := CheckResult{WebsiteID: website.ID, Agent: "extra", Status: StatusDown, Timestamp: now}
+	if err := me.SubmitResult(extra); err != ErrTooManyAgents {
+		t.Errorf("submitting for one agent too many returned %v, want ErrTooManyAgents", err)
+	}
+	me.applyQuorum(&extra)
+	if extra.Status != StatusDegraded || extra.ErrorCode != ErrorCodeAgents {
+		t.Errorf("untracked down result = %q (%s), want degraded by agents", extra.Status, extra.ErrorCode)
+	}
+	me.mutex.RLock()
+	views := len(me.agents[website.ID])
+	me.mutex.RUnlock()
+	if views != maxAgentsPerWebsite {
+		t.Errorf("tracking %d agents, want %d", views, maxAgentsPerWebsite)
+	}
+
+	// Tracked agents still count, and reach the quorum
+	for _, agent := range []string{"agent0", "agent1"} {
+		result := CheckResult{WebsiteID: website.ID, Agent: agent, Status: StatusDown, Timestamp: now}
+		me.applyQuorum(&result)
+		if agent == "agent1" && result.Status != StatusDown {
+			t.Errorf("status = %q with two agents down, want down", result.Status)
+		}
+	}
+}
+
+func TestSubmitResultRejectsStaleResults(t *testing.T) {
+	// Paused, so only the results below count
+	website := testWebsite("site")
+	website.Enabled = false
+	me := startTestEngine(t, signalingDoer(make(chan string, 10)), nil, website)
+
+	now := time.Now()
+	submit := func(agent string, timestamp time.Time) error {
+		return me.SubmitResult(CheckResult{WebsiteID: website.ID, Agent: agent, Status: StatusDown, Timestamp: timestamp})
+	}
+	if err := submit("eu", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("submitting a recent result: %v", err)
+	}
+	if err := submit("eu", now.Add(-time.Minute)); err != ErrStaleResult {
+		t.Errorf("replaying a result returned %v, want ErrStaleResult", err)
+	}
+	if err := submit("eu", now.Add(-2*time.Minute)); err != ErrStaleResult {
+		t.Errorf("submitting a result older than the last returned %v, want ErrStaleResult", err)
+	}
+	if err := submit("eu", now.Add(-MaxResultAge-time.Minute)); err != ErrStaleResult {
+		t.Errorf("submitting a result past MaxResultAge returned %v, want ErrStaleResult", err)
+	}
+	// Agents are ordered separately
+	if err := submit("us", now.Add(-2*time.Minute)); err != nil {
+		t.Errorf("submitting another agent's earlier result: %v", err)
+	}
+	if err := submit("eu", now); err != nil {
+		t.Errorf("submitting a newer result: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Authentication types supported by Auth
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// Auth configures the credentials sent with each check
+type Auth struct {
+	Type     string `json:"type"` // AuthBasic or AuthBearer
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// Validate checks that the fields required by the auth type are set
+func (a *Auth) Validate() error {
+	switch a.Type {
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("username is required for basic auth")
+		}
+	case AuthBearer:
+		if a.Token == "" {
+			return fmt.Errorf("token is required for bearer auth")
+		}
+	default:
+		return fmt.Errorf("type must be %q or %q", AuthBasic, AuthBearer)
+	}
+	return nil
+}
+
+// apply sets the Authorization header on a check request
+func (a *Auth) apply(req *http.Request) {
+	switch a.Type {
+	case AuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+}
@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of a failure capture, so a website returning a huge error page
+// doesn't grow the engine's memory
+const (
+	captureBodyBytes   = 4096 // Bytes of the body kept
+	captureHeaders     = 50   // Headers kept
+	captureHeaderBytes = 512  // Bytes kept of each header value
+)
+
+// FailureCapture is what the server returned to a website's last failed HTTP
+// check, kept for debugging when the website sets CaptureOnFailure
+type FailureCapture struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	URL           string            `json:"url"` // URL of the final response after redirects
+	StatusCode    int               `json:"status_code,omitempty"`
+	Error         string            `json:"error"`
+	ErrorCode     string            `json:"error_code,omitempty"` // Set once the result is processed
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"`
+}
+
+// newFailureCapture records the response to a failed check. resp is nil when
+// the check got no response, leaving only the error; body is the part of the
+// body that was read, if any.
+func newFailureCapture(website *Website, result CheckResult, resp *http.Response, body []byte) *FailureCapture {
+	capture := &FailureCapture{
+		Timestamp: result.Timestamp,
+		URL:       website.URL,
+	}
+	if result.Error != nil {
+		capture.Error = result.Error.Error()
+	}
+	if resp == nil {
+		return capture
+	}
+
+	if result.FinalURL != "" {
+		capture.URL = result.FinalURL
+	}
+	capture.StatusCode = resp.StatusCode
+	capture.Headers = captureHeaderValues(resp.Header)
+	if len(body) > captureBodyBytes {
+		body = body[:captureBodyBytes]
+		capture.BodyTruncated = true
+	}
+	// Binary bodies can't be served as JSON strings as they are
+	capture.Body = strings.ToValidUTF8(string(body), string(utf8.RuneError))
+	return capture
+}
+
+// captureHeaderValues returns the first captureHeaders response headers in
+// name order, values of a repeated header joined and long values cut off.
+// Cookies the server sets are redacted.
+func captureHeaderValues(header http.Header) map[string]string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > captureHeaders {
+		names = names[:captureHeaders]
+	}
+
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if strings.EqualFold(name, "Set-Cookie") {
+			value = "[redacted]"
+		}
+		if len(value) > captureHeaderBytes {
+			value = value[:captureHeaderBytes]
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// LastFailure returns what the server returned to a website's last failed
+// check, if it sets CaptureOnFailure and has failed since the process started
+func (me *MonitorEngine) LastFailure(id string) (*FailureCapture, bool) {
+	me.recentMutex.RLock()
+	defer me.recentMutex.RUnlock()
+	capture, exists := me.captures[id]
+	return capture, exists
+}
+
+// recordCapture keeps a website's latest failure capture
+func (me *MonitorEngine) recordCapture(id string, capture *FailureCapture) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	me.captures[id] = capture
+}
+
+// dropCapture forgets a website's failure capture, when it is removed or
+// stops capturing
+func (me *MonitorEngine) dropCapture(id string) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	delete(me.captures, id)
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFailureCaptureLimits(t *testing.T) {
+	me := NewMonitorEngineWithDeps(fakeDoer(func(req *http.Request) (*http.Response, error) {
+		resp := fakeResponse(http.StatusInternalServerError, strings.Repeat("x", captureBodyBytes+100))
+		resp.Header.Set("Set-Cookie", "session=secret")
+		resp.Header.Set("Content-Security-Policy", strings.Repeat("v", captureHeaderBytes+1))
+		for i := 0; i < captureHeaders; i++ {
+			resp.Header.Set(fmt.Sprintf("X-Extra-%02d", i), "1")
+		}
+		return resp, nil
+	}), nil)
+
+	website := testWebsite("site")
+	website.CaptureOnFailure = true
+	capture := checkOnce(t, me, website).Capture
+	if capture == nil {
+		t.Fatal("no failure capture")
+	}
+	if capture.StatusCode != http.StatusInternalServerError || capture.Error == "" {
+		t.Errorf("capture = %d %q, want the failed response", capture.StatusCode, capture.Error)
+	}
+	if len(capture.Body) != captureBodyBytes || !capture.BodyTruncated {
+		t.Errorf("body of %d bytes, truncated %v, want the first %d", len(capture.Body), capture.BodyTruncated, captureBodyBytes)
+	}
+	if len(capture.Headers) != captureHeaders {
+		t.Errorf("%d headers, want %d", len(capture.Headers), captureHeaders)
+	}
+	if value := capture.Headers["Set-Cookie"]; value != "[redacted]" {
+		t.Errorf("Set-Cookie = %q, want it redacted", value)
+	}
+	if value := capture.Headers["Content-Security-Policy"]; len(value) != captureHeaderBytes {
+		t.Errorf("Content-Security-Policy of %d bytes, want %d", len(value), captureHeaderBytes)
+	}
+}
+
+func TestFailureCaptureOnlyForFailures(t *testing.T) {
+	website := testWebsite("up")
+	website.CaptureOnFailure = true
+	if result := checkOnce(t, NewMonitorEngineWithDeps(bodyDoer("ok"), nil), website); result.Capture != nil {
+		t.Errorf("capture = %+v for a successful check, want none", result.Capture)
+	}
+
+	failing := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		return fakeResponse(http.StatusServiceUnavailable, "down"), nil
+	})
+	if result := checkOnce(t, NewMonitorEngineWithDeps(failing, nil), testWebsite("uncaptured")); result.Capture != nil {
+		t.Errorf("capture = %+v without capture_on_failure, want none", result.Capture)
+	}
+}
+
+func TestFailureCaptureDroppedWhenCapturingStops(t *testing.T) {
+	me := NewMonitorEngine()
+	website := testWebsite("site")
+	website.CaptureOnFailure = true
+	me.AddWebsite(website)
+	me.recordCapture("site", &FailureCapture{Error: "unexpected status code 503"})
+
+	me.WebsiteChanged("site")
+	if _, exists := me.LastFailure("site"); !exists {
+		t.Fatal("capture dropped while the website still captures")
+	}
+
+	me.UpdateWebsite("site", func(website *Website) {
+		website.CaptureOnFailure = false
+	})
+	me.WebsiteChanged("site")
+	if _, exists := me.LastFailure("site"); exists {
+		t.Error("capture kept after the website stopped capturing")
+	}
+}
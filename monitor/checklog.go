@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"uptime-monitor/logger"
+)
+
+// Check logging modes, see SetCheckLogging
+const (
+	CheckLogAll     = "all"     // Log every check, successes at the debug level
+	CheckLogChanges = "changes" // Log only checks that change a website's status
+)
+
+// SetCheckLogging sets which check results are logged: every check
+// (CheckLogAll, the default) or only status changes (CheckLogChanges).
+// Websites with Verbose set log every check either way.
+func (me *MonitorEngine) SetCheckLogging(mode string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if mode != CheckLogChanges {
+		mode = CheckLogAll
+	}
+	me.checkLogging = mode
+}
+
+// websiteLogState returns what logging a website's check result depends on:
+// the website's status and whether it logs every check
+func (me *MonitorEngine) websiteLogState(id string) (status string, verbose bool) {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	if website, exists := me.websites[id]; exists {
+		return website.Status, website.Verbose
+	}
+	return "", false
+}
+
+// logResult logs a processed check result that took a website from
+// oldStatus to newStatus. Failures are worth a warning, routine successes
+// only when debugging or for verbose websites, and recoveries always.
+func (me *MonitorEngine) logResult(result CheckResult, oldStatus, newStatus string, verbose bool) {
+	me.mutex.RLock()
+	changesOnly := me.checkLogging == CheckLogChanges
+	me.mutex.RUnlock()
+
+	changed := oldStatus != newStatus
+	if changesOnly && !changed && !verbose {
+		return
+	}
+
+	switch {
+	case result.Status == StatusUp && changed && oldStatus != "" && oldStatus != StatusUnknown:
+		logger.Infof("Website %s is %s again, was %s (Response time: %dms)", result.WebsiteID, result.Status, oldStatus, result.ResponseTime)
+	case result.Status == StatusUp && verbose:
+		logger.Infof("Website %s is %s (Response time: %dms)", result.WebsiteID, result.Status, result.ResponseTime)
+	case result.Status == StatusUp:
+		logger.Debugf("Website %s is %s (Response time: %dms)", result.WebsiteID, result.Status, result.ResponseTime)
+	case result.Unconfirmed:
+		logger.Warnf("Website %s failed a check, not down until its failure threshold is met (Error: %v)", result.WebsiteID, result.Error)
+	case result.Error != nil:
+		logger.Warnf("Website %s is %s (Error: %v)", result.WebsiteID, result.Status, result.Error)
+	default:
+		logger.Warnf("Website %s is %s (Response time: %dms)", result.WebsiteID, result.Status, result.ResponseTime)
+	}
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"uptime-monitor/logger"
+)
+
+// captureLog collects what is logged until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestCheckLogging(t *testing.T) {
+	up := CheckResult{WebsiteID: "site", Status: StatusUp, ResponseTime: 100}
+	down := CheckResult{WebsiteID: "site", Status: StatusDown}
+	tests := []struct {
+		name      string
+		mode      string
+		result    CheckResult
+		oldStatus string
+		newStatus string
+		verbose   bool
+		want      string // Logged line, "" for none
+	}{
+		{name: "failure", mode: CheckLogAll, result: down, oldStatus: StatusDown, newStatus: StatusDown, want: "WARN Website site is down"},
+		{name: "repeated failure", mode: CheckLogChanges, result: down, oldStatus: StatusDown, newStatus: StatusDown},
+		{name: "new failure", mode: CheckLogChanges, result: down, oldStatus: StatusUp, newStatus: StatusDown, want: "WARN Website site is down"},
+		{name: "verbose failure", mode: CheckLogChanges, result: down, oldStatus: StatusDown, newStatus: StatusDown, verbose: true, want: "WARN Website site is down"},
+		{name: "routine success", mode: CheckLogAll, result: up, oldStatus: StatusUp, newStatus: StatusUp},
+		{name: "verbose success", mode: CheckLogChanges, result: up, oldStatus: StatusUp, newStatus: StatusUp, verbose: true, want: "INFO Website site is up"},
+		{name: "recovery", mode: CheckLogChanges, result: up, oldStatus: StatusDown, newStatus: StatusUp, want: "INFO Website site is up again, was down"},
+		{name: "first check", mode: CheckLogChanges, result: up, oldStatus: StatusUnknown, newStatus: StatusUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLog(t)
+			me := NewMonitorEngine()
+			me.SetCheckLogging(tt.mode)
+			me.logResult(tt.result, tt.oldStatus, tt.newStatus, tt.verbose)
+
+			logged := strings.TrimSpace(buf.String())
+			if tt.want == "" && logged != "" || !strings.Contains(logged, tt.want) {
+				t.Errorf("logged %q, want %q", logged, tt.want)
+			}
+		})
+	}
+
+	// Debug logging shows the routine successes
+	logger.SetLevel(logger.LevelDebug)
+	defer logger.SetLevel(logger.LevelInfo)
+	buf := captureLog(t)
+	NewMonitorEngine().logResult(up, StatusUp, StatusUp, false)
+	if !strings.Contains(buf.String(), "DEBUG Website site is up") {
+		t.Errorf("logged %q at the debug level, want the success", buf.String())
+	}
+}
@@ -0,0 +1,211 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maxContentBytes bounds how much of a response body is read by default
+const maxContentBytes = 1 << 20 // 1MB
+
+// bodyLimit returns how many bytes of a website's response body may be read
+func bodyLimit(website *Website) int64 {
+	if website.MaxBodyBytes > 0 {
+		return website.MaxBodyBytes
+	}
+	return maxContentBytes
+}
+
+// maxDrainBytes bounds how much of an unread response body is drained
+const maxDrainBytes = 4 << 10 // 4KB
+
+// discardBody reads what's left of a response body, up to maxDrainBytes, so a
+// small body doesn't keep its connection from being reused. A larger body is
+// cut off when it's closed, without downloading the rest.
+func discardBody(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+}
+
+// readBody reads up to limit bytes of the (decompressed) response body. The
+// engine sets Accept-Encoding itself, so the transport leaves bodies
+// compressed and they have to be decoded here.
+func readBody(resp *http.Response, limit int64) ([]byte, error) {
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(reader, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %v", err)
+	}
+	return body, nil
+}
+
+// decodeBody returns a reader of the response body decoded according to its
+// Content-Encoding. Only gzip and deflate are asked for in Accept-Encoding;
+// a server sending anything else, such as brotli, gets an error rather than
+// having its compressed bytes matched against.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return ioutil.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip body: %v", err)
+		}
+		return gz, nil
+	case "deflate":
+		// Deflate should come wrapped in zlib, but some servers send it raw
+		buffered := bufio.NewReader(resp.Body)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode deflate body: %v", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(buffered), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+}
+
+// bodyRegexp is a website's compiled ExpectedBodyRegex
+type bodyRegexp struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+// bodyRegexpFor returns the compiled ExpectedBodyRegex of a website, nil when
+// it has none. It is compiled once and again only when the pattern changes.
+func (me *MonitorEngine) bodyRegexpFor(website *Website) (*regexp.Regexp, error) {
+	if website.ExpectedBodyRegex == "" {
+		return nil, nil
+	}
+
+	me.regexpsMutex.Lock()
+	defer me.regexpsMutex.Unlock()
+
+	cached, exists := me.bodyRegexps[website.ID]
+	if exists && cached.pattern == website.ExpectedBodyRegex {
+		return cached.regexp, nil
+	}
+
+	compiled, err := regexp.Compile(website.ExpectedBodyRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected_body_regex: %v", err)
+	}
+	me.bodyRegexps[website.ID] = &bodyRegexp{pattern: website.ExpectedBodyRegex, regexp: compiled}
+	return compiled, nil
+}
+
+// dropBodyRegexp forgets the compiled body regexp of a removed website
+func (me *MonitorEngine) dropBodyRegexp(id string) {
+	me.regexpsMutex.Lock()
+	defer me.regexpsMutex.Unlock()
+	delete(me.bodyRegexps, id)
+}
+
+// checkContent verifies the response body against the website's content
+// expectations: the size bounds, the keyword, bodyPattern, the compiled
+// ExpectedBodyRegex, and the JSON assertions. It returns nil when the content
+// is acceptable, along with the body as read and its size, or nil and -1 when
+// the website has no expectations and the body isn't read.
+func checkContent(website *Website, resp *http.Response, bodyPattern *regexp.Regexp) ([]byte, int64, error) {
+	if website.ExpectedKeyword == "" && bodyPattern == nil && len(website.JSONAssertions) == 0 &&
+		website.MinResponseBytes == 0 && website.MaxResponseBytes == 0 {
+		return nil, -1, nil
+	}
+
+	limit := bodyLimit(website)
+	body, err := readBody(resp, limit)
+	if err != nil {
+		return nil, -1, err
+	}
+	size := int64(len(body))
+
+	if err := checkResponseSize(website, size, size >= limit); err != nil {
+		return body, size, err
+	}
+	if website.ExpectedKeyword != "" && !bytes.Contains(body, []byte(website.ExpectedKeyword)) {
+		return body, size, fmt.Errorf("expected keyword %q not found in response", website.ExpectedKeyword)
+	}
+	if bodyPattern != nil && !bodyPattern.Match(body) {
+		return body, size, fmt.Errorf("response doesn't match expected body regex %q", website.ExpectedBodyRegex)
+	}
+	if len(website.JSONAssertions) > 0 {
+		return body, size, checkJSONAssertions(website.JSONAssertions, body)
+	}
+	return body, size, nil
+}
+
+// sizeError reports a response body outside the website's size bounds
+type sizeError struct {
+	size      int64
+	truncated bool // The body was cut off at the read limit, so size is a lower bound
+	minBytes  int64
+	maxBytes  int64
+}
+
+func (e *sizeError) Error() string {
+	if e.maxBytes > 0 && e.size > e.maxBytes {
+		if e.truncated {
+			return fmt.Sprintf("response body is at least %d bytes, above the %d byte maximum", e.size, e.maxBytes)
+		}
+		return fmt.Sprintf("response body is %d bytes, above the %d byte maximum", e.size, e.maxBytes)
+	}
+	return fmt.Sprintf("response body is %d bytes, below the %d byte minimum", e.size, e.minBytes)
+}
+
+// checkResponseSize checks the size of a response body against the website's
+// MinResponseBytes and MaxResponseBytes
+func checkResponseSize(website *Website, size int64, truncated bool) error {
+	if (website.MinResponseBytes > 0 && size < website.MinResponseBytes) ||
+		(website.MaxResponseBytes > 0 && size > website.MaxResponseBytes) {
+		return &sizeError{size: size, truncated: truncated, minBytes: website.MinResponseBytes, maxBytes: website.MaxResponseBytes}
+	}
+	return nil
+}
+
+// ValidateResponseSize checks a website's response size bounds. They need a
+// body to measure, and a maximum must be below how much of the body is read,
+// maxBodyBytes or 1MB, to ever be exceeded.
+func ValidateResponseSize(method string, minBytes, maxBytes, maxBodyBytes int64) error {
+	if minBytes < 0 || maxBytes < 0 {
+		return fmt.Errorf("min_response_bytes and max_response_bytes must not be negative")
+	}
+	if minBytes == 0 && maxBytes == 0 {
+		return nil
+	}
+	if strings.EqualFold(method, http.MethodHead) {
+		return fmt.Errorf("response size bounds can't be checked with http_method HEAD, which returns no body")
+	}
+	if maxBytes > 0 && minBytes > maxBytes {
+		return fmt.Errorf("min_response_bytes must not exceed max_response_bytes")
+	}
+
+	limit := int64(maxContentBytes)
+	if maxBodyBytes > 0 {
+		limit = maxBodyBytes
+	}
+	if minBytes > limit {
+		return fmt.Errorf("min_response_bytes must not exceed the %d bytes of the body that are read (max_body_bytes)", limit)
+	}
+	if maxBytes >= limit {
+		return fmt.Errorf("max_response_bytes must be below the %d bytes of the body that are read (max_body_bytes)", limit)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package monitor
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// encodedPage is the page the encoding test server compresses
+const encodedPage = "<html><body>uptime-keyword</body></html>"
+
+// encode compresses data with a writer made by newWriter
+func encode(t *testing.T, data string, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestContentEncodings(t *testing.T) {
+	bodies := map[string][]byte{
+		"gzip": encode(t, encodedPage, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+		"zlib": encode(t, encodedPage, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+		"raw": encode(t, encodedPage, func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}),
+		"identity": []byte(encodedPage),
+	}
+
+	tests := []struct {
+		name          string
+		encoding      string
+		body          string // Key of bodies
+		keyword       string
+		wantStatus    string
+		wantErrorCode string
+		wantError     string
+	}{
+		{name: "gzip", encoding: "gzip", body: "gzip", keyword: "uptime-keyword", wantStatus: StatusUp},
+		{name: "x-gzip", encoding: "x-gzip", body: "gzip", keyword: "uptime-keyword", wantStatus: StatusUp},
+		{name: "zlib deflate", encoding: "deflate", body: "zlib", keyword: "uptime-keyword", wantStatus: StatusUp},
+		{name: "raw deflate", encoding: "deflate", body: "raw", keyword: "uptime-keyword", wantStatus: StatusUp},
+		{name: "identity", encoding: "", body: "identity", keyword: "uptime-keyword", wantStatus: StatusUp},
+		{
+			name: "gzip without the keyword", encoding: "gzip", body: "gzip", keyword: "missing-keyword",
+			wantStatus: StatusDown, wantErrorCode: ErrorCodeContent, wantError: "expected keyword",
+		},
+		{
+			name: "unsupported encoding", encoding: "br", body: "identity", keyword: "uptime-keyword",
+			wantStatus: StatusDown, wantErrorCode: ErrorCodeContent, wantError: `unsupported Content-Encoding "br"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Header().Set("Content-Type", "text/html")
+				w.Write(bodies[tt.body])
+			}))
+			defer server.Close()
+
+			website := testWebsite("site")
+			website.URL = server.URL
+			website.ExpectedKeyword = tt.keyword
+			result := checkOnce(t, NewMonitorEngine(), website)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q (error: %v)", result.Status, tt.wantStatus, result.Error)
+			}
+			if result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("error code = %q, want %q", result.ErrorCode, tt.wantErrorCode)
+			}
+			if tt.wantError != "" && (result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantError)) {
+				t.Errorf("error = %v, want it to mention %q", result.Error, tt.wantError)
+			}
+			if tt.wantStatus == StatusUp && (result.ResponseBytes == nil || *result.ResponseBytes != int64(len(encodedPage))) {
+				t.Errorf("response bytes = %v, want the %d decoded bytes", result.ResponseBytes, len(encodedPage))
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	read int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func TestUncheckedBodyIsNotDownloaded(t *testing.T) {
+	body := &countingReader{Reader: strings.NewReader(strings.Repeat("x", maxContentBytes))}
+	me := NewMonitorEngineWithDeps(fakeDoer(func(req *http.Request) (*http.Response, error) {
+		resp := fakeResponse(http.StatusOK, "")
+		resp.Body = ioutil.NopCloser(body)
+		return resp, nil
+	}), nil)
+
+	result := checkOnce(t, me, testWebsite("site"))
+	if result.Status != StatusUp {
+		t.Fatalf("status = %q, want %q (error: %v)", result.Status, StatusUp, result.Error)
+	}
+	if body.read > maxDrainBytes {
+		t.Errorf("%d bytes read, want at most %d", body.read, maxDrainBytes)
+	}
+}
+
+// bodyDoer answers every check with 200 and body
+func bodyDoer(body string) fakeDoer {
+	return func(req *http.Request) (*http.Response, error) {
+		return fakeResponse(http.StatusOK, body), nil
+	}
+}
+
+func TestExpectedBodyRegex(t *testing.T) {
+	tests := []struct {
+		name          string
+		pattern       string
+		wantStatus    string
+		wantErrorCode string
+	}{
+		{name: "match", pattern: `"status":\s*"(ok|healthy)"`, wantStatus: StatusUp},
+		{name: "no match", pattern: `"status":\s*"down"`, wantStatus: StatusDown, wantErrorCode: ErrorCodeContent},
+		{name: "invalid", pattern: `"status":(`, wantStatus: StatusError, wantErrorCode: ErrorCodeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := testWebsite("site")
+			website.ExpectedBodyRegex = tt.pattern
+			result := checkOnce(t, NewMonitorEngineWithDeps(bodyDoer(`{"status": "healthy"}`), nil), website)
+			if result.Status != tt.wantStatus || result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("status %q (%s), want %q (%s), error: %v", result.Status, result.ErrorCode, tt.wantStatus, tt.wantErrorCode, result.Error)
+			}
+		})
+	}
+}
+
+func TestExpectedBodyRegexRecompiledOnChange(t *testing.T) {
+	me := NewMonitorEngineWithDeps(bodyDoer("version 2"), nil)
+	website := testWebsite("site")
+	website.ExpectedBodyRegex = `version 1`
+	if result := checkOnce(t, me, website); result.Status != StatusDown {
+		t.Fatalf("status %q, want down on the first pattern", result.Status)
+	}
+
+	me.UpdateWebsite(website.ID, func(website *Website) { website.ExpectedBodyRegex = `version \d` })
+	updated, _ := me.GetWebsite(website.ID)
+	if result := checkOnce(t, me, updated); result.Status != StatusUp {
+		t.Errorf("status %q, want up with the changed pattern (error: %v)", result.Status, result.Error)
+	}
+}
+
+func TestResponseSizeBounds(t *testing.T) {
+	tests := []struct {
+		name         string
+		bodyBytes    int
+		minBytes     int64
+		maxBytes     int64
+		maxBodyBytes int64
+		wantError    string // Empty for up
+		wantBytes    int64
+	}{
+		{name: "within bounds", bodyBytes: 300, minBytes: 100, maxBytes: 500, wantBytes: 300},
+		{name: "below minimum", bodyBytes: 300, minBytes: 500, wantError: "response body is 300 bytes, below the 500 byte minimum", wantBytes: 300},
+		{name: "above maximum", bodyBytes: 300, maxBytes: 200, wantError: "response body is 300 bytes, above the 200 byte maximum", wantBytes: 300},
+		{name: "cut off at the read limit", bodyBytes: 5000, maxBytes: 500, maxBodyBytes: 1000, wantError: "response body is at least 1000 bytes, above the 500 byte maximum", wantBytes: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := testWebsite("site")
+			website.MinResponseBytes = tt.minBytes
+			website.MaxResponseBytes = tt.maxBytes
+			website.MaxBodyBytes = tt.maxBodyBytes
+			result := checkOnce(t, NewMonitorEngineWithDeps(bodyDoer(strings.Repeat("x", tt.bodyBytes)), nil), website)
+
+			if tt.wantError == "" {
+				if result.Status != StatusUp {
+					t.Errorf("status %q, want up (error: %v)", result.Status, result.Error)
+				}
+			} else if result.Status != StatusDown || result.ErrorCode != ErrorCodeResponseSize ||
+				result.Error == nil || result.Error.Error() != tt.wantError {
+				t.Errorf("status %q (%s), error %v, want down on %q", result.Status, result.ErrorCode, result.Error, tt.wantError)
+			}
+			if result.ResponseBytes == nil || *result.ResponseBytes != tt.wantBytes {
+				t.Errorf("response bytes = %v, want %d", result.ResponseBytes, tt.wantBytes)
+			}
+		})
+	}
+}
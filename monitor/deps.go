@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPDoer sends the requests of HTTP checks; *http.Client satisfies it
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Clock tells the engine the time, and when a time has come. It times checks
+// and status changes and drives the check schedule and retry delays.
+type Clock interface {
+	Now() time.Time
+	// TimerAt returns a timer that fires once the clock reaches at, right
+	// away if it already has
+	TimerAt(at time.Time) Timer
+}
+
+// Timer is a one-shot timer of a Clock
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing; the channel isn't closed
+	Stop() bool
+}
+
+// systemClock is the real time, the default Clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) TimerAt(at time.Time) Timer {
+	return systemTimer{time.NewTimer(time.Until(at))}
+}
+
+// systemTimer is a Timer backed by a time.Timer
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.timer.C }
+func (t systemTimer) Stop() bool          { return t.timer.Stop() }
+
+// NewMonitorEngineWithDeps creates an engine that sends HTTP checks through
+// doer and reads the time from clock, e.g. a fake server and a fake clock in
+// tests. Either may be nil to keep the default of NewMonitorEngine.
+//
+// A doer replaces every client the engine would build, so per-website
+// transport settings such as proxy_url, ip_version, client_cert,
+// insecure_skip_tls_verify and internal DNS are up to it. The clock times
+// check results, response times and status changes, and decides when checks
+// and retries are due; timeouts and network phase timings still use the real
+// time.
+func NewMonitorEngineWithDeps(doer HTTPDoer, clock Clock) *MonitorEngine {
+	me := NewMonitorEngine()
+	me.doer = doer
+	if clock != nil {
+		me.clock = clock
+	}
+	return me
+}
+
+// now returns the engine's current time
+func (me *MonitorEngine) now() time.Time {
+	return me.clock.Now()
+}
+
+// doerFor returns what sends the HTTP checks of a website: the injected doer
+// if any, else the website's client
+func (me *MonitorEngine) doerFor(website *Website) (HTTPDoer, error) {
+	if me.doer != nil {
+		return me.doer, nil
+	}
+	client, err := me.clientFor(website)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
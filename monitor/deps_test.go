@@ -0,0 +1,253 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDoer answers HTTP checks with a function instead of the network
+type fakeDoer func(req *http.Request) (*http.Response, error)
+
+func (f fakeDoer) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// fakeResponse returns a response the way a fake doer typically builds it,
+// without its Request
+func fakeResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// fakeClock is a Clock that moves forward by step every time it is read,
+// and by Advance
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	step   time.Duration
+	timers []*fakeTimer
+}
+
+func newFakeClock(step time.Duration) *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), step: step}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	c.fireLocked()
+	return now
+}
+
+// Advance moves the clock forward, firing the timers that become due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	c.fireLocked()
+}
+
+func (c *fakeClock) TimerAt(at time.Time) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &fakeTimer{clock: c, at: at, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.fireLocked()
+	return timer
+}
+
+// fireLocked fires and forgets the timers that are due. The caller must hold mutex.
+func (c *fakeClock) fireLocked() {
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+}
+
+// fakeTimer is a Timer of a fakeClock
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutErr is a network error that timed out
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+// testWebsite returns an enabled HTTP website with the given ID
+func testWebsite(id string) *Website {
+	return &Website{
+		ID:              id,
+		Name:            id,
+		URL:             "http://" + id + ".example.com/",
+		IntervalSeconds: 60,
+		Enabled:         true,
+	}
+}
+
+// checkOnce runs one check of website on an engine that isn't started and
+// returns its result
+func checkOnce(t *testing.T, me *MonitorEngine, website *Website) CheckResult {
+	t.Helper()
+	me.AddWebsite(website)
+	me.checkWebsite(website)
+	select {
+	case result := <-me.resultChan:
+		return result
+	default:
+		t.Fatal("check sent no result")
+		return CheckResult{}
+	}
+}
+
+func TestCheckClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		doer          fakeDoer
+		wantStatus    string
+		wantErrorCode string
+		wantCode      int
+	}{
+		{
+			name: "up",
+			doer: func(req *http.Request) (*http.Response, error) {
+				return fakeResponse(http.StatusOK, "ok"), nil
+			},
+			wantStatus: StatusUp,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name: "down on status",
+			doer: func(req *http.Request) (*http.Response, error) {
+				return fakeResponse(http.StatusInternalServerError, "oops"), nil
+			},
+			wantStatus:    StatusDown,
+			wantErrorCode: ErrorCodeHTTPStatus,
+			wantCode:      http.StatusInternalServerError,
+		},
+		{
+			name: "down on connection error",
+			doer: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("no route to host")
+			},
+			wantStatus:    StatusDown,
+			wantErrorCode: ErrorCodeOther,
+		},
+		{
+			name: "timeout",
+			doer: func(req *http.Request) (*http.Response, error) {
+				return nil, timeoutErr{}
+			},
+			wantStatus:    StatusDown,
+			wantErrorCode: ErrorCodeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock(250 * time.Millisecond)
+			me := NewMonitorEngineWithDeps(tt.doer, clock)
+
+			result := checkOnce(t, me, testWebsite("site"))
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q (error: %v)", result.Status, tt.wantStatus, result.Error)
+			}
+			if result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("error code = %q, want %q", result.ErrorCode, tt.wantErrorCode)
+			}
+			if result.StatusCode != tt.wantCode {
+				t.Errorf("status code = %d, want %d", result.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != 0 {
+				// Timed by the fake clock: one step between sending and the response
+				if result.ResponseTime != 250 {
+					t.Errorf("response time = %dms, want 250ms", result.ResponseTime)
+				}
+				if result.FinalURL != "http://site.example.com/" {
+					t.Errorf("final URL = %q, want the request URL", result.FinalURL)
+				}
+			}
+			if !result.Timestamp.Before(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("timestamp = %v, want a time of the fake clock", result.Timestamp)
+			}
+		})
+	}
+}
+
+func TestCheckCertificateExpiryUsesClock(t *testing.T) {
+	clock := newFakeClock(time.Millisecond)
+	expiresAt := clock.now.Add(30*24*time.Hour + 12*time.Hour)
+	me := NewMonitorEngineWithDeps(fakeDoer(func(req *http.Request) (*http.Response, error) {
+		resp := fakeResponse(http.StatusOK, "ok")
+		resp.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{NotAfter: expiresAt}}}
+		return resp, nil
+	}), clock)
+
+	website := testWebsite("site")
+	website.InsecureSkipTLSVerify = true
+	result := checkOnce(t, me, website)
+	if !result.CertExpiresAt.Equal(expiresAt) {
+		t.Errorf("certificate expiry = %v, want %v", result.CertExpiresAt, expiresAt)
+	}
+	if result.CertDaysRemaining != 30 {
+		t.Errorf("days remaining = %d, want 30", result.CertDaysRemaining)
+	}
+	if !result.TLSUnverified {
+		t.Error("an unverifiable certificate wasn't flagged")
+	}
+}
+
+func TestFailureCaptureWithoutResponseRequest(t *testing.T) {
+	me := NewMonitorEngineWithDeps(fakeDoer(func(req *http.Request) (*http.Response, error) {
+		return fakeResponse(http.StatusServiceUnavailable, "maintenance"), nil
+	}), nil)
+
+	website := testWebsite("site")
+	website.CaptureOnFailure = true
+	result := checkOnce(t, me, website)
+	if result.Capture == nil {
+		t.Fatal("no failure capture")
+	}
+	if result.Capture.URL != website.URL {
+		t.Errorf("capture URL = %q, want %q", result.Capture.URL, website.URL)
+	}
+	if result.Capture.Body != "maintenance" {
+		t.Errorf("capture body = %q, want %q", result.Capture.Body, "maintenance")
+	}
+}
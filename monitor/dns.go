@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DNS record types a DNS check can look up
+const (
+	DNSRecordA     = "A"
+	DNSRecordAAAA  = "AAAA"
+	DNSRecordCNAME = "CNAME"
+	DNSRecordMX    = "MX"
+	DNSRecordNS    = "NS"
+	DNSRecordTXT   = "TXT"
+)
+
+// ValidateDNSRecordType checks the record type of a DNS check; empty means A
+func ValidateDNSRecordType(recordType string) error {
+	switch strings.ToUpper(recordType) {
+	case "", DNSRecordA, DNSRecordAAAA, DNSRecordCNAME, DNSRecordMX, DNSRecordNS, DNSRecordTXT:
+		return nil
+	}
+	return fmt.Errorf("dns_record_type must be one of A, AAAA, CNAME, MX, NS, TXT")
+}
+
+// lookupRecords resolves the records of one type for host
+func lookupRecords(ctx context.Context, resolver *net.Resolver, recordType, host string) ([]string, error) {
+	var records []string
+
+	switch strings.ToUpper(recordType) {
+	case "", DNSRecordA, DNSRecordAAAA:
+		network := "ip4"
+		if strings.ToUpper(recordType) == DNSRecordAAAA {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case DNSRecordCNAME:
+		cname, err := resolver.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, cname)
+	case DNSRecordMX:
+		mxs, err := resolver.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			records = append(records, mx.Host)
+		}
+	case DNSRecordNS:
+		nss, err := resolver.LookupNS(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+	case DNSRecordTXT:
+		txts, err := resolver.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		records = txts
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", recordType)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no %s records found", recordType)
+	}
+	return records, nil
+}
+
+// normalizeRecord makes records comparable: names are case-insensitive and
+// may be written with or without the trailing dot
+func normalizeRecord(record string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(record)), ".")
+}
+
+// performDNSCheck resolves the website's hostname and, when an expected value
+// is set, checks that it is among the resolved records
+func (me *MonitorEngine) performDNSCheck(website *Website) CheckResult {
+	result := CheckResult{
+		WebsiteID:      website.ID,
+		Status:         StatusDown,
+		ContentMatched: true,
+	}
+
+	host, err := CheckTarget(CheckTypeDNS, website.URL)
+	if err == nil {
+		err = ValidateDNSRecordType(website.DNSRecordType)
+	}
+	if err != nil {
+		result.Status = StatusError
+		result.Timestamp = me.now()
+		result.Error = &ConfigError{Err: err}
+		return result
+	}
+
+	recordType := strings.ToUpper(website.DNSRecordType)
+	if recordType == "" {
+		recordType = DNSRecordA
+	}
+
+	timeout := checkTimeout(website)
+	ctx, cancel := context.WithTimeout(me.ctx, timeout)
+	defer cancel()
+
+	resolver := me.dialerFor(website).Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	start := me.now()
+	records, err := lookupRecords(ctx, resolver, recordType, host)
+	result.Timestamp = me.now()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = &timeoutError{message: fmt.Sprintf("%s lookup timed out after %s", recordType, timeout)}
+		}
+		result.Error = err
+		return result
+	}
+	result.ResponseTime = int(me.now().Sub(start).Milliseconds())
+
+	if website.ExpectedDNSValue != "" {
+		expected := normalizeRecord(website.ExpectedDNSValue)
+		matched := false
+		for _, record := range records {
+			if normalizeRecord(record) == expected {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			// Report what the name resolves to now, e.g. after a hijack
+			result.Error = fmt.Errorf("%s records of %s are %s, expected %s",
+				recordType, host, strings.Join(records, ", "), website.ExpectedDNSValue)
+			return result
+		}
+	}
+
+	result.Status = StatusUp
+	return result
+}
@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Error codes classify why a check failed, for display and filtering
+const (
+	ErrorCodeTimeout           = "timeout"            // The check ran out of time
+	ErrorCodeDNS               = "dns"                // The host name could not be resolved
+	ErrorCodeConnectionRefused = "connection_refused" // Nothing listens on the port
+	ErrorCodeConnectionReset   = "connection_reset"   // The server dropped the connection
+	ErrorCodeTLS               = "tls"                // Handshake or certificate failure
+	ErrorCodeHTTPStatus        = "http_status"        // The response status wasn't expected
+	ErrorCodeProtocol          = "protocol"           // The response wasn't HTTP/2 as required
+	ErrorCodeContent           = "content_mismatch"   // The response failed the content checks
+	ErrorCodeResponseSize      = "response_size"      // The response body was outside the size bounds
+	ErrorCodeSlow              = "slow_response"      // Up, but over MaxResponseTimeMs
+	ErrorCodeConfig            = "config"             // The website's settings prevent checking it
+	ErrorCodeAgents            = "agents"             // Down as seen by some agents, see SetAgent
+	ErrorCodeSubCheck          = "sub_check"          // A sub-check failed and the website requires them
+	ErrorCodeOther             = "other"              // Any other failure
+)
+
+// timeoutError reports a check attempt that ran out of time, keeping the
+// message readable while still classifying as a timeout
+type timeoutError struct {
+	message string
+}
+
+func (e *timeoutError) Error() string { return e.message }
+func (e *timeoutError) Timeout() bool { return true }
+
+// statusError reports a response whose status code the website doesn't expect
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.statusCode)
+}
+
+// protocolError reports a response over HTTP/1.x from a website that requires HTTP/2
+type protocolError struct {
+	proto string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("responded over %s instead of HTTP/2", e.proto)
+}
+
+// errorCode returns the error code of a check result, or "" if it succeeded
+func errorCode(result CheckResult) string {
+	switch {
+	case result.Status == StatusDegraded:
+		return ErrorCodeSlow
+	case result.Status == StatusUp || result.Status == StatusUnknown:
+		return ""
+	case !result.ContentMatched:
+		return ErrorCodeContent
+	case result.Error == nil:
+		return ErrorCodeOther
+	}
+	return classifyError(result.Error)
+}
+
+// classifyError maps a check error to an error code. DNS failures are
+// reported as such even when the lookup timed out.
+func classifyError(err error) string {
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return ErrorCodeConfig
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return ErrorCodeHTTPStatus
+	}
+
+	var sizeErr *sizeError
+	if errors.As(err, &sizeErr) {
+		return ErrorCodeResponseSize
+	}
+
+	var subCheckErr *subCheckError
+	if errors.As(err, &subCheckErr) {
+		return ErrorCodeSubCheck
+	}
+
+	var protocolErr *protocolError
+	if errors.As(err, &protocolErr) {
+		return ErrorCodeProtocol
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorCodeDNS
+	}
+
+	// The host only resolves to addresses of the other IP version
+	var ipVersionErr *ipVersionError
+	if errors.As(err, &ipVersionErr) {
+		return ErrorCodeDNS
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return ErrorCodeTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorCodeConnectionRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return ErrorCodeConnectionReset
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &recordHeader) {
+		return ErrorCodeTLS
+	}
+	// Handshake alerts are not exported as types
+	if strings.Contains(err.Error(), "tls: ") {
+		return ErrorCodeTLS
+	}
+
+	return ErrorCodeOther
+}
@@ -0,0 +1,83 @@
+package monitor
+
+import "time"
+
+// Flap detection defaults
+const (
+	DefaultFlapThreshold = 4
+	DefaultFlapWindow    = 10 * time.Minute
+)
+
+// flapState tracks the recent status changes of a website
+type flapState struct {
+	status   string      // Status of the last check
+	changes  []time.Time // When the status changed, within the flap window
+	flapping bool
+}
+
+// SetFlapDetection marks websites whose status changes more than threshold
+// times within window as flapping, until their status holds for a whole
+// window. A threshold of 0 disables flap detection.
+func (me *MonitorEngine) SetFlapDetection(threshold int, window time.Duration) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if window <= 0 {
+		window = DefaultFlapWindow
+	}
+	me.flapThreshold = threshold
+	me.flapWindow = window
+	me.flaps = make(map[string]*flapState)
+	for id := range me.websites {
+		me.swapLocked(id, func(website *Website) {
+			website.Flapping = false
+		})
+	}
+}
+
+// CurrentStatus returns the status the website is shown and counted with:
+// StatusFlapping while it is flapping, its Status otherwise
+func (w *Website) CurrentStatus() string {
+	if w.Flapping {
+		return StatusFlapping
+	}
+	return w.Status
+}
+
+// updateFlappingLocked records status, what a website's latest check found
+// before FailureThreshold held back a down status, and sets the website's
+// Flapping flag. The caller must hold me.mutex.
+func (me *MonitorEngine) updateFlappingLocked(website *Website, status string, now time.Time) {
+	if me.flapThreshold <= 0 {
+		return
+	}
+
+	state, exists := me.flaps[website.ID]
+	if !exists {
+		state = &flapState{}
+		me.flaps[website.ID] = state
+	}
+	if state.status != "" && state.status != status {
+		state.changes = append(state.changes, now)
+	}
+	state.status = status
+
+	cutoff := now.Add(-me.flapWindow)
+	for len(state.changes) > 0 && !state.changes[0].After(cutoff) {
+		state.changes = state.changes[1:]
+	}
+
+	if len(state.changes) > me.flapThreshold {
+		state.flapping = true
+	} else if len(state.changes) == 0 {
+		state.flapping = false
+	}
+	website.Flapping = state.flapping
+}
+
+// isFlapping reports whether a website is flapping
+func (me *MonitorEngine) isFlapping(id string) bool {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	state, exists := me.flaps[id]
+	return exists && state.flapping
+}
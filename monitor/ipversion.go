@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IP versions a website's checks can be forced to connect over
+const (
+	IPVersionAuto = "auto" // Either, as the resolver and dialer choose; the default
+	IPVersionIPv4 = "ipv4"
+	IPVersionIPv6 = "ipv6"
+)
+
+// ValidateIPVersion checks the IP version of a website; empty means auto.
+// Forcing one is supported for HTTP, TCP and ICMP checks without a proxy.
+func ValidateIPVersion(ipVersion, checkType, proxyURL string) error {
+	switch strings.ToLower(ipVersion) {
+	case "", IPVersionAuto:
+		return nil
+	case IPVersionIPv4, IPVersionIPv6:
+	default:
+		return fmt.Errorf("ip_version must be one of auto, ipv4, ipv6")
+	}
+	if strings.EqualFold(checkType, CheckTypeDNS) {
+		return fmt.Errorf("ip_version is not supported for dns checks")
+	}
+	if proxyURL != "" {
+		return fmt.Errorf("ip_version can't be forced for checks through a proxy_url")
+	}
+	return nil
+}
+
+// forcedIPVersion returns the IP version a website's checks must use, or ""
+// when either will do
+func forcedIPVersion(website *Website) string {
+	switch strings.ToLower(website.IPVersion) {
+	case IPVersionIPv4:
+		return IPVersionIPv4
+	case IPVersionIPv6:
+		return IPVersionIPv6
+	}
+	return ""
+}
+
+// ipVersionError reports a host without an address in the forced IP version
+type ipVersionError struct {
+	ipVersion string
+	host      string
+}
+
+func (e *ipVersionError) Error() string {
+	if e.ipVersion == IPVersionIPv6 {
+		return fmt.Sprintf("no IPv6 address for %s", e.host)
+	}
+	return fmt.Sprintf("no IPv4 address for %s", e.host)
+}
+
+// ipNetwork narrows a network name such as "tcp" or "ip" to an IP version
+func ipNetwork(network, ipVersion string) string {
+	switch ipVersion {
+	case IPVersionIPv4:
+		return network + "4"
+	case IPVersionIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// dialIPVersion returns a dial function connecting over ipVersion only, or
+// over either when it is empty
+func dialIPVersion(dialer *net.Dialer, ipVersion string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if ipVersion == "" {
+			return dialer.DialContext(ctx, network, address)
+		}
+		conn, err := dialer.DialContext(ctx, ipNetwork(network, ipVersion), address)
+
+		// The dialer reports a host with addresses of the other version only this way
+		var addrErr *net.AddrError
+		if errors.As(err, &addrErr) && addrErr.Err == "no suitable address found" {
+			return nil, &ipVersionError{ipVersion: ipVersion, host: addrErr.Addr}
+		}
+		return conn, err
+	}
+}
@@ -0,0 +1,202 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultMaxChecksPerHost is the default number of simultaneous checks against one host
+const DefaultMaxChecksPerHost = 2
+
+// checkJobRetention is how long finished check jobs are kept for polling
+const checkJobRetention = time.Hour
+
+// CheckJob tracks the progress of an on-demand check of all websites
+type CheckJob struct {
+	ID         string     `json:"id"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Done       bool       `json:"done"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// SetMaxChecksPerHost sets how many checks may run against the same host at once
+func (me *MonitorEngine) SetMaxChecksPerHost(limit int) {
+	me.hostMutex.Lock()
+	defer me.hostMutex.Unlock()
+	if limit < 1 {
+		limit = DefaultMaxChecksPerHost
+	}
+	me.maxChecksPerHost = limit
+	me.hostSlots = make(map[string]chan struct{})
+}
+
+// acquireHost blocks until a check slot for the website's host is free and
+// returns a function that releases it. It returns false without a slot if
+// the engine stops while waiting.
+func (me *MonitorEngine) acquireHost(host string) (func(), bool) {
+	if host == "" {
+		return func() {}, true
+	}
+
+	me.hostMutex.Lock()
+	slots, exists := me.hostSlots[host]
+	if !exists {
+		slots = make(chan struct{}, me.maxChecksPerHost)
+		me.hostSlots[host] = slots
+	}
+	me.hostMutex.Unlock()
+
+	// Prefer a free slot over stopping, like acquireCheckSlot
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-me.stopChan:
+		return nil, false
+	}
+}
+
+// CheckAll queues an immediate check of every enabled website for the
+// workers and returns the job tracking its progress. Checks run in the
+// background, within the engine's concurrency limits. Websites whose check is
+// already running are left to it and not counted in the job, and so is every
+// website while the engine isn't running.
+func (me *MonitorEngine) CheckAll() CheckJob {
+	startedAt := me.now()
+	var websites []*Website
+	me.ForEachWebsite(func(website *Website) {
+		if website.Enabled && website.IsActiveAt(startedAt) {
+			websites = append(websites, website)
+		}
+	})
+	websites = me.claimChecks(websites)
+
+	// Registered under the lock so Stop waits for the checks to be handed out
+	me.mutex.RLock()
+	running := me.running
+	if running && len(websites) > 0 {
+		me.jobChecks.Add(1)
+	}
+	me.mutex.RUnlock()
+	if !running {
+		for _, website := range websites {
+			me.checkDone(website.ID)
+		}
+		websites = nil
+	}
+
+	job := &CheckJob{
+		Total:     len(websites),
+		StartedAt: startedAt,
+	}
+
+	me.jobsMutex.Lock()
+	me.pruneJobsLocked()
+	job.ID = me.newJobIDLocked(startedAt)
+	me.jobs[job.ID] = job
+	if job.Total == 0 {
+		me.finishJobLocked(job)
+	}
+	snapshot := *job
+	me.jobsMutex.Unlock()
+
+	if len(websites) > 0 {
+		go me.queueJobChecks(job, websites)
+	}
+	return snapshot
+}
+
+// queueJobChecks hands a job's checks to the workers, one goroutine feeding
+// them all however many websites there are. If the engine stops first, the
+// remaining websites are released and the job never completes.
+func (me *MonitorEngine) queueJobChecks(job *CheckJob, websites []*Website) {
+	defer me.jobChecks.Done()
+
+	for i, website := range websites {
+		select {
+		case me.checkQueue <- checkRequest{website: website, job: job}:
+		case <-me.stopChan:
+			for _, website := range websites[i:] {
+				me.checkDone(website.ID)
+			}
+			return
+		}
+	}
+}
+
+// jobCheckDone counts a finished check towards its job
+func (me *MonitorEngine) jobCheckDone(job *CheckJob) {
+	me.jobsMutex.Lock()
+	defer me.jobsMutex.Unlock()
+
+	job.Completed++
+	if job.Completed == job.Total {
+		me.finishJobLocked(job)
+	}
+}
+
+// claimChecks marks websites as being checked, as the scheduler does before
+// handing one to a worker, so no two checks of a website run at once and
+// report out of order. It returns the websites that weren't already.
+func (me *MonitorEngine) claimChecks(websites []*Website) []*Website {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+
+	claimed := websites[:0]
+	for _, website := range websites {
+		if me.inFlight[website.ID] {
+			continue
+		}
+		me.inFlight[website.ID] = true
+		claimed = append(claimed, website)
+	}
+	return claimed
+}
+
+// newJobIDLocked returns an ID for a job started at startedAt that no job
+// uses yet, e.g. when a clock hasn't moved since the last one. The caller
+// must hold jobsMutex.
+func (me *MonitorEngine) newJobIDLocked(startedAt time.Time) string {
+	for n := startedAt.UnixNano(); ; n++ {
+		id := fmt.Sprintf("job_%d", n)
+		if _, exists := me.jobs[id]; !exists {
+			return id
+		}
+	}
+}
+
+// GetCheckJob returns the current state of a check job
+func (me *MonitorEngine) GetCheckJob(id string) (CheckJob, bool) {
+	me.jobsMutex.Lock()
+	defer me.jobsMutex.Unlock()
+
+	job, exists := me.jobs[id]
+	if !exists {
+		return CheckJob{}, false
+	}
+	return *job, true
+}
+
+// finishJobLocked marks a job as done. The caller must hold jobsMutex.
+func (me *MonitorEngine) finishJobLocked(job *CheckJob) {
+	now := me.now()
+	job.Done = true
+	job.FinishedAt = &now
+}
+
+// pruneJobsLocked drops jobs that finished long ago. The caller must hold jobsMutex.
+func (me *MonitorEngine) pruneJobsLocked() {
+	now := me.now()
+	for id, job := range me.jobs {
+		if job.Done && now.Sub(*job.FinishedAt) > checkJobRetention {
+			delete(me.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitForJob waits until a check job is done
+func waitForJob(t *testing.T, me *MonitorEngine, id string) CheckJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, exists := me.GetCheckJob(id)
+		if !exists {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Done {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s not done in time", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// isCheckRunning reports whether a website is marked as being checked
+func isCheckRunning(me *MonitorEngine, id string) bool {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+	return me.inFlight[id]
+}
+
+func TestCheckAllSkipsRunningChecks(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	defer close(release)
+	// Checks of busy hang until the test ends
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		started <- req.URL.Host
+		if req.URL.Host == "busy.example.com" {
+			<-release
+		}
+		return fakeResponse(http.StatusOK, "ok"), nil
+	})
+
+	busy := testWebsite("busy")
+	busy.IntervalSeconds = 3600
+	idle := testWebsite("idle")
+	idle.IntervalSeconds = 3600
+	me := startTestEngine(t, doer, nil, busy, idle)
+	waitForCheck(t, started, 2*time.Second)
+	waitForCheck(t, started, 2*time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for isCheckRunning(me, idle.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled check of idle didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// busy's scheduled check is still running, so only idle is checked
+	job := me.CheckAll()
+	if job.Total != 1 {
+		t.Fatalf("job checks %d websites, want only the idle one", job.Total)
+	}
+	if host := waitForCheck(t, started, 2*time.Second); host != "idle.example.com" {
+		t.Errorf("checked %s, want idle.example.com", host)
+	}
+	waitForJob(t, me, job.ID)
+
+	if isCheckRunning(me, idle.ID) {
+		t.Error("idle website still marked as being checked after the job")
+	}
+	if !isCheckRunning(me, busy.ID) {
+		t.Error("busy website no longer marked as being checked")
+	}
+	select {
+	case host := <-started:
+		t.Errorf("%s checked again while its check was running", host)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCheckAllQueuesToWorkers(t *testing.T) {
+	const sites = 200
+
+	// Two workers; the clock stands still so websites are only checked once
+	// by the scheduler
+	doer := newConcurrencyDoer(2 * sites)
+	me := NewMonitorEngineWithDeps(doer, newFakeClock(0))
+	me.SetMaxConcurrentChecks(2)
+	me.SetMaxStartupJitter(0)
+	for i := 0; i < sites; i++ {
+		website := testWebsite(fmt.Sprintf("site%d", i))
+		website.IntervalSeconds = 3600
+		me.AddWebsite(website)
+	}
+	me.Start()
+	defer me.Stop()
+	go func() {
+		for range me.GetResultChannel() {
+		}
+	}()
+	waitForCheck(t, doer.started, 2*time.Second)
+	waitForCheck(t, doer.started, 2*time.Second)
+
+	// The job's checks wait for the busy workers instead of each starting
+	// a goroutine of its own
+	before := runtime.NumGoroutine()
+	job := me.CheckAll()
+	if job.Total == 0 {
+		t.Fatal("job checks no websites")
+	}
+	if started := runtime.NumGoroutine() - before; started > 5 {
+		t.Errorf("CheckAll started %d goroutines for %d websites", started, job.Total)
+	}
+
+	close(doer.release)
+	if done := waitForJob(t, me, job.ID); done.Completed != job.Total {
+		t.Errorf("job completed %d of %d checks", done.Completed, job.Total)
+	}
+	if max := doer.maxInFlight(); max > 2 {
+		t.Errorf("%d checks ran at once, want at most the 2 workers", max)
+	}
+}
+
+func TestAcquireHostStopsWithEngine(t *testing.T) {
+	me := startTestEngine(t, signalingDoer(make(chan string, 10)), nil)
+	me.SetMaxChecksPerHost(1)
+
+	release, acquired := me.acquireHost("example.com")
+	if !acquired {
+		t.Fatal("the host's only slot wasn't acquired")
+	}
+	defer release()
+
+	// Waiting for the taken slot gives up when the engine stops
+	waited := make(chan bool)
+	go func() {
+		_, acquired := me.acquireHost("example.com")
+		waited <- acquired
+	}()
+	me.Stop()
+	select {
+	case acquired := <-waited:
+		if acquired {
+			t.Error("a taken slot was acquired")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("acquireHost still waiting after Stop")
+	}
+}
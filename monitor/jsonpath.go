@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONAssertion requires the value at Path in a JSON response body to equal
+// Expected, e.g. {"path": "$.status", "expected": "ok"}. Paths start at the
+// document root "$" and select object members with .name or ['name'] and
+// array elements with [index].
+type JSONAssertion struct {
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected"`
+}
+
+// String renders the assertion as path == expected
+func (a JSONAssertion) String() string {
+	expected, _ := json.Marshal(a.Expected)
+	return fmt.Sprintf("%s == %s", a.Path, expected)
+}
+
+// assertionError reports a JSON assertion the response body failed
+type assertionError struct {
+	assertion JSONAssertion
+	message   string
+}
+
+func (e *assertionError) Error() string {
+	return fmt.Sprintf("assertion %s failed: %s", e.assertion, e.message)
+}
+
+// jsonPathStep selects an object member by key or an array element by index
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// ValidateJSONPath checks that path is a JSON path assertions support
+func ValidateJSONPath(path string) error {
+	_, err := parseJSONPath(path)
+	return err
+}
+
+// parseJSONPath splits a path such as $.checks[0]['db name'] into its steps
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path %q must start with $", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("path %q has an empty member name", path)
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", path)
+			}
+			selector := rest[1:end]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				steps = append(steps, jsonPathStep{key: selector[1 : len(selector)-1]})
+			} else if index, err := strconv.Atoi(selector); err == nil && index >= 0 {
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("path %q has an invalid selector [%s]", path, selector)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %q is invalid at %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath returns the value the steps select in a decoded JSON document
+func lookupJSONPath(document interface{}, steps []jsonPathStep) (interface{}, bool) {
+	value := document
+	for _, step := range steps {
+		if step.isIndex {
+			array, ok := value.([]interface{})
+			if !ok || step.index >= len(array) {
+				return nil, false
+			}
+			value = array[step.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// checkJSONAssertions evaluates assertions against a JSON response body and
+// returns an *assertionError for the first one that fails
+func checkJSONAssertions(assertions []JSONAssertion, body []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return fmt.Errorf("response is not valid JSON: %v", err)
+	}
+
+	for _, assertion := range assertions {
+		steps, err := parseJSONPath(assertion.Path)
+		if err != nil {
+			return &assertionError{assertion: assertion, message: err.Error()}
+		}
+		actual, found := lookupJSONPath(document, steps)
+		if !found {
+			return &assertionError{assertion: assertion, message: "path not found"}
+		}
+		if !reflect.DeepEqual(actual, normalizeJSON(assertion.Expected)) {
+			got, _ := json.Marshal(actual)
+			return &assertionError{assertion: assertion, message: fmt.Sprintf("got %s", got)}
+		}
+	}
+	return nil
+}
+
+// normalizeJSON converts a value to what decoding it from JSON yields, so
+// e.g. an int set from code compares equal to the float64 in the body
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if json.Unmarshal(data, &normalized) != nil {
+		return value
+	}
+	return normalized
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []jsonPathStep
+		wantErr bool
+	}{
+		{path: "$", want: nil},
+		{path: "$.status", want: []jsonPathStep{{key: "status"}}},
+		{path: "$.checks[1].name", want: []jsonPathStep{{key: "checks"}, {index: 1, isIndex: true}, {key: "name"}}},
+		{path: "$['db name'][\"up\"]", want: []jsonPathStep{{key: "db name"}, {key: "up"}}},
+		{path: "status", wantErr: true},
+		{path: "$..status", wantErr: true},
+		{path: "$.checks[", wantErr: true},
+		{path: "$.checks[-1]", wantErr: true},
+		{path: "$.checks[*]", wantErr: true},
+		{path: "$status", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			steps, err := parseJSONPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want one: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(steps) != len(tt.want) {
+				t.Fatalf("steps = %+v, want %+v", steps, tt.want)
+			}
+			for i := range steps {
+				if steps[i] != tt.want[i] {
+					t.Errorf("step %d = %+v, want %+v", i, steps[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckJSONAssertions(t *testing.T) {
+	body := []byte(`{"status": "ok", "version": 3, "checks": [{"name": "db", "up": true}, {"name": "cache", "up": false}], "extra": null}`)
+	tests := []struct {
+		name       string
+		assertions []JSONAssertion
+		wantErr    string
+	}{
+		{name: "string", assertions: []JSONAssertion{{Path: "$.status", Expected: "ok"}}},
+		{name: "number set from code", assertions: []JSONAssertion{{Path: "$.version", Expected: 3}}},
+		{name: "array element", assertions: []JSONAssertion{{Path: "$.checks[0].up", Expected: true}}},
+		{name: "null", assertions: []JSONAssertion{{Path: "$.extra", Expected: nil}}},
+		{name: "object", assertions: []JSONAssertion{{Path: "$.checks[1]", Expected: map[string]interface{}{"name": "cache", "up": false}}}},
+		{
+			name:       "first failure reported",
+			assertions: []JSONAssertion{{Path: "$.status", Expected: "ok"}, {Path: "$.checks[1].up", Expected: true}, {Path: "$.version", Expected: 4}},
+			wantErr:    `assertion $.checks[1].up == true failed: got false`,
+		},
+		{name: "missing member", assertions: []JSONAssertion{{Path: "$.uptime", Expected: 1}}, wantErr: `assertion $.uptime == 1 failed: path not found`},
+		{name: "index out of range", assertions: []JSONAssertion{{Path: "$.checks[2]", Expected: nil}}, wantErr: `assertion $.checks[2] == null failed: path not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkJSONAssertions(tt.assertions, body)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			var assertErr *assertionError
+			if !errors.As(err, &assertErr) || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want the assertion error %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := checkJSONAssertions([]JSONAssertion{{Path: "$.status", Expected: "ok"}}, []byte("<html>")); err == nil {
+		t.Error("a body that isn't JSON passed")
+	}
+}
+
+func TestJSONAssertionFailureIsReported(t *testing.T) {
+	website := testWebsite("site")
+	website.JSONAssertions = []JSONAssertion{{Path: "$.status", Expected: "ok"}}
+	result := checkOnce(t, NewMonitorEngineWithDeps(bodyDoer(`{"status": "degraded"}`), nil), website)
+	if result.Status != StatusDown || result.ErrorCode != ErrorCodeContent {
+		t.Errorf("status %q (%s), want down on content", result.Status, result.ErrorCode)
+	}
+	if result.FailedAssertion != `$.status == "ok"` {
+		t.Errorf("failed assertion = %q, want the one that failed", result.FailedAssertion)
+	}
+}
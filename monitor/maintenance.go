@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaintenanceWindow is a period during which a website's alerts are
+// suppressed. It is either a one-off range, when StartsAt and EndsAt are set,
+// or a weekly recurring window given by the embedded TimeWindow fields.
+type MaintenanceWindow struct {
+	TimeWindow
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+// OneOff reports whether the window is a single range rather than recurring
+func (w MaintenanceWindow) OneOff() bool {
+	return w.StartsAt != nil || w.EndsAt != nil
+}
+
+// Validate checks that the window is well-formed
+func (w MaintenanceWindow) Validate() error {
+	if !w.OneOff() {
+		return w.TimeWindow.Validate()
+	}
+	if w.StartsAt == nil || w.EndsAt == nil {
+		return fmt.Errorf("one-off windows need both starts_at and ends_at")
+	}
+	if !w.EndsAt.After(*w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// Contains reports whether t falls inside the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if !w.OneOff() {
+		return w.TimeWindow.Contains(t)
+	}
+	if w.StartsAt == nil || w.EndsAt == nil {
+		return false
+	}
+	return !t.Before(*w.StartsAt) && t.Before(*w.EndsAt)
+}
+
+// InMaintenanceAt reports whether t falls inside one of the website's
+// maintenance windows
+func (w *Website) InMaintenanceAt(t time.Time) bool {
+	for _, window := range w.MaintenanceWindows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,57 +1,371 @@
 package monitor
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Website status values
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusUnknown  = "unknown"  // Not checked yet
+	StatusError    = "error"    // Check could not run because of a configuration problem
+	StatusDegraded = "degraded" // Up, but slower than the website's MaxResponseTimeMs
+	StatusFlapping = "flapping" // Changing status too often, see SetFlapDetection
+)
+
+// DefaultTimeoutSeconds is the check timeout for websites without their own
+const DefaultTimeoutSeconds = 30
+
 // Website represents a website to monitor
 type Website struct {
-	ID                string    `json:"id"`
-	Name              string    `json:"name"`
-	URL               string    `json:"url"`
-	IntervalSeconds   int       `json:"interval_seconds"`
-	Status            string    `json:"status"`
-	LastCheckTime     time.Time `json:"last_check_time"`
-	LastResponseTime  int       `json:"last_response_time_ms"`
-	NotificationEmails []string `json:"notification_emails"`
-	SlackWebhook      string    `json:"slack_webhook"`
-	Enabled           bool      `json:"enabled"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// CheckType is "http" (the default), "tcp", "icmp" or "dns". TCP checks
+	// connect to URL as host:port, ICMP checks ping URL as a host and DNS
+	// checks resolve it.
+	CheckType string `json:"check_type,omitempty"`
+	// DNSRecordType is the record a DNS check looks up, A by default
+	DNSRecordType string `json:"dns_record_type,omitempty"`
+	// ExpectedDNSValue must be among the records a DNS check resolves; empty
+	// only requires the lookup to succeed
+	ExpectedDNSValue   string    `json:"expected_dns_value,omitempty"`
+	IntervalSeconds    int       `json:"interval_seconds"`
+	Status             string    `json:"status"`
+	LastCheckTime      time.Time `json:"last_check_time"`
+	LastResponseTime   int       `json:"last_response_time_ms"`
+	NotificationEmails []string  `json:"notification_emails"`
+	SlackWebhook       string    `json:"slack_webhook"`
+	Enabled            bool      `json:"enabled"`
+
+	// GenericWebhook receives a JSON POST on status changes, with the body
+	// rendered from WebhookTemplate (a text/template) when it is set
+	GenericWebhook  string `json:"generic_webhook,omitempty"`
+	WebhookTemplate string `json:"webhook_template,omitempty"`
+
+	// TelegramChatID receives Telegram notifications (requires a bot token)
+	TelegramChatID string `json:"telegram_chat_id,omitempty"`
+
+	// TeamsWebhook receives Microsoft Teams notifications as MessageCards
+	TeamsWebhook string `json:"teams_webhook,omitempty"`
+
+	// NotificationThrottleSeconds is the minimum time between notifications.
+	// Nil uses the default of 5 minutes, 0 disables throttling.
+	NotificationThrottleSeconds *int `json:"notification_throttle_seconds,omitempty"`
+
+	// NotifyOn limits status change notifications to "down" or "up"; empty or "both" sends both
+	NotifyOn string `json:"notify_on,omitempty"`
+
+	// EscalationAfterSeconds repeats the down notification at this interval
+	// while the website stays down; 0 disables reminders
+	EscalationAfterSeconds int `json:"escalation_after_seconds,omitempty"`
+
+	// Tags group websites, e.g. by client
+	Tags []string `json:"tags,omitempty"`
+
+	// TLS certificate expiry seen on the last HTTPS check
+	CertExpiresAt     time.Time `json:"cert_expires_at"`
+	CertDaysRemaining int       `json:"cert_days_remaining"`
+
+	// CertExpiryWarningDays overrides the global expiry warning threshold (0 uses the default)
+	CertExpiryWarningDays int `json:"cert_expiry_warning_days,omitempty"`
+
+	// LastError describes why the last check failed, and LastErrorCode
+	// classifies it (see the ErrorCode constants). Both are empty after a
+	// successful check.
+	LastError     string `json:"last_error,omitempty"`
+	LastErrorCode string `json:"last_error_code,omitempty"`
+
+	// StatusStale is set while Status is the last known value from before a restart
+	StatusStale bool `json:"status_stale"`
+
+	// Flapping is set while the website's status changes too often, see
+	// SetFlapDetection. Status keeps following its checks meanwhile.
+	Flapping bool `json:"-"`
+
+	// Request to send; defaults to GET without a body
+	HTTPMethod  string `json:"http_method,omitempty"`
+	RequestBody string `json:"request_body,omitempty"`
+	ContentType string `json:"content_type,omitempty"` // Content-Type of RequestBody
+
+	// Headers are added to every check request. They override the defaults the
+	// monitor sets itself (User-Agent, Accept, Accept-Language, Accept-Encoding,
+	// Connection, Upgrade-Insecure-Requests, Content-Type). "Host" sets the
+	// virtual host requested instead of being sent as a plain header.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// TimeoutSeconds bounds each check attempt; 0 uses DefaultTimeoutSeconds
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// PagerDuty opens an incident on the configured PagerDuty service while
+	// the website is down
+	PagerDuty bool `json:"pagerduty,omitempty"`
+
+	// MaxBodyBytes bounds how much of a response body an HTTP check reads,
+	// for keyword matching or otherwise; 0 uses maxContentBytes. A larger
+	// body is cut off without failing the check.
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+
+	// MinResponseBytes and MaxResponseBytes bound the size of the
+	// (decompressed) response body of a healthy HTTP check; a body outside
+	// them reports the site as down. 0 leaves a bound unset.
+	MinResponseBytes int64 `json:"min_response_bytes,omitempty"`
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty"`
+
+	// MaxResponseTimeMs reports successful checks slower than this as
+	// "degraded" instead of "up"; 0 disables the threshold
+	MaxResponseTimeMs int `json:"max_response_time_ms,omitempty"`
+
+	// FollowRedirects controls whether checks follow redirects; nil follows
+	// them. When they aren't followed, the 3xx response is the final status.
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+	// MaxRedirects limits the redirects followed; 0 uses DefaultMaxRedirects
+	MaxRedirects int `json:"max_redirects,omitempty"`
+	// FinalURL is where the last HTTP check ended up after any redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// Protocol is the HTTP version the last HTTP check's response used, e.g. "HTTP/2.0"
+	Protocol string `json:"protocol,omitempty"`
+
+	// RemoteIP is the IP address the last check connected to, or last tried to
+	RemoteIP string `json:"remote_ip,omitempty"`
+
+	// IPVersion forces checks to connect over "ipv4" or "ipv6" only; empty
+	// or "auto" uses either. A host without an address in the forced
+	// version is down.
+	IPVersion string `json:"ip_version,omitempty"`
+
+	// RequireHTTP2 reports an HTTPS website as down when it answers over
+	// HTTP/1.x instead of negotiating HTTP/2
+	RequireHTTP2 bool `json:"require_http2,omitempty"`
+
+	// Retry a failed check this many times, waiting RetryDelaySeconds between
+	// attempts, before reporting the site as down
+	RetryCount        int `json:"retry_count,omitempty"`
+	RetryDelaySeconds int `json:"retry_delay_seconds,omitempty"`
+
+	// ExpectedStatusCodes lists the status codes that count as up; empty means 200-399
+	ExpectedStatusCodes []int `json:"expected_status_codes,omitempty"`
+
+	// ExpectedKeyword must appear in the response body for the site to be up
+	ExpectedKeyword string `json:"expected_keyword,omitempty"`
+
+	// ExpectedBodyRegex is a regular expression the response body must match
+	// for the site to be up
+	ExpectedBodyRegex string `json:"expected_body_regex,omitempty"`
+
+	// JSONAssertions are evaluated against the response body parsed as JSON;
+	// the site is down if the body isn't JSON or any of them fails
+	JSONAssertions []JSONAssertion `json:"json_assertions,omitempty"`
+
+	// Internal marks a site only reachable from the private network
+	Internal bool `json:"internal,omitempty"`
+
+	// ProxyURL routes HTTP checks through an http://, https:// or socks5://
+	// proxy; empty connects directly
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// InsecureSkipTLSVerify accepts any server certificate, e.g. for staging
+	// servers with self-signed certificates. Only this website's checks skip
+	// verification; they run on a dedicated client.
+	InsecureSkipTLSVerify bool `json:"insecure_skip_tls_verify,omitempty"`
+	// TLSUnverified is set when the last check only succeeded because
+	// certificate verification was skipped
+	TLSUnverified bool `json:"tls_unverified,omitempty"`
+
+	// ClientCert is presented to servers that require mutual TLS
+	ClientCert *ClientCertificate `json:"client_cert,omitempty"`
+
+	// Auth sets the Authorization header of each check
+	Auth *Auth `json:"auth,omitempty"`
+
+	// Hold back recovery notifications until the site has been up for this
+	// many consecutive checks and/or seconds (0 disables each condition)
+	RecoveryConfirmChecks  int `json:"recovery_confirm_checks,omitempty"`
+	RecoveryConfirmSeconds int `json:"recovery_confirm_seconds,omitempty"`
+
+	// Check a site that stays down less often: once it has failed
+	// BackoffAfterFailures checks in a row (0 disables backoff), each further
+	// failure multiplies the interval by BackoffFactor, up to
+	// BackoffMaxIntervalSeconds. The interval is back to normal on recovery.
+	BackoffAfterFailures      int     `json:"backoff_after_failures,omitempty"`
+	BackoffFactor             float64 `json:"backoff_factor,omitempty"`
+	BackoffMaxIntervalSeconds int     `json:"backoff_max_interval_seconds,omitempty"`
+
+	// FailureThreshold is how many checks in a row must fail before the
+	// website is declared down and notified about; 0 and 1 declare it down
+	// on the first failed check. A retried check counts once.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+
+	// CaptureOnFailure keeps the headers and start of the body of the last
+	// failed HTTP check in memory, see LastFailure
+	CaptureOnFailure bool `json:"capture_on_failure,omitempty"`
+
+	// SubChecks are paths such as /robots.txt requested after each HTTP
+	// check. With RequireSubChecks the website is down when one fails.
+	SubChecks        []SubCheck `json:"sub_checks,omitempty"`
+	RequireSubChecks bool       `json:"require_sub_checks,omitempty"`
+
+	// Verbose logs every check of the website, successes included, even when
+	// the engine only logs status changes
+	Verbose bool `json:"verbose,omitempty"`
+
+	// Consecutive-success tracking, maintained by the engine
+	ConsecutiveSuccesses int       `json:"consecutive_successes"`
+	UpSince              time.Time `json:"up_since"`
+
+	// Consecutive-failure tracking for backoff and FailureThreshold, maintained by the engine
+	ConsecutiveFailures int `json:"consecutive_failures"`
+
+	// Checks run and checks that failed since the process started, maintained by the engine
+	ChecksTotal   uint64 `json:"-"`
+	FailuresTotal uint64 `json:"-"`
+
+	// ActiveSchedule limits monitoring to these windows; empty means always active
+	ActiveSchedule []TimeWindow `json:"active_schedule,omitempty"`
+
+	// MaintenanceWindows suppress notifications while checks keep running
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows,omitempty"`
+
+	// UptimeAlert replaces per-transition notifications with a rolling uptime rule
+	UptimeAlert *UptimeAlert `json:"uptime_alert,omitempty"`
+}
+
+// UptimeAlert notifies when rolling uptime over a window drops below a threshold
+type UptimeAlert struct {
+	WindowMinutes    int     `json:"window_minutes"`
+	ThresholdPercent float64 `json:"threshold_percent"`
 }
 
 // CheckResult represents the result of a website check
 type CheckResult struct {
-	WebsiteID    string
-	Status       string
-	ResponseTime int
-	Timestamp    time.Time
-	Error        error
+	WebsiteID       string
+	Status          string
+	ResponseTime    int
+	Timestamp       time.Time
+	Error           error
+	ContentMatched  bool                      // False when the body failed the website's content checks
+	StatusCode      int                       // HTTP status of the final response, 0 without one
+	ErrorCode       string                    // Why the check failed, one of the ErrorCode constants, "" if it succeeded
+	FinalURL        string                    // URL of the final response after redirects, HTTP checks only
+	Protocol        string                    // HTTP version of the final response, e.g. "HTTP/2.0", HTTP checks only
+	TLSUnverified   bool                      // The certificate failed verification, which the website skips
+	Flapping        bool                      // The website is flapping; Status is still this check's own
+	FailedAssertion string                    // The JSON assertion the body failed, as path == expected
+	Maintenance     bool                      // The check ran during a maintenance window of the website
+	Agent           string                    // Region label of the agent that ran the check, see SetAgent
+	ResponseBytes   *int64                    // Size of the response body as read, nil when it wasn't read
+	RemoteIP        string                    // IP address the check connected to, or last tried to; "" if none was dialed
+	Timings         *Timings                  // Phase timings of an HTTP check, nil for other checks
+	Capture         *FailureCapture           // What a failed HTTP check got back, for websites with CaptureOnFailure
+	Unconfirmed     bool                      // A down result below the website's FailureThreshold, which kept its status
+	SubChecks       map[string]SubCheckResult // Results of the website's sub-checks by path, nil without any
+
+	// TLS certificate expiry, zero for plain HTTP
+	CertExpiresAt     time.Time
+	CertDaysRemaining int
 }
 
 // MonitorEngine manages the monitoring of multiple websites
 type MonitorEngine struct {
-	websites     map[string]*Website
-	mutex        sync.RWMutex
-	resultChan   chan CheckResult
-	stopChan     chan bool
-	httpClient   *http.Client
+	websites    map[string]*Website
+	mutex       sync.RWMutex
+	resultChan  chan CheckResult
+	outputChan  chan CheckResult // Results forwarded after the engine has applied them
+	resultsDone chan struct{}    // Closed once every result has been forwarded
+	stopChan    chan bool
+	// Parent of every check's context, cancelled by Stop so that running
+	// checks are abandoned instead of waiting out their timeout
+	ctx               context.Context
+	cancel            context.CancelFunc
+	httpClient        *http.Client
+	internalClient    *http.Client // No proxy, optional internal resolver
+	internalDNSServer string
+
+	// Dedicated clients for websites with their own transport settings
+	siteClients  map[string]*siteClient
+	clientsMutex sync.Mutex
+
+	// Compiled ExpectedBodyRegex of each website
+	bodyRegexps  map[string]*bodyRegexp
+	regexpsMutex sync.Mutex
 	userAgents   []string
 	running      bool
+
+	// Check scheduling: a min-heap of due times feeding a fixed pool of workers
+	schedule            scheduleHeap
+	scheduled           map[string]*scheduleEntry
+	inFlight            map[string]bool
+	scheduleMutex       sync.Mutex
+	wake                chan struct{}
+	checkQueue          chan checkRequest
+	workers             sync.WaitGroup
+	jobChecks           sync.WaitGroup // CheckAll jobs still handing checks to the workers
+	maxConcurrentChecks int
+	checkSlots          chan struct{} // Bounds running checks, scheduled and on-demand alike
+	maxStartupJitter    time.Duration
+	jitterRand          *rand.Rand
+
+	// Per-host concurrency limiting
+	hostSlots        map[string]chan struct{}
+	maxChecksPerHost int
+	hostMutex        sync.Mutex
+
+	// On-demand check jobs
+	jobs      map[string]*CheckJob
+	jobsMutex sync.Mutex
+
+	// Flap detection, guarded by mutex
+	flapThreshold int
+	flapWindow    time.Duration
+	flaps         map[string]*flapState
+
+	// Multi-agent checks, guarded by mutex
+	agentID       string
+	downQuorum    int
+	agents        map[string]map[string]agentView // Latest view of each agent per website
+	submitted     sync.WaitGroup                  // Results being submitted by remote agents
+	lastSubmitted map[string]map[string]time.Time // Timestamp of each agent's latest submitted result per website
+
+	// Which check results are logged, guarded by mutex
+	checkLogging string
+
+	// Latest results of each website, served without reading storage
+	recent      map[string]*resultRing
+	recentSize  int
+	captures    map[string]*FailureCapture // Last failure of websites with CaptureOnFailure
+	recentMutex sync.RWMutex
+
+	// Injected dependencies, see NewMonitorEngineWithDeps
+	doer  HTTPDoer // Sends HTTP checks instead of the engine's clients when set
+	clock Clock
 }
 
 // NewMonitorEngine creates a new monitoring engine
 func NewMonitorEngine() *MonitorEngine {
 	// Create HTTP client with timeout and TLS config
+	// Timeouts are applied per request from each website's settings
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		CheckRedirect: checkRedirect,
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: false,
 			},
+			// A custom TLS config turns off HTTP/2 unless asked for explicitly
+			ForceAttemptHTTP2:   true,
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
@@ -70,28 +384,117 @@ func NewMonitorEngine() *MonitorEngine {
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Edge/91.0.864.59",
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &MonitorEngine{
-		websites:   make(map[string]*Website),
-		resultChan: make(chan CheckResult, 1000),
-		stopChan:   make(chan bool),
-		httpClient: client,
-		userAgents: userAgents,
-		running:    false,
+		websites:       make(map[string]*Website),
+		resultChan:     make(chan CheckResult, 1000),
+		outputChan:     make(chan CheckResult, 1000),
+		resultsDone:    make(chan struct{}),
+		stopChan:       make(chan bool),
+		ctx:            ctx,
+		cancel:         cancel,
+		httpClient:     client,
+		internalClient: newInternalClient(""),
+		userAgents:     userAgents,
+		running:        false,
+
+		hostSlots:           make(map[string]chan struct{}),
+		maxChecksPerHost:    DefaultMaxChecksPerHost,
+		jobs:                make(map[string]*CheckJob),
+		siteClients:         make(map[string]*siteClient),
+		bodyRegexps:         make(map[string]*bodyRegexp),
+		scheduled:           make(map[string]*scheduleEntry),
+		inFlight:            make(map[string]bool),
+		wake:                make(chan struct{}, 1),
+		checkQueue:          make(chan checkRequest),
+		maxConcurrentChecks: DefaultMaxConcurrentChecks,
+		checkSlots:          make(chan struct{}, DefaultMaxConcurrentChecks),
+		maxStartupJitter:    DefaultMaxStartupJitter,
+		jitterRand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		flapThreshold:       DefaultFlapThreshold,
+		flapWindow:          DefaultFlapWindow,
+		flaps:               make(map[string]*flapState),
+		recent:              make(map[string]*resultRing),
+		captures:            make(map[string]*FailureCapture),
+		downQuorum:          DefaultDownQuorum,
+		agents:              make(map[string]map[string]agentView),
+		lastSubmitted:       make(map[string]map[string]time.Time),
+		recentSize:          DefaultRecentResults,
+		checkLogging:        CheckLogAll,
+		clock:               systemClock{},
+	}
+}
+
+// EngineSettings describes the effective configuration of the engine
+type EngineSettings struct {
+	MaxChecksPerHost        int    `json:"max_checks_per_host"`
+	MaxConcurrentChecks     int    `json:"max_concurrent_checks"`
+	MaxStartupJitterSeconds int    `json:"max_startup_jitter_seconds"`
+	InternalDNSServer       string `json:"internal_dns_server"`
+	ResultBufferSize        int    `json:"result_buffer_size"`
+	FlapThreshold           int    `json:"flap_threshold"`
+	FlapWindowSeconds       int    `json:"flap_window_seconds"`
+	RecentResults           int    `json:"recent_results"`
+	AgentID                 string `json:"agent_id"`
+	DownQuorum              int    `json:"down_quorum"`
+	CheckLogging            string `json:"check_logging"`
+	Running                 bool   `json:"running"`
+}
+
+// Settings returns the effective configuration of the engine
+func (me *MonitorEngine) Settings() EngineSettings {
+	me.mutex.RLock()
+	settings := EngineSettings{
+		InternalDNSServer:       me.internalDNSServer,
+		ResultBufferSize:        cap(me.resultChan),
+		MaxConcurrentChecks:     me.maxConcurrentChecks,
+		MaxStartupJitterSeconds: int(me.maxStartupJitter / time.Second),
+		FlapThreshold:           me.flapThreshold,
+		FlapWindowSeconds:       int(me.flapWindow / time.Second),
+		AgentID:                 me.agentID,
+		DownQuorum:              me.downQuorum,
+		CheckLogging:            me.checkLogging,
+		Running:                 me.running,
 	}
+	me.mutex.RUnlock()
+
+	me.hostMutex.Lock()
+	settings.MaxChecksPerHost = me.maxChecksPerHost
+	me.hostMutex.Unlock()
+
+	me.recentMutex.RLock()
+	settings.RecentResults = me.recentSize
+	me.recentMutex.RUnlock()
+
+	return settings
 }
 
 // AddWebsite adds a website to monitor
 func (me *MonitorEngine) AddWebsite(website *Website) {
 	me.mutex.Lock()
 	defer me.mutex.Unlock()
+	// Flapping is only known from the checks since the engine started
+	website.Flapping = false
 	me.websites[website.ID] = website
+	me.scheduleLocked(website, 0)
 }
 
 // RemoveWebsite removes a website from monitoring
 func (me *MonitorEngine) RemoveWebsite(id string) {
 	me.mutex.Lock()
-	defer me.mutex.Unlock()
 	delete(me.websites, id)
+	delete(me.flaps, id)
+	delete(me.agents, id)
+	delete(me.lastSubmitted, id)
+	me.mutex.Unlock()
+
+	me.unschedule(id)
+
+	me.dropClient(id)
+	me.dropBodyRegexp(id)
+	me.dropRecent(id)
+	me.dropCapture(id)
 }
 
 // GetWebsite gets a website by ID
@@ -102,29 +505,274 @@ func (me *MonitorEngine) GetWebsite(id string) (*Website, bool) {
 	return website, exists
 }
 
+// UpdateWebsite changes a website's configuration. update is applied to a
+// copy of the website, under the engine's lock, which then replaces it, so
+// checks already running keep reading the configuration they started with.
+// update must not call back into the engine. It returns false if the website
+// does not exist.
+func (me *MonitorEngine) UpdateWebsite(id string, update func(website *Website)) bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.swapLocked(id, update)
+}
+
+// swapLocked applies update to a copy of a website and puts the copy in its
+// place. Websites handed out by GetWebsite and the like are read without the
+// engine's lock, so they are never changed in place. It returns false if the
+// website does not exist. The caller must hold me.mutex for writing.
+func (me *MonitorEngine) swapLocked(id string, update func(website *Website)) bool {
+	current, exists := me.websites[id]
+	if !exists {
+		return false
+	}
+	updated := *current
+	update(&updated)
+	me.websites[id] = &updated
+	return true
+}
+
+// FindWebsiteByURL returns the website checking the same URL as rawURL, after
+// normalizing both. When several do, the one with the lowest ID is returned.
+func (me *MonitorEngine) FindWebsiteByURL(rawURL string) (*Website, bool) {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+
+	normalized := NormalizeURL(rawURL)
+	var found *Website
+	for _, website := range me.websites {
+		if NormalizeURL(website.URL) == normalized && (found == nil || website.ID < found.ID) {
+			found = website
+		}
+	}
+	return found, found != nil
+}
+
+// NormalizeURL returns a canonical form of a website URL for comparison: the
+// scheme and host are lowercased, a default port and the fragment dropped and
+// an empty path becomes "/". Targets that aren't URLs, such as the host:port
+// of a TCP check, are only trimmed and lowercased.
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	port := parsed.Port()
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	parsed.Host = host
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	if parsed.Path == "" && parsed.RawPath == "" {
+		parsed.Path = "/"
+	}
+	return parsed.String()
+}
+
 // GetAllWebsites returns all websites
 func (me *MonitorEngine) GetAllWebsites() map[string]*Website {
 	me.mutex.RLock()
 	defer me.mutex.RUnlock()
-	
+
 	// Create a copy to avoid race conditions
-	websites := make(map[string]*Website)
+	websites := make(map[string]*Website, len(me.websites))
 	for id, website := range me.websites {
 		websites[id] = website
 	}
 	return websites
 }
 
-// UpdateWebsiteStatus updates the status of a website
-func (me *MonitorEngine) UpdateWebsiteStatus(id, status string, responseTime int) {
+// ForEachWebsite calls fn for every website without copying the website map.
+// It is meant for read-only callers; fn runs under the engine's read lock, so
+// it must not modify websites, call back into the engine or block.
+func (me *MonitorEngine) ForEachWebsite(fn func(website *Website)) {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	for _, website := range me.websites {
+		fn(website)
+	}
+}
+
+// UpdateWebsiteStatus updates the status of a website. It reports false when
+// a down status is held back because the website hasn't failed
+// FailureThreshold checks in a row yet; the website then keeps its status.
+func (me *MonitorEngine) UpdateWebsiteStatus(id, status string, responseTime int) bool {
 	me.mutex.Lock()
 	defer me.mutex.Unlock()
-	
-	if website, exists := me.websites[id]; exists {
-		website.Status = status
+
+	confirmed := true
+	me.swapLocked(id, func(website *Website) {
+		website.ChecksTotal++
+		if status == StatusUp {
+			if website.ConsecutiveSuccesses == 0 {
+				website.UpSince = me.now()
+			}
+			website.ConsecutiveSuccesses++
+			website.ConsecutiveFailures = 0
+		} else {
+			website.ConsecutiveSuccesses = 0
+			website.UpSince = time.Time{}
+			if status != StatusDegraded {
+				website.FailuresTotal++
+				website.ConsecutiveFailures++
+			} else {
+				website.ConsecutiveFailures = 0
+			}
+		}
+
+		// Ride out brief blips: down only once enough checks failed in a row
+		confirmed = status != StatusDown || website.ConsecutiveFailures >= website.FailureThreshold
+		if confirmed {
+			website.Status = status
+		}
 		website.LastResponseTime = responseTime
-		website.LastCheckTime = time.Now()
+		website.LastCheckTime = me.now()
+		website.StatusStale = false
+		me.updateFlappingLocked(website, status, website.LastCheckTime)
+	})
+	return confirmed
+}
+
+// applyHeaders sets custom headers on a request. Go ignores a "Host" entry in
+// req.Header, so it is applied to req.Host instead.
+func applyHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+}
+
+// IsExpectedStatus reports whether an HTTP status code means the website is up
+func (w *Website) IsExpectedStatus(code int) bool {
+	if len(w.ExpectedStatusCodes) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, expected := range w.ExpectedStatusCodes {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCertificate records the TLS certificate expiry of a website
+func (me *MonitorEngine) updateCertificate(id string, expiresAt time.Time, daysRemaining int) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(id, func(website *Website) {
+		website.CertExpiresAt = expiresAt
+		website.CertDaysRemaining = daysRemaining
+	})
+}
+
+// updateFinalURL records where a website's last check landed after redirects
+func (me *MonitorEngine) updateFinalURL(id string, finalURL string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(id, func(website *Website) {
+		website.FinalURL = finalURL
+	})
+}
+
+// updateProtocol records the HTTP version of a website's last response
+func (me *MonitorEngine) updateProtocol(id string, protocol string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(id, func(website *Website) {
+		website.Protocol = protocol
+	})
+}
+
+// updateRemoteIP records the IP address a website's last check connected to
+func (me *MonitorEngine) updateRemoteIP(id string, remoteIP string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(id, func(website *Website) {
+		website.RemoteIP = remoteIP
+	})
+}
+
+// updateLastError records why a website's last check failed, or clears it
+func (me *MonitorEngine) updateLastError(result CheckResult) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(result.WebsiteID, func(website *Website) {
+		website.LastErrorCode = result.ErrorCode
+		website.LastError = ""
+		if result.Error != nil {
+			website.LastError = result.Error.Error()
+		}
+	})
+}
+
+// updateTLSUnverified records whether a website's last check only succeeded
+// because certificate verification was skipped
+func (me *MonitorEngine) updateTLSUnverified(result CheckResult) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	me.swapLocked(result.WebsiteID, func(website *Website) {
+		website.TLSUnverified = result.TLSUnverified && result.Status != StatusDown
+	})
+}
+
+// certificateExpiry returns the soonest NotAfter date of the peer certificates
+func certificateExpiry(state *tls.ConnectionState) (time.Time, bool) {
+	var soonest time.Time
+	for _, cert := range state.PeerCertificates {
+		if soonest.IsZero() || cert.NotAfter.Before(soonest) {
+			soonest = cert.NotAfter
+		}
+	}
+	return soonest, !soonest.IsZero()
+}
+
+// certificateVerifies reports whether the peer certificates form a chain the
+// system trusts for host, as checked when verification isn't skipped
+func certificateVerifies(state *tls.ConnectionState, host string) bool {
+	if len(state.PeerCertificates) == 0 {
+		return false
 	}
+	if state.ServerName != "" {
+		host = state.ServerName
+	}
+
+	options := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(options)
+	return err == nil
+}
+
+// RecoveryConfirmed reports whether the website has been up long enough for
+// its recovery to be announced
+func (w *Website) RecoveryConfirmed(now time.Time) bool {
+	if w.RecoveryConfirmChecks > 0 && w.ConsecutiveSuccesses < w.RecoveryConfirmChecks {
+		return false
+	}
+	if w.RecoveryConfirmSeconds > 0 && (w.UpSince.IsZero() || now.Sub(w.UpSince) < time.Duration(w.RecoveryConfirmSeconds)*time.Second) {
+		return false
+	}
+	return true
 }
 
 // Start begins monitoring all websites
@@ -135,20 +783,66 @@ func (me *MonitorEngine) Start() {
 		return
 	}
 	me.running = true
+
+	// Start the check workers
+	for i := 0; i < me.maxConcurrentChecks; i++ {
+		me.workers.Add(1)
+		go me.runWorker()
+	}
+
+	// Schedule every website after a random delay, which staggers the later
+	// checks as well since each is due one interval after the previous one
+	for _, website := range me.websites {
+		me.scheduleLocked(website, me.startupDelayLocked(website))
+	}
 	me.mutex.Unlock()
 
-	// Start result processor
+	// Start result processor and scheduler
 	go me.processResults()
+	go me.runScheduler()
+}
 
-	// Start monitoring goroutines for each website
-	for _, website := range me.GetAllWebsites() {
-		if website.Enabled {
-			go me.monitorWebsite(website)
-		}
+// PauseWebsite disables a website and removes it from the check schedule.
+// Its history is kept. Like UpdateWebsite, it swaps in a disabled copy of the
+// website, so the scheduler never sees one half-changed. It returns false if
+// the website does not exist.
+func (me *MonitorEngine) PauseWebsite(id string) bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	current, exists := me.websites[id]
+	if !exists {
+		return false
+	}
+	paused := *current
+	paused.Enabled = false
+	me.websites[id] = &paused
+	me.unschedule(id)
+	return true
+}
+
+// ResumeWebsite enables a website, as a copy swapped in like PauseWebsite
+// does, and schedules it for an immediate check. It returns false if the
+// website does not exist.
+func (me *MonitorEngine) ResumeWebsite(id string) bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+
+	current, exists := me.websites[id]
+	if !exists {
+		return false
 	}
+	resumed := *current
+	resumed.Enabled = true
+	me.websites[id] = &resumed
+	me.scheduleLocked(&resumed, 0)
+	return true
 }
 
-// Stop stops monitoring all websites
+// Stop stops monitoring all websites. Running checks are cancelled and an
+// attempt cut short is not reported. Stop returns once they have returned
+// and every result has been handed to the result channel, which is then
+// closed.
 func (me *MonitorEngine) Stop() {
 	me.mutex.Lock()
 	if !me.running {
@@ -156,55 +850,161 @@ func (me *MonitorEngine) Stop() {
 		return
 	}
 	me.running = false
+	// Closed under the lock so CheckAll can't start checks after this
+	close(me.stopChan)
+	me.cancel()
 	me.mutex.Unlock()
 
-	close(me.stopChan)
+	// Wait for the cancelled checks to return
+	me.workers.Wait()
+	me.jobChecks.Wait()
+	me.submitted.Wait()
+
+	// No more results can be sent; wait for the processor to forward the rest
+	close(me.resultChan)
+	<-me.resultsDone
 }
 
-// GetResultChannel returns the result channel for external processing
+// GetResultChannel returns the result channel for external processing.
+// Results arrive after the website's status has been updated. The channel is
+// closed when Stop returns.
 func (me *MonitorEngine) GetResultChannel() <-chan CheckResult {
-	return me.resultChan
+	return me.outputChan
+}
+
+// MarkStatusesStale flags every website's current status as last-known until
+// its next check completes. Websites that were never checked are left alone.
+func (me *MonitorEngine) MarkStatusesStale() {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for id := range me.websites {
+		me.swapLocked(id, func(website *Website) {
+			website.StatusStale = website.Status != "" && website.Status != StatusUnknown
+		})
+	}
 }
 
-// monitorWebsite monitors a single website in a goroutine
-func (me *MonitorEngine) monitorWebsite(website *Website) {
-	ticker := time.NewTicker(time.Duration(website.IntervalSeconds) * time.Second)
-	defer ticker.Stop()
+// checkWebsite checks a website, retrying failed attempts according to its
+// retry settings, and sends the final result
+func (me *MonitorEngine) checkWebsite(website *Website) {
+	// Don't hit the same host with too many checks at once
+	release, acquired := me.acquireHost(checkHost(website))
+	if !acquired {
+		return
+	}
+	defer release()
+
+	// Nor run more checks than allowed overall, e.g. when CheckAll starts
+	// next to the scheduled checks
+	releaseSlot, acquired := me.acquireCheckSlot()
+	if !acquired {
+		return
+	}
+	defer releaseSlot()
+
+	check := me.performCheck
+	switch website.CheckType {
+	case CheckTypeTCP:
+		check = me.performTCPCheck
+	case CheckTypeICMP:
+		check = me.performICMPCheck
+	case CheckTypeDNS:
+		check = me.performDNSCheck
+	}
 
-	// Perform initial check
-	me.checkWebsite(website)
+	result := degradeSlowResult(website, check(website))
+	if me.ctx.Err() != nil {
+		// Cancelled by Stop; an aborted check says nothing about the website
+		return
+	}
 
-	for {
+	// Only a check that fails every attempt is reported as down
+	retryDelay := time.Duration(website.RetryDelaySeconds) * time.Second
+	for attempt := 0; attempt < website.RetryCount && result.Status == StatusDown; attempt++ {
+		timer := me.clock.TimerAt(me.now().Add(retryDelay))
 		select {
-		case <-ticker.C:
-			// Check if website still exists and is enabled
-			if currentWebsite, exists := me.GetWebsite(website.ID); exists && currentWebsite.Enabled {
-				me.checkWebsite(currentWebsite)
-			} else {
-				// Website was removed or disabled, stop monitoring
-				return
-			}
+		case <-timer.C():
 		case <-me.stopChan:
+			timer.Stop()
+			// Shutting down, report what we have instead of blocking
+			result.ErrorCode = errorCode(result)
+			result.Agent = me.localAgent()
+			me.resultChan <- result
 			return
 		}
+		retried := degradeSlowResult(website, check(website))
+		if me.ctx.Err() != nil {
+			// Cancelled by Stop, report the last complete attempt
+			break
+		}
+		result = retried
 	}
+
+	// Sub-checks run once, after the main check has settled
+	if len(website.SubChecks) > 0 && result.Status != StatusError && (website.CheckType == "" || website.CheckType == CheckTypeHTTP) {
+		me.runSubChecks(website, &result)
+		if me.ctx.Err() != nil {
+			return
+		}
+	}
+
+	result.ErrorCode = errorCode(result)
+	result.Agent = me.localAgent()
+	me.resultChan <- result
 }
 
-// checkWebsite performs a single check on a website
-func (me *MonitorEngine) checkWebsite(website *Website) {
-	start := time.Now()
-	
+// degradeSlowResult reports a successful check slower than the website's
+// MaxResponseTimeMs as degraded
+func degradeSlowResult(website *Website, result CheckResult) CheckResult {
+	if result.Status == StatusUp && website.MaxResponseTimeMs > 0 && result.ResponseTime > website.MaxResponseTimeMs {
+		result.Status = StatusDegraded
+		result.Error = fmt.Errorf("responded in %dms, over the %dms threshold", result.ResponseTime, website.MaxResponseTimeMs)
+	}
+	return result
+}
+
+// checkTimeout returns how long a single check attempt on a website may take
+func checkTimeout(website *Website) time.Duration {
+	timeout := time.Duration(website.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultTimeoutSeconds * time.Second
+	}
+	return timeout
+}
+
+// performCheck performs a single HTTP check attempt on a website
+func (me *MonitorEngine) performCheck(website *Website) CheckResult {
+	timeout := checkTimeout(website)
+	ctx, cancel := context.WithTimeout(me.ctx, timeout)
+	defer cancel()
+	ctx = withRedirectPolicy(ctx, website)
+
+	// Note which address the check connects to and time its phases
+	trace := &checkTrace{}
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+
+	start := me.now()
+
+	method := website.HTTPMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if website.RequestBody != "" {
+		body = strings.NewReader(website.RequestBody)
+	}
+
 	// Create request with random user agent
-	req, err := http.NewRequest("GET", website.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, website.URL, body)
 	if err != nil {
-		me.resultChan <- CheckResult{
-			WebsiteID:    website.ID,
-			Status:       "down",
-			ResponseTime: 0,
-			Timestamp:    time.Now(),
-			Error:        err,
+		return CheckResult{
+			WebsiteID:      website.ID,
+			Status:         StatusDown,
+			ResponseTime:   0,
+			Timestamp:      me.now(),
+			Error:          err,
+			ContentMatched: true,
 		}
-		return
 	}
 
 	// Set random user agent
@@ -215,50 +1015,183 @@ func (me *MonitorEngine) checkWebsite(website *Website) {
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	if website.RequestBody != "" {
+		contentType := website.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	applyHeaders(req, website.Headers)
+	if website.Auth != nil {
+		website.Auth.apply(req)
+	}
+
+	client, err := me.doerFor(website)
+	var bodyPattern *regexp.Regexp
+	if err == nil {
+		bodyPattern, err = me.bodyRegexpFor(website)
+	}
+	if err != nil {
+		// Report misconfiguration separately from the site being down
+		return CheckResult{
+			WebsiteID:      website.ID,
+			Status:         StatusError,
+			ResponseTime:   0,
+			Timestamp:      me.now(),
+			Error:          err,
+			ContentMatched: true,
+		}
+	}
 
 	// Perform request
-	resp, err := me.httpClient.Do(req)
-	responseTime := int(time.Since(start).Milliseconds())
+	resp, err := client.Do(req)
+	responseTime := int(me.now().Sub(start).Milliseconds())
 
 	var status string
+	contentMatched := true
+	failedAssertion := ""
+	var responseBytes *int64
+	var capturedBody []byte
 	if err != nil {
-		status = "down"
-		responseTime = 0
+		status = StatusDown
+		if ctx.Err() == context.DeadlineExceeded {
+			// Report how long we waited before giving up
+			err = &timeoutError{message: fmt.Sprintf("timed out after %s", timeout)}
+		} else {
+			responseTime = 0
+		}
 	} else {
 		defer resp.Body.Close()
-		if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-			status = "up"
+		if website.IsExpectedStatus(resp.StatusCode) {
+			status = StatusUp
 		} else {
-			status = "down"
+			status = StatusDown
+			err = &statusError{statusCode: resp.StatusCode}
+		}
+		if status == StatusUp && website.RequireHTTP2 && resp.ProtoMajor < 2 {
+			status = StatusDown
+			err = &protocolError{proto: resp.Proto}
+		}
+
+		// HEAD responses have no body to verify or read
+		if method != http.MethodHead {
+			// Verify the body only for otherwise healthy responses
+			if status == StatusUp {
+				body, size, contentErr := checkContent(website, resp, bodyPattern)
+				if size >= 0 {
+					responseBytes = &size
+				}
+				if contentErr != nil {
+					status = StatusDown
+					err = contentErr
+					capturedBody = body
+
+					// A body of the wrong size is reported as such, not as a content mismatch
+					var sizeErr *sizeError
+					contentMatched = errors.As(contentErr, &sizeErr)
+
+					var assertErr *assertionError
+					if errors.As(contentErr, &assertErr) {
+						failedAssertion = assertErr.assertion.String()
+					}
+				}
+			}
+			// A failed status leaves the body unread
+			if status != StatusUp && website.CaptureOnFailure && capturedBody == nil {
+				capturedBody, _ = readBody(resp, captureBodyBytes+1)
+			}
+			discardBody(resp)
 		}
 	}
 
-	// Send result
-	me.resultChan <- CheckResult{
-		WebsiteID:    website.ID,
-		Status:       status,
-		ResponseTime: responseTime,
-		Timestamp:    time.Now(),
-		Error:        err,
+	result := CheckResult{
+		WebsiteID:       website.ID,
+		Status:          status,
+		ResponseTime:    responseTime,
+		Timestamp:       me.now(),
+		Error:           err,
+		ContentMatched:  contentMatched,
+		FailedAssertion: failedAssertion,
+		ResponseBytes:   responseBytes,
+		RemoteIP:        trace.remoteIP(),
+		Timings:         trace.timings(responseTime),
+	}
+
+	// An injected doer may return responses without their request
+	finalURL := req.URL
+	if resp != nil && resp.Request != nil {
+		finalURL = resp.Request.URL
+	}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.FinalURL = finalURL.String()
+		result.Protocol = resp.Proto
+	}
+
+	if status != StatusUp && website.CaptureOnFailure {
+		result.Capture = newFailureCapture(website, result, resp, capturedBody)
+	}
+
+	// Record when the certificate chain expires
+	if resp != nil && resp.TLS != nil {
+		if expiresAt, ok := certificateExpiry(resp.TLS); ok {
+			result.CertExpiresAt = expiresAt
+			result.CertDaysRemaining = int(expiresAt.Sub(me.now()).Hours() / 24)
+		}
+		// Flag responses that strict verification would have rejected
+		if website.InsecureSkipTLSVerify {
+			result.TLSUnverified = !certificateVerifies(resp.TLS, finalURL.Hostname())
+		}
 	}
+
+	return result
 }
 
-// processResults processes check results
+// processResults processes check results until Stop closes the result channel
 func (me *MonitorEngine) processResults() {
+	defer close(me.resultsDone)
+	defer close(me.outputChan)
+
 	for result := range me.resultChan {
+		// Weigh the result against the other agents' view of the website
+		me.applyQuorum(&result)
+
 		// Update website status
-		me.UpdateWebsiteStatus(result.WebsiteID, result.Status, result.ResponseTime)
-		
-		// Log result (can be extended to save to JSON files)
-		if result.Error != nil {
-			fmt.Printf("[%s] Website %s is %s (Error: %v)\n", 
-				result.Timestamp.Format("2006-01-02 15:04:05"), 
-				result.WebsiteID, result.Status, result.Error)
-		} else {
-			fmt.Printf("[%s] Website %s is %s (Response time: %dms)\n", 
-				result.Timestamp.Format("2006-01-02 15:04:05"), 
-				result.WebsiteID, result.Status, result.ResponseTime)
+		oldStatus, verbose := me.websiteLogState(result.WebsiteID)
+		result.Unconfirmed = !me.UpdateWebsiteStatus(result.WebsiteID, result.Status, result.ResponseTime)
+		me.applyBackoff(result.WebsiteID)
+		result.Flapping = me.isFlapping(result.WebsiteID)
+		if !result.CertExpiresAt.IsZero() {
+			me.updateCertificate(result.WebsiteID, result.CertExpiresAt, result.CertDaysRemaining)
 		}
+		if result.FinalURL != "" {
+			me.updateFinalURL(result.WebsiteID, result.FinalURL)
+		}
+		if result.Protocol != "" {
+			me.updateProtocol(result.WebsiteID, result.Protocol)
+		}
+		if result.RemoteIP != "" {
+			me.updateRemoteIP(result.WebsiteID, result.RemoteIP)
+		}
+		me.updateLastError(result)
+		me.updateTLSUnverified(result)
+		if result.Capture != nil {
+			// Kept apart from the result, which history and recent results keep many of
+			result.Capture.ErrorCode = result.ErrorCode
+			me.recordCapture(result.WebsiteID, result.Capture)
+			result.Capture = nil
+		}
+		if website, exists := me.GetWebsite(result.WebsiteID); exists {
+			result.Maintenance = website.InMaintenanceAt(result.Timestamp)
+			me.recordRecent(result)
+		}
+
+		// Log the result, or only a status change when so configured
+		newStatus, _ := me.websiteLogState(result.WebsiteID)
+		me.logResult(result, oldStatus, newStatus, verbose)
+
+		// Hand the result on for history and notifications
+		me.outputChan <- result
 	}
 }
-
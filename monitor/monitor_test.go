@@ -0,0 +1,304 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// engineWithWebsites returns an engine that isn't started, holding n websites
+func engineWithWebsites(n int) *MonitorEngine {
+	me := NewMonitorEngine()
+	for i := 0; i < n; i++ {
+		me.AddWebsite(testWebsite(fmt.Sprintf("site%d", i)))
+	}
+	return me
+}
+
+func TestUpdateWebsite(t *testing.T) {
+	me := NewMonitorEngine()
+	website := testWebsite("site")
+	website.Status = StatusDown
+	website.Headers = map[string]string{"X-Env": "prod"}
+	me.AddWebsite(website)
+
+	updated := me.UpdateWebsite("site", func(website *Website) {
+		website.Name = "Renamed"
+		website.Headers = map[string]string{"X-Env": "staging"}
+	})
+	if !updated {
+		t.Fatal("UpdateWebsite didn't find the website")
+	}
+
+	current, _ := me.GetWebsite("site")
+	if current.Name != "Renamed" || current.Headers["X-Env"] != "staging" {
+		t.Errorf("website = %q with headers %v, want the update applied", current.Name, current.Headers)
+	}
+	if current.Status != StatusDown {
+		t.Errorf("status = %q, want it kept", current.Status)
+	}
+	// A check holding the website it started with doesn't see the update
+	if website.Name != "site" || website.Headers["X-Env"] != "prod" {
+		t.Errorf("previous website changed to %q with headers %v", website.Name, website.Headers)
+	}
+
+	if me.UpdateWebsite("missing", func(*Website) {}) {
+		t.Error("updating a missing website succeeded")
+	}
+}
+
+// TestUpdateWebsiteWhileChecking changes a website while it is being checked,
+// for the race detector
+func TestUpdateWebsiteWhileChecking(t *testing.T) {
+	checked := make(chan string, 100)
+	website := testWebsite("site")
+	website.IntervalSeconds = 1
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		select {
+		case checked <- req.URL.String():
+		default:
+		}
+		// Still checking when the website is updated
+		time.Sleep(5 * time.Millisecond)
+		return fakeResponse(http.StatusOK, "ok"), nil
+	})
+	me := startTestEngine(t, doer, nil, website)
+
+	waitForCheck(t, checked, 2*time.Second)
+	for i := 0; i < 50; i++ {
+		me.CheckAll()
+		me.UpdateWebsite("site", func(website *Website) {
+			website.Headers = map[string]string{"X-Update": fmt.Sprint(i)}
+			website.ExpectedKeyword = "ok"
+		})
+		me.WebsiteChanged("site")
+		time.Sleep(time.Millisecond)
+	}
+	waitForCheck(t, checked, 2*time.Second)
+}
+
+func BenchmarkGetAllWebsites(b *testing.B) {
+	me := engineWithWebsites(5000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if websites := me.GetAllWebsites(); len(websites) != 5000 {
+			b.Fatalf("got %d websites, want 5000", len(websites))
+		}
+	}
+}
+
+func BenchmarkForEachWebsite(b *testing.B) {
+	me := engineWithWebsites(5000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		me.ForEachWebsite(func(website *Website) { count++ })
+		if count != 5000 {
+			b.Fatalf("visited %d websites, want 5000", count)
+		}
+	}
+}
+
+func TestFlappingEnteredAndLeft(t *testing.T) {
+	clock := newFakeClock(0)
+	me := NewMonitorEngineWithDeps(nil, clock)
+	me.SetFlapDetection(2, 10*time.Minute)
+	website := testWebsite("site")
+	website.FailureThreshold = 2
+	me.AddWebsite(website)
+
+	check := func(status string) *Website {
+		clock.Advance(30 * time.Second)
+		me.UpdateWebsiteStatus(website.ID, status, 100)
+		current, _ := me.GetWebsite(website.ID)
+		return current
+	}
+
+	// Single failures below the threshold keep the status up, yet count as
+	// changes of what the checks find
+	for _, status := range []string{StatusUp, StatusDown, StatusUp, StatusDown} {
+		check(status)
+	}
+	current := check(StatusUp)
+	if !current.Flapping || current.CurrentStatus() != StatusFlapping {
+		t.Fatalf("flapping = %v, current status %q after alternating checks, want flapping", current.Flapping, current.CurrentStatus())
+	}
+	if current.Status != StatusUp {
+		t.Errorf("status = %q while flapping, want the last confirmed status up", current.Status)
+	}
+
+	// A confirmed outage still shows in Status while flapping
+	check(StatusDown)
+	if current = check(StatusDown); current.Status != StatusDown || !current.Flapping {
+		t.Errorf("status = %q, flapping = %v after two failures, want down and flapping", current.Status, current.Flapping)
+	}
+
+	// Holding steady for a whole window ends it
+	for i := 0; i < 21; i++ {
+		current = check(StatusUp)
+	}
+	if current.Flapping || current.CurrentStatus() != StatusUp {
+		t.Errorf("flapping = %v, current status %q after a steady window, want up", current.Flapping, current.CurrentStatus())
+	}
+}
+
+func TestInsecureSkipTLSVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The test server's certificate isn't trusted
+	website := testWebsite("verified")
+	website.URL = server.URL
+	result := checkOnce(t, NewMonitorEngine(), website)
+	if result.Status != StatusDown || result.ErrorCode != ErrorCodeTLS {
+		t.Errorf("status %q (%s) with verification, want down on tls", result.Status, result.ErrorCode)
+	}
+
+	website = testWebsite("unverified")
+	website.URL = server.URL
+	website.InsecureSkipTLSVerify = true
+	result = checkOnce(t, NewMonitorEngine(), website)
+	if result.Status != StatusUp {
+		t.Errorf("status %q without verification, want up (error: %v)", result.Status, result.Error)
+	}
+	if !result.TLSUnverified {
+		t.Error("a check that skipped verification wasn't flagged")
+	}
+}
+
+func TestRequireHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	http1 := httptest.NewTLSServer(handler)
+	defer http1.Close()
+	http2 := httptest.NewUnstartedServer(handler)
+	http2.EnableHTTP2 = true
+	http2.StartTLS()
+	defer http2.Close()
+
+	tests := []struct {
+		name          string
+		url           string
+		wantStatus    string
+		wantErrorCode string
+		wantProtocol  string
+	}{
+		{name: "HTTP/1.1", url: http1.URL, wantStatus: StatusDown, wantErrorCode: ErrorCodeProtocol, wantProtocol: "HTTP/1.1"},
+		{name: "HTTP/2", url: http2.URL, wantStatus: StatusUp, wantProtocol: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := testWebsite("site")
+			website.URL = tt.url
+			website.InsecureSkipTLSVerify = true
+			website.RequireHTTP2 = true
+			result := checkOnce(t, NewMonitorEngine(), website)
+
+			if result.Status != tt.wantStatus || result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("status %q (%s), want %q (%s)", result.Status, result.ErrorCode, tt.wantStatus, tt.wantErrorCode)
+			}
+			if result.Protocol != tt.wantProtocol {
+				t.Errorf("protocol = %q, want %q", result.Protocol, tt.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://example.com", want: "https://example.com/"},
+		{url: " HTTPS://Example.COM:443/#top ", want: "https://example.com/"},
+		{url: "http://example.com:80/Status?Full=1", want: "http://example.com/Status?Full=1"},
+		{url: "http://example.com:8080", want: "http://example.com:8080/"},
+		{url: "https://[2001:DB8::1]:443", want: "https://[2001:db8::1]/"},
+		{url: "DB.internal:5432", want: "db.internal:5432"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeURL(tt.url); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	me := NewMonitorEngine()
+	website := testWebsite("site")
+	website.URL = "https://example.com"
+	me.AddWebsite(website)
+	if found, exists := me.FindWebsiteByURL("https://EXAMPLE.com/"); !exists || found.ID != "site" {
+		t.Errorf("found %v, want the website with the same URL", found)
+	}
+	if _, exists := me.FindWebsiteByURL("https://example.com/other"); exists {
+		t.Error("found a website for another path")
+	}
+}
+
+func TestFailureThreshold(t *testing.T) {
+	me := NewMonitorEngine()
+	website := testWebsite("site")
+	website.FailureThreshold = 3
+	me.AddWebsite(website)
+	me.UpdateWebsiteStatus("site", StatusUp, 100)
+
+	// A blip, then failures in a row up to the threshold
+	steps := []struct {
+		status        string
+		wantConfirmed bool
+		wantStatus    string
+	}{
+		{status: StatusDown, wantConfirmed: false, wantStatus: StatusUp},
+		{status: StatusUp, wantConfirmed: true, wantStatus: StatusUp},
+		{status: StatusDown, wantConfirmed: false, wantStatus: StatusUp},
+		{status: StatusDown, wantConfirmed: false, wantStatus: StatusUp},
+		{status: StatusDown, wantConfirmed: true, wantStatus: StatusDown},
+	}
+	for i, step := range steps {
+		confirmed := me.UpdateWebsiteStatus("site", step.status, 0)
+		current, _ := me.GetWebsite("site")
+		if confirmed != step.wantConfirmed || current.Status != step.wantStatus {
+			t.Errorf("check %d (%s): confirmed %v with status %q, want %v with %q", i+1, step.status, confirmed, current.Status, step.wantConfirmed, step.wantStatus)
+		}
+	}
+}
+
+func TestStatusUpdatesDontChangeWebsitesBeingRead(t *testing.T) {
+	me := NewMonitorEngine()
+	me.AddWebsite(testWebsite("site"))
+	read, _ := me.GetWebsite("site")
+
+	// Run with -race: readers hold websites without the engine's lock
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			website, _ := me.GetWebsite("site")
+			_ = fmt.Sprint(website.CurrentStatus(), website.LastError, website.ChecksTotal, website.LastResponseTime, website.StatusStale)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		me.UpdateWebsiteStatus("site", StatusDown, i)
+		me.updateLastError(CheckResult{WebsiteID: "site", Status: StatusDown, ErrorCode: ErrorCodeTimeout})
+		me.MarkStatusesStale()
+	}
+	wg.Wait()
+
+	if read.ChecksTotal != 0 || read.Status != "" {
+		t.Errorf("website read before the checks = %d checks, status %q, want it unchanged", read.ChecksTotal, read.Status)
+	}
+	current, _ := me.GetWebsite("site")
+	if current.ChecksTotal != 100 || current.Status != StatusDown || !current.StatusStale || current.LastErrorCode != ErrorCodeTimeout {
+		t.Errorf("website = %d checks, status %q, stale %v, error %q, want every update applied", current.ChecksTotal, current.Status, current.StatusStale, current.LastErrorCode)
+	}
+}
@@ -0,0 +1,296 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Check types. Websites without a check type are checked over HTTP.
+const (
+	CheckTypeHTTP = "http"
+	CheckTypeTCP  = "tcp"
+	CheckTypeICMP = "icmp"
+	CheckTypeDNS  = "dns"
+)
+
+// CheckTarget returns what a non-HTTP check connects to: host:port for TCP
+// checks and the host for ICMP and DNS checks. The target may be given with
+// or without a tcp://, icmp:// or dns:// scheme.
+func CheckTarget(checkType, target string) (string, error) {
+	if strings.Contains(target, "://") {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid target %q", target)
+		}
+		if parsed.Scheme != checkType {
+			return "", fmt.Errorf("%s checks need a %s:// target, got %q", checkType, checkType, target)
+		}
+		target = parsed.Host
+		if checkType == CheckTypeICMP || checkType == CheckTypeDNS {
+			if parsed.Port() != "" {
+				return "", fmt.Errorf("%s checks need a host target without a port, got %q", checkType, target)
+			}
+			target = parsed.Hostname()
+		}
+	}
+
+	switch checkType {
+	case CheckTypeTCP:
+		host, port, err := net.SplitHostPort(target)
+		if err != nil || host == "" || port == "" {
+			return "", fmt.Errorf("tcp checks need a host:port target, got %q", target)
+		}
+	case CheckTypeICMP:
+		if target == "" || strings.ContainsAny(target, "/:") && net.ParseIP(target) == nil {
+			return "", fmt.Errorf("icmp checks need a host target, got %q", target)
+		}
+	case CheckTypeDNS:
+		if target == "" || strings.ContainsAny(target, "/:") {
+			return "", fmt.Errorf("dns checks need a hostname target, got %q", target)
+		}
+	default:
+		return "", fmt.Errorf("unknown check type %q", checkType)
+	}
+	return target, nil
+}
+
+// ValidateCheckType checks the check type of a website and that its URL is a
+// usable target for it
+func ValidateCheckType(checkType, target string) error {
+	switch checkType {
+	case "", CheckTypeHTTP:
+		return nil
+	case CheckTypeTCP, CheckTypeICMP, CheckTypeDNS:
+		_, err := CheckTarget(checkType, target)
+		return err
+	}
+	return fmt.Errorf("check_type must be one of http, tcp, icmp, dns")
+}
+
+// checkHost returns the host a website's checks go to, used to limit
+// concurrent checks per host
+func checkHost(website *Website) string {
+	switch website.CheckType {
+	case CheckTypeTCP, CheckTypeICMP, CheckTypeDNS:
+		target, err := CheckTarget(website.CheckType, website.URL)
+		if err != nil {
+			return ""
+		}
+		if host, _, err := net.SplitHostPort(target); err == nil {
+			return host
+		}
+		return target
+	}
+
+	parsed, err := url.Parse(website.URL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// dialerFor returns the dialer used for a website's non-HTTP checks. Internal
+// websites resolve names with the internal DNS server.
+func (me *MonitorEngine) dialerFor(website *Website) *net.Dialer {
+	if !website.Internal {
+		return &net.Dialer{}
+	}
+
+	me.mutex.RLock()
+	dnsServer := me.internalDNSServer
+	me.mutex.RUnlock()
+	return newDialer(dnsServer)
+}
+
+// performTCPCheck checks that a TCP connection to the website's host:port can
+// be opened, timing the connect
+func (me *MonitorEngine) performTCPCheck(website *Website) CheckResult {
+	address, err := CheckTarget(CheckTypeTCP, website.URL)
+	if err != nil {
+		return CheckResult{
+			WebsiteID:      website.ID,
+			Status:         StatusError,
+			Timestamp:      me.now(),
+			Error:          &ConfigError{Err: err},
+			ContentMatched: true,
+		}
+	}
+
+	timeout := checkTimeout(website)
+	ctx, cancel := context.WithTimeout(me.ctx, timeout)
+	defer cancel()
+
+	start := me.now()
+	conn, err := dialIPVersion(me.dialerFor(website), forcedIPVersion(website))(ctx, "tcp", address)
+	responseTime := int(me.now().Sub(start).Milliseconds())
+
+	status := StatusUp
+	remoteIP := dialErrorIP(err)
+	if err != nil {
+		status = StatusDown
+		if ctx.Err() == context.DeadlineExceeded {
+			err = &timeoutError{message: fmt.Sprintf("timed out after %s", timeout)}
+		} else {
+			responseTime = 0
+		}
+	} else {
+		remoteIP = addrIP(conn.RemoteAddr().String())
+		conn.Close()
+	}
+
+	return CheckResult{
+		WebsiteID:      website.ID,
+		Status:         status,
+		ResponseTime:   responseTime,
+		Timestamp:      me.now(),
+		Error:          err,
+		ContentMatched: true,
+		RemoteIP:       remoteIP,
+	}
+}
+
+// performICMPCheck pings the website's host once, timing the round trip
+func (me *MonitorEngine) performICMPCheck(website *Website) CheckResult {
+	result := CheckResult{
+		WebsiteID:      website.ID,
+		Status:         StatusDown,
+		ContentMatched: true,
+	}
+
+	host, err := CheckTarget(CheckTypeICMP, website.URL)
+	if err != nil {
+		result.Status = StatusError
+		result.Timestamp = me.now()
+		result.Error = &ConfigError{Err: err}
+		return result
+	}
+
+	timeout := checkTimeout(website)
+	ctx, cancel := context.WithTimeout(me.ctx, timeout)
+	defer cancel()
+
+	resolver := me.dialerFor(website).Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		result.Timestamp = me.now()
+		result.Error = fmt.Errorf("failed to resolve %s: %v", host, err)
+		return result
+	}
+
+	// Ping the first address of the forced IP version
+	ipVersion := forcedIPVersion(website)
+	var ip net.IP
+	for _, addr := range addrs {
+		isIPv4 := addr.IP.To4() != nil
+		if ipVersion == "" || (ipVersion == IPVersionIPv4) == isIPv4 {
+			ip = addr.IP
+			break
+		}
+	}
+	if ip == nil {
+		result.Timestamp = me.now()
+		result.Error = &ipVersionError{ipVersion: ipVersion, host: host}
+		return result
+	}
+
+	rtt, err := ping(ctx, ip)
+	result.Timestamp = me.now()
+	result.RemoteIP = ip.String()
+	switch err.(type) {
+	case nil:
+		result.Status = StatusUp
+		result.ResponseTime = int(rtt.Milliseconds())
+	case *ConfigError:
+		result.Status = StatusError
+		result.Error = err
+	default:
+		if ctx.Err() == context.DeadlineExceeded {
+			err = &timeoutError{message: fmt.Sprintf("no reply within %s", timeout)}
+		}
+		result.Error = err
+	}
+	return result
+}
+
+// ping sends one ICMP echo request to ip and waits for the matching reply
+// until ctx is done. It uses an unprivileged ping socket when the system
+// allows it (net.ipv4.ping_group_range on Linux) and a raw socket otherwise.
+func ping(ctx context.Context, ip net.IP) (time.Duration, error) {
+	network, rawNetwork, listenAddr, protocol := "udp4", "ip4:icmp", "0.0.0.0", 1
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		network, rawNetwork, listenAddr, protocol = "udp6", "ip6:ipv6-icmp", "::", 58
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	var destination net.Addr = &net.UDPAddr{IP: ip}
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		var rawErr error
+		conn, rawErr = icmp.ListenPacket(rawNetwork, listenAddr)
+		if rawErr != nil {
+			return 0, &ConfigError{Err: fmt.Errorf("ICMP is not permitted for this process: %v", err)}
+		}
+		destination = &net.IPAddr{IP: ip}
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Unblock the read early when ctx is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	// A random payload identifies our reply; unprivileged sockets rewrite the echo ID
+	token := make([]byte, 16)
+	rand.Read(token)
+	request := icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{ID: 1, Seq: 1, Data: token},
+	}
+	data, err := request.Marshal(nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build echo request: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(data, destination); err != nil {
+		return 0, fmt.Errorf("failed to send echo request: %v", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, fmt.Errorf("no echo reply: %v", err)
+		}
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); ok && bytes.Equal(echo.Data, token) {
+			return time.Since(start), nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateIPVersion(t *testing.T) {
+	tests := []struct {
+		ipVersion string
+		checkType string
+		proxyURL  string
+		wantErr   bool
+	}{
+		{ipVersion: "", checkType: CheckTypeDNS, proxyURL: "http://proxy:3128"},
+		{ipVersion: "auto", checkType: CheckTypeHTTP},
+		{ipVersion: "IPv6", checkType: CheckTypeHTTP},
+		{ipVersion: "ipv4", checkType: CheckTypeTCP},
+		{ipVersion: "ipv5", checkType: CheckTypeHTTP, wantErr: true},
+		{ipVersion: "ipv4", checkType: CheckTypeDNS, wantErr: true},
+		{ipVersion: "ipv6", checkType: CheckTypeHTTP, proxyURL: "http://proxy:3128", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateIPVersion(tt.ipVersion, tt.checkType, tt.proxyURL); (err != nil) != tt.wantErr {
+			t.Errorf("%q for %s (proxy %q): error = %v, want one: %v", tt.ipVersion, tt.checkType, tt.proxyURL, err, tt.wantErr)
+		}
+	}
+}
+
+func TestForcedIPVersion(t *testing.T) {
+	// The test server listens on 127.0.0.1, which has no IPv6 address
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		checkType     string
+		url           string
+		ipVersion     string
+		wantStatus    string
+		wantErrorCode string
+	}{
+		{name: "http over ipv4", checkType: CheckTypeHTTP, url: server.URL, ipVersion: IPVersionIPv4, wantStatus: StatusUp},
+		{name: "http over ipv6", checkType: CheckTypeHTTP, url: server.URL, ipVersion: IPVersionIPv6, wantStatus: StatusDown, wantErrorCode: ErrorCodeDNS},
+		{name: "tcp over ipv4", checkType: CheckTypeTCP, url: server.Listener.Addr().String(), ipVersion: IPVersionIPv4, wantStatus: StatusUp},
+		{name: "tcp over ipv6", checkType: CheckTypeTCP, url: server.Listener.Addr().String(), ipVersion: IPVersionIPv6, wantStatus: StatusDown, wantErrorCode: ErrorCodeDNS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := testWebsite("site")
+			website.CheckType = tt.checkType
+			website.URL = tt.url
+			website.IPVersion = tt.ipVersion
+			result := checkOnce(t, NewMonitorEngine(), website)
+			if result.Status != tt.wantStatus || result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("status %q (%s), want %q (%s); error: %v", result.Status, result.ErrorCode, tt.wantStatus, tt.wantErrorCode, result.Error)
+			}
+			if tt.wantErrorCode != "" && (result.Error == nil || !strings.HasSuffix(result.Error.Error(), "no IPv6 address for 127.0.0.1")) {
+				t.Errorf("error = %v, want the missing address named", result.Error)
+			}
+		})
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name       string
+		checkType  string
+		url        string
+		wantStatus string
+	}{
+		{name: "http", checkType: CheckTypeHTTP, url: server.URL, wantStatus: StatusUp},
+		{name: "http refused", checkType: CheckTypeHTTP, url: "http://" + closedAddr, wantStatus: StatusDown},
+		{name: "tcp", checkType: CheckTypeTCP, url: server.Listener.Addr().String(), wantStatus: StatusUp},
+		{name: "tcp refused", checkType: CheckTypeTCP, url: closedAddr, wantStatus: StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := testWebsite("site")
+			website.CheckType = tt.checkType
+			website.URL = tt.url
+			result := checkOnce(t, NewMonitorEngine(), website)
+			if result.Status != tt.wantStatus {
+				t.Errorf("status %q, want %q; error: %v", result.Status, tt.wantStatus, result.Error)
+			}
+			// Also recorded for checks that never connect
+			if result.RemoteIP != "127.0.0.1" {
+				t.Errorf("remote IP = %q, want 127.0.0.1", result.RemoteIP)
+			}
+		})
+	}
+}
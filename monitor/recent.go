@@ -0,0 +1,90 @@
+package monitor
+
+// DefaultRecentResults is how many recent check results are kept in memory
+// per website
+const DefaultRecentResults = 100
+
+// resultRing holds the latest results of a website, oldest first once read
+type resultRing struct {
+	results []CheckResult
+	next    int // Where the next result goes once the ring is full
+}
+
+// add records a result, replacing the oldest one when the ring holds size
+func (r *resultRing) add(result CheckResult, size int) {
+	if len(r.results) < size {
+		r.results = append(r.results, result)
+		return
+	}
+	r.results[r.next] = result
+	r.next = (r.next + 1) % len(r.results)
+}
+
+// snapshot returns a copy of the results, oldest first
+func (r *resultRing) snapshot() []CheckResult {
+	results := make([]CheckResult, 0, len(r.results))
+	results = append(results, r.results[r.next:]...)
+	return append(results, r.results[:r.next]...)
+}
+
+// SetRecentResults sets how many recent results are kept per website.
+// Results already kept are discarded.
+func (me *MonitorEngine) SetRecentResults(size int) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	if size <= 0 {
+		size = DefaultRecentResults
+	}
+	me.recentSize = size
+	me.recent = make(map[string]*resultRing)
+}
+
+// SeedRecentResults fills a website's recent results, e.g. from stored
+// history after a restart. results must be oldest first; only the newest
+// that fit are kept. Websites that already have results are left alone.
+func (me *MonitorEngine) SeedRecentResults(id string, results []CheckResult) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	if _, exists := me.recent[id]; exists {
+		return
+	}
+	if len(results) > me.recentSize {
+		results = results[len(results)-me.recentSize:]
+	}
+	ring := &resultRing{}
+	for _, result := range results {
+		ring.add(result, me.recentSize)
+	}
+	me.recent[id] = ring
+}
+
+// RecentResults returns a website's latest results, oldest first, without
+// touching storage. At most the number set by SetRecentResults is kept.
+func (me *MonitorEngine) RecentResults(id string) []CheckResult {
+	me.recentMutex.RLock()
+	defer me.recentMutex.RUnlock()
+	ring, exists := me.recent[id]
+	if !exists {
+		return []CheckResult{}
+	}
+	return ring.snapshot()
+}
+
+// recordRecent adds a result to its website's recent results
+func (me *MonitorEngine) recordRecent(result CheckResult) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	ring, exists := me.recent[result.WebsiteID]
+	if !exists {
+		ring = &resultRing{}
+		me.recent[result.WebsiteID] = ring
+	}
+	ring.add(result, me.recentSize)
+}
+
+// dropRecent forgets a removed website's recent results
+func (me *MonitorEngine) dropRecent(id string) {
+	me.recentMutex.Lock()
+	defer me.recentMutex.Unlock()
+	delete(me.recent, id)
+}
@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// responseTimes returns the response times of results, which tests use to
+// tell results apart
+func responseTimes(results []CheckResult) []int {
+	times := []int{}
+	for _, result := range results {
+		times = append(times, result.ResponseTime)
+	}
+	return times
+}
+
+func TestRecentResultsKeepTheNewest(t *testing.T) {
+	me := NewMonitorEngine()
+	me.SetRecentResults(3)
+	for i := 1; i <= 5; i++ {
+		me.recordRecent(CheckResult{WebsiteID: "site", ResponseTime: i})
+	}
+	if got := responseTimes(me.RecentResults("site")); !reflect.DeepEqual(got, []int{3, 4, 5}) {
+		t.Errorf("recent results = %v, want the newest 3, oldest first", got)
+	}
+	if got := me.RecentResults("other"); len(got) != 0 {
+		t.Errorf("recent results of an unchecked website = %v, want none", got)
+	}
+
+	me.AddWebsite(testWebsite("site"))
+	me.RemoveWebsite("site")
+	if got := me.RecentResults("site"); len(got) != 0 {
+		t.Errorf("recent results of a removed website = %v, want none", got)
+	}
+}
+
+func TestSeedRecentResults(t *testing.T) {
+	me := NewMonitorEngine()
+	me.SetRecentResults(2)
+	stored := []CheckResult{{ResponseTime: 1}, {ResponseTime: 2}, {ResponseTime: 3}}
+	me.SeedRecentResults("site", stored)
+	if got := responseTimes(me.RecentResults("site")); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("seeded results = %v, want the newest 2", got)
+	}
+
+	// Results recorded since startup win over stored history
+	me.recordRecent(CheckResult{WebsiteID: "checked", ResponseTime: 4})
+	me.SeedRecentResults("checked", stored)
+	if got := responseTimes(me.RecentResults("checked")); !reflect.DeepEqual(got, []int{4}) {
+		t.Errorf("recent results = %v, want the recorded one", got)
+	}
+}
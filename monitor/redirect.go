@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxRedirects is how many redirects a check follows by default
+const DefaultMaxRedirects = 10
+
+// redirectPolicyKey carries a check's redirectPolicy in its request context
+type redirectPolicyKey struct{}
+
+// redirectPolicy is how a single check request handles redirects. The HTTP
+// clients are shared between websites, so the policy travels with the request.
+type redirectPolicy struct {
+	follow       bool
+	maxRedirects int
+}
+
+// withRedirectPolicy attaches a website's redirect settings to a check context
+func withRedirectPolicy(ctx context.Context, website *Website) context.Context {
+	policy := redirectPolicy{follow: true, maxRedirects: DefaultMaxRedirects}
+	if website.FollowRedirects != nil {
+		policy.follow = *website.FollowRedirects
+	}
+	if website.MaxRedirects > 0 {
+		policy.maxRedirects = website.MaxRedirects
+	}
+	return context.WithValue(ctx, redirectPolicyKey{}, policy)
+}
+
+// checkRedirect is the CheckRedirect function of every check client. Without
+// following, the redirect response itself is the check's final response.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	policy, ok := req.Context().Value(redirectPolicyKey{}).(redirectPolicy)
+	if !ok {
+		policy = redirectPolicy{follow: true, maxRedirects: DefaultMaxRedirects}
+	}
+
+	if !policy.follow {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > policy.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", policy.maxRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps the short day names accepted in time windows
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeWindow represents a recurring weekly time range, e.g. Mon-Fri 09:00-17:00.
+// A window whose end is before its start runs past midnight into the next day.
+type TimeWindow struct {
+	Days     []string `json:"days"`     // Short day names ("mon", "tue", ...), empty means every day
+	Start    string   `json:"start"`    // Start time as HH:MM
+	End      string   `json:"end"`      // End time as HH:MM
+	Timezone string   `json:"timezone"` // IANA timezone name, empty means server local time
+}
+
+// Validate checks that the window is well-formed
+func (w TimeWindow) Validate() error {
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+	if _, err := parseClock(w.Start); err != nil {
+		return fmt.Errorf("invalid start time: %v", err)
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return fmt.Errorf("invalid end time: %v", err)
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", w.Timezone)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether t falls inside the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false
+		}
+		t = t.In(loc)
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return w.onDay(t.Weekday()) && minute >= start && minute < end
+	}
+
+	// Overnight window: the part after midnight belongs to the previous day
+	if minute >= start {
+		return w.onDay(t.Weekday())
+	}
+	return minute < end && w.onDay((t.Weekday()+6)%7)
+}
+
+// onDay reports whether the window applies to the given weekday
+func (w TimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses an HH:MM string into minutes since midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not in HH:MM format", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// IsActiveAt reports whether the website should be monitored at t.
+// Websites without an active schedule are always active.
+func (w *Website) IsActiveAt(t time.Time) bool {
+	if len(w.ActiveSchedule) == 0 {
+		return true
+	}
+	for _, window := range w.ActiveSchedule {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+package monitor
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DefaultMaxConcurrentChecks is the default number of checks running at once
+const DefaultMaxConcurrentChecks = 50
+
+// DefaultMaxStartupJitter is the default bound of the random delay before
+// each website's first check after Start
+const DefaultMaxStartupJitter = 10 * time.Second
+
+// scheduleEntry is a website's place in the check schedule
+type scheduleEntry struct {
+	id       string
+	due      time.Time
+	interval time.Duration
+	index    int // Position in the heap, maintained by scheduleHeap
+}
+
+// scheduleHeap is a min-heap of schedule entries ordered by due time
+type scheduleHeap []*scheduleEntry
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h scheduleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+	entry := x.(*scheduleEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// checkInterval returns how often a website is checked
+func checkInterval(website *Website) time.Duration {
+	if website.IntervalSeconds < 1 {
+		return 60 * time.Second
+	}
+	return time.Duration(website.IntervalSeconds) * time.Second
+}
+
+// Defaults of a website's check backoff
+const (
+	DefaultBackoffFactor      = 2.0
+	DefaultBackoffMaxInterval = time.Hour
+)
+
+// EffectiveInterval returns how long the scheduler waits between the
+// website's checks: its check interval, stretched by backoff while the
+// website keeps failing
+func (w *Website) EffectiveInterval() time.Duration {
+	interval := checkInterval(w)
+	if w.BackoffAfterFailures < 1 || w.ConsecutiveFailures < w.BackoffAfterFailures {
+		return interval
+	}
+	factor := w.BackoffFactor
+	if factor <= 1 {
+		factor = DefaultBackoffFactor
+	}
+	max := DefaultBackoffMaxInterval
+	if w.BackoffMaxIntervalSeconds > 0 {
+		max = time.Duration(w.BackoffMaxIntervalSeconds) * time.Second
+	}
+	if max <= interval {
+		return interval
+	}
+
+	steps := w.ConsecutiveFailures - w.BackoffAfterFailures + 1
+	backoff := float64(interval) * math.Pow(factor, float64(steps))
+	if backoff >= float64(max) {
+		return max
+	}
+	return time.Duration(backoff)
+}
+
+// SetMaxConcurrentChecks sets the number of checks that may run at once,
+// across all websites. The number of workers changes when the engine is
+// started; the limit on on-demand checks applies right away.
+func (me *MonitorEngine) SetMaxConcurrentChecks(limit int) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if limit < 1 {
+		limit = DefaultMaxConcurrentChecks
+	}
+	me.maxConcurrentChecks = limit
+	me.checkSlots = make(chan struct{}, limit)
+}
+
+// acquireCheckSlot blocks until fewer than maxConcurrentChecks checks are
+// running and returns a function that releases the slot. It returns false
+// without a slot if the engine stops while waiting.
+func (me *MonitorEngine) acquireCheckSlot() (func(), bool) {
+	me.mutex.RLock()
+	slots := me.checkSlots
+	me.mutex.RUnlock()
+
+	// Prefer a free slot over stopping, so a check handed out before Stop still runs
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	case <-me.stopChan:
+		return nil, false
+	}
+}
+
+// SetMaxStartupJitter sets the bound of the random delay before each
+// website's first check after Start, so a large number of websites doesn't
+// hit the network in one burst, nor again at every interval. A website's
+// delay is also below its check interval. Zero checks every website at once.
+func (me *MonitorEngine) SetMaxStartupJitter(max time.Duration) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if max < 0 {
+		max = 0
+	}
+	me.maxStartupJitter = max
+}
+
+// SetRandSource sets the source of the startup jitter, e.g. a fixed seed to
+// make the first check times reproducible
+func (me *MonitorEngine) SetRandSource(src rand.Source) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.jitterRand = rand.New(src)
+}
+
+// startupDelayLocked returns a random delay for a website's first check,
+// below both maxStartupJitter and its check interval. The caller must hold mutex.
+func (me *MonitorEngine) startupDelayLocked(website *Website) time.Duration {
+	bound := me.maxStartupJitter
+	if interval := checkInterval(website); interval < bound {
+		bound = interval
+	}
+	if bound <= 0 {
+		return 0
+	}
+	return time.Duration(me.jitterRand.Int63n(int64(bound)))
+}
+
+// scheduleLocked adds an enabled website to the check schedule, due after
+// delay, unless it is already scheduled. The caller must hold mutex.
+func (me *MonitorEngine) scheduleLocked(website *Website, delay time.Duration) {
+	if !me.running || !website.Enabled {
+		return
+	}
+
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+
+	if _, exists := me.scheduled[website.ID]; exists {
+		return
+	}
+	entry := &scheduleEntry{
+		id:       website.ID,
+		due:      me.now().Add(delay),
+		interval: website.EffectiveInterval(),
+	}
+	heap.Push(&me.schedule, entry)
+	me.scheduled[website.ID] = entry
+	me.wakeScheduler()
+}
+
+// unschedule removes a website from the check schedule. A check already
+// running for it still completes.
+func (me *MonitorEngine) unschedule(id string) {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+
+	if entry, exists := me.scheduled[id]; exists {
+		heap.Remove(&me.schedule, entry.index)
+		delete(me.scheduled, id)
+	}
+}
+
+// WebsiteChanged tells the scheduler that a website's configuration was
+// updated, so a new check interval takes effect without a restart. The last
+// failure capture is dropped when the website stops capturing.
+func (me *MonitorEngine) WebsiteChanged(id string) {
+	me.mutex.RLock()
+	website, exists := me.websites[id]
+	var interval time.Duration
+	var capture bool
+	if exists {
+		interval = website.EffectiveInterval()
+		capture = website.CaptureOnFailure
+	}
+	me.mutex.RUnlock()
+	if !exists {
+		return
+	}
+	if !capture {
+		me.dropCapture(id)
+	}
+
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+
+	entry, scheduled := me.scheduled[id]
+	if !scheduled || entry.interval == interval {
+		return
+	}
+	entry.interval = interval
+	entry.due = me.now().Add(interval)
+	heap.Fix(&me.schedule, entry.index)
+	me.wakeScheduler()
+}
+
+// applyBackoff moves a website's next check after a result changed its
+// effective interval, so the gaps grow while it keeps failing and the normal
+// interval is back as soon as it recovers
+func (me *MonitorEngine) applyBackoff(id string) {
+	me.mutex.RLock()
+	website, exists := me.websites[id]
+	var interval time.Duration
+	if exists {
+		interval = website.EffectiveInterval()
+	}
+	me.mutex.RUnlock()
+	if !exists {
+		return
+	}
+
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+
+	entry, scheduled := me.scheduled[id]
+	if !scheduled || entry.interval == interval {
+		return
+	}
+	// The next check was due one old interval after the last one started; a
+	// recovered website whose normal interval has already passed is due now
+	entry.due = entry.due.Add(interval - entry.interval)
+	entry.interval = interval
+	heap.Fix(&me.schedule, entry.index)
+	me.wakeScheduler()
+}
+
+// wakeScheduler makes the scheduler re-read the schedule. The caller must hold scheduleMutex.
+func (me *MonitorEngine) wakeScheduler() {
+	select {
+	case me.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runScheduler hands due websites to the workers until the engine stops
+func (me *MonitorEngine) runScheduler() {
+	for {
+		timer := me.clock.TimerAt(me.dispatchDue())
+
+		select {
+		case <-timer.C():
+		case <-me.wake:
+		case <-me.stopChan:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// dispatchDue sends every due website to the workers and returns when the
+// next one is due
+func (me *MonitorEngine) dispatchDue() time.Time {
+	for {
+		me.scheduleMutex.Lock()
+		now := me.now()
+		if len(me.schedule) == 0 {
+			me.scheduleMutex.Unlock()
+			return now.Add(time.Hour)
+		}
+
+		entry := me.schedule[0]
+		if entry.due.After(now) {
+			due := entry.due
+			me.scheduleMutex.Unlock()
+			return due
+		}
+
+		// The next check is due one interval after this one starts
+		entry.due = now.Add(entry.interval)
+		heap.Fix(&me.schedule, entry.index)
+
+		// Skip the slot if the previous check is still running, like a ticker drops ticks
+		id := entry.id
+		busy := me.inFlight[id]
+		me.inFlight[id] = true
+		me.scheduleMutex.Unlock()
+		if busy {
+			continue
+		}
+
+		website, exists := me.GetWebsite(id)
+		if !exists || !website.Enabled {
+			me.checkDone(id)
+			me.unschedule(id)
+			continue
+		}
+		// Skip checks outside the website's active schedule
+		if !website.IsActiveAt(now) {
+			me.checkDone(id)
+			continue
+		}
+
+		select {
+		case me.checkQueue <- checkRequest{website: website}:
+		case <-me.stopChan:
+			return now.Add(time.Hour)
+		}
+	}
+}
+
+// checkDone marks a website's check as finished
+func (me *MonitorEngine) checkDone(id string) {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+	delete(me.inFlight, id)
+}
+
+// checkRequest hands a website's check to a worker
+type checkRequest struct {
+	website *Website
+	job     *CheckJob // The CheckJob the check belongs to, nil for scheduled checks
+}
+
+// runWorker runs checks handed out by the scheduler and CheckAll until the
+// engine stops
+func (me *MonitorEngine) runWorker() {
+	defer me.workers.Done()
+
+	for {
+		select {
+		case request := <-me.checkQueue:
+			me.checkWebsite(request.website)
+			me.checkDone(request.website.ID)
+			if request.job != nil {
+				me.jobCheckDone(request.job)
+			}
+		case <-me.stopChan:
+			return
+		}
+	}
+}
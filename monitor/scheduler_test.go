@@ -0,0 +1,479 @@
+package monitor
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signalingDoer answers every check with 200 and sends the checked URL on
+// checked, so tests can wait for checks
+func signalingDoer(checked chan<- string) fakeDoer {
+	return func(req *http.Request) (*http.Response, error) {
+		checked <- req.URL.String()
+		return fakeResponse(http.StatusOK, "ok"), nil
+	}
+}
+
+// startTestEngine starts an engine checking through doer, on clock if it
+// isn't nil, with no startup jitter, and stops it when the test ends
+func startTestEngine(t *testing.T, doer HTTPDoer, clock Clock, websites ...*Website) *MonitorEngine {
+	t.Helper()
+	me := NewMonitorEngineWithDeps(doer, clock)
+	me.SetMaxStartupJitter(0)
+	for _, website := range websites {
+		me.AddWebsite(website)
+	}
+	me.Start()
+	t.Cleanup(me.Stop)
+
+	// Nothing reads the results in tests; keep the processor from blocking
+	go func() {
+		for range me.GetResultChannel() {
+		}
+	}()
+	return me
+}
+
+// waitForCheck waits for the next check reported on checked
+func waitForCheck(t *testing.T, checked <-chan string, within time.Duration) string {
+	t.Helper()
+	select {
+	case url := <-checked:
+		return url
+	case <-time.After(within):
+		t.Fatalf("no check within %s", within)
+		return ""
+	}
+}
+
+// isScheduled reports whether a website is on the check schedule
+func isScheduled(me *MonitorEngine, id string) bool {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+	_, scheduled := me.scheduled[id]
+	return scheduled
+}
+
+func TestPauseAndResumeWebsite(t *testing.T) {
+	checked := make(chan string, 10)
+	website := testWebsite("site")
+	website.IntervalSeconds = 3600
+	me := startTestEngine(t, signalingDoer(checked), nil, website)
+
+	waitForCheck(t, checked, 2*time.Second)
+
+	if !me.PauseWebsite(website.ID) {
+		t.Fatal("PauseWebsite didn't find the website")
+	}
+	if paused, _ := me.GetWebsite(website.ID); paused.Enabled {
+		t.Error("paused website is still enabled")
+	}
+	if isScheduled(me, website.ID) {
+		t.Error("paused website is still scheduled")
+	}
+
+	if !me.ResumeWebsite(website.ID) {
+		t.Fatal("ResumeWebsite didn't find the website")
+	}
+	if resumed, _ := me.GetWebsite(website.ID); !resumed.Enabled {
+		t.Error("resumed website isn't enabled")
+	}
+	if !isScheduled(me, website.ID) {
+		t.Error("resumed website isn't scheduled")
+	}
+	// Resuming checks right away rather than an hour after the last check
+	waitForCheck(t, checked, 2*time.Second)
+
+	if me.PauseWebsite("missing") || me.ResumeWebsite("missing") {
+		t.Error("pausing or resuming a missing website succeeded")
+	}
+}
+
+// TestPauseAndResumeWhileScheduling toggles a website while the scheduler
+// holds it, waiting for a worker to take its check, for the race detector
+func TestPauseAndResumeWhileScheduling(t *testing.T) {
+	started := make(chan string, 1000)
+	release := make(chan struct{})
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		started <- req.URL.Host
+		if req.URL.Host == "slow.example.com" {
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+		}
+		return fakeResponse(http.StatusOK, "ok"), nil
+	})
+
+	// A single worker, kept busy by slow
+	me := NewMonitorEngineWithDeps(doer, nil)
+	me.SetMaxStartupJitter(0)
+	me.SetMaxConcurrentChecks(1)
+	slow := testWebsite("slow")
+	slow.IntervalSeconds = 3600
+	me.AddWebsite(slow)
+	me.Start()
+	t.Cleanup(me.Stop)
+	go func() {
+		for range me.GetResultChannel() {
+		}
+	}()
+	waitForCheck(t, started, 2*time.Second)
+
+	// site is due at once, so the scheduler reads it and waits for the worker
+	website := testWebsite("site")
+	website.IntervalSeconds = 1
+	me.AddWebsite(website)
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		me.PauseWebsite(website.ID)
+		me.ResumeWebsite(website.ID)
+		if i == 10 {
+			close(release)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Resuming last leaves the website enabled and checked
+	if current, _ := me.GetWebsite(website.ID); !current.Enabled {
+		t.Error("website isn't enabled after resuming it")
+	}
+	if !isScheduled(me, website.ID) {
+		t.Error("website isn't scheduled after resuming it")
+	}
+	if host := waitForCheck(t, started, 2*time.Second); host != "site.example.com" {
+		t.Errorf("checked %s, want site.example.com", host)
+	}
+}
+
+// concurrencyDoer holds every check until release is closed and records how
+// many ran at once
+type concurrencyDoer struct {
+	mutex    sync.Mutex
+	inFlight int
+	max      int
+	started  chan string
+	release  chan struct{}
+}
+
+func newConcurrencyDoer(checks int) *concurrencyDoer {
+	return &concurrencyDoer{started: make(chan string, checks), release: make(chan struct{})}
+}
+
+func (d *concurrencyDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mutex.Lock()
+	d.inFlight++
+	if d.inFlight > d.max {
+		d.max = d.inFlight
+	}
+	d.mutex.Unlock()
+
+	d.started <- req.URL.Host
+	<-d.release
+
+	d.mutex.Lock()
+	d.inFlight--
+	d.mutex.Unlock()
+	return fakeResponse(http.StatusOK, "ok"), nil
+}
+
+func (d *concurrencyDoer) maxInFlight() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.max
+}
+
+func TestMaxConcurrentChecks(t *testing.T) {
+	const limit = 3
+	const sites = 12
+
+	// The clock stands still, so every website is checked exactly once:
+	// either by its first scheduled check or by CheckAll
+	doer := newConcurrencyDoer(sites)
+	me := NewMonitorEngineWithDeps(doer, newFakeClock(0))
+	me.SetMaxConcurrentChecks(limit)
+	me.SetMaxStartupJitter(0)
+	for i := 0; i < sites; i++ {
+		website := testWebsite(fmt.Sprintf("site%d", i))
+		website.IntervalSeconds = 3600
+		me.AddWebsite(website)
+	}
+	me.Start()
+	defer me.Stop()
+	go func() {
+		for range me.GetResultChannel() {
+		}
+	}()
+
+	// On-demand checks compete for the same slots as the scheduled ones
+	me.CheckAll()
+
+	// limit checks are held at once; the others wait for a slot
+	for i := 0; i < limit; i++ {
+		waitForCheck(t, doer.started, 2*time.Second)
+	}
+	close(doer.release)
+	for i := limit; i < sites; i++ {
+		waitForCheck(t, doer.started, 2*time.Second)
+	}
+
+	if max := doer.maxInFlight(); max != limit {
+		t.Errorf("%d checks ran at once, want the limit of %d", max, limit)
+	}
+}
+
+// setInterval changes a website's interval the way the API does
+func setInterval(me *MonitorEngine, id string, seconds int) {
+	me.UpdateWebsite(id, func(website *Website) {
+		website.IntervalSeconds = seconds
+	})
+	me.WebsiteChanged(id)
+}
+
+// scheduledEntry returns when a website's next check is due and the
+// interval it is scheduled at
+func scheduledEntry(me *MonitorEngine, id string) (time.Time, time.Duration) {
+	me.scheduleMutex.Lock()
+	defer me.scheduleMutex.Unlock()
+	if entry, scheduled := me.scheduled[id]; scheduled {
+		return entry.due, entry.interval
+	}
+	return time.Time{}, 0
+}
+
+func TestIntervalChangeTakesEffect(t *testing.T) {
+	checked := make(chan string, 10)
+	clock := newFakeClock(0)
+	website := testWebsite("site")
+	website.IntervalSeconds = 3600
+	me := startTestEngine(t, signalingDoer(checked), clock, website)
+	waitForCheck(t, checked, 2*time.Second)
+
+	// Shortened from an hour, checks follow each other a second apart
+	setInterval(me, website.ID, 1)
+	due, interval := scheduledEntry(me, website.ID)
+	if interval != time.Second {
+		t.Fatalf("scheduled interval = %s, want 1s", interval)
+	}
+	if want := clock.Now().Add(time.Second); !due.Equal(want) {
+		t.Fatalf("next check due %s, want %s", due, want)
+	}
+	clock.Advance(time.Second)
+	waitForCheck(t, checked, 2*time.Second)
+	clock.Advance(time.Second)
+	waitForCheck(t, checked, 2*time.Second)
+
+	// Lengthened again, the next check is an hour away
+	setInterval(me, website.ID, 3600)
+	due, interval = scheduledEntry(me, website.ID)
+	if interval != time.Hour {
+		t.Fatalf("scheduled interval = %s, want 1h", interval)
+	}
+	if want := clock.Now().Add(time.Hour); !due.Equal(want) {
+		t.Fatalf("next check due %s, want %s", due, want)
+	}
+	clock.Advance(time.Hour)
+	waitForCheck(t, checked, 2*time.Second)
+}
+
+// startupDelays returns the first check delays a seeded engine gives websites
+// with the given intervals, under a 10s jitter bound
+func startupDelays(seed int64, intervals []int) []time.Duration {
+	me := NewMonitorEngine()
+	me.SetMaxStartupJitter(10 * time.Second)
+	me.SetRandSource(rand.NewSource(seed))
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	delays := make([]time.Duration, len(intervals))
+	for i, seconds := range intervals {
+		website := testWebsite(fmt.Sprintf("site%d", i))
+		website.IntervalSeconds = seconds
+		delays[i] = me.startupDelayLocked(website)
+	}
+	return delays
+}
+
+func TestStartupDelays(t *testing.T) {
+	const sites = 100
+	intervals := make([]int, sites)
+	for i := range intervals {
+		// Half the websites are checked more often than the jitter bound
+		intervals[i] = 60
+		if i%2 == 1 {
+			intervals[i] = 4
+		}
+	}
+
+	delays := startupDelays(42, intervals)
+	seen := make(map[time.Duration]bool)
+	var earliest, latest time.Duration = time.Hour, 0
+	for i, delay := range delays {
+		bound := 10 * time.Second
+		if interval := time.Duration(intervals[i]) * time.Second; interval < bound {
+			bound = interval
+		}
+		if delay < 0 || delay >= bound {
+			t.Errorf("site%d: delay %s outside [0, %s)", i, delay, bound)
+		}
+		seen[delay] = true
+		if intervals[i] == 60 {
+			if delay < earliest {
+				earliest = delay
+			}
+			if delay > latest {
+				latest = delay
+			}
+		}
+	}
+
+	// Spread out rather than bunched together
+	if len(seen) < sites*9/10 {
+		t.Errorf("only %d distinct delays among %d websites", len(seen), sites)
+	}
+	if earliest > 2*time.Second || latest < 8*time.Second {
+		t.Errorf("delays span %s to %s, want most of [0, 10s)", earliest, latest)
+	}
+
+	// The same seed gives the same delays
+	again := startupDelays(42, intervals)
+	for i := range delays {
+		if delays[i] != again[i] {
+			t.Fatalf("site%d: delay %s with the same seed, first %s", i, again[i], delays[i])
+		}
+	}
+}
+
+func TestStartupDelayWithoutJitter(t *testing.T) {
+	me := NewMonitorEngine()
+	me.SetMaxStartupJitter(0)
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if delay := me.startupDelayLocked(testWebsite("site")); delay != 0 {
+		t.Errorf("delay = %s without jitter, want 0", delay)
+	}
+}
+
+func TestStopCancelsRunningChecks(t *testing.T) {
+	started := make(chan struct{})
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		close(started)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	me := NewMonitorEngineWithDeps(doer, nil)
+	me.SetMaxStartupJitter(0)
+	me.AddWebsite(testWebsite("site"))
+	me.Start()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the check didn't start")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		me.Stop()
+		close(stopped)
+	}()
+	var results []CheckResult
+	drained := make(chan struct{})
+	go func() {
+		for result := range me.GetResultChannel() {
+			results = append(results, result)
+		}
+		close(drained)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for the check to time out")
+	}
+	<-drained
+	if len(results) != 0 {
+		t.Errorf("results = %+v, want the cancelled check unreported", results)
+	}
+}
+
+func TestEffectiveInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		website  Website
+		failures int
+		want     time.Duration
+	}{
+		{name: "backoff disabled", website: Website{IntervalSeconds: 60}, failures: 10, want: time.Minute},
+		{name: "before backoff", website: Website{IntervalSeconds: 60, BackoffAfterFailures: 3}, failures: 2, want: time.Minute},
+		{name: "first step", website: Website{IntervalSeconds: 60, BackoffAfterFailures: 3}, failures: 3, want: 2 * time.Minute},
+		{name: "custom factor", website: Website{IntervalSeconds: 60, BackoffAfterFailures: 3, BackoffFactor: 3}, failures: 4, want: 9 * time.Minute},
+		{name: "default cap", website: Website{IntervalSeconds: 60, BackoffAfterFailures: 1}, failures: 20, want: DefaultBackoffMaxInterval},
+		{name: "custom cap", website: Website{IntervalSeconds: 60, BackoffAfterFailures: 1, BackoffMaxIntervalSeconds: 300}, failures: 5, want: 5 * time.Minute},
+		{name: "cap below interval", website: Website{IntervalSeconds: 600, BackoffAfterFailures: 1, BackoffMaxIntervalSeconds: 300}, failures: 5, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		website := tt.website
+		website.ConsecutiveFailures = tt.failures
+		if got := website.EffectiveInterval(); got != tt.want {
+			t.Errorf("%s: interval = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffEndsOnRecovery(t *testing.T) {
+	me := NewMonitorEngine()
+	website := testWebsite("site")
+	website.BackoffAfterFailures = 2
+	me.AddWebsite(website)
+
+	for i := 0; i < 3; i++ {
+		me.UpdateWebsiteStatus("site", StatusDown, 0)
+	}
+	current, _ := me.GetWebsite("site")
+	if current.ConsecutiveFailures != 3 || current.EffectiveInterval() != 4*time.Minute {
+		t.Errorf("after 3 failures: %d in a row, interval %s, want 4m", current.ConsecutiveFailures, current.EffectiveInterval())
+	}
+
+	// Degraded isn't a failure
+	me.UpdateWebsiteStatus("site", StatusDegraded, 100)
+	current, _ = me.GetWebsite("site")
+	if current.ConsecutiveFailures != 0 || current.EffectiveInterval() != time.Minute {
+		t.Errorf("after recovering: %d failures in a row, interval %s, want the normal 1m", current.ConsecutiveFailures, current.EffectiveInterval())
+	}
+}
+
+func TestBackoffReschedulesNextCheck(t *testing.T) {
+	checked := make(chan string, 10)
+	clock := newFakeClock(0)
+	start := clock.Now()
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		checked <- req.URL.String()
+		return fakeResponse(http.StatusServiceUnavailable, "down"), nil
+	})
+	website := testWebsite("site")
+	website.BackoffAfterFailures = 1
+	me := startTestEngine(t, doer, clock, website)
+	waitForCheck(t, checked, 2*time.Second)
+
+	// The result is processed after the check returns
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		due, interval := scheduledEntry(me, website.ID)
+		if interval == 2*time.Minute {
+			if want := start.Add(2 * time.Minute); !due.Equal(want) {
+				t.Fatalf("next check due %s, want %s", due, want)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduled interval = %s, want 2m after the failure", interval)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
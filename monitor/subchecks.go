@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxSubChecks bounds the sub-checks of a website, which run one after
+// another within its check
+const MaxSubChecks = 10
+
+// SubCheck is an auxiliary path of an HTTP website, such as /robots.txt or
+// /sitemap.xml, requested after each check of the website
+type SubCheck struct {
+	Path           string `json:"path"`                      // Relative to the website's URL, starting with /
+	ExpectedStatus int    `json:"expected_status,omitempty"` // 0 means 200
+}
+
+// expectedStatus returns the status code the sub-check passes with
+func (s SubCheck) expectedStatus() int {
+	if s.ExpectedStatus == 0 {
+		return http.StatusOK
+	}
+	return s.ExpectedStatus
+}
+
+// SubCheckResult is the outcome of one sub-check of a check
+type SubCheckResult struct {
+	Passed       bool   `json:"passed"`
+	StatusCode   int    `json:"status_code,omitempty"` // 0 when no response arrived
+	ResponseTime int    `json:"response_time_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// subCheckError reports a failed sub-check of a website whose status depends
+// on its sub-checks
+type subCheckError struct {
+	path   string
+	reason string
+}
+
+func (e *subCheckError) Error() string {
+	return fmt.Sprintf("sub-check %s failed: %s", e.path, e.reason)
+}
+
+// ValidateSubChecks checks the sub-checks of a website
+func ValidateSubChecks(subChecks []SubCheck) error {
+	if len(subChecks) > MaxSubChecks {
+		return fmt.Errorf("sub_checks may list at most %d paths", MaxSubChecks)
+	}
+	seen := make(map[string]bool)
+	for _, subCheck := range subChecks {
+		if !strings.HasPrefix(subCheck.Path, "/") || strings.HasPrefix(subCheck.Path, "//") {
+			return fmt.Errorf("sub_checks path must start with a single /, got %q", subCheck.Path)
+		}
+		if _, err := url.Parse(subCheck.Path); err != nil {
+			return fmt.Errorf("sub_checks path %q is invalid: %v", subCheck.Path, err)
+		}
+		if seen[subCheck.Path] {
+			return fmt.Errorf("sub_checks lists %s more than once", subCheck.Path)
+		}
+		seen[subCheck.Path] = true
+		if subCheck.ExpectedStatus != 0 && (subCheck.ExpectedStatus < 100 || subCheck.ExpectedStatus > 599) {
+			return fmt.Errorf("sub_checks expected_status must be between 100 and 599, got %d", subCheck.ExpectedStatus)
+		}
+	}
+	return nil
+}
+
+// runSubChecks requests the sub-checks of a website and records their
+// results, keyed by path, on the result of its main check. A website with
+// RequireSubChecks is down when one of them fails.
+func (me *MonitorEngine) runSubChecks(website *Website, result *CheckResult) {
+	base, err := url.Parse(website.URL)
+	if err != nil {
+		return
+	}
+	client, err := me.doerFor(website)
+	if err != nil {
+		return
+	}
+
+	result.SubChecks = make(map[string]SubCheckResult, len(website.SubChecks))
+	for _, subCheck := range website.SubChecks {
+		subResult := me.performSubCheck(website, client, base, subCheck)
+		result.SubChecks[subCheck.Path] = subResult
+		if me.ctx.Err() != nil {
+			// Cancelled by Stop, the remaining paths say nothing
+			return
+		}
+
+		if !subResult.Passed && website.RequireSubChecks && (result.Status == StatusUp || result.Status == StatusDegraded) {
+			result.Status = StatusDown
+			result.Error = &subCheckError{path: subCheck.Path, reason: subResult.Error}
+		}
+	}
+}
+
+// performSubCheck requests one sub-check path of a website, with the
+// website's headers, authentication and timeout
+func (me *MonitorEngine) performSubCheck(website *Website, client HTTPDoer, base *url.URL, subCheck SubCheck) SubCheckResult {
+	ctx, cancel := context.WithTimeout(me.ctx, checkTimeout(website))
+	defer cancel()
+	ctx = withRedirectPolicy(ctx, website)
+
+	ref, _ := url.Parse(subCheck.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return SubCheckResult{Error: err.Error()}
+	}
+	req.Header.Set("User-Agent", me.userAgents[rand.Intn(len(me.userAgents))])
+	applyHeaders(req, website.Headers)
+	if website.Auth != nil {
+		website.Auth.apply(req)
+	}
+
+	start := me.now()
+	resp, err := client.Do(req)
+	subResult := SubCheckResult{ResponseTime: int(me.now().Sub(start).Milliseconds())}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = &timeoutError{message: fmt.Sprintf("timed out after %s", checkTimeout(website))}
+		}
+		subResult.Error = err.Error()
+		return subResult
+	}
+	defer resp.Body.Close()
+	discardBody(resp)
+
+	subResult.StatusCode = resp.StatusCode
+	subResult.Passed = resp.StatusCode == subCheck.expectedStatus()
+	if !subResult.Passed {
+		subResult.Error = (&statusError{statusCode: resp.StatusCode}).Error()
+	}
+	return subResult
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateSubChecks(t *testing.T) {
+	tooMany := make([]SubCheck, MaxSubChecks+1)
+	for i := range tooMany {
+		tooMany[i] = SubCheck{Path: "/" + strings.Repeat("a", i+1)}
+	}
+	tests := []struct {
+		name      string
+		subChecks []SubCheck
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "paths", subChecks: []SubCheck{{Path: "/robots.txt"}, {Path: "/sitemap.xml", ExpectedStatus: 301}}},
+		{name: "relative path", subChecks: []SubCheck{{Path: "robots.txt"}}, wantErr: true},
+		{name: "other host", subChecks: []SubCheck{{Path: "//example.org/robots.txt"}}, wantErr: true},
+		{name: "repeated path", subChecks: []SubCheck{{Path: "/robots.txt"}, {Path: "/robots.txt"}}, wantErr: true},
+		{name: "invalid status", subChecks: []SubCheck{{Path: "/robots.txt", ExpectedStatus: 42}}, wantErr: true},
+		{name: "too many", subChecks: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateSubChecks(tt.subChecks); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want one: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSubChecks(t *testing.T) {
+	doer := fakeDoer(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("X-Env") != "prod" {
+			return fakeResponse(http.StatusForbidden, ""), nil
+		}
+		if req.URL.Path == "/sitemap.xml" {
+			return fakeResponse(http.StatusNotFound, ""), nil
+		}
+		return fakeResponse(http.StatusOK, "ok"), nil
+	})
+
+	for _, require := range []bool{false, true} {
+		website := testWebsite("site")
+		website.Headers = map[string]string{"X-Env": "prod"}
+		website.SubChecks = []SubCheck{{Path: "/robots.txt"}, {Path: "/sitemap.xml"}}
+		website.RequireSubChecks = require
+		result := checkOnce(t, NewMonitorEngineWithDeps(doer, nil), website)
+
+		robots, sitemap := result.SubChecks["/robots.txt"], result.SubChecks["/sitemap.xml"]
+		if !robots.Passed || sitemap.Passed || sitemap.StatusCode != http.StatusNotFound {
+			t.Errorf("require %v: sub-checks = %+v, want /robots.txt to pass and /sitemap.xml to fail with 404", require, result.SubChecks)
+		}
+		wantStatus, wantErrorCode := StatusUp, ""
+		if require {
+			wantStatus, wantErrorCode = StatusDown, ErrorCodeSubCheck
+		}
+		if result.Status != wantStatus || result.ErrorCode != wantErrorCode {
+			t.Errorf("require %v: status %q (%s), want %q (%s)", require, result.Status, result.ErrorCode, wantStatus, wantErrorCode)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings breaks an HTTP check's response time down into the phases of its
+// final request, in milliseconds. Phases that didn't happen are 0, such as
+// DNS, connect and TLS on a connection reused from the pool, and so are
+// phases that took under a millisecond.
+type Timings struct {
+	DNS     int `json:"dns_ms,omitempty"`     // Resolving the host name
+	Connect int `json:"connect_ms,omitempty"` // Opening the TCP connection
+	TLS     int `json:"tls_ms,omitempty"`     // The TLS handshake
+	TTFB    int `json:"ttfb_ms,omitempty"`    // From sending the request to the first response byte
+	Total   int `json:"total_ms,omitempty"`   // The whole check, as ResponseTime
+}
+
+// checkTrace records which address an HTTP check connected to and how long
+// the phases of its final request took
+type checkTrace struct {
+	mutex     sync.Mutex
+	connected string // Remote address of the connection the last request was sent on
+	attempted string // Address of the last connection attempt, for checks that never connect
+
+	// Phase times of the last request
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+}
+
+// clientTrace returns the hooks that feed the trace. GotConn reports the
+// connection of every request, including redirects and connections reused
+// from the pool, which skip the DNS, connect and TLS hooks. The connect hooks
+// may run concurrently when several addresses are dialed at once.
+func (t *checkTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			// Each request of a redirect chain starts over, so the timings
+			// are those of the final request
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.wroteRequest, t.firstByte = time.Time{}, time.Time{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mark(&t.dnsDone)
+		},
+		ConnectStart: func(network, addr string) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.attempted = addr
+			if err == nil {
+				t.connectDone = time.Now()
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.connected = info.Conn.RemoteAddr().String()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mark(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	}
+}
+
+// mark records the current time as a phase time
+func (t *checkTrace) mark(phase *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	*phase = time.Now()
+}
+
+// remoteIP returns the IP address the check's final request was sent to, or
+// the address last tried when no connection was made, "" when none was
+// dialed, e.g. because the name didn't resolve
+func (t *checkTrace) remoteIP() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.connected != "" {
+		return addrIP(t.connected)
+	}
+	return addrIP(t.attempted)
+}
+
+// timings returns the phase timings of the check's final request, with
+// total the response time of the whole check. Phases that didn't complete,
+// e.g. a connect that failed, are left out, and nil is returned when
+// nothing is left.
+func (t *checkTrace) timings(total int) *Timings {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	timings := Timings{
+		DNS:     phaseMs(t.dnsStart, t.dnsDone),
+		Connect: phaseMs(t.connectStart, t.connectDone),
+		TLS:     phaseMs(t.tlsStart, t.tlsDone),
+		TTFB:    phaseMs(t.wroteRequest, t.firstByte),
+		Total:   total,
+	}
+	if timings == (Timings{}) {
+		return nil
+	}
+	return &timings
+}
+
+// phaseMs returns the milliseconds between the start and end of a phase, 0
+// unless both happened
+func phaseMs(start, end time.Time) int {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return int(end.Sub(start).Milliseconds())
+}
+
+// dialErrorIP returns the address a failed dial was trying to reach, if known
+func dialErrorIP(err error) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Addr != nil {
+		return addrIP(opErr.Addr.String())
+	}
+	return ""
+}
+
+// addrIP returns the IP of a host:port address
+func addrIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	website := testWebsite("site")
+	website.URL = server.URL
+	result := checkOnce(t, NewMonitorEngine(), website)
+	if result.Timings == nil {
+		t.Fatal("an HTTP check recorded no timings")
+	}
+	if result.Timings.TTFB < 30 || result.Timings.TTFB > result.Timings.Total {
+		t.Errorf("time to first byte = %dms, want the server's 30ms and at most the total %dms", result.Timings.TTFB, result.Timings.Total)
+	}
+	if result.Timings.Total != result.ResponseTime {
+		t.Errorf("total = %dms, want the response time %dms", result.Timings.Total, result.ResponseTime)
+	}
+	// No name to resolve
+	if result.Timings.DNS != 0 {
+		t.Errorf("DNS = %dms, want none for an IP address", result.Timings.DNS)
+	}
+}
+
+func TestTimingsOfFinalRequest(t *testing.T) {
+	trace := &checkTrace{}
+	hooks := trace.clientTrace()
+
+	// A redirected request that had to resolve and connect
+	hooks.GetConn("old.example.com:80")
+	hooks.DNSStart(httptrace.DNSStartInfo{})
+	time.Sleep(5 * time.Millisecond)
+	hooks.DNSDone(httptrace.DNSDoneInfo{})
+	hooks.ConnectStart("tcp", "192.0.2.1:80")
+	time.Sleep(5 * time.Millisecond)
+	hooks.ConnectDone("tcp", "192.0.2.1:80", nil)
+
+	// The final request, on a pooled connection
+	hooks.GetConn("new.example.com:80")
+	hooks.WroteRequest(httptrace.WroteRequestInfo{})
+	time.Sleep(5 * time.Millisecond)
+	hooks.GotFirstResponseByte()
+
+	timings := trace.timings(20)
+	if timings == nil {
+		t.Fatal("no timings")
+	}
+	if timings.DNS != 0 || timings.Connect != 0 {
+		t.Errorf("DNS %dms, connect %dms, want the redirected request's left out", timings.DNS, timings.Connect)
+	}
+	if timings.TTFB < 5 || timings.Total != 20 {
+		t.Errorf("timings = %+v, want the final request's", *timings)
+	}
+
+	if timings := (&checkTrace{}).timings(0); timings != nil {
+		t.Errorf("timings = %+v, want nil when nothing was timed", *timings)
+	}
+}
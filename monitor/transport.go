@@ -0,0 +1,238 @@
+package monitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientCertificate configures a TLS client certificate for mutual TLS.
+// Either the file paths or the inline PEM blocks must be set.
+type ClientCertificate struct {
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	CertPEM  string `json:"cert_pem,omitempty"`
+	KeyPEM   string `json:"key_pem,omitempty"`
+}
+
+// Load reads and parses the certificate and key
+func (cc *ClientCertificate) Load() (tls.Certificate, error) {
+	if cc.CertPEM != "" || cc.KeyPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(cc.CertPEM), []byte(cc.KeyPEM))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		return cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cc.CertFile, cc.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// ConfigError reports a website configuration problem that prevented a check
+// from running, as opposed to the website itself being down
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("configuration error: %v", e.Err)
+}
+
+// ValidateProxyURL checks a website's proxy URL; empty means no proxy
+func ValidateProxyURL(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy_url: %v", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy_url must start with http://, https:// or socks5://")
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("proxy_url must include a host")
+	}
+	return nil
+}
+
+// siteClient is an HTTP client built for one website's specific settings
+type siteClient struct {
+	key    string
+	client *http.Client
+}
+
+// newDialer creates a dialer, resolving names with dnsServer when it is set
+func newDialer(dnsServer string) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if dnsServer != "" {
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+
+	return dialer
+}
+
+// newClient creates an HTTP client with the engine's default transport settings
+func newClient(dialer *net.Dialer, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		CheckRedirect: checkRedirect,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     tlsConfig,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// newInternalClient creates the HTTP client used for internal websites. It never
+// goes through a proxy and, when dnsServer is set, resolves names with that server.
+func newInternalClient(dnsServer string) *http.Client {
+	return newClient(newDialer(dnsServer), &tls.Config{
+		InsecureSkipVerify: false,
+	})
+}
+
+// SetInternalResolver sets the DNS server (host:port) used to resolve internal
+// websites. An empty value uses the system resolver.
+func (me *MonitorEngine) SetInternalResolver(dnsServer string) {
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+	}
+
+	me.mutex.Lock()
+	me.internalClient = newInternalClient(dnsServer)
+	me.internalDNSServer = dnsServer
+	me.mutex.Unlock()
+
+	// Per-site clients embed the resolver, rebuild them on next use
+	me.clientsMutex.Lock()
+	me.siteClients = make(map[string]*siteClient)
+	me.clientsMutex.Unlock()
+}
+
+// clientKey identifies the settings a website needs from its HTTP client.
+// An empty key means the website can use one of the shared clients.
+func clientKey(website *Website) string {
+	ipVersion := forcedIPVersion(website)
+	if website.ClientCert == nil && website.ProxyURL == "" && !website.InsecureSkipTLSVerify && ipVersion == "" {
+		return ""
+	}
+
+	key := fmt.Sprintf("internal=%t|proxy=%s|insecure=%t|ip=%s", website.Internal, website.ProxyURL, website.InsecureSkipTLSVerify, ipVersion)
+	if cert := website.ClientCert; cert != nil {
+		pemHash := sha256.Sum256([]byte(cert.CertPEM + "\x00" + cert.KeyPEM))
+		key += fmt.Sprintf("|cert=%s|key=%s|pem=%x", cert.CertFile, cert.KeyFile, pemHash)
+	}
+	return key
+}
+
+// clientFor returns the HTTP client to use when checking a website. Websites
+// with their own TLS, proxy or IP version settings, including skipped
+// certificate verification, get a dedicated client, cached until they change.
+func (me *MonitorEngine) clientFor(website *Website) (*http.Client, error) {
+	key := clientKey(website)
+	if key == "" {
+		me.mutex.RLock()
+		defer me.mutex.RUnlock()
+		if website.Internal {
+			return me.internalClient, nil
+		}
+		return me.httpClient, nil
+	}
+
+	me.clientsMutex.Lock()
+	defer me.clientsMutex.Unlock()
+
+	cached, exists := me.siteClients[website.ID]
+	if exists && cached.key == key {
+		return cached.client, nil
+	}
+
+	client, err := me.buildClient(website)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		cached.client.CloseIdleConnections()
+	}
+	me.siteClients[website.ID] = &siteClient{key: key, client: client}
+	return client, nil
+}
+
+// buildClient creates a dedicated HTTP client for a website
+func (me *MonitorEngine) buildClient(website *Website) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: website.InsecureSkipTLSVerify,
+	}
+
+	if website.ClientCert != nil {
+		cert, err := website.ClientCert.Load()
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	dnsServer := ""
+	if website.Internal {
+		me.mutex.RLock()
+		dnsServer = me.internalDNSServer
+		me.mutex.RUnlock()
+	}
+
+	dialer := newDialer(dnsServer)
+	client := newClient(dialer, tlsConfig)
+
+	// Connect over the forced IP version only
+	if ipVersion := forcedIPVersion(website); ipVersion != "" {
+		client.Transport.(*http.Transport).DialContext = dialIPVersion(dialer, ipVersion)
+	}
+
+	// The transport speaks HTTP CONNECT to http(s) proxies and SOCKS5 to socks5 ones
+	if website.ProxyURL != "" {
+		if err := ValidateProxyURL(website.ProxyURL); err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		proxyURL, _ := url.Parse(website.ProxyURL)
+		client.Transport.(*http.Transport).Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return client, nil
+}
+
+// dropClient discards the dedicated client of a removed website
+func (me *MonitorEngine) dropClient(id string) {
+	me.clientsMutex.Lock()
+	defer me.clientsMutex.Unlock()
+	if cached, exists := me.siteClients[id]; exists {
+		cached.client.CloseIdleConnections()
+		delete(me.siteClients, id)
+	}
+}
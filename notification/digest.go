@@ -0,0 +1,213 @@
+package notification
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// Digest schedules
+const (
+	DigestOff    = ""
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfig holds the schedule and recipients of the uptime digest email
+type DigestConfig struct {
+	// Schedule is DigestDaily, DigestWeekly or DigestOff
+	Schedule string
+	// Hour and Minute are the time of day the digest is sent at, in Location
+	Hour   int
+	Minute int
+	// Weekday is the day weekly digests are sent on
+	Weekday  time.Weekday
+	Location *time.Location
+
+	Recipients []string
+	// Namespace is the namespace the digest covers, named in its subject.
+	// Empty for the default namespace.
+	Namespace string
+}
+
+// Enabled reports whether digests are scheduled and have recipients
+func (c DigestConfig) Enabled() bool {
+	return c.Schedule != DigestOff && len(c.Recipients) > 0
+}
+
+// Period returns the time span a digest covers
+func (c DigestConfig) Period() time.Duration {
+	if c.Schedule == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// location returns the time zone of the schedule, local time by default
+func (c DigestConfig) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// Next returns the first time after now the digest is due
+func (c DigestConfig) Next(now time.Time) time.Time {
+	location := c.location()
+	now = now.In(location)
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), c.Hour, c.Minute, 0, 0, location)
+	if c.Schedule == DigestWeekly {
+		next = next.AddDate(0, 0, (int(c.Weekday)-int(next.Weekday())+7)%7)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// DigestEntry summarizes one website over a digest period
+type DigestEntry struct {
+	Name              string
+	URL               string
+	Status            string
+	UptimePercent     float64
+	Outages           int
+	DowntimeSeconds   int64
+	AvgResponseTimeMs float64
+}
+
+// DigestSource compiles the digest entries for the period ending now
+type DigestSource func(period time.Duration) ([]DigestEntry, error)
+
+// DigestScheduler emails an uptime summary of every website on a daily or
+// weekly schedule, through the notification manager's SMTP connection
+type DigestScheduler struct {
+	config   DigestConfig
+	source   DigestSource
+	manager  *NotificationManager
+	stopChan chan struct{}
+	stopped  chan struct{}
+}
+
+// NewDigestScheduler creates a digest scheduler; Start begins sending
+func NewDigestScheduler(config DigestConfig, source DigestSource, manager *NotificationManager) *DigestScheduler {
+	return &DigestScheduler{
+		config:   config,
+		source:   source,
+		manager:  manager,
+		stopChan: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start sends digests in the background until Stop is called. It does
+// nothing when digests are disabled.
+func (d *DigestScheduler) Start() {
+	if !d.config.Enabled() {
+		close(d.stopped)
+		return
+	}
+	logger.Infof("Sending %s digests to %v, next at %s", d.config.Schedule, d.config.Recipients, d.config.Next(time.Now()).Format(time.RFC1123))
+	go d.run()
+}
+
+// Stop stops the scheduler, waiting for a digest being sent
+func (d *DigestScheduler) Stop() {
+	select {
+	case <-d.stopChan:
+	default:
+		close(d.stopChan)
+	}
+	<-d.stopped
+}
+
+// run waits for each scheduled time and sends the digest
+func (d *DigestScheduler) run() {
+	defer close(d.stopped)
+
+	for {
+		timer := time.NewTimer(time.Until(d.config.Next(time.Now())))
+		select {
+		case <-timer.C:
+			if err := d.Send(); err != nil {
+				logger.Errorf("Error sending %s digest: %v", d.config.Schedule, err)
+			}
+		case <-d.stopChan:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Send compiles the digest for the period ending now and emails it
+func (d *DigestScheduler) Send() error {
+	config := d.manager.Config()
+	if config.SMTPHost == "" {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	period := d.config.Period()
+	entries, err := d.source(period)
+	if err != nil {
+		return fmt.Errorf("failed to compile digest: %v", err)
+	}
+
+	end := time.Now().In(d.config.location())
+	subject := fmt.Sprintf("Uptime digest for %s", end.Format("2006-01-02"))
+	if d.config.Schedule == DigestWeekly {
+		subject = fmt.Sprintf("Weekly uptime digest for %s to %s", end.Add(-period).Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	if d.config.Namespace != "" {
+		subject = fmt.Sprintf("[%s] %s", d.config.Namespace, subject)
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		config.FromEmail,
+		strings.Join(d.config.Recipients, ","),
+		subject,
+		formatDigest(entries, end.Add(-period), end))
+	if err := d.manager.smtpSender.Send(config, d.config.Recipients, []byte(message)); err != nil {
+		return err
+	}
+	logger.Infof("Digest email sent to %v", d.config.Recipients)
+	return nil
+}
+
+// formatDigest renders the digest entries as a plain text report, listing the
+// websites with the lowest uptime first
+func formatDigest(entries []DigestEntry, start, end time.Time) string {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].UptimePercent < entries[j].UptimePercent
+	})
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Uptime summary from %s to %s\n\n", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04 MST"))
+	if len(entries) == 0 {
+		text.WriteString("No websites are monitored.\n")
+	}
+
+	totalOutages := 0
+	for _, entry := range entries {
+		totalOutages += entry.Outages
+		fmt.Fprintf(&text, "%s (%s)\n", entry.Name, entry.URL)
+		fmt.Fprintf(&text, "  Uptime: %.2f%%\n", entry.UptimePercent)
+		fmt.Fprintf(&text, "  Outages: %d", entry.Outages)
+		if entry.DowntimeSeconds > 0 {
+			fmt.Fprintf(&text, " (%s down)", (time.Duration(entry.DowntimeSeconds) * time.Second).String())
+		}
+		text.WriteString("\n")
+		fmt.Fprintf(&text, "  Average response time: %.0fms\n", entry.AvgResponseTimeMs)
+		fmt.Fprintf(&text, "  Current status: %s\n\n", strings.ToUpper(entry.Status))
+	}
+
+	fmt.Fprintf(&text, "%d websites, %d outages in total.\n\n", len(entries), totalOutages)
+	text.WriteString("This is an automated digest from your uptime monitoring system.\n")
+	return text.String()
+}
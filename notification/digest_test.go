@@ -0,0 +1,44 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestWithoutAuthentication(t *testing.T) {
+	host, port, messages := startSMTPRelay(t)
+	nm := NewNotificationManager(NotificationConfig{SMTPHost: host, SMTPPort: port, SMTPEncryption: SMTPEncryptionNone, FromEmail: "monitor@example.com"})
+	defer nm.smtpSender.Close()
+
+	source := func(period time.Duration) ([]DigestEntry, error) {
+		return []DigestEntry{{Name: "Site", URL: "https://site.example.com", Status: "up", UptimePercent: 99.5}}, nil
+	}
+	digest := NewDigestScheduler(DigestConfig{Schedule: DigestDaily, Recipients: []string{"ops@example.com"}, Location: time.UTC}, source, nm)
+
+	if err := digest.Send(); err != nil {
+		t.Fatalf("sending the digest through a relay without authentication failed: %v", err)
+	}
+	message := waitForMessage(t, messages)
+	if !strings.Contains(message, "Subject: Uptime digest for") || !strings.Contains(message, "Uptime: 99.50%") {
+		t.Errorf("message isn't the digest:\n%s", message)
+	}
+}
+
+func TestDigestNamesNamespace(t *testing.T) {
+	host, port, messages := startSMTPRelay(t)
+	nm := NewNotificationManager(NotificationConfig{SMTPHost: host, SMTPPort: port, SMTPEncryption: SMTPEncryptionNone, FromEmail: "monitor@example.com"})
+	defer nm.smtpSender.Close()
+
+	source := func(period time.Duration) ([]DigestEntry, error) {
+		return []DigestEntry{{Name: "Site", URL: "https://site.example.com", Status: "up", UptimePercent: 99.5}}, nil
+	}
+	digest := NewDigestScheduler(DigestConfig{Schedule: DigestDaily, Recipients: []string{"ops@example.com"}, Location: time.UTC, Namespace: "staging"}, source, nm)
+
+	if err := digest.Send(); err != nil {
+		t.Fatalf("sending the digest failed: %v", err)
+	}
+	if message := waitForMessage(t, messages); !strings.Contains(message, "Subject: [staging] Uptime digest for") {
+		t.Errorf("subject doesn't name the namespace:\n%s", message)
+	}
+}
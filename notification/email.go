@@ -0,0 +1,258 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"uptime-monitor/logger"
+)
+
+// emailNotifier sends notifications by email over SMTP
+type emailNotifier struct {
+	config func() NotificationConfig
+	sender *smtpSender
+}
+
+// Name returns the channel name
+func (n *emailNotifier) Name() string {
+	return "email"
+}
+
+// Notify sends an email to the website's notification addresses
+func (n *emailNotifier) Notify(event StatusChangeEvent) error {
+	config := n.config()
+	if len(event.Emails) == 0 || config.SMTPHost == "" {
+		return ErrSkipped
+	}
+
+	subject := fmt.Sprintf("Website %s is %s", event.WebsiteName, strings.ToUpper(event.NewStatus))
+
+	var reason string
+	if event.Reason != "" {
+		reason = fmt.Sprintf("Reason: %s\n", event.Reason)
+	}
+
+	var body string
+	if event.EventType == EventTest {
+		subject = fmt.Sprintf("Test notification for %s", event.WebsiteName)
+		body = fmt.Sprintf(`This is a test notification for %s (%s).
+
+Current status: %s
+%s
+If you received this, email notifications are working.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			strings.ToUpper(event.NewStatus),
+			reason)
+	} else if event.EventType == EventCertExpiring {
+		subject = fmt.Sprintf("TLS certificate for %s expires soon", event.WebsiteName)
+		body = fmt.Sprintf(`The TLS certificate of %s (%s) expires soon.
+
+%s
+Please renew the certificate before it expires.
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			reason)
+	} else if event.EventType == EventEscalation {
+		subject = fmt.Sprintf("Website %s is still DOWN", event.WebsiteName)
+		body = fmt.Sprintf(`Website %s (%s) is still DOWN.
+
+%s
+Please check your website immediately.
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			reason)
+	} else if event.NewStatus == "up" {
+		body = fmt.Sprintf(`Website %s (%s) is now UP!
+
+Status changed from %s to %s at %s
+Response time: %dms
+%s
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			strings.ToUpper(event.OldStatus),
+			strings.ToUpper(event.NewStatus),
+			event.Timestamp.Format("2006-01-02 15:04:05"),
+			event.ResponseTime,
+			reason)
+	} else if event.NewStatus == "degraded" {
+		body = fmt.Sprintf(`Website %s (%s) is DEGRADED.
+
+Status changed from %s to %s at %s
+Response time: %dms
+%s
+The website is responding, but slower than its threshold.
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			strings.ToUpper(event.OldStatus),
+			strings.ToUpper(event.NewStatus),
+			event.Timestamp.Format("2006-01-02 15:04:05"),
+			event.ResponseTime,
+			reason)
+	} else if event.NewStatus == "flapping" {
+		body = fmt.Sprintf(`Website %s (%s) is FLAPPING.
+
+Status changed from %s to %s at %s
+%s
+The website keeps going up and down. Individual status changes are not
+notified until it is stable again.
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			strings.ToUpper(event.OldStatus),
+			strings.ToUpper(event.NewStatus),
+			event.Timestamp.Format("2006-01-02 15:04:05"),
+			reason)
+	} else {
+		body = fmt.Sprintf(`Website %s (%s) is DOWN!
+
+Status changed from %s to %s at %s
+%s
+Please check your website immediately.
+
+This is an automated notification from your uptime monitoring system.`,
+			event.WebsiteName,
+			event.WebsiteURL,
+			strings.ToUpper(event.OldStatus),
+			strings.ToUpper(event.NewStatus),
+			event.Timestamp.Format("2006-01-02 15:04:05"),
+			reason)
+	}
+
+	// Create email message
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		config.FromEmail,
+		strings.Join(event.Emails, ","),
+		subject,
+		body)
+	if config.HTMLEmail {
+		htmlMessage, err := buildHTMLMessage(config, event, subject, body)
+		if err != nil {
+			return err
+		}
+		message = htmlMessage
+	}
+
+	// Send email over the shared connection
+	if err := n.sender.Send(config, event.Emails, []byte(message)); err != nil {
+		return err
+	}
+	logger.Infof("Email notification sent for %s to %v", event.WebsiteID, event.Emails)
+	return nil
+}
+
+// emailTemplate renders the HTML part of notification emails
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f4f5f7;font-family:Arial,Helvetica,sans-serif;color:#1f2933;">
+  <table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:8px;">
+    <tr>
+      <td style="padding:24px;">
+        <span style="display:inline-block;padding:4px 12px;border-radius:12px;background:{{.BadgeColor}};color:#ffffff;font-weight:bold;font-size:12px;">{{.Badge}}</span>
+        <h2 style="margin:16px 0 4px;">{{.Title}}</h2>
+        <p style="margin:0 0 16px;"><a href="{{.Event.WebsiteURL}}" style="color:#3366cc;">{{.Event.WebsiteURL}}</a></p>
+        <table role="presentation" cellpadding="4" cellspacing="0" style="font-size:14px;">
+          {{if .ShowStatus}}<tr><td style="color:#6b7280;">Status</td><td>{{.OldStatus}} &rarr; {{.NewStatus}}</td></tr>{{end}}
+          <tr><td style="color:#6b7280;">Time</td><td>{{.Time}}</td></tr>
+          {{if .ResponseTime}}<tr><td style="color:#6b7280;">Response time</td><td>{{.ResponseTime}}ms</td></tr>{{end}}
+          {{if .Event.Reason}}<tr><td style="color:#6b7280;">Reason</td><td>{{.Event.Reason}}</td></tr>{{end}}
+        </table>
+        {{if .DashboardURL}}<p style="margin:24px 0 0;"><a href="{{.DashboardURL}}" style="display:inline-block;padding:10px 18px;background:#3366cc;color:#ffffff;text-decoration:none;border-radius:4px;">Open dashboard</a></p>{{end}}
+        <p style="margin:24px 0 0;font-size:12px;color:#9aa5b1;">This is an automated notification from your uptime monitoring system.</p>
+      </td>
+    </tr>
+  </table>
+</body>
+</html>
+`))
+
+// emailTemplateData is the data passed to emailTemplate
+type emailTemplateData struct {
+	Event        StatusChangeEvent
+	Title        string
+	Badge        string
+	BadgeColor   string
+	ShowStatus   bool
+	OldStatus    string
+	NewStatus    string
+	Time         string
+	ResponseTime int
+	DashboardURL string
+}
+
+// buildHTMLMessage builds a multipart/alternative email carrying the plain
+// text body alongside an HTML version, so text-only clients still work
+func buildHTMLMessage(config NotificationConfig, event StatusChangeEvent, subject, textBody string) (string, error) {
+	data := emailTemplateData{
+		Event:        event,
+		Title:        subject,
+		ShowStatus:   event.EventType == EventStatusChange,
+		OldStatus:    strings.ToUpper(event.OldStatus),
+		NewStatus:    strings.ToUpper(event.NewStatus),
+		Time:         event.Timestamp.Format("2006-01-02 15:04:05"),
+		DashboardURL: config.DashboardURL,
+	}
+	switch {
+	case event.EventType == EventTest:
+		data.Badge, data.BadgeColor = "TEST", "#3366cc"
+	case event.EventType == EventCertExpiring:
+		data.Badge, data.BadgeColor = "CERTIFICATE", "#d97706"
+	case event.EventType == EventEscalation:
+		data.Badge, data.BadgeColor = "STILL DOWN", "#dc2626"
+	case event.NewStatus == "up":
+		data.Badge, data.BadgeColor = "UP", "#16a34a"
+		data.ResponseTime = event.ResponseTime
+	case event.NewStatus == "degraded":
+		data.Badge, data.BadgeColor = "DEGRADED", "#d97706"
+		data.ResponseTime = event.ResponseTime
+	case event.NewStatus == "flapping":
+		data.Badge, data.BadgeColor = "FLAPPING", "#d97706"
+	default:
+		data.Badge, data.BadgeColor = "DOWN", "#dc2626"
+	}
+
+	var htmlBody bytes.Buffer
+	if err := emailTemplate.Execute(&htmlBody, data); err != nil {
+		return "", fmt.Errorf("failed to render HTML email: %v", err)
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", textBody},
+		{"text/html; charset=UTF-8", htmlBody.String()},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return "", err
+		}
+		if _, err := partWriter.Write([]byte(part.body)); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n%s",
+		config.FromEmail,
+		strings.Join(event.Emails, ","),
+		subject,
+		writer.Boundary(),
+		parts.String()), nil
+}
@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+)
+
+// escalationCheckInterval is how often websites that stay down are checked
+// for a due reminder
+const escalationCheckInterval = 10 * time.Second
+
+// escalation tracks a website that is down and has escalation enabled
+type escalation struct {
+	event     StatusChangeEvent // The latest down event, carrying the website's recipients
+	since     time.Time         // When the website went down
+	lastSent  time.Time         // When the last notification about the outage was sent
+	reminders int               // Reminders sent so far
+}
+
+// trackEscalation follows status changes of websites with escalation enabled.
+// It reports whether the event is the recovery of a website reminders were
+// sent for, which is always notified.
+func (nm *NotificationManager) trackEscalation(event StatusChangeEvent) bool {
+	if event.EventType != EventStatusChange {
+		return false
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	current, tracked := nm.escalations[event.WebsiteID]
+	if event.NewStatus == "down" {
+		if event.EscalationAfterSeconds > 0 && !tracked {
+			nm.escalations[event.WebsiteID] = &escalation{event: event, since: event.Timestamp, lastSent: event.Timestamp}
+		}
+		return false
+	}
+
+	delete(nm.escalations, event.WebsiteID)
+	return tracked && current.reminders > 0 && event.NewStatus == "up"
+}
+
+// UpdateEscalation is called with a down event for every check that finds a
+// website still down. It refreshes the recipients and escalation settings of
+// the tracked outage, and starts tracking outages that were already going on,
+// e.g. before a restart.
+func (nm *NotificationManager) UpdateEscalation(event StatusChangeEvent) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if event.EscalationAfterSeconds <= 0 {
+		delete(nm.escalations, event.WebsiteID)
+		return
+	}
+	if current, tracked := nm.escalations[event.WebsiteID]; tracked {
+		current.event = event
+		return
+	}
+	nm.escalations[event.WebsiteID] = &escalation{event: event, since: event.Timestamp, lastSent: event.Timestamp}
+}
+
+// StopEscalation stops the reminders for a website, e.g. because it was
+// deleted, disabled or entered a maintenance window
+func (nm *NotificationManager) StopEscalation(websiteID string) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	delete(nm.escalations, websiteID)
+}
+
+// sendEscalations sends a reminder for every website that has been down for
+// another EscalationAfterSeconds since it was last notified about
+func (nm *NotificationManager) sendEscalations(now time.Time) {
+	var reminders []StatusChangeEvent
+
+	nm.mutex.Lock()
+	for _, current := range nm.escalations {
+		after := time.Duration(current.event.EscalationAfterSeconds) * time.Second
+		if now.Sub(current.lastSent) < after {
+			continue
+		}
+		current.lastSent = now
+		current.reminders++
+
+		reminder := current.event
+		reminder.EventType = EventEscalation
+		reminder.OldStatus = "down"
+		reminder.NewStatus = "down"
+		reminder.Timestamp = now
+		reminder.Reason = fmt.Sprintf("Still down after %s (reminder %d)", now.Sub(current.since).Round(time.Second), current.reminders)
+		reminders = append(reminders, reminder)
+	}
+	nm.mutex.Unlock()
+
+	for _, reminder := range reminders {
+		if reminder.wanted() {
+			nm.handleStatusChange(reminder)
+		}
+	}
+}
@@ -1,14 +1,11 @@
 package notification
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"net/smtp"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"uptime-monitor/logger"
 )
 
 // NotificationConfig holds configuration for notifications
@@ -18,43 +15,113 @@ type NotificationConfig struct {
 	SMTPUsername string
 	SMTPPassword string
 	FromEmail    string
-}
 
-// SlackMessage represents a Slack webhook message
-type SlackMessage struct {
-	Text        string       `json:"text"`
-	Username    string       `json:"username,omitempty"`
-	IconEmoji   string       `json:"icon_emoji,omitempty"`
-	Attachments []Attachment `json:"attachments,omitempty"`
+	// SMTPReuseConnection keeps the SMTP connection open between notifications
+	SMTPReuseConnection    bool
+	SMTPIdleTimeoutSeconds int
+
+	// SMTPEncryption is SMTPEncryptionNone, SMTPEncryptionSTARTTLS or
+	// SMTPEncryptionTLS. Empty uses STARTTLS when the server offers it.
+	SMTPEncryption string
+	// SMTPSkipVerify disables certificate verification, for self-hosted servers
+	SMTPSkipVerify bool
+
+	// HTMLEmail sends multipart emails with an HTML version next to the plain text
+	HTMLEmail bool
+	// DashboardURL is linked from HTML emails when set
+	DashboardURL string
+
+	// TelegramBotToken enables Telegram notifications when set
+	TelegramBotToken string
+
+	// PagerDutyRoutingKey is the integration key of the PagerDuty service
+	// incidents are opened on; empty disables PagerDuty
+	PagerDutyRoutingKey string
+	// PagerDutyEventsURL overrides DefaultPagerDutyEventsURL, e.g. for the EU region
+	PagerDutyEventsURL string
+
+	// Throttle is the minimum time between notifications for the same website
+	// when the website doesn't set its own; zero uses DefaultThrottle
+	Throttle time.Duration
+
+	// QueueSize is the number of events waiting to be sent before new ones
+	// are dropped; zero uses DefaultQueueSize. It is read once at startup.
+	QueueSize int
+	// QueueTimeout is how long to wait for room in a full queue before
+	// dropping an event; zero drops it right away
+	QueueTimeout time.Duration
 }
 
-// Attachment represents a Slack message attachment
-type Attachment struct {
-	Color     string  `json:"color"`
-	Title     string  `json:"title"`
-	Text      string  `json:"text"`
-	Timestamp int64   `json:"ts"`
-	Fields    []Field `json:"fields,omitempty"`
-}
+// Event types carried by StatusChangeEvent
+const (
+	EventStatusChange = ""              // The website's status changed
+	EventCertExpiring = "cert_expiring" // The website's TLS certificate expires soon
+	EventTest         = "test"          // A test notification requested by the user
+	EventEscalation   = "escalation"    // A reminder that the website is still down
+)
 
-// Field represents a field in a Slack attachment
-type Field struct {
-	Title string `json:"title"`
-	Value string `json:"value"`
-	Short bool   `json:"short"`
-}
+// Status change directions a website can be notified about
+const (
+	NotifyOnBoth = "both"
+	NotifyOnDown = "down"
+	NotifyOnUp   = "up"
+)
+
+// DefaultThrottle is the minimum time between notifications for the same website
+const DefaultThrottle = 5 * time.Minute
+
+// DefaultQueueSize is the number of events that can wait to be sent
+const DefaultQueueSize = 100
 
 // StatusChangeEvent represents a website status change
 type StatusChangeEvent struct {
-	WebsiteID    string
-	WebsiteName  string
-	WebsiteURL   string
-	OldStatus    string
-	NewStatus    string
-	ResponseTime int
-	Timestamp    time.Time
-	Emails       []string
-	SlackWebhook string
+	WebsiteID       string
+	WebsiteName     string
+	WebsiteURL      string
+	OldStatus       string
+	NewStatus       string
+	ResponseTime    int
+	Timestamp       time.Time
+	Emails          []string
+	SlackWebhook    string
+	Webhook         string // Generic webhook URL
+	WebhookTemplate string // Optional text/template for the webhook body
+	TelegramChatID  string
+	TeamsWebhook    string // Microsoft Teams incoming webhook URL
+	PagerDuty       bool   // Open and resolve PagerDuty incidents for the website
+	Reason          string // Optional explanation, e.g. for rule-based alerts
+	EventType       string // EventStatusChange unless set
+	ThrottleSeconds *int   // Minimum seconds between notifications, nil uses the configured throttle
+	NotifyOn        string // NotifyOnBoth, NotifyOnDown or NotifyOnUp; empty means both
+
+	// EscalationAfterSeconds repeats the down notification at this interval
+	// while the website stays down, 0 disables reminders
+	EscalationAfterSeconds int
+
+	// stateOnly limits delivery to state tracking channels, for status
+	// changes filtered out by throttling or NotifyOn
+	stateOnly bool
+	// throttleKey is the throttle window the event reserved when it was
+	// queued, at throttleReserved, replacing the window started at
+	// throttlePrevious (zero if none). The reservation is rolled back unless
+	// a channel sends the event.
+	throttleKey      string
+	throttleReserved time.Time
+	throttlePrevious time.Time
+}
+
+// stateTracker is implemented by channels that mirror a website's state,
+// such as a PagerDuty incident that must be resolved on recovery. They get
+// every status change, regardless of throttling and NotifyOn, one website's
+// events in the order they were handled.
+type stateTracker interface {
+	tracksState() bool
+}
+
+// tracksState reports whether notifier is a state tracking channel
+func tracksState(notifier Notifier) bool {
+	tracker, ok := notifier.(stateTracker)
+	return ok && tracker.tracksState()
 }
 
 // NotificationManager manages sending notifications
@@ -62,22 +129,75 @@ type NotificationManager struct {
 	config       NotificationConfig
 	eventQueue   chan StatusChangeEvent
 	stopChan     chan bool
+	stopped      chan struct{}  // Closed when processEvents returns
+	sending      sync.WaitGroup // Notifications being delivered
 	running      bool
 	mutex        sync.RWMutex
-	httpClient   *http.Client
+	smtpSender   *smtpSender
+	notifiers    []Notifier
+	onAttempt    func(attempt Attempt)
 	lastNotified map[string]time.Time // Track last notification time per website to prevent spam
+	escalations  map[string]*escalation
+	dropped      uint64 // Events dropped because the queue was full, accessed atomically
+
+	// Last delivery per state tracking channel and website, closed once it
+	// returns, so the next one waits for it
+	deliveries    map[string]chan struct{}
+	deliveryMutex sync.Mutex
+}
+
+// QueueStats describes the notification queue
+type QueueStats struct {
+	Length   int    // Events waiting to be sent
+	Capacity int    // Events that can wait before new ones are dropped
+	Dropped  uint64 // Events dropped since startup because the queue was full
 }
 
 // NewNotificationManager creates a new notification manager
 func NewNotificationManager(config NotificationConfig) *NotificationManager {
-	return &NotificationManager{
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	nm := &NotificationManager{
 		config:       config,
-		eventQueue:   make(chan StatusChangeEvent, 100),
+		eventQueue:   make(chan StatusChangeEvent, queueSize),
 		stopChan:     make(chan bool),
+		stopped:      make(chan struct{}),
 		running:      false,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		smtpSender:   &smtpSender{},
 		lastNotified: make(map[string]time.Time),
+		escalations:  make(map[string]*escalation),
+		deliveries:   make(map[string]chan struct{}),
 	}
+
+	// Built-in channels
+	nm.RegisterNotifier(&emailNotifier{config: nm.Config, sender: nm.smtpSender})
+	nm.RegisterNotifier(&slackNotifier{httpClient: &http.Client{Timeout: 30 * time.Second}})
+	nm.RegisterNotifier(&webhookNotifier{httpClient: &http.Client{Timeout: 30 * time.Second}})
+	nm.RegisterNotifier(&teamsNotifier{httpClient: &http.Client{Timeout: 30 * time.Second}})
+	nm.RegisterNotifier(&telegramNotifier{config: nm.Config, httpClient: &http.Client{Timeout: 30 * time.Second}})
+	nm.RegisterNotifier(&pagerDutyNotifier{config: nm.Config, httpClient: &http.Client{Timeout: 30 * time.Second}})
+
+	return nm
+}
+
+// RegisterNotifier adds a notification channel. Every event is passed to all
+// registered notifiers, e.g. channels added at startup.
+func (nm *NotificationManager) RegisterNotifier(notifier Notifier) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	nm.notifiers = append(nm.notifiers, notifier)
+}
+
+// SetAttemptHandler registers a function called after every notification
+// attempt, e.g. to persist a delivery log. It is called from the sending
+// goroutines, so it must be safe for concurrent use.
+func (nm *NotificationManager) SetAttemptHandler(handler func(attempt Attempt)) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	nm.onAttempt = handler
 }
 
 // Start begins processing notification events
@@ -93,7 +213,8 @@ func (nm *NotificationManager) Start() {
 	go nm.processEvents()
 }
 
-// Stop stops processing notification events
+// Stop stops processing notification events. Events already queued are
+// still sent, and Stop returns once every delivery has finished.
 func (nm *NotificationManager) Stop() {
 	nm.mutex.Lock()
 	if !nm.running {
@@ -104,177 +225,291 @@ func (nm *NotificationManager) Stop() {
 	nm.mutex.Unlock()
 
 	close(nm.stopChan)
+	<-nm.stopped
+	nm.sending.Wait()
+	nm.smtpSender.Close()
 }
 
-// SendStatusChange queues a status change notification
+// SendStatusChange queues a status change notification. Status changes that
+// are throttled or filtered out by NotifyOn still reach state tracking channels.
+// The recovery of a website that reminders were sent for is never throttled.
 func (nm *NotificationManager) SendStatusChange(event StatusChangeEvent) {
-	// Check if we should throttle notifications for this website
-	nm.mutex.RLock()
-	lastNotified, exists := nm.lastNotified[event.WebsiteID]
-	nm.mutex.RUnlock()
+	escalated := nm.trackEscalation(event)
 
-	// Don't send notifications more than once every 5 minutes for the same website
-	if exists && time.Since(lastNotified) < 5*time.Minute {
+	// Skip directions the website isn't interested in, without touching the throttle
+	if !event.wanted() {
+		nm.sendStateOnly(event)
 		return
 	}
 
-	select {
-	case nm.eventQueue <- event:
-		nm.mutex.Lock()
-		nm.lastNotified[event.WebsiteID] = time.Now()
+	// Check if we should throttle notifications for this website
+	// Different event types are throttled independently
+	throttleKey := event.WebsiteID + "|" + event.EventType
+
+	// Check and reserve the window at once, so a burst of events queued
+	// before the first is sent can't all pass
+	nm.mutex.Lock()
+	lastNotified, exists := nm.lastNotified[throttleKey]
+	throttle := nm.config.Throttle
+
+	// Don't send notifications more than once per throttle window for the same website
+	if throttle <= 0 {
+		throttle = DefaultThrottle
+	}
+	if event.ThrottleSeconds != nil {
+		throttle = time.Duration(*event.ThrottleSeconds) * time.Second
+	}
+	if exists && time.Since(lastNotified) < throttle && !escalated {
 		nm.mutex.Unlock()
-	default:
-		fmt.Printf("Warning: notification queue is full, dropping event for %s\n", event.WebsiteID)
+		nm.sendStateOnly(event)
+		return
 	}
-}
 
-// processEvents processes notification events from the queue
-func (nm *NotificationManager) processEvents() {
-	for {
-		select {
-		case event := <-nm.eventQueue:
-			nm.handleStatusChange(event)
-		case <-nm.stopChan:
-			return
-		}
+	event.throttleKey = throttleKey
+	event.throttleReserved = time.Now()
+	event.throttlePrevious = lastNotified
+	nm.lastNotified[throttleKey] = event.throttleReserved
+	nm.mutex.Unlock()
+
+	if !nm.enqueue(event) {
+		nm.releaseThrottle(event)
 	}
 }
 
-// handleStatusChange handles a single status change event
-func (nm *NotificationManager) handleStatusChange(event StatusChangeEvent) {
-	// Send email notifications
-	if len(event.Emails) > 0 && nm.config.SMTPHost != "" {
-		go nm.sendEmailNotification(event)
+// releaseThrottle rolls back the throttle window an event reserved, when no
+// channel sent it. A window reserved by a later event is kept.
+func (nm *NotificationManager) releaseThrottle(event StatusChangeEvent) {
+	if event.throttleKey == "" {
+		return
 	}
 
-	// Send Slack notification
-	if event.SlackWebhook != "" {
-		go nm.sendSlackNotification(event)
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	if !nm.lastNotified[event.throttleKey].Equal(event.throttleReserved) {
+		return
+	}
+	if event.throttlePrevious.IsZero() {
+		delete(nm.lastNotified, event.throttleKey)
+	} else {
+		nm.lastNotified[event.throttleKey] = event.throttlePrevious
 	}
 }
 
-// sendEmailNotification sends an email notification
-func (nm *NotificationManager) sendEmailNotification(event StatusChangeEvent) {
-	if nm.config.SMTPHost == "" || nm.config.SMTPUsername == "" {
-		fmt.Printf("Warning: SMTP not configured, skipping email notification for %s\n", event.WebsiteID)
+// sendStateOnly queues a filtered out status change for the state tracking
+// channels only. Websites without PagerDuty have no such channel, so their
+// events are dropped.
+func (nm *NotificationManager) sendStateOnly(event StatusChangeEvent) {
+	if event.EventType != EventStatusChange || !event.PagerDuty {
 		return
 	}
 
-	subject := fmt.Sprintf("Website %s is %s", event.WebsiteName, strings.ToUpper(event.NewStatus))
-	
-	var body string
-	if event.NewStatus == "up" {
-		body = fmt.Sprintf(`Website %s (%s) is now UP!
-
-Status changed from %s to %s at %s
-Response time: %dms
-
-This is an automated notification from your uptime monitoring system.`,
-			event.WebsiteName,
-			event.WebsiteURL,
-			strings.ToUpper(event.OldStatus),
-			strings.ToUpper(event.NewStatus),
-			event.Timestamp.Format("2006-01-02 15:04:05"),
-			event.ResponseTime)
-	} else {
-		body = fmt.Sprintf(`Website %s (%s) is DOWN!
-
-Status changed from %s to %s at %s
+	event.stateOnly = true
+	event.throttleKey = ""
+	nm.enqueue(event)
+}
 
-Please check your website immediately.
+// enqueue adds an event to the queue, waiting up to QueueTimeout for room
+// when it is full. It reports whether the event was queued; dropped events
+// are counted.
+func (nm *NotificationManager) enqueue(event StatusChangeEvent) bool {
+	select {
+	case nm.eventQueue <- event:
+		return true
+	default:
+	}
 
-This is an automated notification from your uptime monitoring system.`,
-			event.WebsiteName,
-			event.WebsiteURL,
-			strings.ToUpper(event.OldStatus),
-			strings.ToUpper(event.NewStatus),
-			event.Timestamp.Format("2006-01-02 15:04:05"))
+	if timeout := nm.Config().QueueTimeout; timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case nm.eventQueue <- event:
+			return true
+		case <-timer.C:
+		}
 	}
 
-	// Create email message
-	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
-		nm.config.FromEmail,
-		strings.Join(event.Emails, ","),
-		subject,
-		body)
-
-	// Send email
-	auth := smtp.PlainAuth("", nm.config.SMTPUsername, nm.config.SMTPPassword, nm.config.SMTPHost)
-	addr := fmt.Sprintf("%s:%s", nm.config.SMTPHost, nm.config.SMTPPort)
-	
-	err := smtp.SendMail(addr, auth, nm.config.FromEmail, event.Emails, []byte(message))
-	if err != nil {
-		fmt.Printf("Error sending email notification for %s: %v\n", event.WebsiteID, err)
-	} else {
-		fmt.Printf("Email notification sent for %s to %v\n", event.WebsiteID, event.Emails)
+	dropped := atomic.AddUint64(&nm.dropped, 1)
+	logger.Warnf("Notification queue is full, dropping event for %s (%d dropped so far)", event.WebsiteID, dropped)
+	return false
+}
+
+// QueueStats returns the current state of the notification queue
+func (nm *NotificationManager) QueueStats() QueueStats {
+	return QueueStats{
+		Length:   len(nm.eventQueue),
+		Capacity: cap(nm.eventQueue),
+		Dropped:  atomic.LoadUint64(&nm.dropped),
 	}
 }
 
-// sendSlackNotification sends a Slack webhook notification
-func (nm *NotificationManager) sendSlackNotification(event StatusChangeEvent) {
-	var color string
-	var emoji string
-	var title string
+// SendTest sends an event through every channel immediately, bypassing the
+// queue, throttling and NotifyOn filtering, and waits for the results.
+// Channels the event has no recipients for are left out.
+func (nm *NotificationManager) SendTest(event StatusChangeEvent) []Attempt {
+	nm.mutex.RLock()
+	notifiers := nm.notifiers
+	onAttempt := nm.onAttempt
+	nm.mutex.RUnlock()
 
-	if event.NewStatus == "up" {
-		color = "good"
-		emoji = ":white_check_mark:"
-		title = fmt.Sprintf("%s Website %s is UP", emoji, event.WebsiteName)
-	} else {
-		color = "danger"
-		emoji = ":x:"
-		title = fmt.Sprintf("%s Website %s is DOWN", emoji, event.WebsiteName)
+	results := make([]*Attempt, len(notifiers))
+	var wg sync.WaitGroup
+	for i, notifier := range notifiers {
+		wg.Add(1)
+		go func(i int, notifier Notifier) {
+			defer wg.Done()
+			err := notifier.Notify(event)
+			if err == ErrSkipped {
+				return
+			}
+			results[i] = &Attempt{Event: event, Channel: notifier.Name(), Timestamp: time.Now(), Err: err}
+		}(i, notifier)
 	}
+	wg.Wait()
 
-	fields := []Field{
-		{Title: "Website", Value: event.WebsiteName, Short: true},
-		{Title: "URL", Value: event.WebsiteURL, Short: true},
-		{Title: "Status Change", Value: fmt.Sprintf("%s → %s", strings.ToUpper(event.OldStatus), strings.ToUpper(event.NewStatus)), Short: true},
-		{Title: "Time", Value: event.Timestamp.Format("2006-01-02 15:04:05"), Short: true},
+	attempts := []Attempt{}
+	for _, attempt := range results {
+		if attempt == nil {
+			continue
+		}
+		attempts = append(attempts, *attempt)
+		if onAttempt != nil {
+			onAttempt(*attempt)
+		}
 	}
+	return attempts
+}
 
-	if event.NewStatus == "up" && event.ResponseTime > 0 {
-		fields = append(fields, Field{Title: "Response Time", Value: fmt.Sprintf("%dms", event.ResponseTime), Short: true})
+// wanted reports whether the event matches the website's NotifyOn setting.
+// Events other than status changes and reminders are always wanted.
+func (event StatusChangeEvent) wanted() bool {
+	if event.EventType != EventStatusChange && event.EventType != EventEscalation {
+		return true
 	}
-
-	attachment := Attachment{
-		Color:     color,
-		Title:     title,
-		Timestamp: event.Timestamp.Unix(),
-		Fields:    fields,
+	switch event.NotifyOn {
+	case NotifyOnDown:
+		return event.NewStatus != "up"
+	case NotifyOnUp:
+		return event.NewStatus == "up"
 	}
+	return true
+}
+
+// processEvents processes notification events from the queue
+func (nm *NotificationManager) processEvents() {
+	defer close(nm.stopped)
+
+	ticker := time.NewTicker(escalationCheckInterval)
+	defer ticker.Stop()
 
-	message := SlackMessage{
-		Username:    "Uptime Monitor",
-		IconEmoji:   ":computer:",
-		Attachments: []Attachment{attachment},
+	for {
+		select {
+		case event := <-nm.eventQueue:
+			nm.handleStatusChange(event)
+		case now := <-ticker.C:
+			nm.sendEscalations(now)
+		case <-nm.stopChan:
+			// Send what was queued before stopping
+			for {
+				select {
+				case event := <-nm.eventQueue:
+					nm.handleStatusChange(event)
+				default:
+					return
+				}
+			}
+		}
 	}
+}
+
+// handleStatusChange passes a single status change event to every notifier.
+// The throttle window the event reserved is released if none of them sends it.
+func (nm *NotificationManager) handleStatusChange(event StatusChangeEvent) {
+	nm.mutex.RLock()
+	notifiers := nm.notifiers
+	onAttempt := nm.onAttempt
+	nm.mutex.RUnlock()
 
-	// Send to Slack
-	jsonData, err := json.Marshal(message)
-	if err != nil {
-		fmt.Printf("Error marshaling Slack message for %s: %v\n", event.WebsiteID, err)
+	var targets []Notifier
+	for _, notifier := range notifiers {
+		if !event.stateOnly || tracksState(notifier) {
+			targets = append(targets, notifier)
+		}
+	}
+	if len(targets) == 0 {
+		nm.releaseThrottle(event)
 		return
 	}
 
-	resp, err := nm.httpClient.Post(event.SlackWebhook, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("Error sending Slack notification for %s: %v\n", event.WebsiteID, err)
-		return
+	// The last channel to finish releases the window unless one delivered
+	remaining := int32(len(targets))
+	var delivered int32
+	for _, notifier := range targets {
+		var previous, done chan struct{}
+		key := notifier.Name() + "/" + event.WebsiteID
+		if tracksState(notifier) {
+			previous, done = nm.queueDelivery(key)
+		}
+		nm.sending.Add(1)
+		go func(notifier Notifier) {
+			defer nm.sending.Done()
+			if done != nil {
+				defer nm.finishDelivery(key, done)
+			}
+			if previous != nil {
+				<-previous
+			}
+			err := notifier.Notify(event)
+			if err == nil {
+				atomic.StoreInt32(&delivered, 1)
+			} else if err != ErrSkipped {
+				logger.Errorf("Error sending %s notification for %s: %v", notifier.Name(), event.WebsiteID, err)
+			}
+			if atomic.AddInt32(&remaining, -1) == 0 && atomic.LoadInt32(&delivered) == 0 {
+				nm.releaseThrottle(event)
+			}
+			if err != ErrSkipped && onAttempt != nil {
+				onAttempt(Attempt{Event: event, Channel: notifier.Name(), Timestamp: time.Now(), Err: err})
+			}
+		}(notifier)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Slack webhook returned status %d for %s\n", resp.StatusCode, event.WebsiteID)
-	} else {
-		fmt.Printf("Slack notification sent for %s\n", event.WebsiteID)
+// queueDelivery puts a delivery of a state tracking channel after the one
+// queued before it for the same key, so that e.g. a website's resolve can't
+// overtake its trigger. It returns the previous delivery to wait for, nil if
+// none is running, and the delivery's own done channel.
+func (nm *NotificationManager) queueDelivery(key string) (previous, done chan struct{}) {
+	nm.deliveryMutex.Lock()
+	defer nm.deliveryMutex.Unlock()
+	previous = nm.deliveries[key]
+	done = make(chan struct{})
+	nm.deliveries[key] = done
+	return previous, done
+}
+
+// finishDelivery releases the delivery queued after the one that is done
+func (nm *NotificationManager) finishDelivery(key string, done chan struct{}) {
+	close(done)
+	nm.deliveryMutex.Lock()
+	defer nm.deliveryMutex.Unlock()
+	if nm.deliveries[key] == done {
+		delete(nm.deliveries, key)
 	}
 }
 
+// Config returns a copy of the current notification configuration
+func (nm *NotificationManager) Config() NotificationConfig {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+	return nm.config
+}
+
 // UpdateConfig updates the notification configuration
 func (nm *NotificationManager) UpdateConfig(config NotificationConfig) {
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 	nm.config = config
-}
 
+	// Reconnect with the new settings on the next email
+	nm.smtpSender.Close()
+}
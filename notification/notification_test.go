@@ -0,0 +1,350 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records the events it is given and fails while failing is set
+type fakeNotifier struct {
+	mutex   sync.Mutex
+	events  []StatusChangeEvent
+	failing bool
+}
+
+func (n *fakeNotifier) Name() string { return "fake" }
+
+func (n *fakeNotifier) Notify(event StatusChangeEvent) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.events = append(n.events, event)
+	if n.failing {
+		return errors.New("delivery failed")
+	}
+	return nil
+}
+
+func (n *fakeNotifier) setFailing(failing bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.failing = failing
+}
+
+func (n *fakeNotifier) count() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.events)
+}
+
+// newTestManager returns a manager that isn't started, sending through
+// notifier only. The built-in channels skip events without recipients.
+func newTestManager(notifier Notifier) *NotificationManager {
+	nm := NewNotificationManager(NotificationConfig{})
+	nm.RegisterNotifier(notifier)
+	return nm
+}
+
+// deliverQueued sends every queued event the way the started manager would
+// and waits for the deliveries
+func deliverQueued(nm *NotificationManager) {
+	for {
+		select {
+		case event := <-nm.eventQueue:
+			nm.handleStatusChange(event)
+		default:
+			nm.sending.Wait()
+			return
+		}
+	}
+}
+
+// statusChange returns a status change event of website "site"
+func statusChange(oldStatus, newStatus string) StatusChangeEvent {
+	return StatusChangeEvent{
+		WebsiteID:   "site",
+		WebsiteName: "Site",
+		WebsiteURL:  "https://site.example.com",
+		OldStatus:   oldStatus,
+		NewStatus:   newStatus,
+		Timestamp:   time.Now(),
+	}
+}
+
+func TestFailedDeliveryDoesNotThrottle(t *testing.T) {
+	notifier := &fakeNotifier{failing: true}
+	nm := newTestManager(notifier)
+
+	nm.SendStatusChange(statusChange("up", "down"))
+	deliverQueued(nm)
+	if notifier.count() != 1 {
+		t.Fatalf("%d events sent, want 1", notifier.count())
+	}
+
+	// Nothing was delivered, so the next status change isn't throttled
+	notifier.setFailing(false)
+	nm.SendStatusChange(statusChange("down", "up"))
+	deliverQueued(nm)
+	if notifier.count() != 2 {
+		t.Fatalf("%d events sent, want the one after the failure too", notifier.count())
+	}
+
+	// Delivered, so the next one within the window is throttled
+	nm.SendStatusChange(statusChange("up", "down"))
+	deliverQueued(nm)
+	if notifier.count() != 2 {
+		t.Errorf("%d events sent, want the one after a delivery throttled", notifier.count())
+	}
+}
+
+func TestBurstIsThrottledBeforeDelivery(t *testing.T) {
+	notifier := &fakeNotifier{}
+	nm := newTestManager(notifier)
+
+	// Queued before the first one is sent, so only the first passes
+	nm.SendStatusChange(statusChange("up", "down"))
+	nm.SendStatusChange(statusChange("down", "up"))
+	nm.SendStatusChange(statusChange("up", "down"))
+	deliverQueued(nm)
+	if notifier.count() != 1 {
+		t.Errorf("%d events sent, want 1", notifier.count())
+	}
+}
+
+func TestWanted(t *testing.T) {
+	tests := []struct {
+		eventType string
+		notifyOn  string
+		newStatus string
+		want      bool
+	}{
+		{EventStatusChange, "", "down", true},
+		{EventStatusChange, "", "up", true},
+		{EventStatusChange, NotifyOnBoth, "up", true},
+		{EventStatusChange, NotifyOnDown, "down", true},
+		{EventStatusChange, NotifyOnDown, "degraded", true},
+		{EventStatusChange, NotifyOnDown, "up", false},
+		{EventStatusChange, NotifyOnUp, "up", true},
+		{EventStatusChange, NotifyOnUp, "down", false},
+		{EventEscalation, NotifyOnUp, "down", false},
+		{EventEscalation, NotifyOnDown, "down", true},
+		{EventCertExpiring, NotifyOnUp, "down", true},
+		{EventTest, NotifyOnDown, "up", true},
+	}
+
+	for _, test := range tests {
+		event := statusChange("", test.newStatus)
+		event.EventType = test.eventType
+		event.NotifyOn = test.notifyOn
+		if got := event.wanted(); got != test.want {
+			t.Errorf("%q event to %s with NotifyOn %q: wanted = %v, want %v", test.eventType, test.newStatus, test.notifyOn, got, test.want)
+		}
+	}
+}
+
+// queued removes the queued events without sending them
+func queued(nm *NotificationManager) []StatusChangeEvent {
+	var events []StatusChangeEvent
+	for {
+		select {
+		case event := <-nm.eventQueue:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSendStatusChangeThrottling(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name          string
+		event         StatusChangeEvent
+		notifiedAgo   time.Duration // Since the last notification, zero for never
+		reminders     int           // Reminders sent for the tracked outage, -1 for none tracked
+		wantQueued    bool
+		wantStateOnly bool
+	}{
+		{name: "first", event: statusChange("up", "down"), reminders: -1, wantQueued: true},
+		{name: "throttled", event: statusChange("down", "up"), notifiedAgo: time.Minute, reminders: -1},
+		{name: "throttled with PagerDuty", event: withPagerDuty(statusChange("down", "up")), notifiedAgo: time.Minute, reminders: -1, wantQueued: true, wantStateOnly: true},
+		{name: "window passed", event: statusChange("down", "up"), notifiedAgo: 10 * time.Minute, reminders: -1, wantQueued: true},
+		{name: "website without throttle", event: withThrottle(statusChange("down", "up"), &zero), notifiedAgo: time.Minute, reminders: -1, wantQueued: true},
+		{name: "escalated recovery", event: statusChange("down", "up"), notifiedAgo: time.Minute, reminders: 1, wantQueued: true},
+		{name: "recovery before any reminder", event: statusChange("down", "up"), notifiedAgo: time.Minute, reminders: 0},
+		{name: "filtered out by NotifyOn", event: withNotifyOn(statusChange("down", "up"), NotifyOnDown), reminders: -1},
+		{name: "filtered out with PagerDuty", event: withPagerDuty(withNotifyOn(statusChange("down", "up"), NotifyOnDown)), reminders: -1, wantQueued: true, wantStateOnly: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nm := newTestManager(&fakeNotifier{})
+			if test.notifiedAgo > 0 {
+				nm.lastNotified["site|"+EventStatusChange] = time.Now().Add(-test.notifiedAgo)
+			}
+			if test.reminders >= 0 {
+				nm.escalations["site"] = &escalation{since: time.Now(), lastSent: time.Now(), reminders: test.reminders}
+			}
+
+			nm.SendStatusChange(test.event)
+			events := queued(nm)
+			if len(events) > 1 || (len(events) == 1) != test.wantQueued {
+				t.Fatalf("%d events queued, want queued = %v", len(events), test.wantQueued)
+			}
+			if !test.wantQueued {
+				return
+			}
+			if events[0].stateOnly != test.wantStateOnly {
+				t.Errorf("stateOnly = %v, want %v", events[0].stateOnly, test.wantStateOnly)
+			}
+			// Only events sent to every channel start a throttle window
+			if wantKey := !test.wantStateOnly; (events[0].throttleKey != "") != wantKey {
+				t.Errorf("throttleKey = %q, want one: %v", events[0].throttleKey, wantKey)
+			}
+		})
+	}
+}
+
+// trackingNotifier is a fakeNotifier that tracks state, like PagerDuty
+type trackingNotifier struct {
+	fakeNotifier
+}
+
+func (n *trackingNotifier) tracksState() bool { return true }
+
+func TestStateOnlyEventsReachTrackingChannels(t *testing.T) {
+	notifier := &fakeNotifier{}
+	tracker := &trackingNotifier{}
+	nm := newTestManager(notifier)
+	nm.RegisterNotifier(tracker)
+
+	nm.SendStatusChange(withPagerDuty(withNotifyOn(statusChange("down", "up"), NotifyOnDown)))
+	deliverQueued(nm)
+	if notifier.count() != 0 || tracker.count() != 1 {
+		t.Errorf("sent to %d plain and %d tracking channels, want the tracking one only", notifier.count(), tracker.count())
+	}
+}
+
+// slowTrackingNotifier is a trackingNotifier that takes a while to send
+// down events, as a slow PagerDuty trigger would
+type slowTrackingNotifier struct {
+	trackingNotifier
+}
+
+func (n *slowTrackingNotifier) Notify(event StatusChangeEvent) error {
+	if event.NewStatus == "down" {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return n.trackingNotifier.Notify(event)
+}
+
+func TestTrackingChannelsGetEventsInOrder(t *testing.T) {
+	tracker := &slowTrackingNotifier{}
+	nm := newTestManager(tracker)
+
+	// A quick flap: the recovery must not overtake the slow trigger
+	nm.handleStatusChange(withPagerDuty(statusChange("up", "down")))
+	nm.handleStatusChange(withPagerDuty(statusChange("down", "up")))
+	nm.sending.Wait()
+
+	events := tracker.events
+	if len(events) != 2 || events[0].NewStatus != "down" || events[1].NewStatus != "up" {
+		t.Fatalf("events = %+v, want down then up", events)
+	}
+	if len(nm.deliveries) != 0 {
+		t.Errorf("%d deliveries left queued, want none", len(nm.deliveries))
+	}
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	nm := NewNotificationManager(NotificationConfig{QueueSize: 1})
+
+	if !nm.enqueue(statusChange("up", "down")) {
+		t.Fatal("first event not queued")
+	}
+	if nm.enqueue(statusChange("down", "up")) {
+		t.Fatal("event queued into a full queue")
+	}
+	stats := nm.QueueStats()
+	if stats.Length != 1 || stats.Capacity != 1 || stats.Dropped != 1 {
+		t.Errorf("stats = %+v, want 1 queued of 1 and 1 dropped", stats)
+	}
+
+	// The queued event is the one that was kept
+	if events := queued(nm); len(events) != 1 || events[0].NewStatus != "down" {
+		t.Errorf("queued %+v, want the first event", events)
+	}
+}
+
+// withPagerDuty, withNotifyOn and withThrottle return a copy of event with a
+// website setting changed
+func withPagerDuty(event StatusChangeEvent) StatusChangeEvent {
+	event.PagerDuty = true
+	return event
+}
+
+func withNotifyOn(event StatusChangeEvent, notifyOn string) StatusChangeEvent {
+	event.NotifyOn = notifyOn
+	return event
+}
+
+func withThrottle(event StatusChangeEvent, seconds *int) StatusChangeEvent {
+	event.ThrottleSeconds = seconds
+	return event
+}
+
+func TestEscalationReminders(t *testing.T) {
+	notifier := &fakeNotifier{}
+	nm := newTestManager(notifier)
+
+	down := statusChange("up", "down")
+	down.EscalationAfterSeconds = 60
+	nm.SendStatusChange(down)
+	deliverQueued(nm)
+
+	// Not due yet, then due once per EscalationAfterSeconds
+	nm.sendEscalations(down.Timestamp.Add(30 * time.Second))
+	nm.sending.Wait()
+	if notifier.count() != 1 {
+		t.Fatalf("%d events sent before the first reminder was due, want 1", notifier.count())
+	}
+	// Each reminder is delivered in its own goroutine; wait so they arrive in order
+	for _, after := range []time.Duration{61 * time.Second, 90 * time.Second, 122 * time.Second} {
+		nm.sendEscalations(down.Timestamp.Add(after))
+		nm.sending.Wait()
+	}
+	if notifier.count() != 3 {
+		t.Fatalf("%d events sent, want the status change and two reminders", notifier.count())
+	}
+	reminder := notifier.events[2]
+	if reminder.EventType != EventEscalation || reminder.Reason != "Still down after 2m2s (reminder 2)" {
+		t.Errorf("reminder = %s %q, want the second escalation", reminder.EventType, reminder.Reason)
+	}
+
+	// The recovery after reminders is sent despite the throttle, and ends them
+	nm.SendStatusChange(statusChange("down", "up"))
+	deliverQueued(nm)
+	nm.sendEscalations(down.Timestamp.Add(time.Hour))
+	nm.sending.Wait()
+	if notifier.count() != 4 || notifier.events[3].NewStatus != "up" {
+		t.Errorf("%d events sent, want the recovery and no more reminders", notifier.count())
+	}
+}
+
+func TestStopEscalation(t *testing.T) {
+	notifier := &fakeNotifier{}
+	nm := newTestManager(notifier)
+
+	down := statusChange("up", "down")
+	down.EscalationAfterSeconds = 60
+	nm.SendStatusChange(down)
+	deliverQueued(nm)
+
+	nm.StopEscalation("site")
+	nm.sendEscalations(down.Timestamp.Add(time.Hour))
+	nm.sending.Wait()
+	if notifier.count() != 1 {
+		t.Errorf("%d events sent, want no reminder after StopEscalation", notifier.count())
+	}
+}
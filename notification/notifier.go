@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSkipped is returned by a Notifier when the event has no recipients for
+// its channel, e.g. a website without a Slack webhook
+var ErrSkipped = errors.New("notification skipped")
+
+// Notifier is a notification channel. Notify is called for every event the
+// manager sends and returns ErrSkipped when there is nothing to send.
+type Notifier interface {
+	Name() string
+	Notify(event StatusChangeEvent) error
+}
+
+// Attempt records the outcome of sending one event through one channel
+type Attempt struct {
+	Event     StatusChangeEvent
+	Channel   string
+	Timestamp time.Time
+	Err       error // Nil when the notification was sent
+}
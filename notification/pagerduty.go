@@ -0,0 +1,190 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// DefaultPagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const DefaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty event actions
+const (
+	pagerDutyTrigger = "trigger"
+	pagerDutyResolve = "resolve"
+)
+
+// pagerDutyEvent is the body of a PagerDuty Events API v2 request
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+// pagerDutyPayload describes the alert of a trigger event
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	Component     string            `json:"component"`
+	Class         string            `json:"class"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// pagerDutyLink is a link shown on the PagerDuty incident
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// pagerDutyResponse is the body of a PagerDuty Events API v2 response
+type pagerDutyResponse struct {
+	Status  string   `json:"status"`
+	Message string   `json:"message"`
+	Errors  []string `json:"errors"`
+}
+
+// pagerDutyNotifier opens a PagerDuty incident when a website goes down and
+// resolves it when the website recovers
+type pagerDutyNotifier struct {
+	config     func() NotificationConfig
+	httpClient *http.Client
+}
+
+// Name returns the channel name
+func (n *pagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+// tracksState makes the notifier receive every status change, so incidents
+// are resolved even when the recovery isn't notified on other channels
+func (n *pagerDutyNotifier) tracksState() bool {
+	return true
+}
+
+// Notify sends a trigger or resolve event for the website's incident
+func (n *pagerDutyNotifier) Notify(event StatusChangeEvent) error {
+	config := n.config()
+	if !event.PagerDuty || config.PagerDutyRoutingKey == "" {
+		return ErrSkipped
+	}
+
+	request, ok := pagerDutyRequest(event)
+	if !ok {
+		return ErrSkipped
+	}
+	request.RoutingKey = config.PagerDutyRoutingKey
+
+	eventsURL := config.PagerDutyEventsURL
+	if eventsURL == "" {
+		eventsURL = DefaultPagerDutyEventsURL
+	}
+	if err := n.send(eventsURL, request); err != nil {
+		return err
+	}
+	logger.Infof("PagerDuty %s event sent for %s", request.EventAction, event.WebsiteID)
+
+	// The test incident has served its purpose once it was opened; resolve
+	// it right away rather than leave it paging on-call
+	if event.EventType == EventTest {
+		resolve := pagerDutyEvent{RoutingKey: request.RoutingKey, EventAction: pagerDutyResolve, DedupKey: request.DedupKey}
+		if err := n.send(eventsURL, resolve); err != nil {
+			return fmt.Errorf("test incident was opened but not resolved: %v", err)
+		}
+	}
+	return nil
+}
+
+// send posts one event to the PagerDuty Events API
+func (n *pagerDutyNotifier) send(eventsURL string, request pagerDutyEvent) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %v", err)
+	}
+
+	resp, err := n.httpClient.Post(eventsURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("PagerDuty request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		var result pagerDutyResponse
+		if json.NewDecoder(resp.Body).Decode(&result) == nil && result.Message != "" {
+			return fmt.Errorf("PagerDuty API returned status %d: %s %v", resp.StatusCode, result.Message, result.Errors)
+		}
+		return fmt.Errorf("PagerDuty API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pagerDutyRequest builds the PagerDuty event for a notification, without the
+// routing key. Down and degraded websites trigger the website's incident and
+// recovered ones resolve it. Expiring certificates trigger an incident of
+// their own, which is resolved in PagerDuty, and test notifications one that
+// Notify resolves right after. It returns false for events PagerDuty isn't
+// told about: a website becoming unknown or flapping, which leaves its
+// incident as it was, or a reminder, as PagerDuty escalates open incidents
+// itself.
+func pagerDutyRequest(event StatusChangeEvent) (pagerDutyEvent, bool) {
+	dedupKey := "uptime-monitor/" + event.WebsiteID
+	severity := "critical"
+	summary := fmt.Sprintf("%s is DOWN", event.WebsiteName)
+
+	switch event.EventType {
+	case EventCertExpiring:
+		dedupKey += "/certificate"
+		severity = "warning"
+		summary = fmt.Sprintf("TLS certificate for %s expires soon", event.WebsiteName)
+	case EventEscalation:
+		return pagerDutyEvent{}, false
+	case EventTest:
+		dedupKey += "/test"
+		severity = "info"
+		summary = fmt.Sprintf("Test notification for %s", event.WebsiteName)
+	default:
+		switch event.NewStatus {
+		case "up":
+			return pagerDutyEvent{EventAction: pagerDutyResolve, DedupKey: dedupKey}, true
+		case "down":
+		case "degraded":
+			severity = "warning"
+			summary = fmt.Sprintf("%s is DEGRADED", event.WebsiteName)
+		default:
+			return pagerDutyEvent{}, false
+		}
+	}
+
+	details := map[string]string{
+		"url":              event.WebsiteURL,
+		"old_status":       event.OldStatus,
+		"new_status":       event.NewStatus,
+		"response_time_ms": fmt.Sprintf("%d", event.ResponseTime),
+	}
+	if event.Reason != "" {
+		details["reason"] = event.Reason
+		summary += ": " + event.Reason
+	}
+
+	return pagerDutyEvent{
+		EventAction: pagerDutyTrigger,
+		DedupKey:    dedupKey,
+		Payload: &pagerDutyPayload{
+			Summary:       summary,
+			Source:        event.WebsiteURL,
+			Severity:      severity,
+			Timestamp:     event.Timestamp.Format(time.RFC3339),
+			Component:     event.WebsiteName,
+			Class:         "uptime",
+			CustomDetails: details,
+		},
+		Links: []pagerDutyLink{{Href: event.WebsiteURL, Text: event.WebsiteName}},
+	}, true
+}
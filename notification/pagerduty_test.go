@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// pagerDutyServer records the events posted to a fake Events API
+type pagerDutyServer struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	events []pagerDutyEvent
+}
+
+func newPagerDutyServer(t *testing.T) *pagerDutyServer {
+	t.Helper()
+	server := &pagerDutyServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		server.mutex.Lock()
+		server.events = append(server.events, event)
+		server.mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newPagerDutyNotifier returns a notifier posting to server
+func newPagerDutyNotifier(server *pagerDutyServer) *pagerDutyNotifier {
+	config := NotificationConfig{PagerDutyRoutingKey: "key", PagerDutyEventsURL: server.URL}
+	return &pagerDutyNotifier{config: func() NotificationConfig { return config }, httpClient: server.Client()}
+}
+
+func TestPagerDutyTestNotificationIsResolved(t *testing.T) {
+	server := newPagerDutyServer(t)
+	notifier := newPagerDutyNotifier(server)
+
+	event := statusChange("", "up")
+	event.EventType = EventTest
+	event.PagerDuty = true
+	if err := notifier.Notify(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.events) != 2 {
+		t.Fatalf("%d events sent, want a trigger and a resolve", len(server.events))
+	}
+	trigger, resolve := server.events[0], server.events[1]
+	if trigger.EventAction != pagerDutyTrigger || resolve.EventAction != pagerDutyResolve {
+		t.Errorf("actions = %q, %q, want trigger then resolve", trigger.EventAction, resolve.EventAction)
+	}
+	if resolve.DedupKey != trigger.DedupKey || trigger.DedupKey != "uptime-monitor/site/test" {
+		t.Errorf("dedup keys = %q, %q, want both uptime-monitor/site/test", trigger.DedupKey, resolve.DedupKey)
+	}
+	if resolve.RoutingKey != "key" {
+		t.Errorf("resolve routing key = %q, want the configured one", resolve.RoutingKey)
+	}
+}
+
+func TestPagerDutyStatusChangeIsNotResolved(t *testing.T) {
+	server := newPagerDutyServer(t)
+	notifier := newPagerDutyNotifier(server)
+
+	event := statusChange("up", "down")
+	event.PagerDuty = true
+	if err := notifier.Notify(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.events) != 1 || server.events[0].EventAction != pagerDutyTrigger {
+		t.Errorf("events = %+v, want a single trigger", server.events)
+	}
+}
+
+func TestPagerDutyRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		newStatus string
+		reason    string
+		sent      bool
+		action    string
+		dedupKey  string
+		severity  string
+		summary   string
+	}{
+		{"down", EventStatusChange, "down", "", true, pagerDutyTrigger, "uptime-monitor/site", "critical", "Site is DOWN"},
+		{"down with reason", EventStatusChange, "down", "HTTP 503", true, pagerDutyTrigger, "uptime-monitor/site", "critical", "Site is DOWN: HTTP 503"},
+		{"degraded", EventStatusChange, "degraded", "", true, pagerDutyTrigger, "uptime-monitor/site", "warning", "Site is DEGRADED"},
+		{"up", EventStatusChange, "up", "", true, pagerDutyResolve, "uptime-monitor/site", "", ""},
+		{"unknown", EventStatusChange, "unknown", "", false, "", "", "", ""},
+		{"flapping", EventStatusChange, "flapping", "", false, "", "", "", ""},
+		{"certificate", EventCertExpiring, "up", "", true, pagerDutyTrigger, "uptime-monitor/site/certificate", "warning", "TLS certificate for Site expires soon"},
+		{"test", EventTest, "up", "", true, pagerDutyTrigger, "uptime-monitor/site/test", "info", "Test notification for Site"},
+		{"reminder", EventEscalation, "down", "", false, "", "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := statusChange("up", test.newStatus)
+			event.EventType = test.eventType
+			event.Reason = test.reason
+
+			request, sent := pagerDutyRequest(event)
+			if sent != test.sent {
+				t.Fatalf("sent = %v, want %v", sent, test.sent)
+			}
+			if !sent {
+				return
+			}
+			if request.EventAction != test.action || request.DedupKey != test.dedupKey {
+				t.Errorf("action %q on %q, want %q on %q", request.EventAction, request.DedupKey, test.action, test.dedupKey)
+			}
+			if test.action == pagerDutyResolve {
+				if request.Payload != nil {
+					t.Error("resolve carries a payload")
+				}
+				return
+			}
+			if request.Payload == nil {
+				t.Fatal("trigger without a payload")
+			}
+			if request.Payload.Severity != test.severity || request.Payload.Summary != test.summary {
+				t.Errorf("%s %q, want %s %q", request.Payload.Severity, request.Payload.Summary, test.severity, test.summary)
+			}
+			if request.Payload.Source != event.WebsiteURL {
+				t.Errorf("source = %q, want the website URL", request.Payload.Source)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"uptime-monitor/logger"
+)
+
+// SlackMessage represents a Slack webhook message
+type SlackMessage struct {
+	Text        string       `json:"text"`
+	Username    string       `json:"username,omitempty"`
+	IconEmoji   string       `json:"icon_emoji,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment represents a Slack message attachment
+type Attachment struct {
+	Color     string  `json:"color"`
+	Title     string  `json:"title"`
+	Text      string  `json:"text"`
+	Timestamp int64   `json:"ts"`
+	Fields    []Field `json:"fields,omitempty"`
+}
+
+// Field represents a field in a Slack attachment
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackNotifier posts notifications to Slack incoming webhooks
+type slackNotifier struct {
+	httpClient *http.Client
+}
+
+// Name returns the channel name
+func (n *slackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify posts a message to the website's Slack webhook
+func (n *slackNotifier) Notify(event StatusChangeEvent) error {
+	if event.SlackWebhook == "" {
+		return ErrSkipped
+	}
+
+	var color string
+	var emoji string
+	var title string
+
+	if event.EventType == EventTest {
+		color = "#3366cc"
+		emoji = ":bell:"
+		title = fmt.Sprintf("%s Test notification for %s", emoji, event.WebsiteName)
+	} else if event.EventType == EventCertExpiring {
+		color = "warning"
+		emoji = ":warning:"
+		title = fmt.Sprintf("%s TLS certificate for %s expires soon", emoji, event.WebsiteName)
+	} else if event.EventType == EventEscalation {
+		color = "danger"
+		emoji = ":rotating_light:"
+		title = fmt.Sprintf("%s Website %s is still DOWN", emoji, event.WebsiteName)
+	} else if event.NewStatus == "up" {
+		color = "good"
+		emoji = ":white_check_mark:"
+		title = fmt.Sprintf("%s Website %s is UP", emoji, event.WebsiteName)
+	} else if event.NewStatus == "degraded" {
+		color = "warning"
+		emoji = ":snail:"
+		title = fmt.Sprintf("%s Website %s is DEGRADED", emoji, event.WebsiteName)
+	} else if event.NewStatus == "flapping" {
+		color = "warning"
+		emoji = ":arrows_counterclockwise:"
+		title = fmt.Sprintf("%s Website %s is FLAPPING", emoji, event.WebsiteName)
+	} else {
+		color = "danger"
+		emoji = ":x:"
+		title = fmt.Sprintf("%s Website %s is DOWN", emoji, event.WebsiteName)
+	}
+
+	fields := []Field{
+		{Title: "Website", Value: event.WebsiteName, Short: true},
+		{Title: "URL", Value: event.WebsiteURL, Short: true},
+		{Title: "Status Change", Value: fmt.Sprintf("%s → %s", strings.ToUpper(event.OldStatus), strings.ToUpper(event.NewStatus)), Short: true},
+		{Title: "Time", Value: event.Timestamp.Format("2006-01-02 15:04:05"), Short: true},
+	}
+
+	if (event.NewStatus == "up" || event.NewStatus == "degraded") && event.ResponseTime > 0 {
+		fields = append(fields, Field{Title: "Response Time", Value: fmt.Sprintf("%dms", event.ResponseTime), Short: true})
+	}
+
+	attachment := Attachment{
+		Color:     color,
+		Title:     title,
+		Text:      event.Reason,
+		Timestamp: event.Timestamp.Unix(),
+		Fields:    fields,
+	}
+
+	message := SlackMessage{
+		Username:    "Uptime Monitor",
+		IconEmoji:   ":computer:",
+		Attachments: []Attachment{attachment},
+	}
+
+	// Send to Slack
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack message: %v", err)
+	}
+
+	resp, err := n.httpClient.Post(event.SlackWebhook, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack webhook returned status %d", resp.StatusCode)
+	}
+	logger.Infof("Slack notification sent for %s", event.WebsiteID)
+	return nil
+}
@@ -0,0 +1,177 @@
+package notification
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// defaultSMTPIdleTimeout is how long an unused SMTP connection is kept open
+const defaultSMTPIdleTimeout = 60 * time.Second
+
+// smtpSender keeps a persistent SMTP connection and reuses it across notifications
+type smtpSender struct {
+	mutex    sync.Mutex
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// Send delivers a message, reusing the open connection when possible and
+// reconnecting once if the connection turns out to be broken
+func (s *smtpSender) Send(config NotificationConfig, to []string, message []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	idleTimeout := defaultSMTPIdleTimeout
+	if config.SMTPIdleTimeoutSeconds > 0 {
+		idleTimeout = time.Duration(config.SMTPIdleTimeoutSeconds) * time.Second
+	}
+
+	// Drop connections that sat idle too long or no longer respond
+	if s.client != nil && (time.Since(s.lastUsed) > idleTimeout || s.client.Noop() != nil) {
+		s.closeLocked()
+	}
+
+	reused := s.client != nil
+	if err := s.sendLocked(config, to, message); err != nil {
+		s.closeLocked()
+		if !reused {
+			return err
+		}
+		// The server may have dropped the connection; retry once on a fresh one
+		if err := s.sendLocked(config, to, message); err != nil {
+			s.closeLocked()
+			return err
+		}
+	}
+
+	if !config.SMTPReuseConnection {
+		s.closeLocked()
+		return nil
+	}
+
+	s.lastUsed = time.Now()
+	return nil
+}
+
+// Close closes the pooled connection if one is open
+func (s *smtpSender) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closeLocked()
+}
+
+// sendLocked sends a message over the current connection, dialing if needed
+func (s *smtpSender) sendLocked(config NotificationConfig, to []string, message []byte) error {
+	if s.client == nil {
+		client, err := s.dial(config)
+		if err != nil {
+			return err
+		}
+		s.client = client
+	}
+
+	if err := s.client.Mail(config.FromEmail); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %v", err)
+	}
+	for _, recipient := range to {
+		if err := s.client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("RCPT TO %s failed: %v", recipient, err)
+		}
+	}
+
+	writer, err := s.client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %v", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish message: %v", err)
+	}
+
+	return nil
+}
+
+// SMTP encryption modes for NotificationConfig.SMTPEncryption
+const (
+	SMTPEncryptionNone     = "none"     // Plain connection, never upgraded
+	SMTPEncryptionSTARTTLS = "starttls" // Plain connection that must be upgraded with STARTTLS, usually port 587
+	SMTPEncryptionTLS      = "tls"      // Implicit TLS from the start, usually port 465
+)
+
+// dial opens and authenticates a new SMTP connection
+func (s *smtpSender) dial(config NotificationConfig) (*smtp.Client, error) {
+	addr := net.JoinHostPort(config.SMTPHost, config.SMTPPort)
+	tlsConfig := &tls.Config{
+		ServerName:         config.SMTPHost,
+		InsecureSkipVerify: config.SMTPSkipVerify,
+	}
+
+	var client *smtp.Client
+	switch config.SMTPEncryption {
+	case SMTPEncryptionTLS:
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+		client, err = smtp.NewClient(conn, config.SMTPHost)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+	case "", SMTPEncryptionNone, SMTPEncryptionSTARTTLS:
+		var err error
+		client, err = smtp.Dial(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown SMTP encryption %q", config.SMTPEncryption)
+	}
+
+	// Upgrade to TLS when required, or when offered and no mode is set (same as smtp.SendMail)
+	if config.SMTPEncryption == "" || config.SMTPEncryption == SMTPEncryptionSTARTTLS {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && config.SMTPEncryption == SMTPEncryptionSTARTTLS {
+			client.Close()
+			return nil, fmt.Errorf("server doesn't support STARTTLS")
+		}
+		if ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("STARTTLS failed: %v", err)
+			}
+		}
+	}
+
+	if config.SMTPUsername != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("server doesn't support AUTH")
+		}
+		auth := smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, config.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+// closeLocked closes the current connection, ignoring errors from dead connections
+func (s *smtpSender) closeLocked() {
+	if s.client == nil {
+		return
+	}
+	if err := s.client.Quit(); err != nil {
+		s.client.Close()
+	}
+	s.client = nil
+}
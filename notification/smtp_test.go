@@ -0,0 +1,103 @@
+package notification
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSMTPRelay starts an SMTP server that accepts mail without
+// authentication, like a self-hosted relay, and sends the data of every
+// message it receives on the returned channel
+func startSMTPRelay(t *testing.T) (host, port string, messages <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(listener.Addr().String())
+	return host, port, received
+}
+
+// serveSMTP answers one SMTP session, offering neither AUTH nor STARTTLS
+func serveSMTP(conn net.Conn, received chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 relay ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250-relay")
+			reply("250 8BITMIME")
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received <- data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// waitForMessage waits for the relay to receive a message
+func waitForMessage(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestEmailWithoutAuthentication(t *testing.T) {
+	host, port, messages := startSMTPRelay(t)
+	config := NotificationConfig{SMTPHost: host, SMTPPort: port, SMTPEncryption: SMTPEncryptionNone, FromEmail: "monitor@example.com"}
+	notifier := &emailNotifier{config: func() NotificationConfig { return config }, sender: &smtpSender{}}
+
+	event := statusChange("up", "down")
+	event.Emails = []string{"ops@example.com"}
+	if err := notifier.Notify(event); err != nil {
+		t.Fatalf("sending through a relay without authentication failed: %v", err)
+	}
+	if message := waitForMessage(t, messages); !strings.Contains(message, "Website Site is DOWN") {
+		t.Errorf("message doesn't have the subject:\n%s", message)
+	}
+}
@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"uptime-monitor/logger"
+)
+
+// teamsMessageCard is a Microsoft Teams incoming webhook message in the
+// legacy MessageCard format
+type teamsMessageCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title"`
+	Text       string         `json:"text,omitempty"`
+	Sections   []teamsSection `json:"sections,omitempty"`
+	Actions    []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+// teamsSection is a group of facts in a MessageCard
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+}
+
+// teamsFact is a name/value pair shown in a MessageCard section
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// teamsAction is a button of a MessageCard
+type teamsAction struct {
+	Type    string              `json:"@type"`
+	Name    string              `json:"name"`
+	Targets []teamsActionTarget `json:"targets"`
+}
+
+// teamsActionTarget is the URI opened by a MessageCard button
+type teamsActionTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// teamsNotifier posts notifications to Microsoft Teams incoming webhooks
+type teamsNotifier struct {
+	httpClient *http.Client
+}
+
+// Name returns the channel name
+func (n *teamsNotifier) Name() string {
+	return "teams"
+}
+
+// Notify posts a card to the website's Teams webhook
+func (n *teamsNotifier) Notify(event StatusChangeEvent) error {
+	if event.TeamsWebhook == "" {
+		return ErrSkipped
+	}
+
+	jsonData, err := json.Marshal(formatTeamsCard(event))
+	if err != nil {
+		return fmt.Errorf("failed to marshal Teams message: %v", err)
+	}
+
+	resp, err := n.httpClient.Post(event.TeamsWebhook, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Connector webhooks answer 200, Workflows webhooks 202
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Teams webhook returned status %d", resp.StatusCode)
+	}
+	logger.Infof("Teams notification sent for %s", event.WebsiteID)
+	return nil
+}
+
+// formatTeamsCard renders an event as a MessageCard themed by the new status
+func formatTeamsCard(event StatusChangeEvent) teamsMessageCard {
+	var color, title string
+
+	switch {
+	case event.EventType == EventTest:
+		color = "3366CC"
+		title = fmt.Sprintf("🔔 Test notification for %s", event.WebsiteName)
+	case event.EventType == EventCertExpiring:
+		color = "FFA500"
+		title = fmt.Sprintf("⚠️ TLS certificate for %s expires soon", event.WebsiteName)
+	case event.EventType == EventEscalation:
+		color = "D40E0D"
+		title = fmt.Sprintf("🚨 Website %s is still DOWN", event.WebsiteName)
+	case event.NewStatus == "up":
+		color = "2EB886"
+		title = fmt.Sprintf("✅ Website %s is UP", event.WebsiteName)
+	case event.NewStatus == "degraded":
+		color = "FFA500"
+		title = fmt.Sprintf("🐌 Website %s is DEGRADED", event.WebsiteName)
+	case event.NewStatus == "flapping":
+		color = "FFA500"
+		title = fmt.Sprintf("🔄 Website %s is FLAPPING", event.WebsiteName)
+	default:
+		color = "D40E0D"
+		title = fmt.Sprintf("❌ Website %s is DOWN", event.WebsiteName)
+	}
+
+	facts := []teamsFact{
+		{Name: "Website", Value: event.WebsiteName},
+		{Name: "URL", Value: event.WebsiteURL},
+	}
+	if event.EventType == EventStatusChange {
+		facts = append(facts, teamsFact{Name: "Status Change", Value: fmt.Sprintf("%s → %s", strings.ToUpper(event.OldStatus), strings.ToUpper(event.NewStatus))})
+	}
+	facts = append(facts, teamsFact{Name: "Time", Value: event.Timestamp.Format("2006-01-02 15:04:05")})
+	if (event.NewStatus == "up" || event.NewStatus == "degraded") && event.ResponseTime > 0 {
+		facts = append(facts, teamsFact{Name: "Response Time", Value: fmt.Sprintf("%dms", event.ResponseTime)})
+	}
+
+	return teamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: color,
+		Summary:    title,
+		Title:      title,
+		Text:       event.Reason,
+		Sections:   []teamsSection{{Facts: facts}},
+		Actions: []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "Open website",
+			Targets: []teamsActionTarget{{OS: "default", URI: event.WebsiteURL}},
+		}},
+	}
+}
@@ -0,0 +1,85 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTeamsNotify(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int // Answered by the webhook
+		wantErr bool
+	}{
+		{name: "connector webhook", status: http.StatusOK},
+		{name: "workflows webhook", status: http.StatusAccepted},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var card teamsMessageCard
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+					t.Errorf("webhook got invalid JSON: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			event := statusChange("up", "down")
+			event.Reason = "HTTP 503"
+			event.TeamsWebhook = server.URL
+			err := (&teamsNotifier{httpClient: server.Client()}).Notify(event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want one: %v", err, tt.wantErr)
+			}
+
+			if card.Type != "MessageCard" || card.ThemeColor != "D40E0D" || card.Title != "❌ Website Site is DOWN" {
+				t.Errorf("card = %s %s %q, want a red DOWN MessageCard", card.Type, card.ThemeColor, card.Title)
+			}
+			if card.Text != "HTTP 503" {
+				t.Errorf("text = %q, want the reason", card.Text)
+			}
+			if len(card.Actions) != 1 || card.Actions[0].Targets[0].URI != event.WebsiteURL {
+				t.Errorf("actions = %+v, want a button opening the website", card.Actions)
+			}
+		})
+	}
+}
+
+func TestTeamsSkipsWithoutWebhook(t *testing.T) {
+	if err := (&teamsNotifier{httpClient: http.DefaultClient}).Notify(statusChange("up", "down")); err != ErrSkipped {
+		t.Errorf("error = %v, want ErrSkipped", err)
+	}
+}
+
+func TestTeamsCardFacts(t *testing.T) {
+	event := statusChange("down", "up")
+	event.ResponseTime = 120
+	facts := map[string]string{}
+	for _, fact := range formatTeamsCard(event).Sections[0].Facts {
+		facts[fact.Name] = fact.Value
+	}
+	if facts["Status Change"] != "DOWN → UP" {
+		t.Errorf("status change = %q, want DOWN → UP", facts["Status Change"])
+	}
+	if facts["Response Time"] != "120ms" {
+		t.Errorf("response time = %q, want 120ms", facts["Response Time"])
+	}
+
+	// Reminders aren't status changes
+	event = statusChange("down", "down")
+	event.EventType = EventEscalation
+	card := formatTeamsCard(event)
+	if card.Title != "🚨 Website Site is still DOWN" {
+		t.Errorf("reminder title = %q", card.Title)
+	}
+	for _, fact := range card.Sections[0].Facts {
+		if fact.Name == "Status Change" {
+			t.Error("reminder shows a status change")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"uptime-monitor/logger"
+)
+
+// telegramAPIURL is the base URL of the Telegram Bot API
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramMessage is the body of a Telegram sendMessage request
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+// telegramResponse is the envelope of every Telegram Bot API response
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// telegramNotifier sends notifications through a Telegram bot
+type telegramNotifier struct {
+	config     func() NotificationConfig
+	httpClient *http.Client
+}
+
+// Name returns the channel name
+func (n *telegramNotifier) Name() string {
+	return "telegram"
+}
+
+// Notify sends a message to the website's Telegram chat
+func (n *telegramNotifier) Notify(event StatusChangeEvent) error {
+	token := n.config().TelegramBotToken
+	if event.TelegramChatID == "" || token == "" {
+		return ErrSkipped
+	}
+
+	jsonData, err := json.Marshal(telegramMessage{
+		ChatID:                event.TelegramChatID,
+		Text:                  formatTelegramMessage(event),
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Telegram message: %v", err)
+	}
+
+	resp, err := n.httpClient.Post(telegramAPIURL+"/bot"+token+"/sendMessage", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		// The request URL contains the bot token, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Telegram request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || !result.OK {
+		if result.Description != "" {
+			return fmt.Errorf("Telegram API returned status %d: %s", resp.StatusCode, result.Description)
+		}
+		return fmt.Errorf("Telegram API returned status %d", resp.StatusCode)
+	}
+	logger.Infof("Telegram notification sent for %s", event.WebsiteID)
+	return nil
+}
+
+// formatTelegramMessage renders an event as a plain text Telegram message
+func formatTelegramMessage(event StatusChangeEvent) string {
+	var text strings.Builder
+
+	switch {
+	case event.EventType == EventTest:
+		fmt.Fprintf(&text, "🔔 Test notification for %s\n", event.WebsiteName)
+	case event.EventType == EventCertExpiring:
+		fmt.Fprintf(&text, "⚠️ TLS certificate for %s expires soon\n", event.WebsiteName)
+	case event.EventType == EventEscalation:
+		fmt.Fprintf(&text, "🚨 Website %s is still DOWN\n", event.WebsiteName)
+	case event.NewStatus == "up":
+		fmt.Fprintf(&text, "✅ Website %s is UP\n", event.WebsiteName)
+	case event.NewStatus == "degraded":
+		fmt.Fprintf(&text, "🐌 Website %s is DEGRADED\n", event.WebsiteName)
+	case event.NewStatus == "flapping":
+		fmt.Fprintf(&text, "🔄 Website %s is FLAPPING\n", event.WebsiteName)
+	default:
+		fmt.Fprintf(&text, "❌ Website %s is DOWN\n", event.WebsiteName)
+	}
+
+	fmt.Fprintf(&text, "\nURL: %s\n", event.WebsiteURL)
+	if event.EventType == EventStatusChange {
+		fmt.Fprintf(&text, "Status: %s → %s\n", strings.ToUpper(event.OldStatus), strings.ToUpper(event.NewStatus))
+	}
+	fmt.Fprintf(&text, "Time: %s\n", event.Timestamp.Format("2006-01-02 15:04:05"))
+	if (event.NewStatus == "up" || event.NewStatus == "degraded") && event.ResponseTime > 0 {
+		fmt.Fprintf(&text, "Response time: %dms\n", event.ResponseTime)
+	}
+	if event.Reason != "" {
+		fmt.Fprintf(&text, "Reason: %s\n", event.Reason)
+	}
+
+	return text.String()
+}
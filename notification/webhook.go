@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// WebhookPayload is the JSON body posted to generic webhooks without a template
+type WebhookPayload struct {
+	EventType    string    `json:"event_type"`
+	WebsiteID    string    `json:"website_id"`
+	WebsiteName  string    `json:"website_name"`
+	WebsiteURL   string    `json:"website_url"`
+	OldStatus    string    `json:"old_status"`
+	NewStatus    string    `json:"new_status"`
+	ResponseTime int       `json:"response_time_ms"`
+	Timestamp    time.Time `json:"timestamp"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// webhookNotifier posts notifications to a website's generic webhook
+type webhookNotifier struct {
+	httpClient *http.Client
+}
+
+// Name returns the channel name
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify posts the event to the website's webhook, rendered with its template if set
+func (n *webhookNotifier) Notify(event StatusChangeEvent) error {
+	if event.Webhook == "" {
+		return ErrSkipped
+	}
+
+	body, err := RenderWebhookBody(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.httpClient.Post(event.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	logger.Infof("Webhook notification sent for %s", event.WebsiteID)
+	return nil
+}
+
+// RenderWebhookBody builds the webhook request body for an event. The website's
+// text/template is executed with the StatusChangeEvent when set, otherwise the
+// event is sent as a WebhookPayload JSON object.
+func RenderWebhookBody(event StatusChangeEvent) ([]byte, error) {
+	if event.WebhookTemplate == "" {
+		eventType := event.EventType
+		if eventType == EventStatusChange {
+			eventType = "status_change"
+		}
+		return json.Marshal(WebhookPayload{
+			EventType:    eventType,
+			WebsiteID:    event.WebsiteID,
+			WebsiteName:  event.WebsiteName,
+			WebsiteURL:   event.WebsiteURL,
+			OldStatus:    event.OldStatus,
+			NewStatus:    event.NewStatus,
+			ResponseTime: event.ResponseTime,
+			Timestamp:    event.Timestamp,
+			Reason:       event.Reason,
+		})
+	}
+
+	tmpl, err := ParseWebhookTemplate(event.WebhookTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %v", err)
+	}
+	return body.Bytes(), nil
+}
+
+// ParseWebhookTemplate parses a webhook body template
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("webhook").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %v", err)
+	}
+	return tmpl, nil
+}
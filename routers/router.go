@@ -1,10 +1,75 @@
 package routers
 
 import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
 	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
 )
 
 func init() {
 	// Serve static files for the dashboard
 	beego.SetStaticPath("/", "static")
+
+	// Registered first, so requests stopped by later filters carry CORS headers too
+	beego.InsertFilter("/api/*", beego.BeforeRouter, corsFilter)
+	beego.InsertFilter("/metrics", beego.BeforeRouter, corsFilter)
+}
+
+// corsFilter lets the dashboard and other browser clients call the API from
+// any origin
+func corsFilter(ctx *context.Context) {
+	ctx.Output.Header("Access-Control-Allow-Origin", "*")
+	ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type, X-Api-Key, X-Agent-Key, X-Namespace")
+	ctx.Output.Header("Access-Control-Expose-Headers", "X-Total-Count")
+}
+
+// RequireAPIKey rejects /api/* and /metrics requests that don't carry apiKey
+func RequireAPIKey(apiKey string) {
+	beego.InsertFilter("/api/*", beego.BeforeRouter, apiKeyFilter(apiKey))
+	beego.InsertFilter("/metrics", beego.BeforeRouter, apiKeyFilter(apiKey))
+}
+
+// apiKeyFilter rejects requests without the API key in the X-Api-Key header.
+// CORS preflight requests are let through since browsers never send custom
+// headers with them. Browsers can't set headers on WebSocket or EventSource
+// connections either, so those may pass the key in the api_key query
+// parameter instead, as may Prometheus scrapes of /metrics.
+func apiKeyFilter(apiKey string) beego.FilterFunc {
+	return func(ctx *context.Context) {
+		if ctx.Input.Method() == http.MethodOptions {
+			return
+		}
+
+		provided := ctx.Input.Header("X-Api-Key")
+		if provided == "" && (isStreamRequest(ctx) || isMetricsRequest(ctx)) {
+			provided = ctx.Input.Query("api_key")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) == 1 {
+			return
+		}
+
+		ctx.Output.SetStatus(http.StatusUnauthorized)
+		ctx.Output.JSON(map[string]string{"error": "Missing or invalid API key"}, false, false)
+	}
+}
+
+// isStreamRequest reports whether a request opens a WebSocket connection or
+// subscribes to the Server-Sent Events stream
+func isStreamRequest(ctx *context.Context) bool {
+	if strings.EqualFold(ctx.Input.Header("Upgrade"), "websocket") {
+		return true
+	}
+	if ctx.Input.Method() == http.MethodGet && strings.TrimSuffix(ctx.Input.URL(), "/") == "/api/events" {
+		return true
+	}
+	return strings.Contains(ctx.Input.Header("Accept"), "text/event-stream")
+}
+
+// isMetricsRequest reports whether a request scrapes the Prometheus metrics
+func isMetricsRequest(ctx *context.Context) bool {
+	return strings.TrimSuffix(ctx.Input.URL(), "/") == "/metrics"
 }
@@ -0,0 +1,77 @@
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/context"
+)
+
+func TestAPIKeyFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "header", method: http.MethodGet, target: "/api/websites", headers: map[string]string{"X-Api-Key": "secret"}, want: http.StatusOK},
+		{name: "missing", method: http.MethodGet, target: "/api/websites", want: http.StatusUnauthorized},
+		{name: "wrong header", method: http.MethodGet, target: "/api/websites", headers: map[string]string{"X-Api-Key": "wrong"}, want: http.StatusUnauthorized},
+		{name: "preflight", method: http.MethodOptions, target: "/api/websites", want: http.StatusOK},
+		{name: "query on plain request", method: http.MethodGet, target: "/api/websites?api_key=secret", want: http.StatusUnauthorized},
+		{name: "query on websocket", method: http.MethodGet, target: "/api/ws?api_key=secret", headers: map[string]string{"Upgrade": "websocket"}, want: http.StatusOK},
+		{name: "query on event stream", method: http.MethodGet, target: "/api/events?api_key=secret", want: http.StatusOK},
+		{name: "wrong query on event stream", method: http.MethodGet, target: "/api/events?api_key=wrong", want: http.StatusUnauthorized},
+		{name: "metrics without key", method: http.MethodGet, target: "/metrics", want: http.StatusUnauthorized},
+		{name: "header on metrics", method: http.MethodGet, target: "/metrics", headers: map[string]string{"X-Api-Key": "secret"}, want: http.StatusOK},
+		{name: "query on metrics", method: http.MethodGet, target: "/metrics?api_key=secret", want: http.StatusOK},
+		{name: "query accepting event stream", method: http.MethodGet, target: "/api/stream?api_key=secret", headers: map[string]string{"Accept": "text/event-stream"}, want: http.StatusOK},
+	}
+
+	filter := apiKeyFilter("secret")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			ctx := context.NewContext()
+			ctx.Reset(rec, req)
+
+			filter(ctx)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSFilter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/websites", nil)
+	rec := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(rec, req)
+
+	// A request the API key filter stops keeps the headers set before it
+	corsFilter(ctx)
+	apiKeyFilter("secret")(ctx)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	headers := rec.Header()
+	if headers.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("allowed origin = %q, want any", headers.Get("Access-Control-Allow-Origin"))
+	}
+	for _, header := range []string{"X-Api-Key", "X-Agent-Key", "X-Namespace"} {
+		if !strings.Contains(headers.Get("Access-Control-Allow-Headers"), header) {
+			t.Errorf("allowed headers = %q, want %s among them", headers.Get("Access-Control-Allow-Headers"), header)
+		}
+	}
+	if headers.Get("Access-Control-Expose-Headers") != "X-Total-Count" {
+		t.Errorf("exposed headers = %q, want X-Total-Count", headers.Get("Access-Control-Expose-Headers"))
+	}
+}
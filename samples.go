@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// sampleWebsite is one entry of the bundled sample list
+type sampleWebsite struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// loadSampleWebsites reads the sample list from path and turns each entry
+// into an enabled website checked every minute
+func loadSampleWebsites(path string) ([]*monitor.Website, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []sampleWebsite
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	websites := make([]*monitor.Website, 0, len(samples))
+	for i, sample := range samples {
+		if sample.Name == "" || sample.URL == "" {
+			return nil, fmt.Errorf("sample %d in %s needs a name and a url", i, path)
+		}
+		websites = append(websites, &monitor.Website{
+			ID:                 fmt.Sprintf("website_%d", time.Now().UnixNano()+int64(i)),
+			Name:               sample.Name,
+			URL:                sample.URL,
+			IntervalSeconds:    60,
+			Status:             monitor.StatusUnknown,
+			NotificationEmails: []string{},
+			Enabled:            true,
+		})
+	}
+	return websites, nil
+}
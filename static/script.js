@@ -5,6 +5,31 @@ let websites = [];
 let selectedWebsiteId = null;
 let responseChart = null;
 
+// Call the API with the stored API key. When the server asks for a key, prompt
+// for it once and retry.
+async function apiFetch(url, options = {}) {
+  const send = () => {
+    const headers = Object.assign({}, options.headers);
+    const apiKey = localStorage.getItem("apiKey");
+    if (apiKey) {
+      headers["X-Api-Key"] = apiKey;
+    }
+    return fetch(url, Object.assign({}, options, { headers }));
+  };
+
+  const response = await send();
+  if (response.status !== 401) {
+    return response;
+  }
+
+  const apiKey = prompt("This dashboard requires an API key:");
+  if (!apiKey) {
+    return response;
+  }
+  localStorage.setItem("apiKey", apiKey);
+  return send();
+}
+
 // Initialize the application
 document.addEventListener("DOMContentLoaded", function () {
   loadWebsites();
@@ -74,7 +99,7 @@ document.addEventListener("DOMContentLoaded", function () {
 // Load websites from API
 async function loadWebsites() {
   try {
-    const response = await fetch(`${API_BASE}/websites`);
+    const response = await apiFetch(`${API_BASE}/websites`);
     if (response.ok) {
       websites = await response.json();
       renderWebsiteList();
@@ -129,6 +154,8 @@ function renderWebsiteList() {
                   ? "up"
                   : entry.status === "down"
                   ? "down"
+                  : entry.status === "degraded"
+                  ? "degraded"
                   : "unknown"
               }"></div>`
           )
@@ -165,6 +192,29 @@ function renderWebsiteList() {
   }
 }
 
+// Icons and labels for the error codes of failed checks
+const errorCodeLabels = {
+  timeout: "⏱️ Timeout",
+  dns: "🌐 DNS failure",
+  connection_refused: "🚫 Connection refused",
+  connection_reset: "🔌 Connection reset",
+  tls: "🔒 TLS error",
+  http_status: "🔢 Unexpected status",
+  protocol: "🔀 Not HTTP/2",
+  content_mismatch: "📄 Content mismatch",
+  slow_response: "🐌 Slow response",
+  response_size: "📏 Unexpected response size",
+  config: "⚙️ Configuration error",
+  agents: "🗺️ Down from some regions",
+  other: "⚠️ Check failed",
+};
+
+// Describe why the last check failed, empty if it succeeded
+function describeErrorCode(code) {
+  if (!code) return "";
+  return errorCodeLabels[code] || errorCodeLabels.other;
+}
+
 // Select a website
 function selectWebsite(websiteId) {
   selectedWebsiteId = websiteId;
@@ -184,6 +234,9 @@ function selectWebsite(websiteId) {
   document.getElementById("currentStatus").className = `status-badge ${
     website.status || "unknown"
   }`;
+  const lastError = document.getElementById("lastError");
+  lastError.textContent = describeErrorCode(website.last_error_code);
+  lastError.title = website.last_error || "";
   document.getElementById("currentResponse").textContent = `${
     website.last_response_time_ms || 0
   } ms`;
@@ -239,6 +292,8 @@ function updateStatusBar(website) {
               ? "up"
               : entry.status === "down"
               ? "down"
+              : entry.status === "degraded"
+              ? "degraded"
               : "unknown"
           }"></div>`
       )
@@ -256,7 +311,7 @@ function updateStatusBar(website) {
 // Load website history and update chart
 async function loadWebsiteHistory(websiteId) {
   try {
-    const response = await fetch(
+    const response = await apiFetch(
       `${API_BASE}/websites/${websiteId}/history?hours=24`
     );
     if (response.ok) {
@@ -410,7 +465,7 @@ async function addMonitor() {
   };
 
   try {
-    const response = await fetch(`${API_BASE}/websites`, {
+    const response = await apiFetch(`${API_BASE}/websites`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -422,6 +477,14 @@ async function addMonitor() {
       hideAddMonitorModal();
       showNotification("Monitor added successfully", "success");
       loadWebsites();
+    } else if (response.status === 409) {
+      // The URL is already monitored: show the existing monitor instead
+      const error = await response.json();
+      hideAddMonitorModal();
+      showNotification(error.error, "error");
+      if (error.existing_id) {
+        selectWebsite(error.existing_id);
+      }
     } else {
       const error = await response.json();
       showNotification(error.error || "Failed to add monitor", "error");
@@ -491,7 +554,7 @@ async function updateMonitor() {
   };
 
   try {
-    const response = await fetch(`${API_BASE}/websites/${selectedWebsiteId}`, {
+    const response = await apiFetch(`${API_BASE}/websites/${selectedWebsiteId}`, {
       method: "PUT",
       headers: {
         "Content-Type": "application/json",
@@ -522,22 +585,10 @@ function pauseWebsite() {
 
   // Toggle enabled state
   const newEnabledState = !website.enabled;
+  const action = newEnabledState ? "resume" : "pause";
 
-  const data = {
-    name: website.name,
-    url: website.url,
-    interval_seconds: website.interval_seconds,
-    notification_emails: website.notification_emails || [],
-    slack_webhook: website.slack_webhook || "",
-    enabled: newEnabledState,
-  };
-
-  fetch(`${API_BASE}/websites/${selectedWebsiteId}`, {
-    method: "PUT",
-    headers: {
-      "Content-Type": "application/json",
-    },
-    body: JSON.stringify(data),
+  apiFetch(`${API_BASE}/websites/${selectedWebsiteId}/${action}`, {
+    method: "POST",
   })
     .then((response) => {
       if (response.ok) {
@@ -571,7 +622,7 @@ function deleteWebsite() {
     return;
   }
 
-  fetch(`${API_BASE}/websites/${selectedWebsiteId}`, {
+  apiFetch(`${API_BASE}/websites/${selectedWebsiteId}`, {
     method: "DELETE",
   })
     .then((response) => {
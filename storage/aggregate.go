@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// HistoryBucket summarizes the history entries of one time bucket
+type HistoryBucket struct {
+	Start           time.Time `json:"start"`
+	Checks          int       `json:"checks"`
+	Uptime          float64   `json:"uptime_percent"`
+	AvgResponseTime float64   `json:"avg_response_time_ms"`
+	MinResponseTime int       `json:"min_response_time_ms"`
+	MaxResponseTime int       `json:"max_response_time_ms"`
+}
+
+// bucketStart returns the start of the bucket containing t. Buckets are
+// aligned to the Unix epoch so every backend agrees on their boundaries.
+func bucketStart(t time.Time, bucket time.Duration) time.Time {
+	return time.Unix(0, t.UnixNano()/int64(bucket)*int64(bucket))
+}
+
+// bucketCounts accumulates one bucket in aggregateHistory
+type bucketCounts struct {
+	HistoryBucket
+	up, total     int
+	responseTotal int
+	responses     int
+}
+
+// aggregateHistory groups chronological history entries into buckets with the
+// uptime rules of calculateUptime and the response time rules of
+// averageResponseTime. Buckets without entries are left out.
+func aggregateHistory(history []HistoryEntry, bucket time.Duration, countUnknownAsDown, countDegradedAsDown bool) []HistoryBucket {
+	var buckets []*bucketCounts
+	checked := false
+
+	for _, entry := range history {
+		start := bucketStart(entry.Timestamp, bucket)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, &bucketCounts{HistoryBucket: HistoryBucket{Start: start}})
+		}
+		current := buckets[len(buckets)-1]
+		current.Checks++
+
+		switch entry.Status {
+		case monitor.StatusUnknown:
+			if checked && countUnknownAsDown {
+				current.total++
+			}
+		case monitor.StatusError:
+		case monitor.StatusUp:
+			checked = true
+			current.up++
+			current.total++
+		case monitor.StatusDegraded:
+			checked = true
+			if !countDegradedAsDown {
+				current.up++
+			}
+			current.total++
+		default:
+			checked = true
+			current.total++
+		}
+
+		if (entry.Status == monitor.StatusUp || entry.Status == monitor.StatusDegraded) && entry.ResponseTime > 0 {
+			if current.responses == 0 || entry.ResponseTime < current.MinResponseTime {
+				current.MinResponseTime = entry.ResponseTime
+			}
+			if entry.ResponseTime > current.MaxResponseTime {
+				current.MaxResponseTime = entry.ResponseTime
+			}
+			current.responseTotal += entry.ResponseTime
+			current.responses++
+		}
+	}
+
+	result := make([]HistoryBucket, 0, len(buckets))
+	for _, current := range buckets {
+		current.Uptime = 100.0
+		if current.total > 0 {
+			current.Uptime = float64(current.up) / float64(current.total) * 100.0
+		}
+		if current.responses > 0 {
+			current.AvgResponseTime = float64(current.responseTotal) / float64(current.responses)
+		}
+		result = append(result, current.HistoryBucket)
+	}
+	return result
+}
+
+// GetAggregatedHistory groups the last hours of a website's history into
+// buckets of bucketMinutes with per-bucket uptime and response times
+func (s *JSONStorage) GetAggregatedHistory(websiteID string, hours int, bucketMinutes int) ([]HistoryBucket, error) {
+	if bucketMinutes < 1 {
+		return nil, fmt.Errorf("bucket must be at least one minute")
+	}
+
+	history, err := s.GetRecentHistory(websiteID, hours)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := time.Duration(bucketMinutes) * time.Minute
+	return aggregateHistory(s.filterActive(websiteID, history), bucket, s.unknownAsDown(), s.degradedAsDown()), nil
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// backupPrefix and backupExt make up the name of a backup archive around its
+// creation time
+const (
+	backupPrefix = "uptime-backup-"
+	backupExt    = ".tar.gz"
+)
+
+// BackupInfo describes a backup archive
+type BackupInfo struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size_bytes"`
+	Files     int       `json:"files"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backup archives websites.json, the history files and the notification logs
+// into a timestamped tarball in destDir. Writes are held off while the files
+// are archived, so the backup is a consistent snapshot.
+func (s *JSONStorage) Backup(destDir string) (BackupInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries, err := ioutil.ReadDir(s.dataDir)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to read data directory: %v", err)
+	}
+	var websiteIDs []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		id := backupWebsiteID(entry.Name())
+		if id != "" && !seen[id] {
+			seen[id] = true
+			websiteIDs = append(websiteIDs, id)
+		}
+	}
+	sort.Strings(websiteIDs)
+
+	// Files are looked up once locked, as a history file may have been
+	// converted to the other compression format in the meantime
+	paths := []string{s.websitesFile}
+	for _, id := range websiteIDs {
+		files := s.filesFor(id)
+		files.RLock()
+		defer files.RUnlock()
+		paths = append(paths, s.historyFile(id), s.notificationLogFile(id))
+	}
+
+	return writeBackup(destDir, func(archive *tar.Writer) (int, error) {
+		count := 0
+		for _, path := range paths {
+			added, err := addBackupFile(archive, path, filepath.Base(path))
+			if err != nil {
+				return count, err
+			}
+			if added {
+				count++
+			}
+		}
+		return count, nil
+	})
+}
+
+// backupWebsiteID returns the ID of the website a file in the data directory
+// belongs to, or "" for files that aren't backed up per website
+func backupWebsiteID(name string) string {
+	for _, suffix := range []string{".jsonl" + compressedHistoryExt, ".jsonl"} {
+		if strings.HasPrefix(name, "history_") && strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(strings.TrimPrefix(name, "history_"), suffix)
+		}
+	}
+	if strings.HasPrefix(name, "notifications_") && strings.HasSuffix(name, ".json") {
+		return strings.TrimSuffix(strings.TrimPrefix(name, "notifications_"), ".json")
+	}
+	return ""
+}
+
+// Backup copies the database into a timestamped tarball in destDir. The copy
+// is taken with VACUUM INTO, which reads a consistent snapshot without
+// holding off writes.
+func (s *SQLiteStorage) Backup(destDir string) (BackupInfo, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	snapshot := filepath.Join(destDir, ".uptime.db.tmp")
+	os.Remove(snapshot)
+	defer os.Remove(snapshot)
+	if _, err := s.db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to copy database: %v", err)
+	}
+
+	return writeBackup(destDir, func(archive *tar.Writer) (int, error) {
+		if _, err := addBackupFile(archive, snapshot, "uptime.db"); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
+}
+
+// writeBackup writes a backup archive to destDir, adding its files with add.
+// The archive is written to a temporary file first, so a crash never leaves
+// a half-written backup under a backup's name.
+func writeBackup(destDir string, add func(archive *tar.Writer) (int, error)) (BackupInfo, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(destDir, backupPrefix+now.Format("20060102-150405")+backupExt)
+	tempFile := path + ".tmp"
+	file, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup: %v", err)
+	}
+
+	compressor := gzip.NewWriter(file)
+	archive := tar.NewWriter(compressor)
+	count, err := add(archive)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile, path)
+	}
+	if err != nil {
+		os.Remove(tempFile)
+		return BackupInfo{}, fmt.Errorf("failed to write backup: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to read backup: %v", err)
+	}
+	return BackupInfo{Path: path, Size: info.Size(), Files: count, CreatedAt: now}, nil
+}
+
+// addBackupFile adds the file at path to a backup archive under name. A file
+// that doesn't exist is skipped, reporting false.
+func addBackupFile(archive *tar.Writer, path, name string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return false, err
+	}
+	header.Name = name
+	if err := archive.WriteHeader(header); err != nil {
+		return false, err
+	}
+	if _, err := io.CopyN(archive, file, info.Size()); err != nil {
+		return false, fmt.Errorf("failed to archive %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// PruneBackups deletes all but the newest keep backup archives in dir. keep
+// of 0 or less keeps them all.
+func PruneBackups(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	// Names sort by creation time
+	backups, _ := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupExt))
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("Failed to delete old backup %s: %v", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
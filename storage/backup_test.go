@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"uptime-monitor/monitor"
+)
+
+// archivedFiles returns the names of the files in a backup archive
+func archivedFiles(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(decompressor)
+	var names []string
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestBackup(t *testing.T) {
+	tests := []struct {
+		backend   string
+		wantFiles []string
+	}{
+		{backend: BackendJSON, wantFiles: []string{"history_site.jsonl", "websites.json"}},
+		{backend: BackendSQLite, wantFiles: []string{"uptime.db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			s, err := NewStorage(tt.backend, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			website := &monitor.Website{ID: "site", Name: "Site", URL: "https://site.example.com", Enabled: true}
+			if err := s.SaveWebsites(map[string]*monitor.Website{"site": website}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveHistory("site", sampleEntries(1)[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			destDir := filepath.Join(t.TempDir(), "backups")
+			info, err := s.Backup(destDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := archivedFiles(t, info.Path); !reflect.DeepEqual(names, tt.wantFiles) {
+				t.Errorf("archived %v, want %v", names, tt.wantFiles)
+			}
+			if info.Files != len(tt.wantFiles) || info.Size == 0 {
+				t.Errorf("info = %+v, want %d files", info, len(tt.wantFiles))
+			}
+			// Only the archive is left in the backup directory
+			if entries, _ := ioutil.ReadDir(destDir); len(entries) != 1 {
+				t.Errorf("backup directory holds %d files, want the archive", len(entries))
+			}
+		})
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"notes.txt",
+		"uptime-backup-20240101-000000.tar.gz",
+		"uptime-backup-20240102-000000.tar.gz",
+		"uptime-backup-20240103-000000.tar.gz",
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	PruneBackups(dir, 0)
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != len(names) {
+		t.Errorf("keep 0 left %d files, want all %d", len(entries), len(names))
+	}
+
+	PruneBackups(dir, 2)
+	var left []string
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	// Other files are left alone
+	if want := []string{names[0], names[2], names[3]}; !reflect.DeepEqual(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compressedHistoryExt ends the name of a gzip-compressed history file
+const compressedHistoryExt = ".gz"
+
+// compressedAppendBatch is after how many appends a compressed history file
+// is rewritten as a single gzip stream. Each append adds a gzip member of its
+// own, which barely compresses a single entry.
+const compressedAppendBatch = 100
+
+// SetCompressHistory sets whether history files are written gzip-compressed,
+// as history_<id>.jsonl.gz. Files in either format are read, and a file in
+// the other format is converted on the next write to it.
+func (s *JSONStorage) SetCompressHistory(compress bool) {
+	s.compressMutex.Lock()
+	defer s.compressMutex.Unlock()
+	s.compressHistory = compress
+}
+
+// newHistoryFile returns the path of a website's history file in the
+// configured format
+func (s *JSONStorage) newHistoryFile(websiteID string) string {
+	s.compressMutex.RLock()
+	defer s.compressMutex.RUnlock()
+
+	historyFile := filepath.Join(s.dataDir, fmt.Sprintf("history_%s.jsonl", websiteID))
+	if s.compressHistory {
+		historyFile += compressedHistoryExt
+	}
+	return historyFile
+}
+
+// historyFile returns the path of a website's history file, stored as JSON
+// lines, gzip-compressed or not. The file in the configured format is
+// preferred; without either, the file to create is returned.
+func (s *JSONStorage) historyFile(websiteID string) string {
+	historyFile := s.newHistoryFile(websiteID)
+	if fileExists(historyFile) {
+		return historyFile
+	}
+	if other := otherHistoryFile(historyFile); fileExists(other) {
+		return other
+	}
+	return historyFile
+}
+
+// otherHistoryFile returns the path of a history file in the other format
+func otherHistoryFile(historyFile string) string {
+	if isCompressed(historyFile) {
+		return strings.TrimSuffix(historyFile, compressedHistoryExt)
+	}
+	return historyFile + compressedHistoryExt
+}
+
+// isCompressed reports whether a history file is gzip-compressed
+func isCompressed(historyFile string) bool {
+	return strings.HasSuffix(historyFile, compressedHistoryExt)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// readHistoryData returns the content of a history file, decompressed. A
+// compressed file yields what could be decompressed when it is cut short by
+// a crash mid-append, with torn set, or otherwise damaged, with damaged set.
+func readHistoryData(historyFile string) (data []byte, torn, damaged bool, err error) {
+	compressed, err := ioutil.ReadFile(historyFile)
+	if err != nil || !isCompressed(historyFile) || len(compressed) == 0 {
+		return compressed, false, false, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err == io.ErrUnexpectedEOF {
+		return nil, true, false, nil
+	}
+	if err != nil {
+		return nil, false, true, nil
+	}
+	data, err = ioutil.ReadAll(reader)
+	switch err {
+	case nil:
+		return data, false, false, nil
+	case io.ErrUnexpectedEOF:
+		return data, true, false, nil
+	default:
+		return data, false, true, nil
+	}
+}
+
+// openHistory opens a history file for reading, decompressing it if needed
+func openHistory(historyFile string) (io.ReadCloser, error) {
+	file, err := os.Open(historyFile)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompressed(historyFile) {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err == io.EOF {
+		// An empty file has no entries
+		return ioutil.NopCloser(bytes.NewReader(nil)), file.Close()
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress history file: %v", err)
+	}
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+// gzipFile decompresses an open file and closes it
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+// encodeHistoryData returns history file content as it is written to
+// historyFile, compressing it if needed
+func encodeHistoryData(historyFile string, data []byte) ([]byte, error) {
+	if !isCompressed(historyFile) {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sampleEntries returns n history entries of the last n minutes, as a
+// website's checks would record them
+func sampleEntries(n int) []HistoryEntry {
+	start := time.Now().Add(-time.Duration(n) * time.Minute)
+	entries := make([]HistoryEntry, n)
+	for i := range entries {
+		entries[i] = HistoryEntry{
+			Timestamp:    start.Add(time.Duration(i) * time.Minute),
+			Status:       "up",
+			ResponseTime: 100 + i%50,
+			StatusCode:   200,
+			Protocol:     "HTTP/1.1",
+			RemoteIP:     "192.0.2.10",
+		}
+		if i%25 == 0 {
+			entries[i].Status = "down"
+			entries[i].StatusCode = 503
+			entries[i].Error = "unexpected status code 503"
+			entries[i].ErrorCode = "http_status"
+		}
+	}
+	return entries
+}
+
+// writeHistory saves entries through a new JSON storage in dir and returns
+// the storage
+func writeHistory(t *testing.T, dir string, compress bool, entries []HistoryEntry) *JSONStorage {
+	t.Helper()
+	s, err := NewJSONStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCompressHistory(compress)
+	for _, entry := range entries {
+		if err := s.SaveHistory("site", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestCompressedHistorySizeAndContent(t *testing.T) {
+	// Past a few compaction batches, with appended members left over
+	const n = 2*compressedAppendBatch + 37
+	entries := sampleEntries(n)
+
+	plainDir, compressedDir := t.TempDir(), t.TempDir()
+	plain := writeHistory(t, plainDir, false, entries)
+	compressed := writeHistory(t, compressedDir, true, entries)
+
+	plainSize := fileSize(t, filepath.Join(plainDir, "history_site.jsonl"))
+	compressedSize := fileSize(t, filepath.Join(compressedDir, "history_site.jsonl.gz"))
+	t.Logf("%d entries: %d bytes plain, %d bytes compressed", n, plainSize, compressedSize)
+	if compressedSize >= plainSize/2 {
+		t.Errorf("compressed history is %d bytes, want well under the %d bytes of plain history", compressedSize, plainSize)
+	}
+
+	plainHistory, err := plain.GetRecentHistory("site", 24*30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressedHistory, err := compressed.GetRecentHistory("site", 24*30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressedHistory) != n {
+		t.Fatalf("read back %d compressed entries, want %d", len(compressedHistory), n)
+	}
+	if !reflect.DeepEqual(plainHistory, compressedHistory) {
+		t.Error("compressed history reads back differently from plain history")
+	}
+	for i, entry := range compressedHistory {
+		if !entry.Timestamp.Equal(entries[i].Timestamp) || entry.Status != entries[i].Status || entry.Error != entries[i].Error {
+			t.Fatalf("entry %d = %+v, want %+v", i, entry, entries[i])
+		}
+	}
+}
+
+func TestHistoryConvertedToConfiguredFormat(t *testing.T) {
+	dir := t.TempDir()
+	entries := sampleEntries(10)
+	writeHistory(t, dir, false, entries[:5])
+
+	// Restarted with compression on, the plain file is converted on the next write
+	s := writeHistory(t, dir, true, entries[5:])
+	if fileExists(filepath.Join(dir, "history_site.jsonl")) {
+		t.Error("plain history file left behind after conversion")
+	}
+	history, err := s.GetRecentHistory("site", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(entries) {
+		t.Fatalf("read back %d entries, want %d", len(history), len(entries))
+	}
+	for i, entry := range history {
+		if !entry.Timestamp.Equal(entries[i].Timestamp) {
+			t.Errorf("entry %d at %v, want %v", i, entry.Timestamp, entries[i].Timestamp)
+		}
+	}
+}
+
+func BenchmarkSaveHistoryCompressed(b *testing.B) {
+	for _, compress := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compress=%t", compress), func(b *testing.B) {
+			s, err := NewJSONStorage(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			s.SetCompressHistory(compress)
+			entry := sampleEntries(1)[0]
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.SaveHistory("site", entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
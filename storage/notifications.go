@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// maxNotificationLogEntries is how many notification attempts are kept per website
+const maxNotificationLogEntries = 500
+
+// NotificationLogEntry records one notification attempt through one channel
+type NotificationLogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Channel   string    `json:"channel"`
+	EventType string    `json:"event_type"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// notificationLogFile returns the path of a website's notification log
+func (s *JSONStorage) notificationLogFile(websiteID string) string {
+	return filepath.Join(s.dataDir, fmt.Sprintf("notifications_%s.json", websiteID))
+}
+
+// SaveNotificationLog appends a notification attempt to a website's log
+func (s *JSONStorage) SaveNotificationLog(websiteID string, entry NotificationLogEntry) error {
+	files := s.filesFor(websiteID)
+	files.Lock()
+	defer files.Unlock()
+
+	logFile := s.notificationLogFile(websiteID)
+
+	var entries []NotificationLogEntry
+	if data, err := ioutil.ReadFile(logFile); err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			// Start a fresh log, keeping the damaged one for inspection
+			backup := corruptBackupFile(logFile)
+			if err := os.Rename(logFile, backup); err != nil {
+				return fmt.Errorf("failed to back up damaged notification log: %v", err)
+			}
+			logger.Warnf("Notification log %s is unreadable (%v), original saved as %s", logFile, err, backup)
+			entries = nil
+		}
+	}
+
+	entries = append(entries, entry)
+	if len(entries) > maxNotificationLogEntries {
+		entries = entries[len(entries)-maxNotificationLogEntries:]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification log: %v", err)
+	}
+
+	if err := writeFileAtomic(logFile, data); err != nil {
+		return fmt.Errorf("failed to write notification log: %v", err)
+	}
+
+	return nil
+}
+
+// LoadNotificationLog returns a website's notification attempts, oldest first
+func (s *JSONStorage) LoadNotificationLog(websiteID string) ([]NotificationLogEntry, error) {
+	files := s.filesFor(websiteID)
+	files.RLock()
+	defer files.RUnlock()
+
+	data, err := ioutil.ReadFile(s.notificationLogFile(websiteID))
+	if os.IsNotExist(err) {
+		return []NotificationLogEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification log: %v", err)
+	}
+
+	var entries []NotificationLogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// The damaged log is replaced on the next notification
+		logger.Warnf("Ignoring unreadable notification log for %s: %v", websiteID, err)
+		return []NotificationLogEntry{}, nil
+	}
+
+	return entries, nil
+}
+
+// DeleteNotificationLog removes a website's notification log
+func (s *JSONStorage) DeleteNotificationLog(websiteID string) error {
+	files := s.filesFor(websiteID)
+	files.Lock()
+	defer files.Unlock()
+
+	if err := os.Remove(s.notificationLogFile(websiteID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete notification log: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// Outage is a period during which a website was down
+type Outage struct {
+	Start time.Time `json:"start"`
+	// End is the time of the first check that found the website back up, nil
+	// while the outage is ongoing
+	End *time.Time `json:"end"`
+	// DurationSeconds runs until now for an ongoing outage
+	DurationSeconds int64 `json:"duration_seconds"`
+	Ongoing         bool  `json:"ongoing"`
+	// Checks is the number of checks that found the website down
+	Checks int `json:"checks"`
+}
+
+// findOutages detects the outages in chronological history entries. An
+// outage starts at the first "down" entry after the website was up and ends
+// at the next "up" entry; "degraded" entries count as up unless
+// countDegradedAsDown is set. "unknown" and "error" entries neither start nor
+// end an outage. An outage already in progress at the first entry starts
+// there, and one still in progress at the last entry is ongoing until now.
+func findOutages(history []HistoryEntry, now time.Time, countDegradedAsDown bool) []Outage {
+	outages := []Outage{}
+	var current *Outage
+
+	for _, entry := range history {
+		down := false
+		switch entry.Status {
+		case monitor.StatusDown:
+			down = true
+		case monitor.StatusDegraded:
+			down = countDegradedAsDown
+		case monitor.StatusUp:
+		default:
+			continue
+		}
+
+		if down {
+			if current == nil {
+				current = &Outage{Start: entry.Timestamp}
+			}
+			current.Checks++
+			continue
+		}
+
+		if current != nil {
+			end := entry.Timestamp
+			current.End = &end
+			current.DurationSeconds = int64(end.Sub(current.Start) / time.Second)
+			outages = append(outages, *current)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		current.Ongoing = true
+		current.DurationSeconds = int64(now.Sub(current.Start) / time.Second)
+		outages = append(outages, *current)
+	}
+	return outages
+}
+
+// GetOutages returns the outages of a website over the last days, oldest first
+func (s *JSONStorage) GetOutages(websiteID string, days int) ([]Outage, error) {
+	history, err := s.GetRecentHistory(websiteID, days*24)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOutages(history, time.Now(), s.degradedAsDown()), nil
+}
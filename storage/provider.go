@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+	"uptime-monitor/monitor"
+)
+
+// Storage backends selectable with NewStorage
+const (
+	BackendJSON   = "json"   // One JSON file per website history, the default
+	BackendSQLite = "sqlite" // A single SQLite database in the data directory
+)
+
+// StorageProvider is implemented by every storage backend
+type StorageProvider interface {
+	// Backend returns the name of the backend, e.g. "json"
+	Backend() string
+	// DataDir returns the directory the storage writes to
+	DataDir() string
+
+	SetCountUnknownAsDown(countAsDown bool)
+	SetCountDegradedAsDown(countAsDown bool)
+	SetActivityFilter(filter func(websiteID string) func(t time.Time) bool)
+	SetStatsCacheTTL(ttl time.Duration)
+	SetHistoryRetention(maxEntries int, maxAge time.Duration)
+	SetCompressHistory(compress bool)
+
+	SaveWebsites(websites map[string]*monitor.Website) error
+	LoadWebsites() (map[string]*monitor.Website, error)
+
+	SaveHistory(websiteID string, entry HistoryEntry) error
+	LoadHistory(websiteID string) ([]HistoryEntry, error)
+	GetRecentHistory(websiteID string, hours int) ([]HistoryEntry, error)
+	GetHistorySince(websiteID string, cutoff time.Time) ([]HistoryEntry, error)
+	GetAggregatedHistory(websiteID string, hours int, bucketMinutes int) ([]HistoryBucket, error)
+	GetOutages(websiteID string, days int) ([]Outage, error)
+	StreamHistory(websiteID string, cutoff time.Time, fn func(entry HistoryEntry) error) error
+	DeleteWebsiteHistory(websiteID string) error
+	CleanupOldHistory(existingWebsiteIDs map[string]bool) error
+
+	// Backup writes a timestamped archive of the stored data to destDir
+	Backup(destDir string) (BackupInfo, error)
+
+	CalculateUptime(websiteID string, hours int) (float64, error)
+	CalculateUptimeWindow(websiteID string, window time.Duration) (float64, error)
+	CalculateUptimeWeighted(websiteID string, hours int) (float64, error)
+	GetAverageResponseTime(websiteID string, hours int) (float64, error)
+	GetResponseTimeStats(websiteID string, hours int) (ResponseTimeStats, error)
+	GetUptimeStats(websiteID string) (UptimeStats, error)
+
+	SaveNotificationLog(websiteID string, entry NotificationLogEntry) error
+	LoadNotificationLog(websiteID string) ([]NotificationLogEntry, error)
+	DeleteNotificationLog(websiteID string) error
+
+	// Close releases the resources held by the backend
+	Close() error
+}
+
+// NewStorage creates the storage backend with the given name, keeping its data in dataDir.
+// An empty backend selects JSON files.
+func NewStorage(backend, dataDir string) (StorageProvider, error) {
+	switch backend {
+	case "", BackendJSON:
+		s, err := NewJSONStorage(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendSQLite:
+		s, err := NewSQLiteStorage(dataDir)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
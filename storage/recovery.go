@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+	"uptime-monitor/logger"
+)
+
+// writeFileAtomic replaces path with data. The data is written to a temporary
+// file and synced to disk before being renamed over path, so a crash leaves
+// either the old or the new content, never a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	file, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
+
+// corruptBackupFile returns the name a damaged file is preserved under
+func corruptBackupFile(path string) string {
+	return fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+}
+
+// recoverHistoryFile rewrites a history file that has unreadable lines with
+// the entries that could be read, after saving a copy of the damaged file
+func recoverHistoryFile(historyFile string, history []HistoryEntry, skipped int) error {
+	data, err := ioutil.ReadFile(historyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %v", err)
+	}
+	backup := corruptBackupFile(historyFile)
+	if err := ioutil.WriteFile(backup, data, 0644); err != nil {
+		return fmt.Errorf("failed to back up damaged history file: %v", err)
+	}
+
+	if err := writeHistoryFile(historyFile, history); err != nil {
+		return err
+	}
+	logger.Warnf("Recovered history file %s: skipped %d unreadable lines and kept %d entries, original saved as %s",
+		historyFile, skipped, len(history), backup)
+	return nil
+}
+
+// decodeLegacyHistory reads a legacy history file holding a JSON array. A
+// file cut short, e.g. by a crash mid-write, yields the entries before the
+// damage along with the decoding error.
+func decodeLegacyHistory(data []byte) ([]HistoryEntry, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("not a JSON array")
+	}
+
+	history := []HistoryEntry{}
+	for decoder.More() {
+		var entry HistoryEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return history, err
+		}
+		history = append(history, entry)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return history, err
+	}
+	return history, nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptBackups returns the damaged copies kept of path
+func corruptBackups(t *testing.T, path string) []string {
+	t.Helper()
+	backups, err := filepath.Glob(path + ".corrupt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backups
+}
+
+func TestHistoryRecovery(t *testing.T) {
+	tests := []struct {
+		name       string
+		damage     string // Written after the first two entries
+		wantBackup bool
+	}{
+		{name: "torn last line", damage: `{"timestamp":"2024-01-`},
+		{name: "damaged line", damage: "garbage\n", wantBackup: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			entries := sampleEntries(3)
+			writeHistory(t, dir, false, entries[:2])
+
+			historyFile := filepath.Join(dir, "history_site.jsonl")
+			file, err := os.OpenFile(historyFile, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.WriteString(tt.damage)
+			file.Close()
+
+			// A restarted storage appends on a line of its own
+			s := writeHistory(t, dir, false, entries[2:])
+			history, err := s.LoadHistory("site")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 3 || !history[2].Timestamp.Equal(entries[2].Timestamp) {
+				t.Errorf("loaded %d entries, want the 3 readable ones", len(history))
+			}
+			if backups := corruptBackups(t, historyFile); (len(backups) > 0) != tt.wantBackup {
+				t.Errorf("backups = %v, want one: %v", backups, tt.wantBackup)
+			}
+		})
+	}
+}
+
+func TestLegacyHistoryRecovery(t *testing.T) {
+	dir := t.TempDir()
+	entries := sampleEntries(2)
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut short inside a third entry, as by a crash mid-write
+	legacyFile := filepath.Join(dir, "history_site.json")
+	data = append(data[:len(data)-1], `,{"timestamp":`...)
+	if err := ioutil.WriteFile(legacyFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewJSONStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := s.LoadHistory("site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("recovered %d entries, want the 2 before the damage", len(history))
+	}
+	if _, err := os.Stat(legacyFile); !os.IsNotExist(err) {
+		t.Errorf("damaged legacy file still in place: %v", err)
+	}
+	if backups := corruptBackups(t, legacyFile); len(backups) != 1 {
+		t.Errorf("backups = %v, want the damaged file", backups)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTempFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "websites.json")
+	for _, content := range []string{"old", "new"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "new" {
+		t.Errorf("content = %q, want the last write", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
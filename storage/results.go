@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"uptime-monitor/monitor"
+)
+
+// NewHistoryEntry returns the history entry recording a check result
+func NewHistoryEntry(result monitor.CheckResult) HistoryEntry {
+	entry := HistoryEntry{
+		Timestamp:     result.Timestamp,
+		Status:        result.Status,
+		ResponseTime:  result.ResponseTime,
+		Maintenance:   result.Maintenance,
+		StatusCode:    result.StatusCode,
+		ErrorCode:     result.ErrorCode,
+		TLSUnverified: result.TLSUnverified,
+		Protocol:      result.Protocol,
+		Agent:         result.Agent,
+		ResponseBytes: result.ResponseBytes,
+		RemoteIP:      result.RemoteIP,
+		Timings:       result.Timings,
+		SubChecks:     result.SubChecks,
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+	return entry
+}
+
+// CheckResult returns the check result a history entry of a website records,
+// as far as the entry holds it
+func (e HistoryEntry) CheckResult(websiteID string) monitor.CheckResult {
+	result := monitor.CheckResult{
+		WebsiteID:     websiteID,
+		Timestamp:     e.Timestamp,
+		Status:        e.Status,
+		ResponseTime:  e.ResponseTime,
+		Maintenance:   e.Maintenance,
+		StatusCode:    e.StatusCode,
+		ErrorCode:     e.ErrorCode,
+		TLSUnverified: e.TLSUnverified,
+		Protocol:      e.Protocol,
+		Agent:         e.Agent,
+		ResponseBytes: e.ResponseBytes,
+		RemoteIP:      e.RemoteIP,
+		Timings:       e.Timings,
+		SubChecks:     e.SubChecks,
+	}
+	if e.Error != "" {
+		result.Error = errors.New(e.Error)
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxHistoryEntries is how many history entries are kept per website by default
+const DefaultMaxHistoryEntries = 1000
+
+// retentionOptions holds the history retention policy shared by the storage backends
+type retentionOptions struct {
+	retentionMutex sync.RWMutex
+
+	// maxEntries caps the number of entries kept per website, 0 keeps all
+	maxEntries int
+	// maxAge drops entries older than this, 0 keeps them regardless of age
+	maxAge time.Duration
+}
+
+// newRetentionOptions creates a retention policy keeping DefaultMaxHistoryEntries per website
+func newRetentionOptions() retentionOptions {
+	return retentionOptions{maxEntries: DefaultMaxHistoryEntries}
+}
+
+// SetHistoryRetention sets how much history is kept per website: at most
+// maxEntries entries (0 for no limit), none older than maxAge (0 for no limit)
+func (r *retentionOptions) SetHistoryRetention(maxEntries int, maxAge time.Duration) {
+	r.retentionMutex.Lock()
+	defer r.retentionMutex.Unlock()
+
+	if maxEntries < 0 {
+		maxEntries = 0
+	}
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	r.maxEntries = maxEntries
+	r.maxAge = maxAge
+}
+
+// historyRetention returns the entry cap and maximum age of history
+func (r *retentionOptions) historyRetention() (int, time.Duration) {
+	r.retentionMutex.RLock()
+	defer r.retentionMutex.RUnlock()
+	return r.maxEntries, r.maxAge
+}
+
+// applyRetention drops the entries of a chronological history that the
+// retention policy no longer keeps
+func (r *retentionOptions) applyRetention(history []HistoryEntry) []HistoryEntry {
+	maxEntries, maxAge := r.historyRetention()
+
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge)
+		kept := len(history)
+		for i, entry := range history {
+			if entry.Timestamp.After(cutoff) {
+				kept = i
+				break
+			}
+		}
+		history = history[kept:]
+	}
+
+	if maxEntries > 0 && len(history) > maxEntries {
+		history = history[len(history)-maxEntries:]
+	}
+
+	return history
+}
@@ -0,0 +1,753 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"uptime-monitor/logger"
+	"uptime-monitor/monitor"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlitePruneInterval is how often history past the retention policy is deleted
+const sqlitePruneInterval = time.Hour
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS websites (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS history (
+	id             INTEGER PRIMARY KEY,
+	website_id     TEXT NOT NULL,
+	timestamp      INTEGER NOT NULL,
+	status         TEXT NOT NULL,
+	response_time  INTEGER NOT NULL,
+	maintenance    INTEGER NOT NULL DEFAULT 0,
+	status_code    INTEGER NOT NULL DEFAULT 0,
+	error          TEXT NOT NULL DEFAULT '',
+	error_code     TEXT NOT NULL DEFAULT '',
+	tls_unverified INTEGER NOT NULL DEFAULT 0,
+	protocol       TEXT NOT NULL DEFAULT '',
+	agent          TEXT NOT NULL DEFAULT '',
+	response_bytes INTEGER,
+	remote_ip      TEXT NOT NULL DEFAULT '',
+	timings        TEXT NOT NULL DEFAULT '',
+	sub_checks     TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS history_website_time ON history (website_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS notification_log (
+	id         INTEGER PRIMARY KEY,
+	website_id TEXT NOT NULL,
+	timestamp  INTEGER NOT NULL,
+	channel    TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	old_status TEXT NOT NULL,
+	new_status TEXT NOT NULL,
+	success    INTEGER NOT NULL,
+	error      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notification_log_website ON notification_log (website_id, id);
+`
+
+// SQLiteStorage keeps websites, history and notification logs in a single
+// SQLite database, computing uptime and response time with SQL aggregations
+type SQLiteStorage struct {
+	dataDir string
+	db      *sql.DB
+
+	pruneMutex sync.Mutex
+	lastPrune  time.Time
+
+	uptimeOptions
+	retentionOptions
+	statsCache
+}
+
+// NewSQLiteStorage opens (creating if needed) the SQLite database in dataDir
+func NewSQLiteStorage(dataDir string) (*SQLiteStorage, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	dsn := "file:" + filepath.Join(dataDir, "uptime.db") + "?_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	// SQLite allows a single writer; one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create database schema: %v", err)
+	}
+
+	// Databases created by older versions lack the later history columns
+	for _, column := range []struct{ name, definition string }{
+		{"maintenance", "INTEGER NOT NULL DEFAULT 0"},
+		{"status_code", "INTEGER NOT NULL DEFAULT 0"},
+		{"error", "TEXT NOT NULL DEFAULT ''"},
+		{"error_code", "TEXT NOT NULL DEFAULT ''"},
+		{"tls_unverified", "INTEGER NOT NULL DEFAULT 0"},
+		{"protocol", "TEXT NOT NULL DEFAULT ''"},
+		{"agent", "TEXT NOT NULL DEFAULT ''"},
+		{"response_bytes", "INTEGER"},
+		{"remote_ip", "TEXT NOT NULL DEFAULT ''"},
+		{"timings", "TEXT NOT NULL DEFAULT ''"},
+		{"sub_checks", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if err := addColumnIfMissing(db, "history", column.name, column.definition); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &SQLiteStorage{
+		dataDir:          dataDir,
+		db:               db,
+		retentionOptions: newRetentionOptions(),
+		statsCache:       newStatsCache(),
+	}, nil
+}
+
+// addColumnIfMissing adds a column to a table created by an older schema
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count); err != nil {
+		return fmt.Errorf("failed to inspect database schema: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %v", err)
+	}
+	return nil
+}
+
+// Backend returns the storage backend name
+func (s *SQLiteStorage) Backend() string {
+	return BackendSQLite
+}
+
+// DataDir returns the directory the database is kept in
+func (s *SQLiteStorage) DataDir() string {
+	return s.dataDir
+}
+
+// SetCompressHistory does nothing: history lives in the database, which
+// compression of history files doesn't apply to
+func (s *SQLiteStorage) SetCompressHistory(compress bool) {}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// SaveWebsites replaces the stored websites with the given ones
+func (s *SQLiteStorage) SaveWebsites(websites map[string]*monitor.Website) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM websites`); err != nil {
+		return fmt.Errorf("failed to clear websites: %v", err)
+	}
+
+	for id, website := range websites {
+		data, err := json.Marshal(website)
+		if err != nil {
+			return fmt.Errorf("failed to marshal website %s: %v", id, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO websites (id, data) VALUES (?, ?)`, id, string(data)); err != nil {
+			return fmt.Errorf("failed to save website %s: %v", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save websites: %v", err)
+	}
+	return nil
+}
+
+// LoadWebsites loads all stored websites
+func (s *SQLiteStorage) LoadWebsites() (map[string]*monitor.Website, error) {
+	rows, err := s.db.Query(`SELECT data FROM websites`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query websites: %v", err)
+	}
+	defer rows.Close()
+
+	websites := make(map[string]*monitor.Website)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read website: %v", err)
+		}
+
+		var website monitor.Website
+		if err := json.Unmarshal([]byte(data), &website); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal website: %v", err)
+		}
+		websites[website.ID] = &website
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read websites: %v", err)
+	}
+	return websites, nil
+}
+
+// SaveHistory saves a history entry for a website
+func (s *SQLiteStorage) SaveHistory(websiteID string, entry HistoryEntry) error {
+	_, err := s.db.Exec(`INSERT INTO history (website_id, timestamp, status, response_time, maintenance, status_code, error, error_code, tls_unverified, protocol, agent, response_bytes, remote_ip, timings, sub_checks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		websiteID, entry.Timestamp.UnixNano(), entry.Status, entry.ResponseTime, entry.Maintenance, entry.StatusCode, entry.Error, entry.ErrorCode, entry.TLSUnverified, entry.Protocol, entry.Agent, entry.ResponseBytes, entry.RemoteIP, encodeTimings(entry.Timings), encodeSubChecks(entry.SubChecks))
+	if err != nil {
+		return fmt.Errorf("failed to save history: %v", err)
+	}
+
+	s.pruneHistory()
+	return nil
+}
+
+// pruneHistory deletes history the retention policy no longer keeps, at most
+// once per sqlitePruneInterval
+func (s *SQLiteStorage) pruneHistory() {
+	s.pruneMutex.Lock()
+	if time.Since(s.lastPrune) < sqlitePruneInterval {
+		s.pruneMutex.Unlock()
+		return
+	}
+	s.lastPrune = time.Now()
+	s.pruneMutex.Unlock()
+
+	maxEntries, maxAge := s.historyRetention()
+
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge).UnixNano()
+		if _, err := s.db.Exec(`DELETE FROM history WHERE timestamp <= ?`, cutoff); err != nil {
+			logger.Warnf("Failed to prune old history: %v", err)
+		}
+	}
+
+	if maxEntries > 0 {
+		_, err := s.db.Exec(`DELETE FROM history WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY website_id ORDER BY timestamp DESC, id DESC) AS position
+				FROM history
+			) WHERE position > ?)`, maxEntries)
+		if err != nil {
+			logger.Warnf("Failed to prune old history: %v", err)
+		}
+	}
+}
+
+// historyColumns are the history columns read into a HistoryEntry, in the
+// order queryHistory and StreamHistory scan them
+const historyColumns = "timestamp, status, response_time, maintenance, status_code, error, error_code, tls_unverified, protocol, agent, response_bytes, remote_ip, timings, sub_checks"
+
+// LoadHistory loads the full history of a website, oldest first
+func (s *SQLiteStorage) LoadHistory(websiteID string) ([]HistoryEntry, error) {
+	return s.queryHistory(`SELECT `+historyColumns+` FROM history
+		WHERE website_id = ? ORDER BY timestamp, id`, websiteID)
+}
+
+// GetRecentHistory gets recent history entries for a website
+func (s *SQLiteStorage) GetRecentHistory(websiteID string, hours int) ([]HistoryEntry, error) {
+	return s.GetHistorySince(websiteID, time.Now().Add(-time.Duration(hours)*time.Hour))
+}
+
+// GetHistorySince gets history entries for a website recorded after cutoff
+func (s *SQLiteStorage) GetHistorySince(websiteID string, cutoff time.Time) ([]HistoryEntry, error) {
+	return s.queryHistory(`SELECT `+historyColumns+` FROM history
+		WHERE website_id = ? AND timestamp > ? ORDER BY timestamp, id`, websiteID, cutoff.UnixNano())
+}
+
+// sqliteStreamBatch is how many history rows StreamHistory reads at a time
+const sqliteStreamBatch = 1000
+
+// StreamHistory calls fn for each history entry of a website recorded after
+// cutoff, oldest first. Rows are read in batches so the database connection
+// isn't held while fn runs.
+func (s *SQLiteStorage) StreamHistory(websiteID string, cutoff time.Time, fn func(entry HistoryEntry) error) error {
+	lastTimestamp, lastID := cutoff.UnixNano(), int64(math.MaxInt64)
+	if cutoff.IsZero() {
+		lastTimestamp = math.MinInt64
+	}
+
+	for {
+		rows, err := s.db.Query(`SELECT id, `+historyColumns+` FROM history
+			WHERE website_id = ? AND (timestamp > ? OR (timestamp = ? AND id > ?))
+			ORDER BY timestamp, id LIMIT ?`,
+			websiteID, lastTimestamp, lastTimestamp, lastID, sqliteStreamBatch)
+		if err != nil {
+			return fmt.Errorf("failed to query history: %v", err)
+		}
+
+		var batch []HistoryEntry
+		for rows.Next() {
+			var entry HistoryEntry
+			if err := rows.Scan(&lastID, &lastTimestamp, &entry.Status, &entry.ResponseTime, &entry.Maintenance, &entry.StatusCode, &entry.Error, &entry.ErrorCode, &entry.TLSUnverified, &entry.Protocol, &entry.Agent, &entry.ResponseBytes, &entry.RemoteIP, timingsColumn{&entry.Timings}, subChecksColumn{&entry.SubChecks}); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read history: %v", err)
+			}
+			entry.Timestamp = time.Unix(0, lastTimestamp)
+			batch = append(batch, entry)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read history: %v", err)
+		}
+
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(batch) < sqliteStreamBatch {
+			return nil
+		}
+	}
+}
+
+// queryHistory runs a history query selecting historyColumns
+func (s *SQLiteStorage) queryHistory(query string, args ...interface{}) ([]HistoryEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var timestamp int64
+		if err := rows.Scan(&timestamp, &entry.Status, &entry.ResponseTime, &entry.Maintenance, &entry.StatusCode, &entry.Error, &entry.ErrorCode, &entry.TLSUnverified, &entry.Protocol, &entry.Agent, &entry.ResponseBytes, &entry.RemoteIP, timingsColumn{&entry.Timings}, subChecksColumn{&entry.SubChecks}); err != nil {
+			return nil, fmt.Errorf("failed to read history: %v", err)
+		}
+		entry.Timestamp = time.Unix(0, timestamp)
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	return history, nil
+}
+
+// DeleteWebsiteHistory deletes all history for a website
+func (s *SQLiteStorage) DeleteWebsiteHistory(websiteID string) error {
+	s.invalidateStats(websiteID)
+
+	if _, err := s.db.Exec(`DELETE FROM history WHERE website_id = ?`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete history: %v", err)
+	}
+	return nil
+}
+
+// CleanupOldHistory removes history of websites that no longer exist
+func (s *SQLiteStorage) CleanupOldHistory(existingWebsiteIDs map[string]bool) error {
+	rows, err := s.db.Query(`SELECT DISTINCT website_id FROM history`)
+	if err != nil {
+		return fmt.Errorf("failed to query history: %v", err)
+	}
+
+	var stale []string
+	for rows.Next() {
+		var websiteID string
+		if err := rows.Scan(&websiteID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read history: %v", err)
+		}
+		if !existingWebsiteIDs[websiteID] {
+			stale = append(stale, websiteID)
+		}
+	}
+	rows.Close()
+
+	for _, websiteID := range stale {
+		if err := s.DeleteWebsiteHistory(websiteID); err != nil {
+			logger.Warnf("Failed to delete old history of %s: %v", websiteID, err)
+		}
+	}
+
+	return nil
+}
+
+// CalculateUptime calculates uptime percentage for a website over a given period
+func (s *SQLiteStorage) CalculateUptime(websiteID string, hours int) (float64, error) {
+	return s.CalculateUptimeWindow(websiteID, time.Duration(hours)*time.Hour)
+}
+
+// CalculateUptimeWindow calculates uptime percentage for a website over an arbitrary window.
+// Websites with an active schedule have their entries filtered in Go, the rest are
+// aggregated by the database.
+func (s *SQLiteStorage) CalculateUptimeWindow(websiteID string, window time.Duration) (float64, error) {
+	cutoff := time.Now().Add(-window)
+
+	if s.activeAt(websiteID) != nil {
+		history, err := s.GetHistorySince(websiteID, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		return calculateUptime(s.filterActive(websiteID, history), s.unknownAsDown(), s.degradedAsDown()), nil
+	}
+
+	return s.queryUptime(websiteID, cutoff)
+}
+
+// CalculateUptimeWeighted calculates uptime percentage for a website over a
+// given period, weighting each check by the time it covers
+func (s *SQLiteStorage) CalculateUptimeWeighted(websiteID string, hours int) (float64, error) {
+	history, err := s.GetRecentHistory(websiteID, hours)
+	if err != nil {
+		return 0, err
+	}
+
+	return calculateUptimeWeighted(history, time.Now(), s.activeAt(websiteID), s.unknownAsDown(), s.degradedAsDown()), nil
+}
+
+// queryUptime aggregates the uptime percentage of a website since cutoff, with
+// the same rules as calculateUptime
+func (s *SQLiteStorage) queryUptime(websiteID string, cutoff time.Time) (float64, error) {
+	var up, checked, lateUnknown int
+	err := s.db.QueryRow(`
+		WITH recent AS (
+			SELECT timestamp, status FROM history WHERE website_id = ? AND timestamp > ?
+		), first_checked AS (
+			SELECT MIN(timestamp) AS timestamp FROM recent WHERE status NOT IN ('unknown', 'error')
+		)
+		SELECT
+			COALESCE(SUM(status = 'up' OR (status = 'degraded' AND ?)), 0),
+			COALESCE(SUM(status NOT IN ('unknown', 'error')), 0),
+			COALESCE(SUM(status = 'unknown' AND timestamp > (SELECT timestamp FROM first_checked)), 0)
+		FROM recent`, websiteID, cutoff.UnixNano(), !s.degradedAsDown()).Scan(&up, &checked, &lateUnknown)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate uptime: %v", err)
+	}
+
+	total := checked
+	if s.unknownAsDown() {
+		total += lateUnknown
+	}
+	if total == 0 {
+		return 100.0, nil // Assume 100% if no data
+	}
+
+	return float64(up) / float64(total) * 100.0, nil
+}
+
+// GetAverageResponseTime calculates average response time for a website over a given period
+func (s *SQLiteStorage) GetAverageResponseTime(websiteID string, hours int) (float64, error) {
+	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	var average sql.NullFloat64
+	err := s.db.QueryRow(`SELECT AVG(response_time) FROM history
+		WHERE website_id = ? AND timestamp > ? AND status IN ('up', 'degraded') AND response_time > 0`,
+		websiteID, cutoff.UnixNano()).Scan(&average)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate average response time: %v", err)
+	}
+
+	return average.Float64, nil
+}
+
+// GetResponseTimeStats returns the minimum, maximum and percentile response
+// times of a website's successful checks over a given period
+func (s *SQLiteStorage) GetResponseTimeStats(websiteID string, hours int) (ResponseTimeStats, error) {
+	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	rows, err := s.db.Query(`SELECT response_time FROM history
+		WHERE website_id = ? AND timestamp > ? AND status IN ('up', 'degraded') AND response_time > 0
+		ORDER BY response_time`, websiteID, cutoff.UnixNano())
+	if err != nil {
+		return ResponseTimeStats{}, fmt.Errorf("failed to query response times: %v", err)
+	}
+	defer rows.Close()
+
+	var times []int
+	for rows.Next() {
+		var responseTime int
+		if err := rows.Scan(&responseTime); err != nil {
+			return ResponseTimeStats{}, fmt.Errorf("failed to read response times: %v", err)
+		}
+		times = append(times, responseTime)
+	}
+	if err := rows.Err(); err != nil {
+		return ResponseTimeStats{}, fmt.Errorf("failed to read response times: %v", err)
+	}
+
+	return responseTimeStatsSorted(times), nil
+}
+
+// GetUptimeStats returns 24h/30d uptime and 24h response time stats for a
+// website, caching the result for a short time
+func (s *SQLiteStorage) GetUptimeStats(websiteID string) (UptimeStats, error) {
+	if stats, cached := s.cachedStatsFor(websiteID); cached {
+		return stats, nil
+	}
+
+	var stats UptimeStats
+	if s.activeAt(websiteID) != nil {
+		history, err := s.GetRecentHistory(websiteID, 24*30)
+		if err != nil {
+			return UptimeStats{}, err
+		}
+		stats = s.statsFromHistory(websiteID, history)
+	} else {
+		var err error
+		now := time.Now()
+		if stats.Uptime24h, err = s.queryUptime(websiteID, now.Add(-24*time.Hour)); err != nil {
+			return UptimeStats{}, err
+		}
+		if stats.Uptime30d, err = s.queryUptime(websiteID, now.Add(-30*24*time.Hour)); err != nil {
+			return UptimeStats{}, err
+		}
+		if stats.AvgResponseTime24h, err = s.GetAverageResponseTime(websiteID, 24); err != nil {
+			return UptimeStats{}, err
+		}
+		if stats.ResponseTime24h, err = s.GetResponseTimeStats(websiteID, 24); err != nil {
+			return UptimeStats{}, err
+		}
+	}
+
+	s.cacheStats(websiteID, stats)
+	return stats, nil
+}
+
+// SaveNotificationLog appends a notification attempt to a website's log
+func (s *SQLiteStorage) SaveNotificationLog(websiteID string, entry NotificationLogEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO notification_log
+		(website_id, timestamp, channel, event_type, old_status, new_status, success, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		websiteID, entry.Timestamp.UnixNano(), entry.Channel, entry.EventType,
+		entry.OldStatus, entry.NewStatus, entry.Success, entry.Error)
+	if err != nil {
+		return fmt.Errorf("failed to save notification log: %v", err)
+	}
+
+	// Keep only the most recent entries
+	_, err = tx.Exec(`DELETE FROM notification_log WHERE website_id = ? AND id NOT IN (
+		SELECT id FROM notification_log WHERE website_id = ? ORDER BY id DESC LIMIT ?)`,
+		websiteID, websiteID, maxNotificationLogEntries)
+	if err != nil {
+		return fmt.Errorf("failed to trim notification log: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save notification log: %v", err)
+	}
+	return nil
+}
+
+// LoadNotificationLog returns a website's notification attempts, oldest first
+func (s *SQLiteStorage) LoadNotificationLog(websiteID string) ([]NotificationLogEntry, error) {
+	rows, err := s.db.Query(`SELECT timestamp, channel, event_type, old_status, new_status, success, error
+		FROM notification_log WHERE website_id = ? ORDER BY id`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []NotificationLogEntry{}
+	for rows.Next() {
+		var entry NotificationLogEntry
+		var timestamp int64
+		if err := rows.Scan(&timestamp, &entry.Channel, &entry.EventType,
+			&entry.OldStatus, &entry.NewStatus, &entry.Success, &entry.Error); err != nil {
+			return nil, fmt.Errorf("failed to read notification log: %v", err)
+		}
+		entry.Timestamp = time.Unix(0, timestamp)
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read notification log: %v", err)
+	}
+	return entries, nil
+}
+
+// DeleteNotificationLog removes a website's notification log
+func (s *SQLiteStorage) DeleteNotificationLog(websiteID string) error {
+	if _, err := s.db.Exec(`DELETE FROM notification_log WHERE website_id = ?`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete notification log: %v", err)
+	}
+	return nil
+}
+
+// GetOutages returns the outages of a website over the last days, oldest first
+func (s *SQLiteStorage) GetOutages(websiteID string, days int) ([]Outage, error) {
+	history, err := s.GetRecentHistory(websiteID, days*24)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOutages(history, time.Now(), s.degradedAsDown()), nil
+}
+
+// GetAggregatedHistory groups the last hours of a website's history into
+// buckets of bucketMinutes with per-bucket uptime and response times.
+// Websites with an active schedule are aggregated in Go, the rest by the database.
+func (s *SQLiteStorage) GetAggregatedHistory(websiteID string, hours int, bucketMinutes int) ([]HistoryBucket, error) {
+	if bucketMinutes < 1 {
+		return nil, fmt.Errorf("bucket must be at least one minute")
+	}
+	bucket := time.Duration(bucketMinutes) * time.Minute
+	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	if s.activeAt(websiteID) != nil {
+		history, err := s.GetHistorySince(websiteID, cutoff)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateHistory(s.filterActive(websiteID, history), bucket, s.unknownAsDown(), s.degradedAsDown()), nil
+	}
+
+	rows, err := s.db.Query(`
+		WITH recent AS (
+			SELECT timestamp, status, response_time FROM history WHERE website_id = ? AND timestamp > ?
+		), first_checked AS (
+			SELECT MIN(timestamp) AS timestamp FROM recent WHERE status NOT IN ('unknown', 'error')
+		)
+		SELECT
+			timestamp / ? AS bucket,
+			COUNT(*),
+			SUM(status = 'up' OR (status = 'degraded' AND ?)),
+			SUM(status NOT IN ('unknown', 'error')),
+			SUM(status = 'unknown' AND timestamp > (SELECT timestamp FROM first_checked)),
+			AVG(CASE WHEN status IN ('up', 'degraded') AND response_time > 0 THEN response_time END),
+			MIN(CASE WHEN status IN ('up', 'degraded') AND response_time > 0 THEN response_time END),
+			MAX(CASE WHEN status IN ('up', 'degraded') AND response_time > 0 THEN response_time END)
+		FROM recent GROUP BY bucket ORDER BY bucket`,
+		websiteID, cutoff.UnixNano(), int64(bucket), !s.degradedAsDown())
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate history: %v", err)
+	}
+	defer rows.Close()
+
+	countUnknownAsDown := s.unknownAsDown()
+	buckets := []HistoryBucket{}
+	for rows.Next() {
+		var index int64
+		var up, checked, lateUnknown sql.NullInt64
+		var average sql.NullFloat64
+		var minimum, maximum sql.NullInt64
+		var current HistoryBucket
+		if err := rows.Scan(&index, &current.Checks, &up, &checked, &lateUnknown, &average, &minimum, &maximum); err != nil {
+			return nil, fmt.Errorf("failed to read aggregated history: %v", err)
+		}
+
+		current.Start = time.Unix(0, index*int64(bucket))
+		total := checked.Int64
+		if countUnknownAsDown {
+			total += lateUnknown.Int64
+		}
+		current.Uptime = 100.0
+		if total > 0 {
+			current.Uptime = float64(up.Int64) / float64(total) * 100.0
+		}
+		current.AvgResponseTime = average.Float64
+		current.MinResponseTime = int(minimum.Int64)
+		current.MaxResponseTime = int(maximum.Int64)
+		buckets = append(buckets, current)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read aggregated history: %v", err)
+	}
+	return buckets, nil
+}
+
+// timingsColumn reads the timings column of a history row, the JSON-encoded
+// phase timings or "" for entries without them
+type timingsColumn struct {
+	timings **monitor.Timings
+}
+
+// Scan implements sql.Scanner
+func (c timingsColumn) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	}
+	if len(data) == 0 {
+		*c.timings = nil
+		return nil
+	}
+
+	var timings monitor.Timings
+	if err := json.Unmarshal(data, &timings); err != nil {
+		return fmt.Errorf("invalid timings: %v", err)
+	}
+	*c.timings = &timings
+	return nil
+}
+
+// encodeTimings returns the timings column value of a history entry
+func encodeTimings(timings *monitor.Timings) string {
+	if timings == nil {
+		return ""
+	}
+	data, _ := json.Marshal(timings)
+	return string(data)
+}
+
+// subChecksColumn reads the sub_checks column of a history row, the
+// JSON-encoded sub-check results by path or "" for entries without them
+type subChecksColumn struct {
+	subChecks *map[string]monitor.SubCheckResult
+}
+
+// Scan implements sql.Scanner
+func (c subChecksColumn) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	}
+	if len(data) == 0 {
+		*c.subChecks = nil
+		return nil
+	}
+
+	var subChecks map[string]monitor.SubCheckResult
+	if err := json.Unmarshal(data, &subChecks); err != nil {
+		return fmt.Errorf("invalid sub-check results: %v", err)
+	}
+	*c.subChecks = subChecks
+	return nil
+}
+
+// encodeSubChecks returns the sub_checks column value of a history entry
+func encodeSubChecks(subChecks map[string]monitor.SubCheckResult) string {
+	if len(subChecks) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(subChecks)
+	return string(data)
+}
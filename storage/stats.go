@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultStatsCacheTTL is how long computed website stats are reused
+const DefaultStatsCacheTTL = 30 * time.Second
+
+// UptimeStats holds the summary values shown for each website
+type UptimeStats struct {
+	Uptime24h          float64
+	Uptime30d          float64
+	AvgResponseTime24h float64
+	ResponseTime24h    ResponseTimeStats
+}
+
+// ResponseTimeStats summarizes the response times of successful checks,
+// degraded ones included. Percentiles use the nearest-rank method, so each is
+// an observed response time. All values are 0 without successful checks.
+type ResponseTimeStats struct {
+	Samples int `json:"samples"`
+	Min     int `json:"min_ms"`
+	Max     int `json:"max_ms"`
+	P50     int `json:"p50_ms"`
+	P95     int `json:"p95_ms"`
+	P99     int `json:"p99_ms"`
+}
+
+// cachedStats is an UptimeStats value with its expiry time
+type cachedStats struct {
+	stats   UptimeStats
+	expires time.Time
+}
+
+// statsCache is a short-lived cache of per-website summary stats, shared by
+// the storage backends
+type statsCache struct {
+	statsMutex    sync.Mutex
+	statsEntries  map[string]cachedStats
+	statsCacheTTL time.Duration
+}
+
+// newStatsCache creates a stats cache with the default TTL
+func newStatsCache() statsCache {
+	return statsCache{
+		statsEntries:  make(map[string]cachedStats),
+		statsCacheTTL: DefaultStatsCacheTTL,
+	}
+}
+
+// SetStatsCacheTTL sets how long GetUptimeStats results are cached. Zero disables caching.
+func (c *statsCache) SetStatsCacheTTL(ttl time.Duration) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	c.statsCacheTTL = ttl
+	c.statsEntries = make(map[string]cachedStats)
+}
+
+// cachedStatsFor returns the cached stats of a website if they haven't expired
+func (c *statsCache) cachedStatsFor(websiteID string) (UptimeStats, bool) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+
+	cached, exists := c.statsEntries[websiteID]
+	if !exists || time.Now().After(cached.expires) {
+		return UptimeStats{}, false
+	}
+	return cached.stats, true
+}
+
+// cacheStats stores the stats of a website unless caching is disabled
+func (c *statsCache) cacheStats(websiteID string, stats UptimeStats) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+
+	if c.statsCacheTTL > 0 {
+		c.statsEntries[websiteID] = cachedStats{stats: stats, expires: time.Now().Add(c.statsCacheTTL)}
+	}
+}
+
+// invalidateStats drops the cached stats of a website
+func (c *statsCache) invalidateStats(websiteID string) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	delete(c.statsEntries, websiteID)
+}
+
+// uptimeOptions holds the uptime calculation settings shared by the storage backends
+type uptimeOptions struct {
+	optionsMutex sync.RWMutex
+
+	// countUnknownAsDown controls whether "unknown" entries count against uptime.
+	// By default they are excluded from the calculation entirely.
+	countUnknownAsDown bool
+
+	// countDegradedAsDown controls whether "degraded" entries (up, but slower
+	// than the website's threshold) count against uptime. By default they count as up.
+	countDegradedAsDown bool
+
+	// activityFilter returns the function reporting whether a website was
+	// scheduled to be monitored at a given time, or nil if it always is
+	activityFilter func(websiteID string) func(t time.Time) bool
+}
+
+// SetCountUnknownAsDown sets whether "unknown" history entries count as downtime
+func (o *uptimeOptions) SetCountUnknownAsDown(countAsDown bool) {
+	o.optionsMutex.Lock()
+	defer o.optionsMutex.Unlock()
+	o.countUnknownAsDown = countAsDown
+}
+
+// SetCountDegradedAsDown sets whether "degraded" history entries count as downtime
+func (o *uptimeOptions) SetCountDegradedAsDown(countAsDown bool) {
+	o.optionsMutex.Lock()
+	defer o.optionsMutex.Unlock()
+	o.countDegradedAsDown = countAsDown
+}
+
+// SetActivityFilter sets the function used to exclude entries outside a website's
+// active schedule from uptime calculations. The filter returns nil for websites
+// that are always active.
+func (o *uptimeOptions) SetActivityFilter(filter func(websiteID string) func(t time.Time) bool) {
+	o.optionsMutex.Lock()
+	defer o.optionsMutex.Unlock()
+	o.activityFilter = filter
+}
+
+// unknownAsDown reports whether "unknown" entries count as downtime
+func (o *uptimeOptions) unknownAsDown() bool {
+	o.optionsMutex.RLock()
+	defer o.optionsMutex.RUnlock()
+	return o.countUnknownAsDown
+}
+
+// degradedAsDown reports whether "degraded" entries count as downtime
+func (o *uptimeOptions) degradedAsDown() bool {
+	o.optionsMutex.RLock()
+	defer o.optionsMutex.RUnlock()
+	return o.countDegradedAsDown
+}
+
+// activeAt returns a website's activity check, or nil if it is always active
+func (o *uptimeOptions) activeAt(websiteID string) func(t time.Time) bool {
+	o.optionsMutex.RLock()
+	activityFilter := o.activityFilter
+	o.optionsMutex.RUnlock()
+
+	if activityFilter == nil {
+		return nil
+	}
+	return activityFilter(websiteID)
+}
+
+// filterActive drops entries outside the website's active schedule, since
+// that time doesn't count towards uptime
+func (o *uptimeOptions) filterActive(websiteID string, history []HistoryEntry) []HistoryEntry {
+	isActive := o.activeAt(websiteID)
+	if isActive == nil {
+		return history
+	}
+
+	active := make([]HistoryEntry, 0, len(history))
+	for _, entry := range history {
+		if isActive(entry.Timestamp) {
+			active = append(active, entry)
+		}
+	}
+	return active
+}
+
+// GetUptimeStats returns 24h/30d uptime and 24h response time stats for a
+// website, reading its history once and caching the result for a short time
+func (s *JSONStorage) GetUptimeStats(websiteID string) (UptimeStats, error) {
+	if stats, cached := s.cachedStatsFor(websiteID); cached {
+		return stats, nil
+	}
+
+	history, err := s.GetRecentHistory(websiteID, 24*30)
+	if err != nil {
+		return UptimeStats{}, err
+	}
+
+	stats := s.statsFromHistory(websiteID, history)
+	s.cacheStats(websiteID, stats)
+	return stats, nil
+}
+
+// statsFromHistory computes the summary stats of a website from its last 30 days of history
+func (o *uptimeOptions) statsFromHistory(websiteID string, history []HistoryEntry) UptimeStats {
+	cutoff := time.Now().Add(-24 * time.Hour)
+	var last24h []HistoryEntry
+	for i, entry := range history {
+		if entry.Timestamp.After(cutoff) {
+			last24h = history[i:]
+			break
+		}
+	}
+
+	countUnknownAsDown, countDegradedAsDown := o.unknownAsDown(), o.degradedAsDown()
+	return UptimeStats{
+		Uptime24h:          calculateUptime(o.filterActive(websiteID, last24h), countUnknownAsDown, countDegradedAsDown),
+		Uptime30d:          calculateUptime(o.filterActive(websiteID, history), countUnknownAsDown, countDegradedAsDown),
+		AvgResponseTime24h: averageResponseTime(last24h),
+		ResponseTime24h:    responseTimeStats(last24h),
+	}
+}
@@ -1,13 +1,20 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"uptime-monitor/logger"
 	"uptime-monitor/monitor"
 )
 
@@ -16,30 +23,114 @@ type HistoryEntry struct {
 	Timestamp    time.Time `json:"timestamp"`
 	Status       string    `json:"status"`
 	ResponseTime int       `json:"response_time_ms"`
+	Maintenance  bool      `json:"maintenance,omitempty"` // Recorded during a maintenance window
+	// StatusCode is the final HTTP status of the check, 0 when no response
+	// arrived or for non-HTTP checks. Error explains a failed check and
+	// ErrorCode classifies it (see the monitor.ErrorCode constants). All are
+	// absent from entries recorded by older versions and read back as empty.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
+	// TLSUnverified marks checks that only succeeded because the website
+	// skips certificate verification
+	TLSUnverified bool `json:"tls_unverified,omitempty"`
+	// Protocol is the HTTP version of the response, e.g. "HTTP/2.0"
+	Protocol string `json:"protocol,omitempty"`
+	// Agent is the region label of the agent that ran the check, empty for
+	// checks run by an instance without agent_id
+	Agent string `json:"agent,omitempty"`
+	// ResponseBytes is the size of the response body as read, absent when
+	// the check didn't read it
+	ResponseBytes *int64 `json:"response_bytes,omitempty"`
+	// RemoteIP is the IP address the check connected to, or last tried to
+	RemoteIP string `json:"remote_ip,omitempty"`
+	// Timings breaks the response time of an HTTP check down into phases
+	Timings *monitor.Timings `json:"timings,omitempty"`
+	// SubChecks holds the results of the website's sub-checks by path
+	SubChecks map[string]monitor.SubCheckResult `json:"sub_checks,omitempty"`
 }
 
-// Storage manages JSON file storage for websites and history
-type Storage struct {
-	dataDir     string
+// JSONStorage manages JSON file storage for websites and history
+type JSONStorage struct {
+	dataDir      string
 	websitesFile string
-	mutex       sync.RWMutex
+	mutex        sync.RWMutex // Guards websitesFile
+
+	// Per-website locks, so files of different websites are accessed concurrently
+	files      map[string]*websiteFiles
+	filesMutex sync.Mutex
+
+	compressHistory bool // Write history files gzip-compressed
+	compressMutex   sync.RWMutex
+
+	uptimeOptions
+	retentionOptions
+	statsCache
+}
+
+// websiteFiles guards the history and notification log files of one website
+type websiteFiles struct {
+	sync.RWMutex
+
+	historyLines int       // Entries in the history file
+	appended     int       // Entries appended since the history file was last rewritten
+	linesCounted bool      // Whether historyLines was counted since startup
+	compactedAt  time.Time // When the history file was last compacted
+}
+
+// filesFor returns the lock of a website's files, creating it on first use
+func (s *JSONStorage) filesFor(websiteID string) *websiteFiles {
+	s.filesMutex.Lock()
+	defer s.filesMutex.Unlock()
+
+	files, exists := s.files[websiteID]
+	if !exists {
+		files = &websiteFiles{}
+		s.files[websiteID] = files
+	}
+	return files
 }
 
-// NewStorage creates a new storage instance
-func NewStorage(dataDir string) *Storage {
+// NewJSONStorage creates a storage instance keeping JSON files in dataDir
+func NewJSONStorage(dataDir string) (*JSONStorage, error) {
 	// Create data directory if it doesn't exist
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create data directory: %v", err))
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
 	}
 
-	return &Storage{
-		dataDir:     dataDir,
-		websitesFile: filepath.Join(dataDir, "websites.json"),
+	s := &JSONStorage{
+		dataDir:          dataDir,
+		websitesFile:     filepath.Join(dataDir, "websites.json"),
+		files:            make(map[string]*websiteFiles),
+		retentionOptions: newRetentionOptions(),
+		statsCache:       newStatsCache(),
 	}
+
+	// Convert history files written before the JSON-lines format
+	if err := s.migrateLegacyHistory(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Backend returns the storage backend name
+func (s *JSONStorage) Backend() string {
+	return BackendJSON
+}
+
+// DataDir returns the directory the storage writes to
+func (s *JSONStorage) DataDir() string {
+	return s.dataDir
+}
+
+// Close releases the storage. JSON files need no cleanup.
+func (s *JSONStorage) Close() error {
+	return nil
 }
 
 // SaveWebsites saves all websites to JSON file
-func (s *Storage) SaveWebsites(websites map[string]*monitor.Website) error {
+func (s *JSONStorage) SaveWebsites(websites map[string]*monitor.Website) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -54,21 +145,15 @@ func (s *Storage) SaveWebsites(websites map[string]*monitor.Website) error {
 		return fmt.Errorf("failed to marshal websites: %v", err)
 	}
 
-	// Write to temporary file first, then rename for atomic operation
-	tempFile := s.websitesFile + ".tmp"
-	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+	if err := writeFileAtomic(s.websitesFile, data); err != nil {
 		return fmt.Errorf("failed to write websites file: %v", err)
 	}
 
-	if err := os.Rename(tempFile, s.websitesFile); err != nil {
-		return fmt.Errorf("failed to rename websites file: %v", err)
-	}
-
 	return nil
 }
 
 // LoadWebsites loads all websites from JSON file
-func (s *Storage) LoadWebsites() (map[string]*monitor.Website, error) {
+func (s *JSONStorage) LoadWebsites() (map[string]*monitor.Website, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -97,81 +182,229 @@ func (s *Storage) LoadWebsites() (map[string]*monitor.Website, error) {
 	return websites, nil
 }
 
-// SaveHistory saves a history entry for a website
-func (s *Storage) SaveHistory(websiteID string, entry HistoryEntry) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// historyAgeCompactionInterval is how often history files are compacted to
+// drop entries past the maximum age
+const historyAgeCompactionInterval = time.Hour
 
-	historyFile := filepath.Join(s.dataDir, fmt.Sprintf("history_%s.json", websiteID))
+// SaveHistory appends a history entry to a website's history file
+func (s *JSONStorage) SaveHistory(websiteID string, entry HistoryEntry) error {
+	files := s.filesFor(websiteID)
+	files.Lock()
+	defer files.Unlock()
 
-	// Load existing history
-	var history []HistoryEntry
-	if data, err := ioutil.ReadFile(historyFile); err == nil {
-		json.Unmarshal(data, &history)
-	}
+	historyFile := s.historyFile(websiteID)
 
-	// Add new entry
-	history = append(history, entry)
+	// Count the existing entries on the first append since startup
+	lines := files.historyLines
+	if !files.linesCounted {
+		history, skipped, clean, err := readHistoryFile(historyFile)
+		if err != nil {
+			return err
+		}
+		lines = len(history)
 
-	// Keep only last 1000 entries to prevent unlimited growth
-	if len(history) > 1000 {
-		history = history[len(history)-1000:]
+		if skipped > 0 {
+			// Damaged lines are dropped, keeping a copy of the file for inspection
+			if err := recoverHistoryFile(historyFile, history, skipped); err != nil {
+				return err
+			}
+		} else if !clean {
+			// Rewrite a file with a torn last line so the new entry starts on its own line
+			if err := writeHistoryFile(historyFile, history); err != nil {
+				return err
+			}
+		}
+
+		// Convert a file written with the other compression setting
+		if target := s.newHistoryFile(websiteID); target != historyFile {
+			if err := writeHistoryFile(target, history); err != nil {
+				return err
+			}
+			if err := os.Remove(historyFile); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove converted history file: %v", err)
+			}
+			historyFile = target
+		}
 	}
 
-	// Save updated history
-	data, err := json.MarshalIndent(history, "", "  ")
+	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal history: %v", err)
 	}
-
-	// Write to temporary file first, then rename for atomic operation
-	tempFile := historyFile + ".tmp"
-	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write history file: %v", err)
+	// A compressed file gets a gzip member per append; readers decompress the concatenation
+	data, err = encodeHistoryData(historyFile, append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to compress history: %v", err)
 	}
 
-	if err := os.Rename(tempFile, historyFile); err != nil {
-		return fmt.Errorf("failed to rename history file: %v", err)
+	file, err := os.OpenFile(historyFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %v", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append history: %v", err)
+	}
+	lines++
+	files.appended++
+
+	// Compact once the file has grown a fifth past the entry cap, so the
+	// rewrite happens once every maxEntries/5 checks, periodically when
+	// entries expire by age, and regularly for compressed files so most of
+	// the file is one well-compressed stream
+	maxEntries, maxAge := s.historyRetention()
+	compact := maxEntries > 0 && lines > maxEntries+maxEntries/5
+	if maxAge > 0 && time.Since(files.compactedAt) > historyAgeCompactionInterval {
+		compact = true
+	}
+	if isCompressed(historyFile) && files.appended >= compressedAppendBatch {
+		compact = true
+	}
+	if compact {
+		history, _, _, err := readHistoryFile(historyFile)
+		if err != nil {
+			return err
+		}
+		history = s.applyRetention(history)
+		if err := writeHistoryFile(historyFile, history); err != nil {
+			return err
+		}
+		lines = len(history)
+		files.appended = 0
+		files.compactedAt = time.Now()
 	}
 
+	files.historyLines = lines
+	files.linesCounted = true
 	return nil
 }
 
 // LoadHistory loads history for a website
-func (s *Storage) LoadHistory(websiteID string) ([]HistoryEntry, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+func (s *JSONStorage) LoadHistory(websiteID string) ([]HistoryEntry, error) {
+	files := s.filesFor(websiteID)
+	files.RLock()
+	defer files.RUnlock()
 
-	historyFile := filepath.Join(s.dataDir, fmt.Sprintf("history_%s.json", websiteID))
+	history, _, _, err := readHistoryFile(s.historyFile(websiteID))
+	return history, err
+}
 
-	// Check if file exists
-	if _, err := os.Stat(historyFile); os.IsNotExist(err) {
+// readHistoryFile reads a JSON-lines history file. Lines that fail to parse
+// are skipped: a last line torn by a crash mid-write is expected, skipped
+// counts the other, damaged ones. clean is false when the file doesn't end
+// with a newline, or a compressed file ends in a torn append.
+func readHistoryFile(historyFile string) (history []HistoryEntry, skipped int, clean bool, err error) {
+	data, torn, damaged, err := readHistoryData(historyFile)
+	if os.IsNotExist(err) {
 		// Return empty slice if file doesn't exist
-		return []HistoryEntry{}, nil
+		return []HistoryEntry{}, 0, true, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	clean = !torn && (len(data) == 0 || data[len(data)-1] == '\n')
+	if damaged {
+		// What follows the damage can't be decompressed; count it as one skipped line
+		skipped++
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	history = []HistoryEntry{}
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if clean || i < len(lines)-1 {
+				skipped++
+			}
+			continue
+		}
+		history = append(history, entry)
 	}
 
-	data, err := ioutil.ReadFile(historyFile)
+	return history, skipped, clean, nil
+}
+
+// writeHistoryFile replaces a history file with the given entries,
+// compressed if its name ends in .gz
+func writeHistoryFile(historyFile string, history []HistoryEntry) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range history {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to marshal history: %v", err)
+		}
+	}
+
+	data, err := encodeHistoryData(historyFile, buf.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("failed to read history file: %v", err)
+		return fmt.Errorf("failed to compress history: %v", err)
+	}
+	if err := writeFileAtomic(historyFile, data); err != nil {
+		return fmt.Errorf("failed to write history file: %v", err)
 	}
 
-	var history []HistoryEntry
-	if err := json.Unmarshal(data, &history); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal history: %v", err)
+	return nil
+}
+
+// migrateLegacyHistory converts history_<id>.json files, which held a single
+// JSON array, to the JSON-lines format. The entries before any damage are
+// recovered from a file cut short, which is kept as a backup.
+func (s *JSONStorage) migrateLegacyHistory() error {
+	legacyFiles, err := filepath.Glob(filepath.Join(s.dataDir, "history_*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list history files: %v", err)
+	}
+
+	for _, legacyFile := range legacyFiles {
+		data, err := ioutil.ReadFile(legacyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read history file: %v", err)
+		}
+
+		history, decodeErr := decodeLegacyHistory(data)
+		if decodeErr != nil && len(history) == 0 {
+			logger.Warnf("Skipping unreadable history file %s: %v", legacyFile, decodeErr)
+			continue
+		}
+
+		if err := writeHistoryFile(legacyFile+"l", history); err != nil {
+			return err
+		}
+		if decodeErr != nil {
+			backup := corruptBackupFile(legacyFile)
+			if err := os.Rename(legacyFile, backup); err != nil {
+				return fmt.Errorf("failed to back up damaged history file: %v", err)
+			}
+			logger.Warnf("Recovered %d entries from damaged history file %s (%v), original saved as %s", len(history), legacyFile, decodeErr, backup)
+			continue
+		}
+		if err := os.Remove(legacyFile); err != nil {
+			return fmt.Errorf("failed to remove migrated history file: %v", err)
+		}
 	}
 
-	return history, nil
+	return nil
 }
 
 // GetRecentHistory gets recent history entries for a website
-func (s *Storage) GetRecentHistory(websiteID string, hours int) ([]HistoryEntry, error) {
+func (s *JSONStorage) GetRecentHistory(websiteID string, hours int) ([]HistoryEntry, error) {
+	return s.GetHistorySince(websiteID, time.Now().Add(-time.Duration(hours)*time.Hour))
+}
+
+// GetHistorySince gets history entries for a website recorded after cutoff
+func (s *JSONStorage) GetHistorySince(websiteID string, cutoff time.Time) ([]HistoryEntry, error) {
 	history, err := s.LoadHistory(websiteID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Filter entries from the last N hours
-	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+	// Filter entries after the cutoff
 	var recentHistory []HistoryEntry
 
 	for _, entry := range history {
@@ -183,91 +416,290 @@ func (s *Storage) GetRecentHistory(websiteID string, hours int) ([]HistoryEntry,
 	return recentHistory, nil
 }
 
-// DeleteWebsiteHistory deletes all history for a website
-func (s *Storage) DeleteWebsiteHistory(websiteID string) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// StreamHistory calls fn for each history entry of a website recorded after
+// cutoff, oldest first, reading the file line by line. The website's lock is
+// only held to open the file, so a slow fn doesn't block new checks.
+func (s *JSONStorage) StreamHistory(websiteID string, cutoff time.Time, fn func(entry HistoryEntry) error) error {
+	files := s.filesFor(websiteID)
+	files.RLock()
+	file, err := openHistory(s.historyFile(websiteID))
+	files.RUnlock()
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	// Read whole lines however long, so a damaged line doesn't stop the stream
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		var entry HistoryEntry
+		if len(line) > 0 && json.Unmarshal(line, &entry) == nil && entry.Timestamp.After(cutoff) {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			// A compressed file may end in an append torn by a crash
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read history file: %v", readErr)
+		}
+	}
+}
 
-	historyFile := filepath.Join(s.dataDir, fmt.Sprintf("history_%s.json", websiteID))
-	
-	if err := os.Remove(historyFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete history file: %v", err)
+// DeleteWebsiteHistory deletes all history for a website
+func (s *JSONStorage) DeleteWebsiteHistory(websiteID string) error {
+	files := s.filesFor(websiteID)
+	files.Lock()
+	defer files.Unlock()
+
+	s.invalidateStats(websiteID)
+	files.historyLines = 0
+	files.appended = 0
+	files.linesCounted = false
+
+	historyFile := s.newHistoryFile(websiteID)
+	for _, path := range []string{historyFile, otherHistoryFile(historyFile)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete history file: %v", err)
+		}
 	}
 
 	return nil
 }
 
 // CalculateUptime calculates uptime percentage for a website over a given period
-func (s *Storage) CalculateUptime(websiteID string, hours int) (float64, error) {
-	history, err := s.GetRecentHistory(websiteID, hours)
+func (s *JSONStorage) CalculateUptime(websiteID string, hours int) (float64, error) {
+	return s.CalculateUptimeWindow(websiteID, time.Duration(hours)*time.Hour)
+}
+
+// CalculateUptimeWindow calculates uptime percentage for a website over an arbitrary window
+func (s *JSONStorage) CalculateUptimeWindow(websiteID string, window time.Duration) (float64, error) {
+	history, err := s.GetHistorySince(websiteID, time.Now().Add(-window))
 	if err != nil {
 		return 0, err
 	}
 
-	if len(history) == 0 {
-		return 100.0, nil // Assume 100% if no data
-	}
+	return calculateUptime(s.filterActive(websiteID, history), s.unknownAsDown(), s.degradedAsDown()), nil
+}
 
+// calculateUptime computes the uptime percentage of the given entries.
+// Leading "unknown" entries (recorded before the first real check) never count,
+// later ones only count as downtime when countUnknownAsDown is set. "error"
+// entries (checks that could not run) are always excluded. "degraded" entries
+// count as up unless countDegradedAsDown is set.
+func calculateUptime(history []HistoryEntry, countUnknownAsDown, countDegradedAsDown bool) float64 {
 	upCount := 0
+	total := 0
+	checked := false
+
 	for _, entry := range history {
-		if entry.Status == "up" {
+		switch entry.Status {
+		case monitor.StatusUnknown:
+			if checked && countUnknownAsDown {
+				total++
+			}
+		case monitor.StatusError:
+			// The check never reached the site, so it says nothing about uptime
+		case monitor.StatusUp:
+			checked = true
 			upCount++
+			total++
+		case monitor.StatusDegraded:
+			checked = true
+			if !countDegradedAsDown {
+				upCount++
+			}
+			total++
+		default:
+			checked = true
+			total++
 		}
 	}
 
-	return float64(upCount) / float64(len(history)) * 100.0, nil
+	if total == 0 {
+		return 100.0 // Assume 100% if no data
+	}
+
+	return float64(upCount) / float64(total) * 100.0
 }
 
-// GetAverageResponseTime calculates average response time for a website over a given period
-func (s *Storage) GetAverageResponseTime(websiteID string, hours int) (float64, error) {
+// CalculateUptimeWeighted calculates uptime percentage for a website over a
+// given period, weighting each check by the time it covers
+func (s *JSONStorage) CalculateUptimeWeighted(websiteID string, hours int) (float64, error) {
 	history, err := s.GetRecentHistory(websiteID, hours)
 	if err != nil {
 		return 0, err
 	}
 
-	if len(history) == 0 {
-		return 0, nil
+	return calculateUptimeWeighted(history, time.Now(), s.activeAt(websiteID), s.unknownAsDown(), s.degradedAsDown()), nil
+}
+
+// calculateUptimeWeighted computes the uptime percentage of the given
+// chronological entries as up time over total time. Each entry covers the
+// time until the next one, and the last one the time until end. An entry
+// covers at most twice the spacing since the entry before it, so a gap in the
+// history (the monitor wasn't running) counts as no data rather than
+// stretching the status before it. Time before the first entry is left out
+// too. Entries are classified like in calculateUptime, and entries for which
+// isActive, if set, returns false are skipped.
+func calculateUptimeWeighted(history []HistoryEntry, end time.Time, isActive func(t time.Time) bool, countUnknownAsDown, countDegradedAsDown bool) float64 {
+	var upTime, totalTime time.Duration
+	checked := false
+
+	for i, entry := range history {
+		next := end
+		if i+1 < len(history) {
+			next = history[i+1].Timestamp
+		}
+		covered := next.Sub(entry.Timestamp)
+		if i > 0 {
+			if maxCoverage := 2 * entry.Timestamp.Sub(history[i-1].Timestamp); maxCoverage > 0 && covered > maxCoverage {
+				covered = maxCoverage
+			}
+		}
+		if covered <= 0 || (isActive != nil && !isActive(entry.Timestamp)) {
+			continue
+		}
+
+		switch entry.Status {
+		case monitor.StatusUnknown:
+			if checked && countUnknownAsDown {
+				totalTime += covered
+			}
+		case monitor.StatusError:
+			// The check never reached the site, so it says nothing about uptime
+		case monitor.StatusUp:
+			checked = true
+			upTime += covered
+			totalTime += covered
+		case monitor.StatusDegraded:
+			checked = true
+			if !countDegradedAsDown {
+				upTime += covered
+			}
+			totalTime += covered
+		default:
+			checked = true
+			totalTime += covered
+		}
 	}
 
+	if totalTime == 0 {
+		return 100.0 // Assume 100% if no data
+	}
+
+	return float64(upTime) / float64(totalTime) * 100.0
+}
+
+// GetAverageResponseTime calculates average response time for a website over a given period
+func (s *JSONStorage) GetAverageResponseTime(websiteID string, hours int) (float64, error) {
+	history, err := s.GetRecentHistory(websiteID, hours)
+	if err != nil {
+		return 0, err
+	}
+
+	return averageResponseTime(history), nil
+}
+
+// averageResponseTime computes the mean response time of successful checks,
+// including degraded ones
+func averageResponseTime(history []HistoryEntry) float64 {
 	totalTime := 0
 	validEntries := 0
 
 	for _, entry := range history {
-		if entry.Status == "up" && entry.ResponseTime > 0 {
+		if (entry.Status == monitor.StatusUp || entry.Status == monitor.StatusDegraded) && entry.ResponseTime > 0 {
 			totalTime += entry.ResponseTime
 			validEntries++
 		}
 	}
 
 	if validEntries == 0 {
-		return 0, nil
+		return 0
 	}
 
-	return float64(totalTime) / float64(validEntries), nil
+	return float64(totalTime) / float64(validEntries)
 }
 
-// CleanupOldHistory removes history files for websites that no longer exist
-func (s *Storage) CleanupOldHistory(existingWebsiteIDs map[string]bool) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// GetResponseTimeStats returns the minimum, maximum and percentile response
+// times of a website's successful checks over a given period
+func (s *JSONStorage) GetResponseTimeStats(websiteID string, hours int) (ResponseTimeStats, error) {
+	history, err := s.GetRecentHistory(websiteID, hours)
+	if err != nil {
+		return ResponseTimeStats{}, err
+	}
+
+	return responseTimeStats(history), nil
+}
+
+// responseTimeStats computes the response time stats of the successful checks in history
+func responseTimeStats(history []HistoryEntry) ResponseTimeStats {
+	var times []int
+	for _, entry := range history {
+		if (entry.Status == monitor.StatusUp || entry.Status == monitor.StatusDegraded) && entry.ResponseTime > 0 {
+			times = append(times, entry.ResponseTime)
+		}
+	}
+	sort.Ints(times)
+	return responseTimeStatsSorted(times)
+}
 
+// responseTimeStatsSorted computes response time stats from ascending response times
+func responseTimeStatsSorted(times []int) ResponseTimeStats {
+	if len(times) == 0 {
+		return ResponseTimeStats{}
+	}
+	return ResponseTimeStats{
+		Samples: len(times),
+		Min:     times[0],
+		Max:     times[len(times)-1],
+		P50:     percentile(times, 50),
+		P95:     percentile(times, 95),
+		P99:     percentile(times, 99),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of ascending, non-empty
+// values: the smallest value at least p percent of the values are less than
+// or equal to. With few values the high percentiles are the maximum.
+func percentile(sorted []int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// CleanupOldHistory removes history files for websites that no longer exist
+func (s *JSONStorage) CleanupOldHistory(existingWebsiteIDs map[string]bool) error {
 	files, err := ioutil.ReadDir(s.dataDir)
 	if err != nil {
 		return fmt.Errorf("failed to read data directory: %v", err)
 	}
 
 	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
+		// History files may be compressed
+		name := strings.TrimSuffix(file.Name(), compressedHistoryExt)
+		if !file.IsDir() && filepath.Ext(name) == ".jsonl" {
 			// Check if it's a history file
-			if len(file.Name()) > 8 && file.Name()[:8] == "history_" {
+			if len(name) > 8 && name[:8] == "history_" {
 				// Extract website ID from filename
-				websiteID := file.Name()[8 : len(file.Name())-5] // Remove "history_" prefix and ".json" suffix
-				
+				websiteID := name[8 : len(name)-6] // Remove "history_" prefix and ".jsonl" suffix
+
 				// If website doesn't exist anymore, delete the history file
 				if !existingWebsiteIDs[websiteID] {
-					historyFile := filepath.Join(s.dataDir, file.Name())
-					if err := os.Remove(historyFile); err != nil {
-						fmt.Printf("Warning: failed to delete old history file %s: %v\n", historyFile, err)
+					if err := s.DeleteWebsiteHistory(websiteID); err != nil {
+						logger.Warnf("Failed to delete old history of %s: %v", websiteID, err)
 					}
 				}
 			}
@@ -276,4 +708,3 @@ func (s *Storage) CleanupOldHistory(existingWebsiteIDs map[string]bool) error {
 
 	return nil
 }
-
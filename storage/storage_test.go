@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statuses returns one history entry per status, a minute apart
+func statuses(start time.Time, statuses ...string) []HistoryEntry {
+	history := make([]HistoryEntry, len(statuses))
+	for i, status := range statuses {
+		history[i] = HistoryEntry{Timestamp: start.Add(time.Duration(i) * time.Minute), Status: status}
+	}
+	return history
+}
+
+func TestCalculateUptime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		history        []HistoryEntry
+		unknownAsDown  bool
+		degradedAsDown bool
+		want           float64
+	}{
+		{name: "no history", want: 100},
+		{name: "all up", history: statuses(start, "up", "up", "up"), want: 100},
+		{name: "half down", history: statuses(start, "up", "down", "up", "down"), want: 50},
+		{name: "leading unknown ignored", history: statuses(start, "unknown", "unknown", "up", "down"), want: 50},
+		{name: "leading unknown ignored even as down", history: statuses(start, "unknown", "unknown", "up", "down"), unknownAsDown: true, want: 50},
+		{name: "all unknown", history: statuses(start, "unknown", "unknown", "unknown"), want: 100},
+		{name: "all unknown as down", history: statuses(start, "unknown", "unknown"), unknownAsDown: true, want: 100},
+		{name: "later unknown excluded", history: statuses(start, "up", "unknown", "up", "down"), want: 100.0 * 2 / 3},
+		{name: "later unknown as down", history: statuses(start, "up", "unknown", "up", "down"), unknownAsDown: true, want: 50},
+		{name: "errors excluded", history: statuses(start, "error", "up", "error", "down"), want: 50},
+		{name: "only errors", history: statuses(start, "error", "error"), want: 100},
+		{name: "degraded as up", history: statuses(start, "up", "degraded", "degraded", "down"), want: 75},
+		{name: "degraded as down", history: statuses(start, "up", "degraded", "degraded", "down"), degradedAsDown: true, want: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateUptime(tt.history, tt.unknownAsDown, tt.degradedAsDown)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("uptime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateUptimeWeighted(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int, status string) HistoryEntry {
+		return HistoryEntry{Timestamp: start.Add(time.Duration(minutes) * time.Minute), Status: status}
+	}
+	tests := []struct {
+		name           string
+		history        []HistoryEntry
+		end            time.Time
+		unknownAsDown  bool
+		degradedAsDown bool
+		want           float64
+	}{
+		{name: "no history", end: start, want: 100},
+		{
+			// Up for 3 minutes, down for 1
+			name:    "weighted by time covered",
+			history: []HistoryEntry{at(0, "up"), at(1, "up"), at(3, "down")},
+			end:     start.Add(4 * time.Minute),
+			want:    75,
+		},
+		{
+			name:          "leading unknown ignored",
+			history:       []HistoryEntry{at(0, "unknown"), at(10, "up"), at(11, "down")},
+			end:           start.Add(12 * time.Minute),
+			unknownAsDown: true,
+			want:          50,
+		},
+		{
+			name:          "all unknown",
+			history:       []HistoryEntry{at(0, "unknown"), at(1, "unknown")},
+			end:           start.Add(2 * time.Minute),
+			unknownAsDown: true,
+			want:          100,
+		},
+		{
+			name:    "errors excluded",
+			history: []HistoryEntry{at(0, "up"), at(1, "error"), at(2, "down")},
+			end:     start.Add(3 * time.Minute),
+			want:    50,
+		},
+		{
+			name:    "degraded as up",
+			history: []HistoryEntry{at(0, "degraded"), at(3, "down")},
+			end:     start.Add(4 * time.Minute),
+			want:    75,
+		},
+		{
+			name:           "degraded as down",
+			history:        []HistoryEntry{at(0, "degraded"), at(3, "up")},
+			end:            start.Add(4 * time.Minute),
+			degradedAsDown: true,
+			want:           25,
+		},
+		{
+			// The last entry covers at most twice the spacing before it
+			name:    "gap isn't stretched",
+			history: []HistoryEntry{at(0, "up"), at(1, "down")},
+			end:     start.Add(60 * time.Minute),
+			want:    100.0 / 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateUptimeWeighted(tt.history, tt.end, nil, tt.unknownAsDown, tt.degradedAsDown)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("uptime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBackendsAgree writes the same history through the JSON and SQLite
+// backends and expects the same uptime, buckets and stats back
+func TestBackendsAgree(t *testing.T) {
+	start := time.Now().Add(-5 * time.Hour)
+	var history []HistoryEntry
+	for i, status := range []string{"unknown", "up", "up", "degraded", "down", "error", "up", "unknown", "down", "up", "degraded", "up"} {
+		history = append(history, HistoryEntry{
+			Timestamp:    start.Add(time.Duration(i) * 25 * time.Minute),
+			Status:       status,
+			ResponseTime: 100 + 37*i,
+		})
+	}
+
+	tests := []struct {
+		name           string
+		unknownAsDown  bool
+		degradedAsDown bool
+	}{
+		{name: "default"},
+		{name: "unknown and degraded as down", unknownAsDown: true, degradedAsDown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make(map[string]struct {
+				uptime  float64
+				buckets []HistoryBucket
+				stats   UptimeStats
+			})
+			for _, backend := range []string{BackendJSON, BackendSQLite} {
+				s, err := NewStorage(backend, t.TempDir())
+				if err != nil {
+					t.Fatalf("creating %s storage: %v", backend, err)
+				}
+				defer s.Close()
+				s.SetCountUnknownAsDown(tt.unknownAsDown)
+				s.SetCountDegradedAsDown(tt.degradedAsDown)
+				for _, entry := range history {
+					if err := s.SaveHistory("site", entry); err != nil {
+						t.Fatalf("saving %s history: %v", backend, err)
+					}
+				}
+
+				result := results[backend]
+				if result.uptime, err = s.CalculateUptime("site", 24); err != nil {
+					t.Fatalf("%s uptime: %v", backend, err)
+				}
+				if result.buckets, err = s.GetAggregatedHistory("site", 24, 60); err != nil {
+					t.Fatalf("%s aggregated history: %v", backend, err)
+				}
+				if result.stats, err = s.GetUptimeStats("site"); err != nil {
+					t.Fatalf("%s stats: %v", backend, err)
+				}
+				results[backend] = result
+			}
+
+			jsonResult, sqliteResult := results[BackendJSON], results[BackendSQLite]
+			if math.Abs(jsonResult.uptime-sqliteResult.uptime) > 1e-9 {
+				t.Errorf("uptime: json %v, sqlite %v", jsonResult.uptime, sqliteResult.uptime)
+			}
+			if len(jsonResult.buckets) == 0 || len(jsonResult.buckets) != len(sqliteResult.buckets) {
+				t.Fatalf("buckets: json %+v, sqlite %+v", jsonResult.buckets, sqliteResult.buckets)
+			}
+			for i, a := range jsonResult.buckets {
+				b := sqliteResult.buckets[i]
+				if !a.Start.Equal(b.Start) || a.Checks != b.Checks || math.Abs(a.Uptime-b.Uptime) > 1e-9 ||
+					math.Abs(a.AvgResponseTime-b.AvgResponseTime) > 1e-9 ||
+					a.MinResponseTime != b.MinResponseTime || a.MaxResponseTime != b.MaxResponseTime {
+					t.Errorf("bucket %d: json %+v, sqlite %+v", i, a, b)
+				}
+			}
+			a, b := jsonResult.stats, sqliteResult.stats
+			if math.Abs(a.Uptime24h-b.Uptime24h) > 1e-9 || math.Abs(a.Uptime30d-b.Uptime30d) > 1e-9 ||
+				math.Abs(a.AvgResponseTime24h-b.AvgResponseTime24h) > 1e-9 || a.ResponseTime24h != b.ResponseTime24h {
+				t.Errorf("stats: json %+v, sqlite %+v", a, b)
+			}
+		})
+	}
+}
+
+// BenchmarkSaveHistoryParallel appends history from parallel goroutines, each
+// for a website of its own (distinct) or all for one website (shared). With
+// per-website locks, distinct websites' appends don't wait for each other.
+func BenchmarkSaveHistoryParallel(b *testing.B) {
+	for _, shared := range []bool{false, true} {
+		name := "distinct"
+		if shared {
+			name = "shared"
+		}
+		b.Run(name, func(b *testing.B) {
+			s, err := NewJSONStorage(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			entry := HistoryEntry{Timestamp: time.Now(), Status: "up", ResponseTime: 120, StatusCode: 200}
+			var goroutines int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				websiteID := "site"
+				if !shared {
+					websiteID = fmt.Sprintf("site%d", atomic.AddInt64(&goroutines, 1))
+				}
+				for pb.Next() {
+					if err := s.SaveHistory(websiteID, entry); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}